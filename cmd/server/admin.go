@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// adminCmd is an administration command, talking to the running server over
+// the internal socket.
+type adminCmd struct {
+	fs     *flag.FlagSet
+	socket string
+	json   bool
+}
+
+func newAdminCmd(name string) *adminCmd {
+	cmd := &adminCmd{
+		fs: flag.NewFlagSet(name, flag.ExitOnError),
+	}
+	cmd.fs.StringVar(&cmd.socket, "socket", "/run/licensing-server.sock", "Internal licensing server socket.")
+	cmd.fs.BoolVar(&cmd.json, "json", false, "Print JSON instead of a table.")
+	return cmd
+}
+
+// parse parses flags, which may be interspersed with positional arguments.
+// Returns positional arguments.
+func (cmd *adminCmd) parse(args []string) []string {
+	var pos []string
+	for {
+		cmd.fs.Parse(args)
+		args = cmd.fs.Args()
+		if len(args) == 0 {
+			return pos
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+// isSet reports whether flag was given.
+func (cmd *adminCmd) isSet(name string) bool {
+	set := false
+	cmd.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// call makes request to the internal server, decoding response into out if it
+// isn't nil. Returns raw response body.
+func (cmd *adminCmd) call(method, path string, in, out interface{}) ([]byte, error) {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	r, err := doInternalReq(ctx, cmd.socket, method, "http://unix"+path, in)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return nil, statusError(r, body)
+	}
+	if out != nil {
+		err = json.Unmarshal(body, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// callList gets every page of a list from the internal server, following
+// X-Next-Cursor, and decodes items into out. Returns items as raw JSON array.
+func (cmd *adminCmd) callList(path string, out interface{}) ([]byte, error) {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	items := make([]json.RawMessage, 0)
+	q := url.Values{"limit": {"1000"}}
+	for {
+		r, err := doInternalReq(ctx, cmd.socket, http.MethodGet, "http://unix"+path+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if r.StatusCode < 200 || r.StatusCode >= 300 {
+			return nil, statusError(r, body)
+		}
+		var page []json.RawMessage
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+
+		cursor := r.Header.Get("X-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+		q.Set("cursor", cursor)
+	}
+
+	body, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	return body, json.Unmarshal(body, out)
+}
+
+// statusError describes unsuccessful response of the internal server.
+func statusError(r *http.Response, body []byte) error {
+	msg, _ := parseMessage(bytes.NewReader(body))
+	switch r.StatusCode {
+	case http.StatusBadRequest:
+		if msg == "" {
+			return errors.New("invalid input")
+		}
+		return fmt.Errorf("invalid input: %s", msg)
+	case http.StatusNotFound:
+		return errors.New("not found")
+	case http.StatusForbidden, http.StatusConflict:
+		if msg == "" {
+			return errors.New(strings.ToLower(http.StatusText(r.StatusCode)))
+		}
+		return errors.New(msg)
+	default:
+		return fmt.Errorf("unexpected status code: %s", r.Status)
+	}
+}
+
+// lookupLicenseIssuer gets license issuer by username.
+func (cmd *adminCmd) lookupLicenseIssuer(username string) (*model.LicenseIssuer, error) {
+	if username == "" || strings.ContainsRune(username, '/') {
+		return nil, fmt.Errorf("invalid username: %s", username)
+	}
+	li := &model.LicenseIssuer{}
+	_, err := cmd.call(http.MethodGet, "/license-issuers/"+url.PathEscape(username), nil, li)
+	if err != nil {
+		return nil, fmt.Errorf("license issuer %s: %w", username, err)
+	}
+	return li, nil
+}
+
+// output prints raw JSON response if -json is given and a table otherwise.
+func (cmd *adminCmd) output(body []byte, header []string, rows [][]string) error {
+	if cmd.json {
+		var buf bytes.Buffer
+		err := json.Indent(&buf, body, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// pathKey encodes license or session ID for use in the path. Both standard
+// (as printed in JSON) and URL base64 encodings are accepted.
+func pathKey(id string) (string, error) {
+	bs, err := base64.URLEncoding.DecodeString(id)
+	if err != nil {
+		bs, err = base64.StdEncoding.DecodeString(id)
+	}
+	if err != nil || len(bs) != 32 {
+		return "", fmt.Errorf("invalid id: %s", id)
+	}
+	return base64.URLEncoding.EncodeToString(bs), nil
+}
+
+func fmtKey(bs []byte) string {
+	if bs == nil {
+		return "-"
+	}
+	return base64.URLEncoding.EncodeToString(bs)
+}
+
+func fmtTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func fmtIntPtr(i *int) string {
+	if i == nil {
+		return "-"
+	}
+	return strconv.Itoa(*i)
+}
+
+// parseTimeFlag parses RFC 3339 time or date, empty value means none.
+func parseTimeFlag(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid time: %s", v)
+	}
+	return &t, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/sewiti/licensing-system/internal/server"
+)
+
+// version is set at build time:
+//
+//	go build -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+func buildInfo() server.BuildInfo {
+	bi := server.BuildInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return bi
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			bi.Revision = s.Value
+		}
+	}
+	return bi
+}
@@ -1,53 +1,284 @@
 package main
 
-import "time"
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/apex/log"
+	"github.com/vrischmann/envconfig"
+	"gopkg.in/yaml.v3"
+)
 
 // config defines licensing server config.
+//
+// Config is read from environment, on top of optional YAML config file with the
+// same structure (see yaml tags).
 type config struct {
-	DbDSN string
+	DbDSN string `yaml:"dbDSN"`
 
 	HTTP struct {
-		Listen          string        `envconfig:"optional"`
-		ReadTimeout     time.Duration `envconfig:"default=30s"`
-		WriteTimeout    time.Duration `envconfig:"default=30s"`
-		ShutdownTimeout time.Duration `envconfig:"default=30s"`
-		Gzip            bool          `envconfig:"default=false"`
+		Listen          string        `envconfig:"optional" yaml:"listen"`
+		ReadTimeout     time.Duration `envconfig:"default=30s" yaml:"readTimeout"`
+		WriteTimeout    time.Duration `envconfig:"default=30s" yaml:"writeTimeout"`
+		ShutdownTimeout time.Duration `envconfig:"default=30s" yaml:"shutdownTimeout"`
+		Gzip            bool          `envconfig:"default=false" yaml:"gzip"`
 
 		CORS struct {
-			ResourceApiEnabled  bool     `envconfig:"default=false"`
-			LicensingApiEnabled bool     `envconfig:"default=false"`
-			AllowedOrigins      []string `envconfig:"optional"`
-		}
+			ResourceApiEnabled  bool     `envconfig:"default=false" yaml:"resourceApiEnabled"`
+			LicensingApiEnabled bool     `envconfig:"default=false" yaml:"licensingApiEnabled"`
+			AllowedOrigins      []string `envconfig:"optional" yaml:"allowedOrigins"`
+		} `yaml:"cors"`
 
-		TLS struct {
-			CertFile string `envconfig:"optional"`
-			KeyFile  string `envconfig:"optional"`
-		}
-	}
+		TLS tlsConf `yaml:"tls"`
+	} `yaml:"http"`
 
 	Licensing struct {
-		ServerKey       []byte
-		MaxTimeDrift    time.Duration `envconfig:"default=6h"`
-		CleanupInterval time.Duration `envconfig:"default=20m"`
+		// Separate listener for licensing API. Without one, licensing API is
+		// served on HTTP listener.
+		HTTP listenerConf `yaml:"http"`
+
+		// Server key sources, at most one can be set. Without one, systemd
+		// credential is used (see loadServerKey).
+		ServerKey               base64Bytes `yaml:"serverKey"`
+		ServerKeyFile           string      `envconfig:"optional" yaml:"serverKeyFile"` // "-" is stdin.
+		ServerKeyCommand        string      `envconfig:"optional" yaml:"serverKeyCommand"`
+		ServerKeyPassphraseFile string      `envconfig:"optional" yaml:"serverKeyPassphraseFile"`
+
+		MaxTimeDrift    time.Duration `envconfig:"default=6h" yaml:"maxTimeDrift"`
+		CleanupInterval time.Duration `envconfig:"default=20m" yaml:"cleanupInterval"`
+
+		IdempotencyRetention time.Duration `envconfig:"default=24h" yaml:"idempotencyRetention"`
+		TrashRetention       time.Duration `envconfig:"default=720h" yaml:"trashRetention"`
 
 		Refresh struct {
-			Min    time.Duration `envconfig:"default=5m"`
-			Max    time.Duration `envconfig:"default=2h"`
-			Jitter float64       `envconfig:"default=0.1"`
-		}
+			Min    time.Duration `envconfig:"default=5m" yaml:"min"`
+			Max    time.Duration `envconfig:"default=2h" yaml:"max"`
+			Jitter float64       `envconfig:"default=0.1" yaml:"jitter"`
+		} `yaml:"refresh"`
 
 		Limiter struct {
-			SessionEvery     time.Duration `envconfig:"default=10m"`
-			SessionEveryInit time.Duration `envconfig:"default=1m"` // not used due to a bug
-			BurstTotal       time.Duration `envconfig:"default=8h"`
+			SessionEvery     time.Duration `envconfig:"default=10m" yaml:"sessionEvery"`
+			SessionEveryInit time.Duration `envconfig:"default=1m" yaml:"sessionEveryInit"` // not used due to a bug
+			BurstTotal       time.Duration `envconfig:"default=8h" yaml:"burstTotal"`
+			Shared           bool          `envconfig:"default=false" yaml:"shared"`
+
+			CacheExpiration      time.Duration `envconfig:"default=24h" yaml:"cacheExpiration"`
+			CacheCleanupInterval time.Duration `envconfig:"default=1h" yaml:"cacheCleanupInterval"`
+		} `yaml:"limiter"`
+
+		Releases struct {
+			Dir            string        `envconfig:"optional" yaml:"dir"`
+			DownloadExpiry time.Duration `envconfig:"default=1h" yaml:"downloadExpiry"`
+		} `yaml:"releases"`
+	} `yaml:"licensing"`
+
+	Notify struct {
+		Interval     time.Duration `envconfig:"default=5m" yaml:"interval"`
+		ReminderDays []int         `envconfig:"default=30;7;1" yaml:"reminderDays"`
+		MaxAttempts  int           `envconfig:"default=8" yaml:"maxAttempts"`
+		RetryDelay   time.Duration `envconfig:"default=1m" yaml:"retryDelay"`
+		Retention    time.Duration `envconfig:"default=2160h" yaml:"retention"`
+		PublicURL    string        `envconfig:"optional" yaml:"publicURL"`
+
+		SMTP struct {
+			Addr     string `envconfig:"optional" yaml:"addr"`
+			Username string `envconfig:"optional" yaml:"username"`
+			Password string `envconfig:"optional" yaml:"password"`
+			From     string `envconfig:"optional" yaml:"from"`
+		} `yaml:"smtp"`
+	} `yaml:"notify"`
+
+	Metrics struct {
+		Enabled bool `envconfig:"default=false" yaml:"enabled"`
+
+		// Separate listener for health, readiness, version and metrics
+		// endpoints.
+		HTTP listenerConf `yaml:"http"`
+	} `yaml:"metrics"`
+
+	InternalSocket   string  `envconfig:"default=/run/licensing-server.sock" yaml:"internalSocket"`
+	MinPasswdEntropy float64 `envconfig:"default=30" yaml:"minPasswdEntropy"`
+	LogLevel         string  `envconfig:"default=info" yaml:"logLevel"`
 
-			CacheExpiration      time.Duration `envconfig:"default=24h"`
-			CacheCleanupInterval time.Duration `envconfig:"default=1h"`
+	DisableGUI bool `envconfig:"default=false" yaml:"disableGUI"`
+}
+
+// listenerConf defines a separate HTTP listener.
+type listenerConf struct {
+	Listen       string        `envconfig:"optional" yaml:"listen"`
+	ReadTimeout  time.Duration `envconfig:"default=30s" yaml:"readTimeout"`
+	WriteTimeout time.Duration `envconfig:"default=30s" yaml:"writeTimeout"`
+	Gzip         bool          `envconfig:"default=false" yaml:"gzip"`
+
+	CORS struct {
+		Enabled        bool     `envconfig:"default=false" yaml:"enabled"`
+		AllowedOrigins []string `envconfig:"optional" yaml:"allowedOrigins"`
+	} `yaml:"cors"`
+
+	TLS tlsConf `yaml:"tls"`
+}
+
+// tlsConf defines TLS of a listener.
+type tlsConf struct {
+	CertFile string `envconfig:"optional" yaml:"certFile"`
+	KeyFile  string `envconfig:"optional" yaml:"keyFile"`
+
+	// Client certificates are verified against CA bundle and authenticate
+	// license issuers in resource API.
+	ClientCAFile string `envconfig:"optional" yaml:"clientCAFile"`
+	ClientAuth   string `envconfig:"default=none" yaml:"clientAuth"` // none, optional or require
+}
+
+// loadConfig reads config from environment and optional YAML config file.
+// Environment variables take precedence over the file.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	err := envconfig.InitWithOptions(cfg, envconfig.Options{AllOptional: true})
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		env := *cfg
+		err = decodeConfigFile(path, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("config file: %w", err)
 		}
+		overlayEnv(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(&env).Elem(), "")
 	}
+	return cfg, cfg.validate()
+}
 
-	InternalSocket   string  `envconfig:"default=/run/licensing-server.sock"`
-	MinPasswdEntropy float64 `envconfig:"default=30"`
+func decodeConfigFile(path string, cfg *config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	DisableGUI bool `envconfig:"default=false"`
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) { // Empty file
+		return err
+	}
+	return nil
+}
+
+// validate checks settings, which are not validated by their consumers.
+func (cfg *config) validate() error {
+	if cfg.DbDSN == "" {
+		return errors.New("DB_DSN is required")
+	}
+	keySources := 0
+	for _, set := range []bool{
+		len(cfg.Licensing.ServerKey) > 0,
+		cfg.Licensing.ServerKeyFile != "",
+		cfg.Licensing.ServerKeyCommand != "",
+	} {
+		if set {
+			keySources++
+		}
+	}
+	if keySources > 1 {
+		return errors.New("only one of LICENSING_SERVER_KEY, LICENSING_SERVER_KEY_FILE and LICENSING_SERVER_KEY_COMMAND can be set")
+	}
+	err := cfg.HTTP.TLS.validate()
+	if err != nil {
+		return fmt.Errorf("http tls: %w", err)
+	}
+	err = cfg.Licensing.HTTP.TLS.validate()
+	if err != nil {
+		return fmt.Errorf("licensing http tls: %w", err)
+	}
+	err = cfg.Metrics.HTTP.TLS.validate()
+	if err != nil {
+		return fmt.Errorf("metrics http tls: %w", err)
+	}
+	_, err = log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return fmt.Errorf("log level: %w", err)
+	}
+	return nil
+}
+
+// overlayEnv copies fields, which are set in the environment, from env to
+// cfg.
+func overlayEnv(cfg, env reflect.Value, name string) {
+	for i := 0; i < cfg.NumField(); i++ {
+		fieldName := cfg.Type().Field(i).Name
+		if name != "" {
+			fieldName = name + "." + fieldName
+		}
+		field := cfg.Field(i)
+		if field.Kind() == reflect.Struct {
+			overlayEnv(field, env.Field(i), fieldName)
+			continue
+		}
+		for _, key := range envKeys(fieldName) {
+			if os.Getenv(key) != "" {
+				field.Set(env.Field(i))
+				break
+			}
+		}
+	}
+}
+
+// envKeys returns environment variables, which envconfig reads the field
+// from, e.g., Licensing.ServerKey is read from LICENSING_SERVER_KEY and
+// LICENSING_SERVERKEY in either case.
+func envKeys(name string) []string {
+	n := []rune(name)
+	var split, joined bytes.Buffer
+	wroteUnderscore := false
+	for i, r := range n {
+		if r == '.' {
+			split.WriteRune('_')
+			joined.WriteRune('_')
+			wroteUnderscore = true
+			continue
+		}
+		prevOrNextLower := i+1 < len(n) && i-1 > 0 && (unicode.IsLower(n[i+1]) || unicode.IsLower(n[i-1]))
+		if i > 0 && unicode.IsUpper(r) && prevOrNextLower && !wroteUnderscore {
+			split.WriteRune('_')
+		}
+		split.WriteRune(r)
+		joined.WriteRune(r)
+		wroteUnderscore = false
+	}
+	return []string{
+		strings.ToUpper(split.String()),
+		strings.ToLower(split.String()),
+		strings.ToUpper(joined.String()),
+		strings.ToLower(joined.String()),
+	}
+}
+
+// base64Bytes are bytes encoded as standard base64 both in environment and
+// config file.
+type base64Bytes []byte
+
+func (b *base64Bytes) Unmarshal(s string) error {
+	bs, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = bs
+	return nil
+}
+
+func (b *base64Bytes) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	err := value.Decode(&s)
+	if err != nil {
+		return err
+	}
+	return b.Unmarshal(s)
 }
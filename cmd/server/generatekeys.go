@@ -3,20 +3,24 @@ package main
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 
 	cryptorand "crypto/rand"
 
+	"github.com/sewiti/licensing-system/internal/keystore"
 	"golang.org/x/crypto/nacl/box"
 )
 
 func generateKeys(args []string) error {
 	var fHex bool
 	var fBase64 bool
+	var fEncrypt bool
 	fs := flag.NewFlagSet("generate-keys", flag.ExitOnError)
 	fs.BoolVar(&fHex, "hex", false, "Print keys hex-encoded.")
 	fs.BoolVar(&fBase64, "base64", false, "Print keys base64 encoded.")
+	fs.BoolVar(&fEncrypt, "encrypt", false, "Encrypt private key with a passphrase.")
 	fs.Parse(args)
 
 	pub, priv, err := box.GenerateKey(cryptorand.Reader)
@@ -31,6 +35,15 @@ func generateKeys(args []string) error {
 		fmt.Printf("id:base64:%s\n", base64.StdEncoding.EncodeToString(pub[:]))
 	}
 
+	if fEncrypt {
+		line, err := encryptKey(priv[:])
+		if err != nil {
+			return err
+		}
+		fmt.Println(line)
+		return nil
+	}
+
 	if fHex || !fBase64 {
 		fmt.Printf("key:hex:%s\n", hex.EncodeToString(priv[:]))
 	}
@@ -39,3 +52,19 @@ func generateKeys(args []string) error {
 	}
 	return nil
 }
+
+// encryptKey prompts for passphrase and encrypts key with it.
+func encryptKey(key []byte) (string, error) {
+	pass, err := readPassword("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	repeat, err := readPassword("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if pass != repeat {
+		return "", errors.New("passphrases do not match")
+	}
+	return keystore.Encrypt(key, []byte(pass))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/gorilla/handlers"
+)
+
+// listener is an HTTP server of licensing server.
+type listener struct {
+	name string
+	srv  *http.Server
+	tls  tlsConf
+}
+
+func newListener(name string, conf listenerConf, h http.Handler) (*listener, error) {
+	tlsConfig, err := conf.TLS.config()
+	if err != nil {
+		return nil, fmt.Errorf("%s tls: %w", name, err)
+	}
+	if conf.Gzip {
+		h = handlers.CompressHandler(h)
+	}
+	return &listener{
+		name: name,
+		srv: &http.Server{
+			Addr:         conf.Listen,
+			Handler:      h,
+			ReadTimeout:  conf.ReadTimeout,
+			WriteTimeout: conf.WriteTimeout,
+			TLSConfig:    tlsConfig,
+		},
+		tls: conf.TLS,
+	}, nil
+}
+
+// serve listens and serves until server is shut down. Calls cancel on return,
+// so failure of one listener stops licensing server.
+func (l *listener) serve(cancel context.CancelFunc) {
+	defer cancel()
+	var err error
+	if !l.tls.enabled() {
+		err = l.srv.ListenAndServe()
+	} else {
+		err = l.srv.ListenAndServeTLS(l.tls.CertFile, l.tls.KeyFile)
+	}
+	if err != nil {
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+		log.WithError(err).Errorf("listening and serving %s server", l.name)
+	}
+}
+
+// shutdownListeners gracefully shuts down all listeners concurrently.
+//
+// Blocks until all listeners are shut down or context is done.
+func shutdownListeners(ctx context.Context, listeners []*listener) {
+	wg := sync.WaitGroup{}
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			err := l.srv.Shutdown(ctx)
+			if err != nil {
+				log.WithError(err).Errorf("shutting down %s server", l.name)
+			}
+		}(l)
+	}
+	wg.Wait()
+}
@@ -16,7 +16,7 @@ func main() {
 
 	switch os.Args[1] {
 	case "run":
-		err := runServer() // manages errors on it's own
+		err := runServer(os.Args[2:]) // manages errors on it's own
 		if err != nil {
 			log.WithError(err).Fatal("run server")
 		}
@@ -27,12 +27,63 @@ func main() {
 			log.WithError(err).Fatal("generate keys")
 		}
 
+	case "issuers":
+		err := listLicenseIssuers(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("list license issuers")
+		}
+
 	case "issuer":
 		err := manageLicenseIssuer(os.Args[2:])
 		if err != nil {
 			log.WithError(err).Fatal("manage license issuer")
 		}
 
+	case "licenses":
+		err := listLicenses(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("list licenses")
+		}
+
+	case "license":
+		err := manageLicense(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("manage license")
+		}
+
+	case "products":
+		err := listProducts(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("list products")
+		}
+
+	case "product":
+		err := manageProduct(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("manage product")
+		}
+
+	case "sessions":
+		err := listLicenseSessions(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("list license sessions")
+		}
+
+	case "session":
+		err := manageLicenseSession(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("manage license session")
+		}
+
+	case "info":
+		err := printServerInfo(os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("server info")
+		}
+
+	case "version":
+		printVersion(os.Stdout)
+
 	case "-h", "-help", "--help":
 		printUsage(os.Stdout)
 
@@ -47,7 +98,31 @@ func printUsage(w io.Writer) {
 	fmt.Fprintf(w, "  %s <command> [arguments]\n", os.Args[0])
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, "Commands:")
-	fmt.Fprintln(w, "  run                                                  run licensing server")
-	fmt.Fprintln(w, "  issuer <username> enable|disable|chpasswd [-socket]  manage license issuers")
-	fmt.Fprintln(w, "  generate-keys [-base64|-hex]                         generate random keys")
+	fmt.Fprintln(w, "  run [-config <file>]                                              run licensing server")
+	fmt.Fprintln(w, "  info                                                              print server ID and stats")
+	fmt.Fprintln(w, "  issuers                                                           list license issuers")
+	fmt.Fprintln(w, "  issuer <username> show|create|delete|enable|disable|chpasswd      manage license issuers")
+	fmt.Fprintln(w, "  issuer <username> max-licenses <n>                                set max licenses, negative means unlimited")
+	fmt.Fprintln(w, "  licenses <username>                                               list issuer's licenses")
+	fmt.Fprintln(w, "  license <username> create                                         create license")
+	fmt.Fprintln(w, "  license <username> <id> show|update|activate|deactivate           manage licenses")
+	fmt.Fprintln(w, "  products <username>                                               list issuer's products")
+	fmt.Fprintln(w, "  product <username> create                                         create product")
+	fmt.Fprintln(w, "  product <username> <id> show|update|activate|deactivate           manage products")
+	fmt.Fprintln(w, "  sessions <username> <license-id>                                  list license's live sessions")
+	fmt.Fprintln(w, "  session <username> <license-id> <id> revoke                       revoke license session")
+	fmt.Fprintln(w, "  generate-keys [-base64|-hex] [-encrypt]                           generate random keys")
+	fmt.Fprintln(w, "  version                                                           print version")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Administration commands talk to the running server over its internal socket")
+	fmt.Fprintln(w, "(-socket), print tables or JSON (-json). Run a command with -h for its flags.")
+}
+
+func printVersion(w io.Writer) {
+	bi := buildInfo()
+	fmt.Fprintf(w, "licensing-server %s", bi.Version)
+	if bi.Revision != "" {
+		fmt.Fprintf(w, " (%s)", bi.Revision)
+	}
+	fmt.Fprintf(w, " %s\n", bi.GoVersion)
 }
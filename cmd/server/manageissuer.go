@@ -5,27 +5,34 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/sewiti/licensing-system/internal/model"
 	"golang.org/x/term"
 )
 
 func manageLicenseIssuer(args []string) error {
-	var socket string
-	fs := flag.NewFlagSet("issuer", flag.ExitOnError)
-	fs.StringVar(&socket, "socket", "/run/licensing-server.sock", "Internal licensing server socket.")
-	fs.Parse(args)
+	var (
+		email       string
+		phoneNumber string
+		maxLicenses int
+	)
+	cmd := newAdminCmd("issuer")
+	cmd.fs.StringVar(&email, "email", "", "Email of the created issuer.")
+	cmd.fs.StringVar(&phoneNumber, "phone", "", "Phone number of the created issuer.")
+	cmd.fs.IntVar(&maxLicenses, "max-licenses", -1, "Max licenses of the created issuer, negative means unlimited.")
+	args = cmd.parse(args)
 
-	args = fs.Args()
-	if len(args) != 2 {
+	if len(args) < 2 {
 		return errors.New("invalid number of arguments")
 	}
 	username := args[0]
@@ -33,13 +40,84 @@ func manageLicenseIssuer(args []string) error {
 	if username == "" || strings.ContainsRune(username, '/') {
 		return fmt.Errorf("invalid username: %s", username)
 	}
+	if action == "max-licenses" && len(args) != 3 || action != "max-licenses" && len(args) != 2 {
+		return errors.New("invalid number of arguments")
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
 	switch action {
+	case "show":
+		li, err := cmd.lookupLicenseIssuer(username)
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(li)
+		if err != nil {
+			return err
+		}
+		return cmd.outputLicenseIssuers(body, li)
+
+	case "create":
+		passwd, err := readPassword("Password: ")
+		if err != nil {
+			return err
+		}
+		req := struct {
+			Username    string      `json:"username"`
+			Password    string      `json:"password"`
+			Email       string      `json:"email"`
+			PhoneNumber string      `json:"phoneNumber"`
+			MaxLicenses model.Limit `json:"maxLicenses"`
+		}{
+			Username:    username,
+			Password:    passwd,
+			Email:       email,
+			PhoneNumber: phoneNumber,
+			MaxLicenses: model.Limit(maxLicenses),
+		}
+		li := &model.LicenseIssuer{}
+		body, err := cmd.call(http.MethodPost, "/license-issuers", req, li)
+		if err != nil {
+			return err
+		}
+		return cmd.outputLicenseIssuers(body, li)
+
+	case "delete":
+		li, err := cmd.lookupLicenseIssuer(username)
+		if err != nil {
+			return err
+		}
+		_, err = cmd.call(http.MethodDelete, fmt.Sprintf("/license-issuers/%d", li.ID), nil, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s has been deleted\n", username)
+		return nil
+
+	case "max-licenses":
+		n, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid max licenses: %s", args[2])
+		}
+		li, err := cmd.lookupLicenseIssuer(username)
+		if err != nil {
+			return err
+		}
+		req := struct {
+			MaxLicenses model.Limit `json:"maxLicenses"`
+		}{
+			MaxLicenses: model.Limit(n),
+		}
+		body, err := cmd.call(http.MethodPatch, fmt.Sprintf("/license-issuers/%d", li.ID), req, li)
+		if err != nil {
+			return err
+		}
+		return cmd.outputLicenseIssuers(body, li)
+
 	case "enable", "disable":
-		err := updateLicenseIssuerActive(ctx, socket, username, action == "enable")
+		err := updateLicenseIssuerActive(ctx, cmd.socket, username, action == "enable")
 		if err != nil {
 			return err
 		}
@@ -47,13 +125,11 @@ func manageLicenseIssuer(args []string) error {
 		return nil
 
 	case "chpasswd":
-		fmt.Print("New password: ")
-		passwd, err := term.ReadPassword(syscall.Stdin)
+		passwd, err := readPassword("New password: ")
 		if err != nil {
 			return err
 		}
-		fmt.Println()
-		err = updateLicenseIssuerPassword(ctx, socket, username, string(passwd))
+		err = updateLicenseIssuerPassword(ctx, cmd.socket, username, passwd)
 		if err != nil {
 			return err
 		}
@@ -65,6 +141,43 @@ func manageLicenseIssuer(args []string) error {
 	}
 }
 
+func listLicenseIssuers(args []string) error {
+	cmd := newAdminCmd("issuers")
+	if len(cmd.parse(args)) != 0 {
+		return errors.New("invalid number of arguments")
+	}
+	var lii []*model.LicenseIssuer
+	body, err := cmd.callList("/license-issuers", &lii)
+	if err != nil {
+		return err
+	}
+	return cmd.outputLicenseIssuers(body, lii...)
+}
+
+func (cmd *adminCmd) outputLicenseIssuers(body []byte, lii ...*model.LicenseIssuer) error {
+	rows := make([][]string, len(lii))
+	for i, li := range lii {
+		maxLicenses := "unlimited"
+		if li.MaxLicenses > model.Unlimited {
+			maxLicenses = strconv.Itoa(int(li.MaxLicenses))
+		}
+		rows[i] = []string{strconv.Itoa(li.ID), li.Username, strconv.FormatBool(li.Active), li.Email, li.PhoneNumber, maxLicenses, fmtTime(&li.Created)}
+	}
+	return cmd.output(body, []string{"ID", "USERNAME", "ACTIVE", "EMAIL", "PHONE", "MAX LICENSES", "CREATED"}, rows)
+}
+
+// readPassword prompts for password on stderr, so that it isn't mixed with
+// output.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passwd, err := term.ReadPassword(syscall.Stdin)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(os.Stderr)
+	return string(passwd), nil
+}
+
 func updateLicenseIssuerActive(ctx context.Context, socket, username string, active bool) error {
 	url := fmt.Sprintf("http://unix/license-issuers/%s/active", username)
 	data := struct {
@@ -118,15 +231,21 @@ func updateLicenseIssuerPassword(ctx context.Context, socket, username, password
 }
 
 func doInternalReq(ctx context.Context, socket string, method, url string, data interface{}) (*http.Response, error) {
-	body, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+	var body io.Reader
+	if data != nil {
+		bs, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(bs)
 	}
-	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
 
 	cl := http.Client{
 		Transport: &http.Transport{
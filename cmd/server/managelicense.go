@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+func manageLicense(args []string) error {
+	var (
+		name         string
+		productID    int
+		maxSessions  int
+		validUntil   string
+		endUserEmail string
+		note         string
+		features     string
+		tags         string
+	)
+	cmd := newAdminCmd("license")
+	cmd.fs.StringVar(&name, "name", "", "License name.")
+	cmd.fs.IntVar(&productID, "product", 0, "Product ID, zero means none.")
+	cmd.fs.IntVar(&maxSessions, "max-sessions", 1, "Max concurrent license sessions.")
+	cmd.fs.StringVar(&validUntil, "valid-until", "", "Expiry time (RFC 3339 or date), empty means never.")
+	cmd.fs.StringVar(&endUserEmail, "email", "", "End user's email.")
+	cmd.fs.StringVar(&note, "note", "", "Note.")
+	cmd.fs.StringVar(&features, "features", "", "Comma separated features.")
+	cmd.fs.StringVar(&tags, "tags", "", "Comma separated tags.")
+	args = cmd.parse(args)
+
+	// fields of the request, which were given as flags.
+	fields := func() (map[string]interface{}, error) {
+		req := make(map[string]interface{})
+		if cmd.isSet("name") {
+			req["name"] = name
+		}
+		if cmd.isSet("product") {
+			req["productID"] = nil
+			if productID != 0 {
+				req["productID"] = productID
+			}
+		}
+		if cmd.isSet("max-sessions") {
+			req["maxSessions"] = maxSessions
+		}
+		if cmd.isSet("valid-until") {
+			t, err := parseTimeFlag(validUntil)
+			if err != nil {
+				return nil, err
+			}
+			req["validUntil"] = t
+		}
+		if cmd.isSet("email") {
+			req["endUserEmail"] = endUserEmail
+		}
+		if cmd.isSet("note") {
+			req["note"] = note
+		}
+		if cmd.isSet("features") {
+			req["features"] = splitList(features)
+		}
+		if cmd.isSet("tags") {
+			req["tags"] = splitList(tags)
+		}
+		return req, nil
+	}
+
+	if len(args) < 2 {
+		return errors.New("invalid number of arguments")
+	}
+	li, err := cmd.lookupLicenseIssuer(args[0])
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/license-issuers/%d/licenses", li.ID)
+
+	if len(args) == 2 {
+		if args[1] != "create" {
+			return fmt.Errorf("invalid action: %s", args[1])
+		}
+		req, err := fields()
+		if err != nil {
+			return err
+		}
+		l := &model.License{}
+		body, err := cmd.call(http.MethodPost, path, req, l)
+		if err != nil {
+			return err
+		}
+		return cmd.outputLicenses(body, l)
+	}
+	if len(args) != 3 {
+		return errors.New("invalid number of arguments")
+	}
+
+	licenseID, err := pathKey(args[1])
+	if err != nil {
+		return err
+	}
+	path += "/" + licenseID
+	var req map[string]interface{}
+	switch action := args[2]; action {
+	case "show":
+		l := &model.License{}
+		body, err := cmd.call(http.MethodGet, path, nil, l)
+		if err != nil {
+			return err
+		}
+		return cmd.outputLicenses(body, l)
+	case "update":
+		req, err = fields()
+		if err != nil {
+			return err
+		}
+	case "activate", "deactivate":
+		req = map[string]interface{}{"active": action == "activate"}
+	default:
+		return fmt.Errorf("invalid action: %s", action)
+	}
+	l := &model.License{}
+	body, err := cmd.call(http.MethodPatch, path, req, l)
+	if err != nil {
+		return err
+	}
+	return cmd.outputLicenses(body, l)
+}
+
+func listLicenses(args []string) error {
+	cmd := newAdminCmd("licenses")
+	args = cmd.parse(args)
+	if len(args) != 1 {
+		return errors.New("invalid number of arguments")
+	}
+	li, err := cmd.lookupLicenseIssuer(args[0])
+	if err != nil {
+		return err
+	}
+	var ll []*model.License
+	body, err := cmd.callList(fmt.Sprintf("/license-issuers/%d/licenses", li.ID), &ll)
+	if err != nil {
+		return err
+	}
+	return cmd.outputLicenses(body, ll...)
+}
+
+func (cmd *adminCmd) outputLicenses(body []byte, ll ...*model.License) error {
+	rows := make([][]string, len(ll))
+	for i, l := range ll {
+		rows[i] = []string{fmtKey(l.ID), util.FormatKey(l.Key), l.Name, strconv.FormatBool(l.Active), fmtIntPtr(l.ProductID), strconv.Itoa(l.MaxSessions), fmtTime(l.ValidUntil), fmtTime(l.LastUsed)}
+	}
+	return cmd.output(body, []string{"ID", "KEY", "NAME", "ACTIVE", "PRODUCT", "MAX SESSIONS", "VALID UNTIL", "LAST USED"}, rows)
+}
+
+// splitList splits comma separated list, dropping empty items.
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
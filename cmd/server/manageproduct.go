@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+func manageProduct(args []string) error {
+	var (
+		name         string
+		contactEmail string
+	)
+	cmd := newAdminCmd("product")
+	cmd.fs.StringVar(&name, "name", "", "Product name.")
+	cmd.fs.StringVar(&contactEmail, "contact-email", "", "Contact email.")
+	args = cmd.parse(args)
+
+	// fields of the request, which were given as flags.
+	fields := func() map[string]interface{} {
+		req := make(map[string]interface{})
+		if cmd.isSet("name") {
+			req["name"] = name
+		}
+		if cmd.isSet("contact-email") {
+			req["contactEmail"] = contactEmail
+		}
+		return req
+	}
+
+	if len(args) < 2 {
+		return errors.New("invalid number of arguments")
+	}
+	li, err := cmd.lookupLicenseIssuer(args[0])
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/license-issuers/%d/products", li.ID)
+
+	if len(args) == 2 {
+		if args[1] != "create" {
+			return fmt.Errorf("invalid action: %s", args[1])
+		}
+		p := &model.Product{}
+		body, err := cmd.call(http.MethodPost, path, fields(), p)
+		if err != nil {
+			return err
+		}
+		return cmd.outputProducts(body, p)
+	}
+	if len(args) != 3 {
+		return errors.New("invalid number of arguments")
+	}
+
+	productID, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid id: %s", args[1])
+	}
+	path += "/" + strconv.Itoa(productID)
+	var req map[string]interface{}
+	switch action := args[2]; action {
+	case "show":
+		p := &model.Product{}
+		body, err := cmd.call(http.MethodGet, path, nil, p)
+		if err != nil {
+			return err
+		}
+		return cmd.outputProducts(body, p)
+	case "update":
+		req = fields()
+	case "activate", "deactivate":
+		req = map[string]interface{}{"active": action == "activate"}
+	default:
+		return fmt.Errorf("invalid action: %s", action)
+	}
+	p := &model.Product{}
+	body, err := cmd.call(http.MethodPatch, path, req, p)
+	if err != nil {
+		return err
+	}
+	return cmd.outputProducts(body, p)
+}
+
+func listProducts(args []string) error {
+	cmd := newAdminCmd("products")
+	args = cmd.parse(args)
+	if len(args) != 1 {
+		return errors.New("invalid number of arguments")
+	}
+	li, err := cmd.lookupLicenseIssuer(args[0])
+	if err != nil {
+		return err
+	}
+	var pp []*model.Product
+	body, err := cmd.callList(fmt.Sprintf("/license-issuers/%d/products", li.ID), &pp)
+	if err != nil {
+		return err
+	}
+	return cmd.outputProducts(body, pp...)
+}
+
+func (cmd *adminCmd) outputProducts(body []byte, pp ...*model.Product) error {
+	rows := make([][]string, len(pp))
+	for i, p := range pp {
+		rows[i] = []string{strconv.Itoa(p.ID), p.Name, strconv.FormatBool(p.Active), p.ContactEmail, fmtTime(&p.Created)}
+	}
+	return cmd.output(body, []string{"ID", "NAME", "ACTIVE", "CONTACT EMAIL", "CREATED"}, rows)
+}
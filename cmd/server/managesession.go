@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+func manageLicenseSession(args []string) error {
+	cmd := newAdminCmd("session")
+	args = cmd.parse(args)
+	if len(args) != 4 {
+		return errors.New("invalid number of arguments")
+	}
+	if args[3] != "revoke" {
+		return fmt.Errorf("invalid action: %s", args[3])
+	}
+	path, err := cmd.licenseSessionsPath(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	clientSessionID, err := pathKey(args[2])
+	if err != nil {
+		return err
+	}
+
+	_, err = cmd.call(http.MethodDelete, path+"/"+clientSessionID, nil, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s has been revoked\n", clientSessionID)
+	return nil
+}
+
+func listLicenseSessions(args []string) error {
+	cmd := newAdminCmd("sessions")
+	args = cmd.parse(args)
+	if len(args) != 2 {
+		return errors.New("invalid number of arguments")
+	}
+	path, err := cmd.licenseSessionsPath(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	var lss []*model.LicenseSession
+	body, err := cmd.callList(path, &lss)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(lss))
+	for i, ls := range lss {
+		rows[i] = []string{fmtKey(ls.ClientID), ls.Identifier, fmtKey(ls.MachineID), ls.AppVersion, fmtTime(&ls.Created), fmtTime(&ls.Expire)}
+	}
+	return cmd.output(body, []string{"ID", "IDENTIFIER", "MACHINE", "APP VERSION", "CREATED", "EXPIRE"}, rows)
+}
+
+// licenseSessionsPath returns path of license's sessions.
+func (cmd *adminCmd) licenseSessionsPath(username, licenseID string) (string, error) {
+	licenseID, err := pathKey(licenseID)
+	if err != nil {
+		return "", err
+	}
+	li, err := cmd.lookupLicenseIssuer(username)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/license-issuers/%d/licenses/%s/sessions", li.ID, licenseID), nil
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"sync"
 	"syscall"
 	"time"
@@ -16,28 +18,38 @@ import (
 
 	"github.com/apex/log"
 	"github.com/coreos/go-systemd/daemon"
-	"github.com/gorilla/handlers"
 	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/core/notify"
 	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/metrics"
 	"github.com/sewiti/licensing-system/internal/server"
-	"github.com/vrischmann/envconfig"
 )
 
-func runServer() error {
+func runServer(args []string) error {
+	var configPath string
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.StringVar(&configPath, "config", "", "YAML config file, reloaded on SIGHUP.")
+	fs.Parse(args)
+
 	mathrand.Seed(time.Now().UnixNano())
 
 	// Config
-	var cfg config
-	err := envconfig.Init(&cfg)
+	cfg, err := loadConfig(configPath)
 	if err != nil {
 		return err
 	}
+	log.SetLevel(log.MustParseLevel(cfg.LogLevel))
 
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 	wg := sync.WaitGroup{}
 
+	serverKey, err := loadServerKey(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("server key: %w", err)
+	}
+
 	// Database
 	migrated, err := db.MigrateUp(cfg.DbDSN)
 	if migrated {
@@ -52,15 +64,34 @@ func runServer() error {
 	}
 	defer db.Close()
 
+	// Notifications
+	notifyConf := core.NotifyConf{
+		ReminderDays: cfg.Notify.ReminderDays,
+		MaxAttempts:  cfg.Notify.MaxAttempts,
+		RetryDelay:   cfg.Notify.RetryDelay,
+		Retention:    cfg.Notify.Retention,
+		PublicURL:    cfg.Notify.PublicURL,
+	}
+	if cfg.Notify.SMTP.Addr != "" {
+		notifyConf.Sender, err = notify.NewSMTPSender(notify.SMTPConf(cfg.Notify.SMTP))
+		if err != nil {
+			return fmt.Errorf("notifications: %w", err)
+		}
+	}
+
 	// Core
 	conf := core.LicensingConf{
-		Limiter:          core.LimiterConf(cfg.Licensing.Limiter),
-		Refresh:          core.RefreshConf(cfg.Licensing.Refresh),
-		MaxTimeDrift:     cfg.Licensing.MaxTimeDrift,
-		MinPasswdEntropy: cfg.MinPasswdEntropy,
-		UseGUI:           !cfg.DisableGUI,
+		Limiter:              core.LimiterConf(cfg.Licensing.Limiter),
+		Refresh:              core.RefreshConf(cfg.Licensing.Refresh),
+		Releases:             core.ReleasesConf(cfg.Licensing.Releases),
+		Notify:               notifyConf,
+		MaxTimeDrift:         cfg.Licensing.MaxTimeDrift,
+		MinPasswdEntropy:     cfg.MinPasswdEntropy,
+		UseGUI:               !cfg.DisableGUI,
+		IdempotencyRetention: cfg.Licensing.IdempotencyRetention,
+		TrashRetention:       cfg.Licensing.TrashRetention,
 	}
-	c, err := core.NewCore(db, cfg.Licensing.ServerKey, time.Now(), conf)
+	c, err := core.NewCore(db, serverKey, time.Now(), conf)
 	if err != nil {
 		return fmt.Errorf("create runtime: %w", err)
 	}
@@ -68,7 +99,7 @@ func runServer() error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			core.RunCleanupRoutine(ctx, db, cfg.Licensing.CleanupInterval, func(msg string, err error) {
+			c.RunCleanupRoutine(ctx, cfg.Licensing.CleanupInterval, func(msg string, err error) {
 				if err != nil {
 					log.WithError(err).Error(msg)
 				} else {
@@ -78,35 +109,79 @@ func runServer() error {
 		}()
 	}
 
-	// Server
-	r := server.NewRouter(c,
-		cfg.HTTP.CORS.ResourceApiEnabled,
-		cfg.HTTP.CORS.LicensingApiEnabled,
-		cfg.HTTP.CORS.AllowedOrigins)
-	if cfg.HTTP.Gzip {
-		r.Use(handlers.CompressHandler)
+	if notifyConf.Sender != nil && cfg.Notify.Interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.RunNotifyRoutine(ctx, cfg.Notify.Interval, func(msg string, err error) {
+				if err != nil {
+					log.WithError(err).Error(msg)
+				} else {
+					log.Info(msg)
+				}
+			})
+		}()
 	}
-	srv := http.Server{
-		Addr:         cfg.HTTP.Listen,
-		Handler:      r,
+
+	build := buildInfo()
+
+	// Metrics
+	if cfg.Metrics.Enabled {
+		err = metrics.Register(metrics.NewActiveSessionsCollector(c.CountActiveLicenseSessions, 10*time.Second))
+		if err != nil {
+			return fmt.Errorf("metrics: %w", err)
+		}
+	}
+
+	// Servers
+	cors := newListenerCORS(cfg)
+	separateLicensing := cfg.Licensing.HTTP.Listen != ""
+	separateMonitoring := cfg.Metrics.HTTP.Listen != ""
+	mainConf := listenerConf{
+		Listen:       cfg.HTTP.Listen,
 		ReadTimeout:  cfg.HTTP.ReadTimeout,
 		WriteTimeout: cfg.HTTP.WriteTimeout,
+		Gzip:         cfg.HTTP.Gzip,
+		TLS:          cfg.HTTP.TLS,
 	}
-	go func() {
-		defer cancel()
-		var err error
-		if cfg.HTTP.TLS.CertFile == "" && cfg.HTTP.TLS.KeyFile == "" {
-			err = srv.ListenAndServe()
-		} else {
-			err = srv.ListenAndServeTLS(cfg.HTTP.TLS.CertFile, cfg.HTTP.TLS.KeyFile)
+	srv, err := newListener("http", mainConf, server.NewRouter(c, server.RouterConf{
+		CORS:         cors.main,
+		LicensingAPI: !separateLicensing,
+		ResourceAPI:  true,
+		Metrics:      cfg.Metrics.Enabled,
+		ServeMetrics: cfg.Metrics.Enabled && !separateMonitoring,
+		Build:        build,
+	}))
+	if err != nil {
+		return err
+	}
+	listeners := []*listener{srv}
+	if separateLicensing {
+		l, err := newListener("licensing http", cfg.Licensing.HTTP, server.NewRouter(c, server.RouterConf{
+			CORS:         cors.licensing,
+			LicensingAPI: true,
+			Metrics:      cfg.Metrics.Enabled,
+			Build:        build,
+		}))
+		if err != nil {
+			return err
 		}
+		listeners = append(listeners, l)
+	}
+	if separateMonitoring {
+		l, err := newListener("metrics http", cfg.Metrics.HTTP, server.NewRouterMonitoring(c, server.RouterConf{
+			CORS:         cors.monitoring,
+			ServeMetrics: cfg.Metrics.Enabled,
+			Build:        build,
+		}))
 		if err != nil {
-			if errors.Is(err, http.ErrServerClosed) {
-				return
-			}
-			log.WithError(err).Error("listening and serving server")
+			return err
 		}
-	}()
+		listeners = append(listeners, l)
+	}
+	for _, l := range listeners {
+		go l.serve(cancel)
+	}
 
 	// Internal Server (for CLI)
 	err = os.Remove(cfg.InternalSocket)
@@ -125,7 +200,7 @@ func runServer() error {
 		return fmt.Errorf("internal server: %w", err)
 	}
 	srvi := http.Server{
-		Handler:      server.NewRouterInternal(c),
+		Handler:      server.NewRouterInternal(c, build),
 		ReadTimeout:  cfg.HTTP.ReadTimeout,
 		WriteTimeout: cfg.HTTP.WriteTimeout,
 	}
@@ -140,8 +215,28 @@ func runServer() error {
 		}
 	}()
 
+	// Config reload
+	if configPath != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runReloader(ctx, configPath, cfg, c, cors)
+		}()
+	}
+
 	// Systemd notify
-	daemon.SdNotify(true, daemon.SdNotifyReady)
+	daemon.SdNotify(false, daemon.SdNotifyReady)
+	watchdog, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		log.WithError(err).Error("systemd watchdog")
+	}
+	if watchdog > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWatchdog(ctx, c, watchdog/2)
+		}()
+	}
 	<-ctx.Done() // Server running
 	daemon.SdNotify(true, daemon.SdNotifyStopping)
 
@@ -149,10 +244,8 @@ func runServer() error {
 	ctx, cancel = context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer cancel()
 
-	err = srv.Shutdown(ctx)
-	if err != nil {
-		log.WithError(err).Error("shutting down http server")
-	}
+	listeners = append(listeners, &listener{name: "internal", srv: &srvi})
+	shutdownListeners(ctx, listeners)
 	wg.Wait()
 
 	err = db.Close()
@@ -161,3 +254,130 @@ func runServer() error {
 	}
 	return nil
 }
+
+// runWatchdog periodically pings systemd watchdog as long as licensing server
+// passes readiness checks.
+//
+// Blocks until context is canceled.
+func runWatchdog(ctx context.Context, c *core.Core, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		checks := c.CheckReadiness(checkCtx)
+		cancel()
+		if !core.Ready(checks) {
+			for _, hc := range checks {
+				if hc.Err != nil {
+					log.WithError(hc.Err).Errorf("watchdog: %s check failed", hc.Name)
+				}
+			}
+			continue
+		}
+		daemon.SdNotify(false, daemon.SdNotifyWatchdog)
+	}
+}
+
+// runReloader reloads config file on SIGHUP and applies settings, which can
+// be changed without restarting. Invalid config is logged and ignored.
+//
+// Blocks until context is canceled.
+func runReloader(ctx context.Context, path string, cfg *config, c *core.Core, cors listenerCORS) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+		case <-ctx.Done():
+			return
+		}
+
+		daemon.SdNotify(false, daemon.SdNotifyReloading)
+		newCfg, err := loadConfig(path)
+		if err == nil {
+			err = c.Reload(reloadConf(newCfg))
+		}
+		daemon.SdNotify(false, daemon.SdNotifyReady)
+		if err != nil {
+			log.WithError(err).Error("reloading config")
+			continue
+		}
+		log.SetLevel(log.MustParseLevel(newCfg.LogLevel))
+		cors.set(newCfg)
+		if !reloadable(cfg, newCfg) {
+			log.Warn("reloaded config has changes, which require restart")
+		}
+		log.Info("reloaded config")
+		cfg = newCfg
+	}
+}
+
+func reloadConf(cfg *config) core.ReloadConf {
+	return core.ReloadConf{
+		MinPasswdEntropy: cfg.MinPasswdEntropy,
+		Refresh:          core.RefreshConf(cfg.Licensing.Refresh),
+		Limiter:          core.LimiterConf(cfg.Licensing.Limiter),
+	}
+}
+
+// listenerCORS holds CORS settings of each listener.
+type listenerCORS struct {
+	main, licensing, monitoring *server.CORS
+}
+
+func newListenerCORS(cfg *config) listenerCORS {
+	main, licensing, monitoring := corsConf(cfg)
+	return listenerCORS{
+		main:       server.NewCORS(main),
+		licensing:  server.NewCORS(licensing),
+		monitoring: server.NewCORS(monitoring),
+	}
+}
+
+func (lc listenerCORS) set(cfg *config) {
+	main, licensing, monitoring := corsConf(cfg)
+	lc.main.Set(main)
+	lc.licensing.Set(licensing)
+	lc.monitoring.Set(monitoring)
+}
+
+// corsConf returns CORS settings of main, licensing and monitoring listeners.
+func corsConf(cfg *config) (main, licensing, monitoring server.CORSConf) {
+	main = server.CORSConf{
+		ResourceApi:    cfg.HTTP.CORS.ResourceApiEnabled,
+		LicensingApi:   cfg.HTTP.CORS.LicensingApiEnabled,
+		AllowedOrigins: cfg.HTTP.CORS.AllowedOrigins,
+	}
+	licensing = server.CORSConf{
+		LicensingApi:   cfg.Licensing.HTTP.CORS.Enabled,
+		AllowedOrigins: cfg.Licensing.HTTP.CORS.AllowedOrigins,
+	}
+	monitoring = server.CORSConf{
+		Monitoring:     cfg.Metrics.HTTP.CORS.Enabled,
+		AllowedOrigins: cfg.Metrics.HTTP.CORS.AllowedOrigins,
+	}
+	return main, licensing, monitoring
+}
+
+// reloadable reports whether all changes between configs are applied by
+// reload.
+func reloadable(prev, next *config) bool {
+	strip := func(cfg config) config {
+		cfg.Licensing.Refresh = prev.Licensing.Refresh
+		cfg.Licensing.Limiter = prev.Licensing.Limiter
+		cfg.HTTP.CORS = prev.HTTP.CORS
+		cfg.Licensing.HTTP.CORS = prev.Licensing.HTTP.CORS
+		cfg.Metrics.HTTP.CORS = prev.Metrics.HTTP.CORS
+		cfg.MinPasswdEntropy = prev.MinPasswdEntropy
+		cfg.LogLevel = prev.LogLevel
+		return cfg
+	}
+	return reflect.DeepEqual(strip(*prev), strip(*next))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+func printServerInfo(args []string) error {
+	cmd := newAdminCmd("info")
+	if len(cmd.parse(args)) != 0 {
+		return errors.New("invalid number of arguments")
+	}
+	var info struct {
+		Version  string             `json:"version"`
+		ServerID []byte             `json:"serverID"`
+		Stats    *model.ServerStats `json:"stats"`
+	}
+	body, err := cmd.call(http.MethodGet, "/server", nil, &info)
+	if err != nil {
+		return err
+	}
+	return cmd.output(body,
+		[]string{"SERVER ID", "VERSION", "ISSUERS", "PRODUCTS", "LICENSES", "ACTIVE LICENSES", "ACTIVE SESSIONS"},
+		[][]string{{
+			base64.StdEncoding.EncodeToString(info.ServerID),
+			info.Version,
+			strconv.Itoa(info.Stats.LicenseIssuers),
+			strconv.Itoa(info.Stats.Products),
+			strconv.Itoa(info.Stats.Licenses),
+			strconv.Itoa(info.Stats.ActiveLicenses),
+			strconv.Itoa(info.Stats.ActiveLicenseSessions),
+		}},
+	)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/sewiti/licensing-system/internal/keystore"
+	"golang.org/x/term"
+)
+
+// Systemd credentials, passed with LoadCredential= or LoadCredentialEncrypted=.
+const (
+	credServerKey           = "server-key"
+	credServerKeyPassphrase = "server-key-passphrase"
+)
+
+// loadServerKey loads licensing server key from configured source. If none is
+// configured, server-key systemd credential is used.
+func loadServerKey(ctx context.Context, cfg *config) ([]byte, error) {
+	if len(cfg.Licensing.ServerKey) > 0 {
+		return cfg.Licensing.ServerKey, nil
+	}
+
+	var p keystore.Provider
+	switch {
+	case cfg.Licensing.ServerKeyFile != "":
+		p = keystore.File(cfg.Licensing.ServerKeyFile)
+	case cfg.Licensing.ServerKeyCommand != "":
+		p = keystore.Command(cfg.Licensing.ServerKeyCommand)
+	default:
+		p = keystore.Credential(credServerKey)
+	}
+	key, err := keystore.Load(ctx, p, serverKeyPassphrase(ctx, cfg))
+	if errors.Is(err, fs.ErrNotExist) && p == keystore.Credential(credServerKey) {
+		return nil, errors.New("server key is not configured")
+	}
+	return key, err
+}
+
+// serverKeyPassphrase returns passphrase of encrypted server key from
+// configured file, server-key-passphrase systemd credential or terminal, in
+// that order.
+func serverKeyPassphrase(ctx context.Context, cfg *config) keystore.PassphraseFunc {
+	return func() ([]byte, error) {
+		if cfg.Licensing.ServerKeyPassphraseFile != "" {
+			pass, err := keystore.File(cfg.Licensing.ServerKeyPassphraseFile).Secret(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.TrimRight(pass, "\r\n"), nil
+		}
+
+		pass, err := keystore.Credential(credServerKeyPassphrase).Secret(ctx)
+		if err == nil {
+			return bytes.TrimRight(pass, "\r\n"), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		// Stdin is taken if key itself is read from it.
+		if cfg.Licensing.ServerKeyFile == "-" || !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, keystore.ErrPassphraseRequired
+		}
+		passwd, err := readPassword("Server key passphrase: ")
+		return []byte(passwd), err
+	}
+}
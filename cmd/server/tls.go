@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Client certificate policies.
+const (
+	clientAuthNone     = "none"
+	clientAuthOptional = "optional" // Verified if given.
+	clientAuthRequire  = "require"
+)
+
+func (t *tlsConf) enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+func (t *tlsConf) validate() error {
+	if t.enabled() && (t.CertFile == "" || t.KeyFile == "") {
+		return errors.New("both cert and key files are required")
+	}
+	switch t.ClientAuth {
+	case clientAuthNone:
+		return nil
+	case clientAuthOptional, clientAuthRequire:
+	default:
+		return fmt.Errorf("invalid client auth: %s", t.ClientAuth)
+	}
+	if !t.enabled() {
+		return errors.New("client auth requires TLS")
+	}
+	if t.ClientCAFile == "" {
+		return errors.New("client auth requires client CA file")
+	}
+	return nil
+}
+
+// config returns TLS config of a listener, certificate itself is loaded by
+// http.Server.ServeTLS. Returns nil if TLS is disabled.
+func (t *tlsConf) config() (*tls.Config, error) {
+	if !t.enabled() {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if t.ClientAuth == clientAuthNone {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(t.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("client CA: %w", err)
+	}
+	cfg.ClientCAs = x509.NewCertPool()
+	if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client CA: no certificates found in %s", t.ClientCAFile)
+	}
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if t.ClientAuth == clientAuthRequire {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
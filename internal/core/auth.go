@@ -101,7 +101,7 @@ func (c *Core) ChangePasswd(ctx context.Context, login *model.LicenseIssuer, lic
 	}
 	err = c.db.UpdateLicenseIssuer(ctx, licenseIssuerID, map[string]interface{}{
 		"password_hash": passwdHash,
-	})
+	}, nil)
 	return handleErrDB(err, "updating license issuer")
 }
 
@@ -125,7 +125,7 @@ func (c *Core) SufficientPasswdStrength(username, password string) (entropy floa
 		"admin",
 	}
 	str := zxcvbn.PasswordStrength(password, userInputs)
-	return str.Entropy, str.Entropy >= c.minPasswdEntropy
+	return str.Entropy, str.Entropy >= c.passwdEntropy()
 }
 
 func (c *Core) IsPrivileged(li *model.LicenseIssuer) bool {
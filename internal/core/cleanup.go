@@ -7,8 +7,13 @@ import (
 	"time"
 
 	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/metrics"
 )
 
+// cleanupLockKey is an advisory lock key used for cleanup routine leader
+// election.
+const cleanupLockKey int64 = 0x6c69632d636c6e // "lic-cln"
+
 type CleanupCallback func(msg string, err error)
 
 func (cb CleanupCallback) call(msg string, err error) {
@@ -21,39 +26,160 @@ func (cb CleanupCallback) call(msg string, err error) {
 // periodically cleans up expired and overused license sessions from the
 // database.
 //
+// Multiple licensing server instances can share the same database, only one of
+// them (leader) performs the cleanup. Leader is elected with an advisory lock,
+// which is released when leader stops or loses database connection.
+//
 // Calls callback with cleanup info and an error if any
 // (nil error means deletion report).
 //
 // Blocks until context is canceled.
-func RunCleanupRoutine(ctx context.Context, db *db.Handler, interval time.Duration, cb CleanupCallback) {
-	cleanup(ctx, db, cb)
+func (c *Core) RunCleanupRoutine(ctx context.Context, interval time.Duration, cb CleanupCallback) {
+	var lock *db.AdvisoryLock
+	defer func() {
+		c.cleanupHeartbeat(0, time.Time{})
+		if lock == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := lock.Release(ctx)
+		if err != nil {
+			cb.call("releasing cleanup leadership", err)
+		}
+	}()
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	for {
+		c.cleanupHeartbeat(interval, time.Now())
+		lock = c.leadership(ctx, lock, cleanupLockKey, "cleanup", cb.call)
+		if lock != nil {
+			c.cleanup(ctx, cb)
+		}
+
 		select {
 		case <-ticker.C:
-			cleanup(ctx, db, cb)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// cleanup deletes expired and overused license sessions.
+// leadership checks whether held lock is still valid and tries to acquire a
+// new one of the key otherwise. Routine is used in callback messages.
+//
+// Returns nil if this instance isn't a leader.
+func (c *Core) leadership(ctx context.Context, lock *db.AdvisoryLock, key int64, routine string, cb func(msg string, err error)) *db.AdvisoryLock {
+	if lock != nil {
+		err := lock.Check(ctx)
+		if err == nil {
+			return lock
+		}
+		cb("lost "+routine+" leadership", err)
+		_ = lock.Release(ctx)
+	}
+
+	lock, err := c.db.TryAdvisoryLock(ctx, key)
+	switch {
+	case err == nil:
+		cb("acquired "+routine+" leadership", nil)
+		return lock
+	case errors.Is(err, db.ErrLocked):
+		// Other instance is a leader.
+		return nil
+	default:
+		cb("acquiring "+routine+" leadership", err)
+		return nil
+	}
+}
+
+// cleanup deletes expired and overused license sessions, stale limiters, old
+// notifications, expired idempotency keys and purges trash.
 //
 // Calls callback with info about deletion and an error if any.
-func cleanup(ctx context.Context, dbh *db.Handler, cb CleanupCallback) {
-	n, err := dbh.DeleteLicenseSessionsExpiredBy(ctx, time.Now())
+func (c *Core) cleanup(ctx context.Context, cb CleanupCallback) {
+	n, err := c.db.DeleteLicenseSessionsExpiredBy(ctx, time.Now())
 	if err != nil && !errors.Is(err, db.ErrNotFound) {
 		cb.call("deleting expired license sessions", err)
 	} else {
+		metrics.CleanupDeleted("expired", n)
 		cb.call(fmt.Sprintf("deleted %d expired license sessions", n), nil)
 	}
 
-	n, err = dbh.DeleteLicenseSessionsOverused(ctx)
+	n, err = c.db.DeleteLicenseSessionsOverused(ctx)
 	if err != nil && !errors.Is(err, db.ErrNotFound) {
 		cb.call("deleting overused license sessions", err)
 	} else {
+		metrics.CleanupDeleted("overused", n)
 		cb.call(fmt.Sprintf("deleted %d overused license sessions", n), nil)
 	}
+
+	if limiter := c.limiterConf(); limiter.Shared {
+		n, err = c.db.DeleteLicenseLimitersUpdatedBefore(ctx, time.Now().Add(-limiter.CacheExpiration))
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			cb.call("deleting stale license limiters", err)
+		} else {
+			metrics.CleanupDeleted("limiters", n)
+			cb.call(fmt.Sprintf("deleted %d stale license limiters", n), nil)
+		}
+	}
+
+	if c.notify.Retention > 0 {
+		n, err = c.db.DeleteNotificationsCreatedBefore(ctx, time.Now().Add(-c.notify.Retention))
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			cb.call("deleting old notifications", err)
+		} else {
+			metrics.CleanupDeleted("notifications", n)
+			cb.call(fmt.Sprintf("deleted %d old notifications", n), nil)
+		}
+	}
+
+	n, err = c.db.DeleteIdempotencyKeysCreatedBefore(ctx, time.Now().Add(-c.idempotencyRetention))
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		cb.call("deleting expired idempotency keys", err)
+	} else {
+		metrics.CleanupDeleted("idempotency_keys", n)
+		cb.call(fmt.Sprintf("deleted %d expired idempotency keys", n), nil)
+	}
+
+	// Usage reports are kept at least as long as their requests are accepted
+	// (see timeInSync), so that replays aren't counted.
+	retention := c.idempotencyRetention
+	if retention < 2*c.maxTimeDrift {
+		retention = 2 * c.maxTimeDrift
+	}
+	n, err = c.db.DeleteUsageReportsCreatedBefore(ctx, time.Now().Add(-retention))
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		cb.call("deleting old usage reports", err)
+	} else {
+		metrics.CleanupDeleted("usage_reports", n)
+		cb.call(fmt.Sprintf("deleted %d old usage reports", n), nil)
+	}
+
+	if c.trashRetention > 0 {
+		c.purgeTrash(ctx, time.Now().Add(-c.trashRetention), cb)
+	}
+}
+
+// purgeTrash permanently deletes licenses, products and license issuers, which
+// were deleted before t.
+func (c *Core) purgeTrash(ctx context.Context, t time.Time, cb CleanupCallback) {
+	purges := []struct {
+		what  string
+		purge func(ctx context.Context, t time.Time) (int, error)
+	}{
+		{"licenses", c.db.PurgeLicensesDeletedBefore},
+		{"products", c.db.PurgeProductsDeletedBefore},
+		{"license issuers", c.db.PurgeLicenseIssuersDeletedBefore},
+	}
+	for _, p := range purges {
+		n, err := p.purge(ctx, t)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			cb.call("purging deleted "+p.what, err)
+			continue
+		}
+		metrics.CleanupDeleted("trash", n)
+		cb.call(fmt.Sprintf("purged %d deleted %s", n, p.what), nil)
+	}
 }
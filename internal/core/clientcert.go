@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// NewClientCert registers client certificate of the license issuer li.
+//
+// Privileged login can register any fingerprint or subject alternative name.
+// Others must prove possession of the certificate by presenting it on the
+// request: only its fingerprint (default) or one of its subject alternative
+// names can be registered.
+//
+// Returns ErrInvalidInput
+// Returns ErrInsufficientPerm
+// Returns ErrDuplicate
+// Returns SensitiveError
+func (c *Core) NewClientCert(ctx context.Context, login, li *model.LicenseIssuer, req *model.ClientCert, presented *x509.Certificate) (*model.ClientCert, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w request", ErrInvalidInput)
+	}
+	privileged := c.IsPrivileged(login)
+	if !privileged && presented == nil {
+		return nil, fmt.Errorf("%w: client certificate must be presented", ErrInsufficientPerm)
+	}
+	cc := &model.ClientCert{
+		Note:     req.Note,
+		Created:  time.Now(),
+		IssuerID: li.ID,
+	}
+	switch {
+	case req.Fingerprint != nil && req.SAN != nil:
+		return nil, fmt.Errorf("%w fingerprint and san are mutually exclusive", ErrInvalidInput)
+	case req.Fingerprint != nil:
+		fingerprint := normalizeFingerprint(*req.Fingerprint)
+		if !ValidClientCertFingerprint(fingerprint) {
+			return nil, fmt.Errorf("%w fingerprint", ErrInvalidInput)
+		}
+		if !privileged && fingerprint != certFingerprint(presented) {
+			return nil, fmt.Errorf("%w: fingerprint doesn't match presented client certificate", ErrInsufficientPerm)
+		}
+		cc.Fingerprint = &fingerprint
+	case req.SAN != nil:
+		if !ValidClientCertSAN(*req.SAN) {
+			return nil, fmt.Errorf("%w san", ErrInvalidInput)
+		}
+		if !privileged && !containsString(certSANs(presented), *req.SAN) {
+			return nil, fmt.Errorf("%w: san doesn't match presented client certificate", ErrInsufficientPerm)
+		}
+		cc.SAN = req.SAN
+	case !privileged:
+		fingerprint := certFingerprint(presented)
+		cc.Fingerprint = &fingerprint
+	default:
+		return nil, fmt.Errorf("%w fingerprint or san is required", ErrInvalidInput)
+	}
+	if !ValidLicenseNote(cc.Note) {
+		return nil, fmt.Errorf("%w note", ErrInvalidInput)
+	}
+
+	var err error
+	cc.ID, err = c.db.InsertClientCert(ctx, cc)
+	return cc, handleErrDB(err, "creating client cert")
+}
+
+// normalizeFingerprint lower cases fingerprint and drops colons, as printed by
+// openssl x509 -fingerprint.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+}
+
+// Returns SensitiveError
+func (c *Core) GetAllClientCerts(ctx context.Context, licenseIssuerID int) ([]*model.ClientCert, error) {
+	cc, err := c.db.SelectAllClientCertsByIssuerID(ctx, licenseIssuerID)
+	return cc, handleErrDB(err, "getting client certs")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) GetClientCert(ctx context.Context, clientCertID int) (*model.ClientCert, error) {
+	cc, err := c.db.SelectClientCertByID(ctx, clientCertID)
+	return cc, handleErrDB(err, "getting client cert")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) DeleteClientCert(ctx context.Context, clientCertID, licenseIssuerID int) error {
+	_, err := c.db.DeleteClientCertByID(ctx, clientCertID, licenseIssuerID)
+	return handleErrDB(err, "deleting client cert")
+}
+
+// AuthenticateClientCert authenticates license issuer by TLS client
+// certificate, which must be already verified against trusted CAs. Certificate
+// whose fingerprint and subject alternative names are registered to different
+// issuers is rejected.
+//
+// Returns ErrNotFound
+// Returns ErrClientCertAmbiguous
+// Returns ErrUserInactive
+// Returns SensitiveError
+func (c *Core) AuthenticateClientCert(ctx context.Context, cert *x509.Certificate) (*model.LicenseIssuer, error) {
+	cc, err := c.db.SelectClientCertsByIdentity(ctx, certFingerprint(cert), certSANs(cert))
+	if err != nil {
+		return nil, handleErrDB(err, "getting client certs")
+	}
+	issuerID, err := clientCertIssuer(cc)
+	if err != nil {
+		return nil, err
+	}
+	li, err := c.GetLicenseIssuer(ctx, issuerID)
+	if err != nil {
+		return nil, err
+	}
+	if !li.Active {
+		return nil, ErrUserInactive
+	}
+	return li, nil
+}
+
+// clientCertIssuer returns license issuer, which all matching client certs
+// are registered to.
+//
+// Returns ErrNotFound
+// Returns ErrClientCertAmbiguous
+func clientCertIssuer(cc []*model.ClientCert) (int, error) {
+	if len(cc) == 0 {
+		return 0, ErrNotFound
+	}
+	for _, c := range cc[1:] {
+		if c.IssuerID != cc[0].IssuerID {
+			return 0, ErrClientCertAmbiguous
+		}
+	}
+	return cc[0].IssuerID, nil
+}
+
+// certFingerprint returns hex encoded SHA-256 fingerprint of the certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// certSANs returns subject alternative names of the certificate.
+func certSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.URIs))
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"crypto/x509"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeFingerprint(t *testing.T) {
+	got := normalizeFingerprint(" " + strings.TrimSuffix(strings.Repeat("AB:", 32), ":") + "\n")
+	assert.Equal(t, strings.Repeat("ab", 32), got)
+	assert.True(t, ValidClientCertFingerprint(got))
+}
+
+func TestCertSANs(t *testing.T) {
+	cert := &x509.Certificate{
+		DNSNames:       []string{"backend.example.com"},
+		EmailAddresses: []string{"ops@example.com"},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/backend"}},
+	}
+	assert.Equal(t, []string{"backend.example.com", "ops@example.com", "spiffe://example.com/backend"}, certSANs(cert))
+	assert.Empty(t, certSANs(&x509.Certificate{}))
+}
+
+func TestCore_NewClientCert_invalid(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name string
+		req  *model.ClientCert
+	}{
+		{
+			name: "nil",
+			req:  nil,
+		},
+		{
+			name: "neither",
+			req:  &model.ClientCert{Note: "note"},
+		},
+		{
+			name: "both",
+			req:  &model.ClientCert{Fingerprint: str(strings.Repeat("ab", 32)), SAN: str("backend.example.com")},
+		},
+		{
+			name: "short fingerprint",
+			req:  &model.ClientCert{Fingerprint: str("abcd")},
+		},
+		{
+			name: "empty san",
+			req:  &model.ClientCert{SAN: str("")},
+		},
+		{
+			name: "long note",
+			req:  &model.ClientCert{SAN: str("backend.example.com"), Note: strings.Repeat("n", 501)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			superadmin := &model.LicenseIssuer{ID: 0}
+			_, err := (&Core{}).NewClientCert(context.Background(), superadmin, &model.LicenseIssuer{ID: 1}, tt.req, nil)
+			assert.ErrorIs(t, err, ErrInvalidInput)
+		})
+	}
+}
+
+func TestCore_NewClientCert_possession(t *testing.T) {
+	str := func(s string) *string { return &s }
+	presented := &x509.Certificate{
+		Raw:      []byte("certificate"),
+		DNSNames: []string{"backend.example.com"},
+	}
+	tests := []struct {
+		name      string
+		req       *model.ClientCert
+		presented *x509.Certificate
+	}{
+		{
+			name:      "not presented",
+			req:       &model.ClientCert{},
+			presented: nil,
+		},
+		{
+			name:      "other fingerprint",
+			req:       &model.ClientCert{Fingerprint: str(strings.Repeat("ab", 32))},
+			presented: presented,
+		},
+		{
+			name:      "other san",
+			req:       &model.ClientCert{SAN: str("frontend.example.com")},
+			presented: presented,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			li := &model.LicenseIssuer{ID: 1}
+			_, err := (&Core{}).NewClientCert(context.Background(), li, li, tt.req, tt.presented)
+			assert.ErrorIs(t, err, ErrInsufficientPerm)
+		})
+	}
+}
+
+func Test_clientCertIssuer(t *testing.T) {
+	_, err := clientCertIssuer(nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	id, err := clientCertIssuer([]*model.ClientCert{{ID: 1, IssuerID: 5}, {ID: 2, IssuerID: 5}})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, id)
+
+	// Fingerprint registered by one issuer, san by another.
+	_, err = clientCertIssuer([]*model.ClientCert{{ID: 1, IssuerID: 5}, {ID: 2, IssuerID: 6}})
+	assert.ErrorIs(t, err, ErrClientCertAmbiguous)
+}
@@ -5,10 +5,11 @@ import (
 	"bytes"
 	"errors"
 	"os"
+	"sync"
 	"time"
 
-	"github.com/patrickmn/go-cache"
 	"github.com/sewiti/licensing-system/internal/core/auth"
+	"github.com/sewiti/licensing-system/internal/core/notify"
 	"github.com/sewiti/licensing-system/internal/db"
 	"github.com/sewiti/licensing-system/pkg/util"
 )
@@ -17,15 +18,30 @@ type Core struct {
 	serverID  []byte
 	serverKey []byte
 
-	db  *db.Handler
-	lim *limiter
-	tm  *auth.TokenManager
+	db *db.Handler
+	tm *auth.TokenManager
 
+	// Reloadable settings, guarded by mu.
+	mu               sync.RWMutex
+	lim              sessionLimiter
 	minPasswdEntropy float64
-	useGui           bool
+	refresh          RefreshConf
+	limiter          LimiterConf
 
-	refresh      RefreshConf
+	useGui       bool
 	maxTimeDrift time.Duration
+
+	releasesDir    string // Empty if releases are disabled.
+	downloadExpiry time.Duration
+
+	notify NotifyConf
+
+	idempotencyRetention time.Duration
+	trashRetention       time.Duration
+
+	// Cleanup routine heartbeat, accessed atomically.
+	cleanupBeat     int64 // Unix nanoseconds
+	cleanupInterval int64 // Nanoseconds, zero if routine isn't running.
 }
 
 type RefreshConf struct {
@@ -34,21 +50,81 @@ type RefreshConf struct {
 	Jitter float64
 }
 
+// ReleasesConf defines where release artifacts are served from.
+type ReleasesConf struct {
+	Dir            string        // Empty disables release artifacts.
+	DownloadExpiry time.Duration // Validity of download tokens.
+}
+
+// NotifyConf defines email notifications.
+type NotifyConf struct {
+	Sender       notify.Sender // Nil disables notifications.
+	ReminderDays []int         // Days before expiry reminders are sent.
+	MaxAttempts  int           // Sending attempts before notification is abandoned.
+	RetryDelay   time.Duration // Delay of the first retry, doubled on each next one.
+	Retention    time.Duration // Zero keeps notifications forever.
+	PublicURL    string        // Base URL of unsubscribe links, empty omits them.
+}
+
 type LicensingConf struct {
 	MaxTimeDrift     time.Duration
 	MinPasswdEntropy float64
 	UseGUI           bool
 
-	Limiter LimiterConf
-	Refresh RefreshConf
+	// IdempotencyRetention is how long responses of create requests are
+	// replayed to their retries.
+	IdempotencyRetention time.Duration
+
+	// TrashRetention is how long deleted licenses, products and issuers are
+	// kept before being purged. Zero keeps them forever.
+	TrashRetention time.Duration
+
+	Limiter  LimiterConf
+	Refresh  RefreshConf
+	Releases ReleasesConf
+	Notify   NotifyConf
 }
 
 func NewCore(db *db.Handler, serverKey []byte, now time.Time, cfg LicensingConf) (*Core, error) {
 	if len(serverKey) != 32 {
 		return nil, errors.New("server key must be of length 32")
 	}
-	if cfg.MinPasswdEntropy < 0 {
-		return nil, errors.New("minimum password entropy must be greater or equal to zero")
+	err := ReloadConf{
+		MinPasswdEntropy: cfg.MinPasswdEntropy,
+		Refresh:          cfg.Refresh,
+		Limiter:          cfg.Limiter,
+	}.validate()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Releases.Dir != "" && cfg.Releases.DownloadExpiry <= 0 {
+		return nil, errors.New("download expiry must be greater than zero")
+	}
+
+	if cfg.Notify.Sender != nil {
+		if cfg.Notify.MaxAttempts < 1 {
+			return nil, errors.New("notification max attempts must be greater than zero")
+		}
+		if cfg.Notify.RetryDelay <= 0 {
+			return nil, errors.New("notification retry delay must be greater than zero")
+		}
+		for _, d := range cfg.Notify.ReminderDays {
+			if d < 1 {
+				return nil, errors.New("expiry reminder days must be greater than zero")
+			}
+			if cfg.Notify.Retention > 0 && time.Duration(d)*24*time.Hour >= cfg.Notify.Retention {
+				return nil, errors.New("notification retention must be longer than expiry reminder days")
+			}
+		}
+	}
+	if cfg.Notify.Retention < 0 {
+		return nil, errors.New("notification retention must be greater or equal to zero")
+	}
+	if cfg.IdempotencyRetention <= 0 {
+		return nil, errors.New("idempotency retention must be greater than zero")
+	}
+	if cfg.TrashRetention < 0 {
+		return nil, errors.New("trash retention must be greater or equal to zero")
 	}
 
 	hostname, err := os.Hostname()
@@ -69,17 +145,23 @@ func NewCore(db *db.Handler, serverKey []byte, now time.Time, cfg LicensingConf)
 		serverKey: key,
 
 		db: db,
-		lim: &limiter{
-			conf:  cfg.Limiter,
-			cache: cache.New(cfg.Limiter.CacheExpiration, cfg.Limiter.CacheCleanupInterval),
-		},
 		tm: tm,
 
+		lim:              newSessionLimiter(db, cfg.Limiter),
 		minPasswdEntropy: cfg.MinPasswdEntropy,
-		useGui:           cfg.UseGUI,
+		refresh:          cfg.Refresh,
+		limiter:          cfg.Limiter,
 
-		refresh:      cfg.Refresh,
+		useGui:       cfg.UseGUI,
 		maxTimeDrift: cfg.MaxTimeDrift,
+
+		releasesDir:    cfg.Releases.Dir,
+		downloadExpiry: cfg.Releases.DownloadExpiry,
+
+		notify: cfg.Notify,
+
+		idempotencyRetention: cfg.IdempotencyRetention,
+		trashRetention:       cfg.TrashRetention,
 	}, nil
 }
 
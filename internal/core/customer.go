@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// Returns ErrInvalidInput
+// Returns ErrDuplicate
+// Returns SensitiveError
+func (c *Core) NewCustomer(ctx context.Context, li *model.LicenseIssuer, req *model.Customer) (*model.Customer, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w request", ErrInvalidInput)
+	}
+	emails := normalizeEmails(req.Emails)
+	err := validateCustomer(req, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cu := &model.Customer{
+		Name:       req.Name,
+		Company:    req.Company,
+		Emails:     emails,
+		ExternalID: req.ExternalID,
+		Note:       req.Note,
+		Created:    now,
+		Updated:    now,
+		IssuerID:   li.ID,
+	}
+	cu.ID, err = c.db.InsertCustomer(ctx, cu)
+	return cu, handleErrDB(err, "creating customer")
+}
+
+func validateCustomer(cu *model.Customer, emails []string) error {
+	if !ValidCustomerName(cu.Name) {
+		return fmt.Errorf("%w name", ErrInvalidInput)
+	}
+	if !ValidCustomerCompany(cu.Company) {
+		return fmt.Errorf("%w company", ErrInvalidInput)
+	}
+	if !ValidCustomerEmails(emails) {
+		return fmt.Errorf("%w emails", ErrInvalidInput)
+	}
+	if !ValidCustomerExternalID(cu.ExternalID) {
+		return fmt.Errorf("%w external id", ErrInvalidInput)
+	}
+	if !ValidLicenseNote(cu.Note) {
+		return fmt.Errorf("%w note", ErrInvalidInput)
+	}
+	return nil
+}
+
+// normalizeEmails lower cases emails and drops duplicates, preserving order.
+func normalizeEmails(emails []string) []string {
+	seen := make(map[string]struct{}, len(emails))
+	normalized := make([]string, 0, len(emails))
+	for _, e := range emails {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if _, ok := seen[e]; ok {
+			continue
+		}
+		seen[e] = struct{}{}
+		normalized = append(normalized, e)
+	}
+	return normalized
+}
+
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) GetCustomersByIssuer(ctx context.Context, licenseIssuerID int, f *model.CustomerFilter, opts *model.ListOptions) ([]*model.Customer, *model.Page, error) {
+	cc, page, err := c.db.SelectCustomersByIssuerID(ctx, licenseIssuerID, f, opts)
+	return cc, page, handleErrDB(err, "getting customers by issuer")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) GetCustomer(ctx context.Context, customerID int) (*model.Customer, error) {
+	cu, err := c.db.SelectCustomerByID(ctx, customerID)
+	return cu, handleErrDB(err, "getting customer")
+}
+
+// Returns ErrInvalidInput
+// Returns ErrDuplicate
+// Returns SensitiveError
+func (c *Core) UpdateCustomer(ctx context.Context, cu *model.Customer, changes map[string]struct{}) error {
+	update := map[string]interface{}{
+		"updated": time.Now(),
+	}
+
+	if _, ok := changes["name"]; ok {
+		if !ValidCustomerName(cu.Name) {
+			return fmt.Errorf("%w name", ErrInvalidInput)
+		}
+		update["name"] = cu.Name
+	}
+	if _, ok := changes["company"]; ok {
+		if !ValidCustomerCompany(cu.Company) {
+			return fmt.Errorf("%w company", ErrInvalidInput)
+		}
+		update["company"] = cu.Company
+	}
+	if _, ok := changes["emails"]; ok {
+		emails := normalizeEmails(cu.Emails)
+		if !ValidCustomerEmails(emails) {
+			return fmt.Errorf("%w emails", ErrInvalidInput)
+		}
+		update["emails"] = pq.Array(emails)
+	}
+	if _, ok := changes["externalID"]; ok {
+		if !ValidCustomerExternalID(cu.ExternalID) {
+			return fmt.Errorf("%w external id", ErrInvalidInput)
+		}
+		update["external_id"] = cu.ExternalID
+	}
+	if _, ok := changes["note"]; ok {
+		if !ValidLicenseNote(cu.Note) {
+			return fmt.Errorf("%w note", ErrInvalidInput)
+		}
+		update["note"] = cu.Note
+	}
+
+	err := c.db.UpdateCustomer(ctx, cu.ID, update)
+	return handleErrDB(err, "updating customer")
+}
+
+// DeleteCustomer deletes customer, its licenses are kept without a customer.
+//
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) DeleteCustomer(ctx context.Context, customerID, licenseIssuerID int) error {
+	_, err := c.db.DeleteCustomerByID(ctx, customerID, licenseIssuerID)
+	return handleErrDB(err, "deleting customer")
+}
+
+func (c *Core) AuthorizeCustomerUpdate(login *model.LicenseIssuer) (updateMask []string, delete bool) {
+	return []string{"name", "company", "emails", "externalID", "note"}, true
+}
+
+// checkCustomerOwner checks whether customer belongs to the issuer.
+func (c *Core) checkCustomerOwner(ctx context.Context, licenseIssuerID, customerID int) error {
+	cu, err := c.db.SelectCustomerByID(ctx, customerID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return handleErrDB(err, "getting customer")
+	}
+	if err != nil || cu.IssuerID != licenseIssuerID {
+		return fmt.Errorf("%w customer", ErrInvalidInput)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_normalizeEmails(t *testing.T) {
+	got := normalizeEmails([]string{" John@ACME.com", "billing@acme.com", "john@acme.com"})
+	assert.Equal(t, []string{"john@acme.com", "billing@acme.com"}, got)
+	assert.Equal(t, []string{}, normalizeEmails(nil))
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const (
+	maxDataSchemaLen = 64 * 1024
+	dataSchemaURL    = "product-data-schema.json"
+)
+
+// FieldError is a validation error of a single data field.
+type FieldError struct {
+	Field   string `json:"field"` // JSON pointer, empty for the whole data.
+	Message string `json:"message"`
+}
+
+// DataError reports data not conforming to the data schema of the product.
+// It wraps ErrInvalidInput.
+type DataError struct {
+	Fields []FieldError
+}
+
+func (e *DataError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		field := f.Field
+		if field == "" {
+			field = "/"
+		}
+		msgs[i] = field + ": " + f.Message
+	}
+	return fmt.Sprintf("%v data: %s", ErrInvalidInput, strings.Join(msgs, "; "))
+}
+
+func (e *DataError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// normalizeDataSchema returns nil for absent or null data schema.
+func normalizeDataSchema(schema json.RawMessage) json.RawMessage {
+	trimmed := bytes.TrimSpace(schema)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil
+	}
+	return schema
+}
+
+// compileDataSchema compiles JSON Schema of product's data. Remote
+// references are not allowed.
+//
+// Returns ErrInvalidInput
+func compileDataSchema(schema []byte) (*jsonschema.Schema, error) {
+	if len(schema) > maxDataSchemaLen {
+		return nil, fmt.Errorf("%w data schema: too long", ErrInvalidInput)
+	}
+	if !json.Valid(schema) {
+		return nil, fmt.Errorf("%w data schema: malformed json", ErrInvalidInput)
+	}
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	c.AssertFormat = true
+	c.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, errors.New("remote references are not allowed")
+	}
+	err := c.AddResource(dataSchemaURL, bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("%w data schema: %v", ErrInvalidInput, err)
+	}
+	s, err := c.Compile(dataSchemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w data schema: %v", ErrInvalidInput, err)
+	}
+	return s, nil
+}
+
+// validateData validates data against the schema. Empty data is always
+// valid.
+//
+// Returns DataError
+func validateData(s *jsonschema.Schema, data []byte) error {
+	if s == nil || len(data) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil || dec.More() {
+		return &DataError{Fields: []FieldError{{Message: "malformed json"}}}
+	}
+	err = s.Validate(v)
+	if err == nil {
+		return nil
+	}
+	var vErr *jsonschema.ValidationError
+	if !errors.As(err, &vErr) {
+		return &DataError{Fields: []FieldError{{Message: err.Error()}}}
+	}
+	return &DataError{Fields: fieldErrors(vErr)}
+}
+
+// fieldErrors flattens validation error to errors of its causes, sorted by
+// field.
+func fieldErrors(vErr *jsonschema.ValidationError) []FieldError {
+	var ff []FieldError
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			ff = append(ff, FieldError{Field: e.InstanceLocation, Message: e.Message})
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(vErr)
+	sort.SliceStable(ff, func(i, j int) bool {
+		return ff[i].Field < ff[j].Field
+	})
+	return ff
+}
+
+// productDataSchema returns compiled data schema of the product, nil if
+// product has none.
+//
+// Returns SensitiveError
+func (c *Core) productDataSchema(ctx context.Context, productID *int) (*jsonschema.Schema, error) {
+	if productID == nil {
+		return nil, nil
+	}
+	p, err := c.db.SelectProductByID(ctx, *productID)
+	if err != nil {
+		return nil, handleErrDB(err, "getting product")
+	}
+	if len(p.DataSchema) == 0 {
+		return nil, nil
+	}
+	s, err := compileDataSchema(p.DataSchema)
+	if err != nil {
+		// Schema has been validated when it was set.
+		return nil, &SensitiveError{Message: "compiling product data schema", Err: err}
+	}
+	return s, nil
+}
+
+// validateLicensesData validates data of licenses against data schemas of
+// their products.
+//
+// Returns DataError
+// Returns SensitiveError
+func (c *Core) validateLicensesData(ctx context.Context, ll []*model.License) error {
+	schemas := make(map[int]*jsonschema.Schema)
+	for i, l := range ll {
+		if l.ProductID == nil || len(l.Data) == 0 {
+			continue
+		}
+		s, ok := schemas[*l.ProductID]
+		if !ok {
+			var err error
+			s, err = c.productDataSchema(ctx, l.ProductID)
+			if err != nil {
+				return err
+			}
+			schemas[*l.ProductID] = s
+		}
+		err := validateData(s, l.Data)
+		if err != nil {
+			if len(ll) > 1 {
+				return fmt.Errorf("license %d: %w", i, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// LicenseDataReport lists data errors of a license.
+type LicenseDataReport struct {
+	LicenseID []byte       `json:"licenseID"`
+	Name      string       `json:"name"`
+	Errors    []FieldError `json:"errors"`
+}
+
+// CheckProductLicensesData validates data of product's licenses against
+// product's current data schema. Reports licenses, which don't conform.
+//
+// Returns SensitiveError
+func (c *Core) CheckProductLicensesData(ctx context.Context, p *model.Product) ([]*LicenseDataReport, error) {
+	if len(p.DataSchema) == 0 {
+		return nil, nil
+	}
+	s, err := compileDataSchema(p.DataSchema)
+	if err != nil {
+		return nil, &SensitiveError{Message: "compiling product data schema", Err: err}
+	}
+	ll, _, err := c.db.SelectLicensesByIssuerID(ctx, p.IssuerID, &model.LicenseFilter{ProductID: &p.ID}, nil)
+	if err != nil {
+		return nil, handleErrDB(err, "getting product licenses")
+	}
+	var reports []*LicenseDataReport
+	for _, l := range ll {
+		err = validateData(s, l.Data)
+		var dErr *DataError
+		if errors.As(err, &dErr) {
+			reports = append(reports, &LicenseDataReport{
+				LicenseID: l.ID,
+				Name:      l.Name,
+				Errors:    dErr.Fields,
+			})
+		}
+	}
+	return reports, nil
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDataSchema = `{
+	"type": "object",
+	"properties": {
+		"seats": {"type": "integer", "minimum": 1},
+		"region": {"enum": ["eu", "us"]},
+		"contact": {"type": "string", "format": "email"}
+	},
+	"required": ["seats"]
+}`
+
+func Test_normalizeDataSchema(t *testing.T) {
+	assert.Nil(t, normalizeDataSchema(nil))
+	assert.Nil(t, normalizeDataSchema(json.RawMessage(" null ")))
+	assert.Equal(t, json.RawMessage(`{}`), normalizeDataSchema(json.RawMessage(`{}`)))
+}
+
+func Test_compileDataSchema(t *testing.T) {
+	_, err := compileDataSchema([]byte(testDataSchema))
+	assert.NoError(t, err)
+
+	tests := []string{
+		`{"type": `,
+		`{"type": "unknown"}`,
+		`{"minimum": "1"}`,
+		`{"$ref": "https://example.com/schema.json"}`,
+	}
+	for _, schema := range tests {
+		_, err = compileDataSchema([]byte(schema))
+		assert.ErrorIs(t, err, ErrInvalidInput, schema)
+	}
+}
+
+func Test_validateData(t *testing.T) {
+	s, err := compileDataSchema([]byte(testDataSchema))
+	require.NoError(t, err)
+
+	assert.NoError(t, validateData(nil, []byte(`"anything"`)))
+	assert.NoError(t, validateData(s, nil))
+	assert.NoError(t, validateData(s, []byte(`{"seats": 5, "region": "eu"}`)))
+
+	err = validateData(s, []byte(`{"seats": 0, "region": "asia", "contact": "nope"}`))
+	require.ErrorIs(t, err, ErrInvalidInput)
+	var dErr *DataError
+	require.ErrorAs(t, err, &dErr)
+	fields := make([]string, len(dErr.Fields))
+	for i, f := range dErr.Fields {
+		fields[i] = f.Field
+		assert.NotEmpty(t, f.Message)
+	}
+	assert.Equal(t, []string{"/contact", "/region", "/seats"}, fields)
+
+	err = validateData(s, []byte(`{"region": "us"}`))
+	require.ErrorAs(t, err, &dErr)
+	require.Len(t, dErr.Fields, 1)
+	assert.Equal(t, "", dErr.Fields[0].Field)
+
+	err = validateData(s, []byte(`{"seats": 1} {}`))
+	require.ErrorAs(t, err, &dErr)
+	assert.Equal(t, []FieldError{{Message: "malformed json"}}, dErr.Fields)
+}
@@ -2,6 +2,8 @@ package core
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/sewiti/licensing-system/internal/db"
 )
@@ -14,10 +16,30 @@ var (
 	ErrLicenseExpired        = errors.New("license has expired")
 	ErrLicenseInactive       = errors.New("license is inactive")
 	ErrLicenseSessionExpired = errors.New("license session has expired")
+	ErrLicenseIsAddon        = errors.New("license is an add-on")
+
+	// License transfer errors
+	ErrTransferDisabled     = errors.New("license transfers are disabled")
+	ErrTransferLimitReached = errors.New("license transfer limit has been reached")
+	ErrTransferCooldown     = errors.New("license transfer is cooling down")
 
 	// Product errors
 	ErrProductInactive = errors.New("product is inactive")
 
+	// Release errors
+	ErrReleasesDisabled = errors.New("releases are disabled")
+	ErrDownloadExpired  = errors.New("download has expired")
+
+	// Notification errors
+	ErrNotificationsDisabled = errors.New("notifications are disabled")
+
+	// Credit errors
+	ErrInsufficientCredits = errors.New("insufficient credits")
+
+	// Idempotency errors
+	ErrIdempotencyKeyReused  = errors.New("idempotency key has been used with a different request")
+	ErrIdempotencyInProgress = errors.New("request with the idempotency key is in progress")
+
 	// License session errors
 	ErrRateLimitReached = errors.New("rate limit has been reached")
 	ErrTimeOutOfSync    = errors.New("time out of sync")
@@ -26,10 +48,12 @@ var (
 	ErrUserInactive        = errors.New("user is inactive")
 	ErrSuperadminImmutable = errors.New("superadmin is immutable")
 	ErrInsufficientPerm    = errors.New("insufficient permissions")
+	ErrClientCertAmbiguous = errors.New("client certificate is registered to multiple issuers")
 
 	// Database errors
-	ErrNotFound  = errors.New("not found")
-	ErrDuplicate = errors.New("duplicate")
+	ErrNotFound           = errors.New("not found")
+	ErrDuplicate          = errors.New("duplicate")
+	ErrPreconditionFailed = errors.New("resource has been modified")
 
 	// Validation errors
 	ErrPasswdTooWeak = errors.New("password is too weak")
@@ -59,6 +83,8 @@ func (e *SensitiveError) Unwrap() error {
 //  - If error is nil, nil is returned.
 //  - If error is db.ErrNotFound, core.ErrNotFound is returned.
 //  - If error is db.ErrDuplicate, core.ErrDuplicate is returned.
+//  - If error is db.ErrPreconditionFailed, core.ErrPreconditionFailed is returned.
+//  - If error is db.ErrInvalidArgument, it's wrapped under core.ErrInvalidInput.
 //  - Other errors are wrapped under core.SensitiveError with a message given.
 func handleErrDB(err error, message string) error {
 	var sErr *SensitiveError
@@ -72,6 +98,15 @@ func handleErrDB(err error, message string) error {
 		return ErrNotFound
 	case errors.Is(err, db.ErrDuplicate):
 		return ErrDuplicate
+	case errors.Is(err, db.ErrPreconditionFailed):
+		return ErrPreconditionFailed
+	case errors.Is(err, db.ErrInvalidArgument):
+		arg := "argument"
+		var dbErr *db.Error
+		if errors.As(err, &dbErr) && dbErr.Unwrap() != db.ErrInvalidArgument {
+			arg = strings.TrimPrefix(dbErr.Unwrap().Error(), db.ErrInvalidArgument.Error()+": ")
+		}
+		return fmt.Errorf("%w %s", ErrInvalidInput, arg)
 	default:
 		return &SensitiveError{
 			Message: message,
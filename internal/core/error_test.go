@@ -2,6 +2,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/sewiti/licensing-system/internal/db"
@@ -33,6 +34,12 @@ func Test_handleErrDB(t *testing.T) {
 			err:     db.ErrNotFound,
 			want:    ErrNotFound,
 		},
+		{
+			name:    "invalid argument",
+			message: "listing",
+			err:     db.ErrInvalidArgument,
+			want:    fmt.Errorf("%w argument", ErrInvalidInput),
+		},
 		{
 			name:    "change message",
 			message: "new message",
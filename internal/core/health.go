@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/db"
+)
+
+var (
+	ErrSchemaVersion = errors.New("unexpected database schema version")
+	ErrCleanupStale  = errors.New("cleanup routine is not running")
+)
+
+// HealthCheck is a single readiness check result.
+type HealthCheck struct {
+	Name string
+	Err  error
+}
+
+// CheckReadiness checks whether licensing server is ready to serve requests:
+//   - database is reachable;
+//   - database schema is at the version application expects;
+//   - cleanup routine is alive (if it has been started).
+func (c *Core) CheckReadiness(ctx context.Context) []HealthCheck {
+	checks := []HealthCheck{
+		{Name: "database", Err: c.checkDatabase(ctx)},
+		{Name: "migrations", Err: c.checkMigrations(ctx)},
+	}
+	if atomic.LoadInt64(&c.cleanupInterval) > 0 {
+		checks = append(checks, HealthCheck{Name: "cleanup", Err: c.checkCleanup(time.Now())})
+	}
+	return checks
+}
+
+// Ready reports whether all of the readiness checks have passed.
+func Ready(checks []HealthCheck) bool {
+	for _, hc := range checks {
+		if hc.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Core) checkDatabase(ctx context.Context) error {
+	err := c.db.Ping(ctx)
+	return handleErrDB(err, "pinging database")
+}
+
+func (c *Core) checkMigrations(ctx context.Context) error {
+	expected, err := db.LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+	version, dirty, err := c.db.MigrationVersion(ctx)
+	if err != nil {
+		return handleErrDB(err, "getting database schema version")
+	}
+	if dirty {
+		return fmt.Errorf("%w: %d (dirty)", ErrSchemaVersion, version)
+	}
+	if version != expected {
+		return fmt.Errorf("%w: %d, expected %d", ErrSchemaVersion, version, expected)
+	}
+	return nil
+}
+
+func (c *Core) checkCleanup(now time.Time) error {
+	interval := time.Duration(atomic.LoadInt64(&c.cleanupInterval))
+	beat := time.Unix(0, atomic.LoadInt64(&c.cleanupBeat))
+	// Allow single cleanup to take up to an interval.
+	if now.Sub(beat) > 2*interval {
+		return fmt.Errorf("%w: last run %v ago", ErrCleanupStale, now.Sub(beat).Truncate(time.Second))
+	}
+	return nil
+}
+
+// cleanupHeartbeat marks cleanup routine as alive.
+func (c *Core) cleanupHeartbeat(interval time.Duration, now time.Time) {
+	atomic.StoreInt64(&c.cleanupBeat, now.UnixNano())
+	atomic.StoreInt64(&c.cleanupInterval, int64(interval))
+}
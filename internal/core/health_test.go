@@ -0,0 +1,45 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCore_checkCleanup(t *testing.T) {
+	now := time.Date(2022, 2, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		beat      time.Time
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "recent",
+			beat:      now.Add(-5 * time.Minute),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "slow",
+			beat:      now.Add(-30 * time.Minute),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "stale",
+			beat:      now.Add(-time.Hour),
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Core{}
+			c.cleanupHeartbeat(20*time.Minute, tt.beat)
+			tt.assertion(t, c.checkCleanup(now))
+		})
+	}
+}
+
+func TestReady(t *testing.T) {
+	assert.True(t, Ready([]HealthCheck{{Name: "database"}, {Name: "migrations"}}))
+	assert.False(t, Ready([]HealthCheck{{Name: "database"}, {Name: "cleanup", Err: ErrCleanupStale}}))
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// idempotencyLease is how long a request in progress holds its key, unless
+// renewed. Key of a request, which didn't finish in time (e.g., server
+// crashed), is taken over by a retry.
+const idempotencyLease = 5 * time.Minute
+
+// idempotencyLeaseRenewal is how often lease of a running request is renewed.
+const idempotencyLeaseRenewal = idempotencyLease / 5
+
+// BeginIdempotentRequest reserves issuer's idempotency key for the request of
+// the hash. Returned key has a zero status code if it has been reserved, in
+// which case request must be finished with CompleteIdempotentRequest or
+// AbortIdempotentRequest. Otherwise it holds the stored response of the same
+// request, which should be replayed.
+//
+// Keys expire after idempotency retention, reservations - after idempotency
+// lease.
+//
+// Returns ErrInvalidInput
+// Returns ErrIdempotencyKeyReused
+// Returns ErrIdempotencyInProgress
+// Returns SensitiveError
+func (c *Core) BeginIdempotentRequest(ctx context.Context, licenseIssuerID int, key string, requestHash []byte) (*model.IdempotencyKey, error) {
+	if !ValidIdempotencyKey(key) {
+		return nil, fmt.Errorf("%w idempotency key", ErrInvalidInput)
+	}
+	now := time.Now().Truncate(time.Microsecond) // database precision
+	lockedUntil := now.Add(idempotencyLease)
+	k := &model.IdempotencyKey{
+		IssuerID:    licenseIssuerID,
+		Key:         key,
+		RequestHash: requestHash,
+		Created:     now,
+		LockedUntil: &lockedUntil,
+	}
+	// Second attempt is made if the previous key has expired.
+	for i := 0; i < 2; i++ {
+		ok, err := c.db.InsertIdempotencyKey(ctx, k)
+		if err != nil {
+			return nil, handleErrDB(err, "inserting idempotency key")
+		}
+		if ok {
+			return k, nil
+		}
+
+		prev, err := c.db.SelectIdempotencyKey(ctx, licenseIssuerID, key)
+		if errors.Is(err, db.ErrNotFound) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			return nil, handleErrDB(err, "getting idempotency key")
+		}
+		if prev.Created.Before(now.Add(-c.idempotencyRetention)) {
+			_, err = c.db.DeleteIdempotencyKey(ctx, licenseIssuerID, key, prev.Created)
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				return nil, handleErrDB(err, "deleting expired idempotency key")
+			}
+			continue
+		}
+		if !bytes.Equal(prev.RequestHash, requestHash) {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if prev.StatusCode != 0 {
+			return prev, nil
+		}
+		if prev.LockedUntil != nil && now.Before(*prev.LockedUntil) {
+			return nil, ErrIdempotencyInProgress
+		}
+		ok, err = c.db.TakeOverIdempotencyKey(ctx, k, prev.LockedUntil)
+		if err != nil {
+			return nil, handleErrDB(err, "taking over idempotency key")
+		}
+		if ok {
+			return k, nil
+		}
+		// Finished or taken over meanwhile.
+	}
+	return nil, ErrIdempotencyInProgress
+}
+
+// HoldIdempotentRequest renews lease of the key reserved by the request in
+// progress, so that retries aren't let in while the request runs longer than
+// the lease. Failed renewals are retried, calling callback with the error.
+//
+// Blocks until context is canceled or key is no longer reserved by the
+// request.
+func (c *Core) HoldIdempotentRequest(ctx context.Context, k *model.IdempotencyKey, cb func(err error)) {
+	ticker := time.NewTicker(idempotencyLeaseRenewal)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		ok, err := c.renewIdempotentRequest(ctx, k, time.Now())
+		if err != nil {
+			if ctx.Err() == nil && cb != nil {
+				cb(err)
+			}
+			continue
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// renewIdempotentRequest extends lease of the reserved key from now. Reports
+// whether key is still reserved by the request.
+//
+// Returns SensitiveError
+func (c *Core) renewIdempotentRequest(ctx context.Context, k *model.IdempotencyKey, now time.Time) (bool, error) {
+	ok, err := c.db.RenewIdempotencyKey(ctx, k, now.Add(idempotencyLease))
+	return ok, handleErrDB(err, "renewing idempotency key")
+}
+
+// CompleteIdempotentRequest stores response of the request, which reserved
+// the key.
+//
+// Returns SensitiveError
+func (c *Core) CompleteIdempotentRequest(ctx context.Context, k *model.IdempotencyKey, statusCode int, header model.ResponseHeader, body []byte) error {
+	err := c.db.UpdateIdempotencyKey(ctx, k.IssuerID, k.Key, k.Created, map[string]interface{}{
+		"status_code":  statusCode,
+		"header":       header,
+		"body":         body,
+		"locked_until": nil,
+	})
+	if err != nil {
+		return handleErrDB(err, "completing idempotency key")
+	}
+	k.StatusCode, k.Header, k.Body, k.LockedUntil = statusCode, header, body, nil
+	return nil
+}
+
+// AbortIdempotentRequest releases the key of a failed request, so that it can
+// be retried.
+//
+// Returns SensitiveError
+func (c *Core) AbortIdempotentRequest(ctx context.Context, k *model.IdempotencyKey) error {
+	_, err := c.db.DeleteIdempotencyKey(ctx, k.IssuerID, k.Key, k.Created)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return handleErrDB(err, "aborting idempotency key")
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	insertIdempotencyKeyQuery   = "INSERT INTO idempotency_key (body,created,header,issuer_id,key,locked_until,request_hash,status_code) VALUES (?,?,?,?,?,?,?,?) ON CONFLICT (issuer_id, key) DO NOTHING"
+	selectIdempotencyKeyQuery   = "SELECT issuer_id, key, request_hash, status_code, header, body, created, locked_until FROM idempotency_key WHERE issuer_id = ? AND key = ?"
+	takeOverIdempotencyKeyQuery = "UPDATE idempotency_key SET created = ?, locked_until = ? WHERE issuer_id = ? AND key = ? AND locked_until = ? AND request_hash = ? AND status_code = ?"
+)
+
+func newMockCore(t *testing.T) (*Core, sqlmock.Sqlmock) {
+	dsn := "sqlmock:" + t.Name()
+	_, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	h, err := db.Open(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return &Core{db: h, idempotencyRetention: 24 * time.Hour}, mock
+}
+
+func idempotencyKeyRows(hash []byte, statusCode int, created time.Time, lockedUntil *time.Time) *sqlmock.Rows {
+	var body []byte
+	if statusCode != 0 {
+		body = []byte(`{"id":1}`)
+	}
+	return sqlmock.NewRows([]string{"issuer_id", "key", "request_hash", "status_code", "header", "body", "created", "locked_until"}).
+		AddRow(3, "order-1234", hash, statusCode, nil, body, created, lockedUntil)
+}
+
+func TestCore_BeginIdempotentRequest_reserved(t *testing.T) {
+	c, mock := newMockCore(t)
+	hash := []byte{1, 2, 3}
+
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	k, err := c.BeginIdempotentRequest(context.Background(), 3, "order-1234", hash)
+	assert.NoError(t, err)
+	assert.Zero(t, k.StatusCode)
+	if assert.NotNil(t, k.LockedUntil) {
+		assert.Equal(t, idempotencyLease, k.LockedUntil.Sub(k.Created))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCore_BeginIdempotentRequest_replayed(t *testing.T) {
+	c, mock := newMockCore(t)
+	hash := []byte{1, 2, 3}
+
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectIdempotencyKeyQuery).
+		WithArgs(3, "order-1234").
+		WillReturnRows(idempotencyKeyRows(hash, 201, time.Now().Add(-time.Hour), nil))
+
+	k, err := c.BeginIdempotentRequest(context.Background(), 3, "order-1234", hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 201, k.StatusCode)
+	assert.Equal(t, []byte(`{"id":1}`), k.Body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCore_BeginIdempotentRequest_reused(t *testing.T) {
+	c, mock := newMockCore(t)
+
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectIdempotencyKeyQuery).
+		WithArgs(3, "order-1234").
+		WillReturnRows(idempotencyKeyRows([]byte{4, 5, 6}, 201, time.Now().Add(-time.Hour), nil))
+
+	_, err := c.BeginIdempotentRequest(context.Background(), 3, "order-1234", []byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCore_BeginIdempotentRequest_inProgress(t *testing.T) {
+	c, mock := newMockCore(t)
+	hash := []byte{1, 2, 3}
+	lockedUntil := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectIdempotencyKeyQuery).
+		WithArgs(3, "order-1234").
+		WillReturnRows(idempotencyKeyRows(hash, 0, time.Now(), &lockedUntil))
+
+	_, err := c.BeginIdempotentRequest(context.Background(), 3, "order-1234", hash)
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCore_BeginIdempotentRequest_leaseExpired(t *testing.T) {
+	c, mock := newMockCore(t)
+	hash := []byte{1, 2, 3}
+	lockedUntil := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectIdempotencyKeyQuery).
+		WithArgs(3, "order-1234").
+		WillReturnRows(idempotencyKeyRows(hash, 0, lockedUntil.Add(-idempotencyLease), &lockedUntil))
+	mock.ExpectExec(takeOverIdempotencyKeyQuery).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 3, "order-1234", lockedUntil, hash, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	k, err := c.BeginIdempotentRequest(context.Background(), 3, "order-1234", hash)
+	assert.NoError(t, err)
+	assert.Zero(t, k.StatusCode)
+	if assert.NotNil(t, k.LockedUntil) {
+		assert.True(t, k.LockedUntil.After(time.Now()))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCore_BeginIdempotentRequest_leaseTakenOver(t *testing.T) {
+	c, mock := newMockCore(t)
+	hash := []byte{1, 2, 3}
+	lockedUntil := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+	newLockedUntil := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectIdempotencyKeyQuery).
+		WithArgs(3, "order-1234").
+		WillReturnRows(idempotencyKeyRows(hash, 0, lockedUntil.Add(-idempotencyLease), &lockedUntil))
+	// Another retry takes it over first.
+	mock.ExpectExec(takeOverIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectIdempotencyKeyQuery).
+		WithArgs(3, "order-1234").
+		WillReturnRows(idempotencyKeyRows(hash, 0, time.Now(), &newLockedUntil))
+
+	_, err := c.BeginIdempotentRequest(context.Background(), 3, "order-1234", hash)
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCore_BeginIdempotentRequest_expired(t *testing.T) {
+	c, mock := newMockCore(t)
+	hash := []byte{1, 2, 3}
+	created := time.Now().Add(-25 * time.Hour).Truncate(time.Microsecond)
+
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(selectIdempotencyKeyQuery).
+		WithArgs(3, "order-1234").
+		WillReturnRows(idempotencyKeyRows([]byte{4, 5, 6}, 201, created, nil))
+	mock.ExpectExec("DELETE FROM idempotency_key WHERE created = ? AND issuer_id = ? AND key = ?").
+		WithArgs(created, 3, "order-1234").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertIdempotencyKeyQuery).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	k, err := c.BeginIdempotentRequest(context.Background(), 3, "order-1234", hash)
+	assert.NoError(t, err)
+	assert.Zero(t, k.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCore_renewIdempotentRequest(t *testing.T) {
+	const query = "UPDATE idempotency_key SET locked_until = ? WHERE created = ? AND issuer_id = ? AND key = ? AND status_code = ?"
+	c, mock := newMockCore(t)
+	now := time.Now()
+	k := &model.IdempotencyKey{IssuerID: 3, Key: "order-1234", Created: now.Add(-10 * time.Minute)}
+
+	mock.ExpectExec(query).
+		WithArgs(now.Add(idempotencyLease), k.Created, 3, "order-1234", 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err := c.renewIdempotentRequest(context.Background(), k, now)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Taken over by a retry after the lease has expired.
+	mock.ExpectExec(query).
+		WithArgs(now.Add(idempotencyLease), k.Created, 3, "order-1234", 0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	ok, err = c.renewIdempotentRequest(context.Background(), k, now)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,76 +1,310 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	cryptorand "crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/db"
 	"github.com/sewiti/licensing-system/internal/model"
 	"github.com/sewiti/licensing-system/pkg/util"
 )
 
+// maxBulkLicenses is the maximum number of licenses created or imported at
+// once.
+const maxBulkLicenses = 10000
+
 // Returns ErrInvalidInput
 // Returns ErrExceedsLimit
 // Returns SensitiveError
 func (c *Core) NewLicense(ctx context.Context, li *model.LicenseIssuer, req *model.License) (*model.License, error) {
-	if req == nil {
+	ll, err := c.NewLicenses(ctx, li, req, 1)
+	if err != nil {
+		return nil, err
+	}
+	return ll[0], nil
+}
+
+// NewLicenses creates count licenses from the template in a single
+// transaction.
+//
+// Returns ErrInvalidInput
+// Returns ErrExceedsLimit
+// Returns SensitiveError
+func (c *Core) NewLicenses(ctx context.Context, li *model.LicenseIssuer, tmpl *model.License, count int) ([]*model.License, error) {
+	if tmpl == nil {
 		return nil, fmt.Errorf("%w request", ErrInvalidInput)
 	}
-	if !ValidLicenseName(req.Name) {
-		return nil, fmt.Errorf("%w name", ErrInvalidInput)
+	if count < 1 || count > maxBulkLicenses {
+		return nil, fmt.Errorf("%w count", ErrInvalidInput)
 	}
-	if !ValidLicenseTags(req.Tags) {
-		return nil, fmt.Errorf("%w tags", ErrInvalidInput)
+	err := validateLicense(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	features := tmpl.Features
+	if features == nil {
+		features = make([]string, 0)
+	}
+	channels := tmpl.Channels
+	if channels == nil {
+		channels = make([]string, 0)
 	}
-	if req.EndUserEmail != "" && !ValidEmail(req.EndUserEmail) {
-		return nil, fmt.Errorf("%w end user email", ErrInvalidInput)
+	quotas := tmpl.Quotas
+	if quotas == nil {
+		quotas = make(model.UsageQuotas)
 	}
-	if !ValidLicenseNote(req.Note) {
-		return nil, fmt.Errorf("%w note", ErrInvalidInput)
+	templateVersion := tmpl.TemplateVersion
+	if tmpl.TemplateID == nil {
+		templateVersion = nil // Version of no template
 	}
-	if req.MaxSessions <= 0 {
-		return nil, fmt.Errorf("%w max sessions", ErrInvalidInput)
+	now := time.Now()
+	ll := make([]*model.License, count)
+	for i := range ll {
+		id, key, err := util.GenerateKey(cryptorand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ll[i] = &model.License{
+			ID:           id,
+			Key:          key,
+			Active:       tmpl.Active,
+			Name:         tmpl.Name,
+			Tags:         tmpl.Tags,
+			Features:     features,
+			EndUserEmail: tmpl.EndUserEmail,
+			Note:         tmpl.Note,
+			Data:         tmpl.Data,
+			MaxSessions:  tmpl.MaxSessions,
+			ValidUntil:   tmpl.ValidUntil,
+			Created:      now,
+			Updated:      now,
+			LastUsed:     nil,
+			IssuerID:     li.ID,
+			ProductID:    tmpl.ProductID,
+
+			TemplateID:      tmpl.TemplateID,
+			TemplateVersion: templateVersion,
+
+			MaxTransfers:     tmpl.MaxTransfers,
+			TransferCooldown: tmpl.TransferCooldown,
+
+			ParentID: tmpl.ParentID,
+
+			EditionID: tmpl.EditionID,
+			Channels:  channels,
+
+			UpdatesUntil: tmpl.UpdatesUntil,
+			Quotas:       quotas,
+
+			CustomerID: tmpl.CustomerID,
+		}
 	}
-	count, err := c.db.SelectLicensesCountByIssuerID(ctx, li.ID)
+	err = c.insertLicenses(ctx, li.ID, ll)
 	if err != nil {
-		return nil, handleErrDB(err, "counting licenses")
+		return nil, err
 	}
-	if !li.MaxLicenses.Allows(count + 1) {
-		return nil, fmt.Errorf("max licenses: %w", ErrExceedsLimit)
+	return ll, nil
+}
+
+// ImportLicenses imports licenses from another system in a single
+// transaction, preserving their IDs and keys.
+//
+// Returns ErrInvalidInput
+// Returns ErrExceedsLimit
+// Returns ErrDuplicate
+// Returns SensitiveError
+func (c *Core) ImportLicenses(ctx context.Context, li *model.LicenseIssuer, req []*model.License) ([]*model.License, error) {
+	if len(req) < 1 || len(req) > maxBulkLicenses {
+		return nil, fmt.Errorf("%w count", ErrInvalidInput)
+	}
+	now := time.Now()
+	ids := make(map[string]struct{}, len(req))
+	ll := make([]*model.License, len(req))
+	for i, r := range req {
+		l, err := importedLicense(li, r, now)
+		if err != nil {
+			return nil, fmt.Errorf("license %d: %w", i, err)
+		}
+		if _, ok := ids[string(l.ID)]; ok {
+			return nil, fmt.Errorf("license %d: %w", i, ErrDuplicate)
+		}
+		ids[string(l.ID)] = struct{}{}
+		ll[i] = l
 	}
+	err := c.insertLicenses(ctx, li.ID, ll)
+	if err != nil {
+		return nil, err
+	}
+	return ll, nil
+}
 
-	id, key, err := util.GenerateKey(cryptorand.Reader)
+// importedLicense validates imported license and returns it owned by the
+// issuer. ID must be the public key of the license key. Fields managed by the
+// server, e.g., last used and deleted times, are reset.
+func importedLicense(li *model.LicenseIssuer, req *model.License, now time.Time) (*model.License, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w request", ErrInvalidInput)
+	}
+	err := validateLicense(req)
 	if err != nil {
 		return nil, err
 	}
-	now := time.Now()
-	l := &model.License{
-		ID:           id,
-		Key:          key,
-		Active:       req.Active,
-		Name:         req.Name,
-		Tags:         req.Tags,
-		EndUserEmail: req.EndUserEmail,
-		Note:         req.Note,
-		Data:         req.Data,
-		MaxSessions:  req.MaxSessions,
-		ValidUntil:   req.ValidUntil,
-		Created:      now,
-		Updated:      now,
-		LastUsed:     nil,
-		IssuerID:     li.ID,
-		ProductID:    req.ProductID,
-	}
-	err = c.db.InsertLicense(ctx, l)
-	return l, handleErrDB(err, "creating license")
+	id, err := util.PublicKey(req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w key", ErrInvalidInput)
+	}
+	if !bytes.Equal(id, req.ID) {
+		return nil, fmt.Errorf("%w id", ErrInvalidInput)
+	}
+
+	l := *req
+	l.IssuerID = li.ID
+	if l.Tags == nil {
+		l.Tags = make([]string, 0)
+	}
+	if l.Features == nil {
+		l.Features = make([]string, 0)
+	}
+	if l.Channels == nil {
+		l.Channels = make([]string, 0)
+	}
+	if l.Quotas == nil {
+		l.Quotas = make(model.UsageQuotas)
+	}
+	l.TemplateID, l.TemplateVersion = nil, nil // templates aren't imported
+	l.LastUsed, l.Deleted = nil, nil // managed by the server
+	if l.Created.IsZero() {
+		l.Created = now
+	}
+	if l.Updated.IsZero() {
+		l.Updated = now
+	}
+	return &l, nil
+}
+
+func validateLicense(l *model.License) error {
+	if !ValidLicenseName(l.Name) {
+		return fmt.Errorf("%w name", ErrInvalidInput)
+	}
+	if !ValidLicenseTags(l.Tags) {
+		return fmt.Errorf("%w tags", ErrInvalidInput)
+	}
+	if l.EndUserEmail != "" && !ValidEmail(l.EndUserEmail) {
+		return fmt.Errorf("%w end user email", ErrInvalidInput)
+	}
+	if !ValidLicenseFeatures(l.Features) {
+		return fmt.Errorf("%w features", ErrInvalidInput)
+	}
+	if !ValidLicenseNote(l.Note) {
+		return fmt.Errorf("%w note", ErrInvalidInput)
+	}
+	if l.MaxSessions <= 0 {
+		return fmt.Errorf("%w max sessions", ErrInvalidInput)
+	}
+	if !ValidMaxTransfers(l.MaxTransfers) {
+		return fmt.Errorf("%w max transfers", ErrInvalidInput)
+	}
+	if l.TransferCooldown != nil && *l.TransferCooldown < 0 {
+		return fmt.Errorf("%w transfer cooldown", ErrInvalidInput)
+	}
+	if !ValidReleaseChannels(l.Channels) {
+		return fmt.Errorf("%w channels", ErrInvalidInput)
+	}
+	if !ValidUsageQuotas(l.Quotas) {
+		return fmt.Errorf("%w quotas", ErrInvalidInput)
+	}
+	return nil
+}
+
+// insertLicenses inserts licenses of the issuer in a single transaction,
+// while holding issuer's lock so that max licenses limit can't be exceeded
+// by concurrent requests. Products and customers of licenses must belong to
+// the issuer and data of licenses must conform to data schemas of their
+// products.
+func (c *Core) insertLicenses(ctx context.Context, licenseIssuerID int, ll []*model.License) error {
+	products := make(map[int]struct{})
+	for _, l := range ll {
+		if l.ProductID != nil {
+			products[*l.ProductID] = struct{}{}
+		}
+	}
+	for productID := range products {
+		err := c.checkProductOwner(ctx, licenseIssuerID, productID)
+		if err != nil {
+			return err
+		}
+	}
+	err := c.validateLicensesData(ctx, ll)
+	if err != nil {
+		return err
+	}
+	type edition struct{ id, productID int }
+	editions := make(map[edition]struct{})
+	for _, l := range ll {
+		if l.EditionID == nil {
+			continue
+		}
+		if l.ProductID == nil {
+			return fmt.Errorf("%w edition: license has no product", ErrInvalidInput)
+		}
+		editions[edition{*l.EditionID, *l.ProductID}] = struct{}{}
+	}
+	for e := range editions {
+		err := c.checkLicenseEdition(ctx, e.id, &e.productID)
+		if err != nil {
+			return err
+		}
+	}
+	customers := make(map[int]struct{})
+	for _, l := range ll {
+		if l.CustomerID != nil {
+			customers[*l.CustomerID] = struct{}{}
+		}
+	}
+	for customerID := range customers {
+		err := c.checkCustomerOwner(ctx, licenseIssuerID, customerID)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.checkLicenseParents(ctx, licenseIssuerID, ll)
+	if err != nil {
+		return err
+	}
+
+	err = c.db.InTx(ctx, func(tx *db.Handler) error {
+		li, err := tx.SelectLicenseIssuerByIDForUpdate(ctx, licenseIssuerID)
+		if err != nil {
+			return err
+		}
+		count, err := tx.SelectLicensesCountByIssuerID(ctx, li.ID)
+		if err != nil {
+			return err
+		}
+		if !li.MaxLicenses.Allows(count + len(ll)) {
+			return fmt.Errorf("max licenses: %w", ErrExceedsLimit)
+		}
+		return tx.InsertLicenses(ctx, ll)
+	})
+	if errors.Is(err, ErrExceedsLimit) {
+		return err
+	}
+	return handleErrDB(err, "creating licenses")
 }
 
+// Returns ErrInvalidInput
 // Returns SensitiveError
-func (c *Core) GetAllLicensesByIssuer(ctx context.Context, licenseIssuerID int) ([]*model.License, error) {
-	ll, err := c.db.SelectAllLicensesByIssuerID(ctx, licenseIssuerID)
-	return ll, handleErrDB(err, "getting all licenses by issuer")
+func (c *Core) GetLicensesByIssuer(ctx context.Context, licenseIssuerID int, f *model.LicenseFilter, opts *model.ListOptions) ([]*model.License, *model.Page, error) {
+	ll, page, err := c.db.SelectLicensesByIssuerID(ctx, licenseIssuerID, f, opts)
+	return ll, page, handleErrDB(err, "getting licenses by issuer")
 }
 
 // Returns ErrNotFound
@@ -80,9 +314,53 @@ func (c *Core) GetLicense(ctx context.Context, licenseID []byte) (*model.License
 	return l, handleErrDB(err, "getting license")
 }
 
+const (
+	// minLookupPrefix is the minimum number of key bytes to look licenses up
+	// by, so that a single lookup can't list many of them.
+	minLookupPrefix = 4
+	// maxLookupLicenses is the maximum number of licenses found by lookup.
+	maxLookupLicenses = 20
+)
+
+// LookupLicenses finds issuer's licenses by key or its beginning. Key can be
+// either formatted or base64 encoded, partial key must be formatted.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) LookupLicenses(ctx context.Context, licenseIssuerID int, key string) ([]*model.License, error) {
+	prefix, err := lookupKeyPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+	ll, err := c.db.SelectLicensesByIssuerIDAndKeyPrefix(ctx, licenseIssuerID, prefix, maxLookupLicenses)
+	return ll, handleErrDB(err, "looking up licenses")
+}
+
+// lookupKeyPrefix parses full or partial license key into key bytes to look
+// licenses up by.
+func lookupKeyPrefix(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	if bs, err := util.ParseFormattedKey(key); err == nil {
+		return bs, nil
+	}
+	if bs, err := base64.RawStdEncoding.DecodeString(strings.TrimSuffix(key, "=")); err == nil && len(bs) == 32 {
+		return bs, nil
+	}
+	prefix, err := util.ParseFormattedKeyPrefix(key)
+	if err != nil || len(prefix) < minLookupPrefix {
+		return nil, fmt.Errorf("%w key", ErrInvalidInput)
+	}
+	return prefix, nil
+}
+
+// UpdateLicense applies changes to the license. If ifMatch isn't nil, license
+// is updated only if it hasn't been updated since any of ifMatch timestamps.
+//
+// Returns ErrNotFound
 // Returns ErrInvalidInput
+// Returns ErrPreconditionFailed
 // Returns SensitiveError
-func (c *Core) UpdateLicense(ctx context.Context, l *model.License, changes map[string]struct{}) error {
+func (c *Core) UpdateLicense(ctx context.Context, l *model.License, changes map[string]struct{}, ifMatch []time.Time) error {
 	update := map[string]interface{}{
 		"updated": time.Now(),
 	}
@@ -108,6 +386,12 @@ func (c *Core) UpdateLicense(ctx context.Context, l *model.License, changes map[
 		}
 		update["end_user_email"] = l.EndUserEmail
 	}
+	if _, ok := changes["features"]; ok {
+		if !ValidLicenseFeatures(l.Features) {
+			return fmt.Errorf("%w features", ErrInvalidInput)
+		}
+		update["features"] = pq.Array(l.Features)
+	}
 	if _, ok := changes["note"]; ok {
 		if !ValidLicenseNote(l.Note) {
 			return fmt.Errorf("%w note", ErrInvalidInput)
@@ -115,6 +399,18 @@ func (c *Core) UpdateLicense(ctx context.Context, l *model.License, changes map[
 		update["note"] = l.Note
 	}
 	if _, ok := changes["data"]; ok {
+		cur, err := c.GetLicense(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		s, err := c.productDataSchema(ctx, cur.ProductID)
+		if err != nil {
+			return err
+		}
+		err = validateData(s, l.Data)
+		if err != nil {
+			return err
+		}
 		update["data"] = l.Data
 	}
 	if _, ok := changes["maxSessions"]; ok {
@@ -129,18 +425,178 @@ func (c *Core) UpdateLicense(ctx context.Context, l *model.License, changes map[
 	if _, ok := changes["lastUsed"]; ok {
 		update["last_used"] = l.LastUsed
 	}
+	if _, ok := changes["parentID"]; ok {
+		if l.ParentID != nil {
+			err := c.checkLicenseParent(ctx, l.IssuerID, l.ID, l.ParentID)
+			if err != nil {
+				return err
+			}
+			addons, err := c.GetLicenseAddons(ctx, l.ID)
+			if err != nil {
+				return err
+			}
+			if len(addons) > 0 {
+				return fmt.Errorf("%w parent id: license has add-ons", ErrInvalidInput)
+			}
+		}
+		update["parent_id"] = l.ParentID
+	}
+	if _, ok := changes["editionID"]; ok {
+		if l.EditionID != nil {
+			cur, err := c.GetLicense(ctx, l.ID)
+			if err != nil {
+				return err
+			}
+			err = c.checkLicenseEdition(ctx, *l.EditionID, cur.ProductID)
+			if err != nil {
+				return err
+			}
+		}
+		update["edition_id"] = l.EditionID
+	}
+	if _, ok := changes["channels"]; ok {
+		if !ValidReleaseChannels(l.Channels) {
+			return fmt.Errorf("%w channels", ErrInvalidInput)
+		}
+		update["channels"] = pq.Array(l.Channels)
+	}
+	if _, ok := changes["quotas"]; ok {
+		if !ValidUsageQuotas(l.Quotas) {
+			return fmt.Errorf("%w quotas", ErrInvalidInput)
+		}
+		update["quotas"] = l.Quotas
+	}
+	if _, ok := changes["customerID"]; ok {
+		if l.CustomerID != nil {
+			err := c.checkCustomerOwner(ctx, l.IssuerID, *l.CustomerID)
+			if err != nil {
+				return err
+			}
+		}
+		update["customer_id"] = l.CustomerID
+	}
+	if _, ok := changes["updatesUntil"]; ok {
+		update["updates_until"] = l.UpdatesUntil
+	}
+	if _, ok := changes["maxTransfers"]; ok {
+		if !ValidMaxTransfers(l.MaxTransfers) {
+			return fmt.Errorf("%w max transfers", ErrInvalidInput)
+		}
+		update["max_transfers"] = l.MaxTransfers
+	}
+	if _, ok := changes["transferCooldown"]; ok {
+		if l.TransferCooldown != nil && *l.TransferCooldown < 0 {
+			return fmt.Errorf("%w transfer cooldown", ErrInvalidInput)
+		}
+		update["transfer_cooldown"] = l.TransferCooldown
+	}
 
-	err := c.db.UpdateLicense(ctx, l.ID, l.IssuerID, update)
+	err := c.db.UpdateLicense(ctx, l.ID, l.IssuerID, update, ifMatch)
 	return handleErrDB(err, "updating license")
 }
 
+// DeleteLicense moves the license with its add-ons to trash and closes their
+// sessions. If ifMatch isn't nil, license is deleted only if it hasn't been
+// updated since any of ifMatch timestamps.
+//
 // Returns ErrNotFound
+// Returns ErrPreconditionFailed
 // Returns SensitiveError
-func (c *Core) DeleteLicense(ctx context.Context, licenseID []byte, licenseIssuerID int) error {
-	_, err := c.db.DeleteLicenseByID(ctx, licenseID, licenseIssuerID)
+func (c *Core) DeleteLicense(ctx context.Context, licenseID []byte, licenseIssuerID int, ifMatch []time.Time) error {
+	now := time.Now()
+	err := c.db.InTx(ctx, func(tx *db.Handler) error {
+		_, err := tx.SoftDeleteLicenseByID(ctx, licenseID, licenseIssuerID, now, ifMatch)
+		if err != nil {
+			return err
+		}
+		_, err = tx.SoftDeleteLicensesByParentID(ctx, licenseID, now)
+		if err != nil {
+			return err
+		}
+		_, err = tx.DeleteLicenseSessionsByLicenseID(ctx, licenseID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+		_, err = tx.DeleteLicenseSessionsByParentID(ctx, licenseID, now)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil // No sessions
+		}
+		return err
+	})
 	return handleErrDB(err, "deleting license")
 }
 
+// RestoreLicense restores the license from trash together with add-ons, which
+// were deleted with it. License of a deleted product or an add-on of a deleted
+// license can't be restored.
+//
+// Returns ErrNotFound
+// Returns ErrInvalidInput
+// Returns ErrExceedsLimit
+// Returns SensitiveError
+func (c *Core) RestoreLicense(ctx context.Context, licenseID []byte, licenseIssuerID int) (*model.License, error) {
+	err := c.db.InTx(ctx, func(tx *db.Handler) error {
+		li, err := tx.SelectLicenseIssuerByIDForUpdate(ctx, licenseIssuerID)
+		if err != nil {
+			return err
+		}
+		l, err := tx.SelectDeletedLicenseByID(ctx, licenseID, li.ID)
+		if err != nil {
+			return err
+		}
+		if l.ProductID != nil {
+			_, err = tx.SelectProductByID(ctx, *l.ProductID)
+			if errors.Is(err, db.ErrNotFound) {
+				return fmt.Errorf("%w product: product is deleted", ErrInvalidInput)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if l.ParentID != nil {
+			_, err = tx.SelectLicenseByID(ctx, l.ParentID)
+			if errors.Is(err, db.ErrNotFound) {
+				return fmt.Errorf("%w parent id: parent license is deleted", ErrInvalidInput)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.RestoreLicenseByID(ctx, l.ID, li.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.RestoreLicensesByParentID(ctx, l.ID, *l.Deleted)
+		if err != nil {
+			return err
+		}
+		return c.checkMaxLicenses(ctx, tx, li)
+	})
+	if errors.Is(err, ErrInvalidInput) || errors.Is(err, ErrExceedsLimit) {
+		return nil, err
+	}
+	err = handleErrDB(err, "restoring license")
+	if err != nil {
+		return nil, err
+	}
+	return c.GetLicense(ctx, licenseID)
+}
+
+// checkMaxLicenses checks whether issuer's licenses, including restored ones,
+// don't exceed the limit. Issuer must be locked by the transaction.
+//
+// Returns ErrExceedsLimit
+func (c *Core) checkMaxLicenses(ctx context.Context, tx *db.Handler, li *model.LicenseIssuer) error {
+	count, err := tx.SelectLicensesCountByIssuerID(ctx, li.ID)
+	if err != nil {
+		return err
+	}
+	if !li.MaxLicenses.Allows(count) {
+		return fmt.Errorf("max licenses: %w", ErrExceedsLimit)
+	}
+	return nil
+}
+
 func (c *Core) AuthorizeLicenseUpdate(login *model.LicenseIssuer) (updateMask []string, delete bool) {
-	return []string{"active", "name", "tags", "endUserEmail", "note", "data", "maxSessions", "validUntil", "productID"}, true
+	return []string{"active", "name", "tags", "features", "endUserEmail", "note", "data", "maxSessions", "validUntil", "productID", "maxTransfers", "transferCooldown", "parentID", "editionID", "channels", "updatesUntil", "quotas", "customerID"}, true
 }
@@ -0,0 +1,105 @@
+package core
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_importedLicense(t *testing.T) {
+	id, key, err := util.GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+	otherID, _, err := util.GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+
+	li := &model.LicenseIssuer{ID: 5}
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		req     *model.License
+		want    *model.License
+		wantErr error
+	}{
+		{
+			name: "ok",
+			req:  &model.License{ID: id, Key: key, Name: "imported", MaxSessions: 1, Created: created, IssuerID: 1},
+			want: &model.License{ID: id, Key: key, Name: "imported", Tags: []string{}, Features: []string{}, Channels: []string{}, Quotas: model.UsageQuotas{}, MaxSessions: 1, Created: created, Updated: now, IssuerID: 5},
+		},
+		{
+			name: "server managed fields",
+			req:  &model.License{ID: id, Key: key, MaxSessions: 1, Created: created, LastUsed: &created, Deleted: &created, TemplateID: &li.ID},
+			want: &model.License{ID: id, Key: key, Tags: []string{}, Features: []string{}, Channels: []string{}, Quotas: model.UsageQuotas{}, MaxSessions: 1, Created: created, Updated: now, IssuerID: 5},
+		},
+		{
+			name:    "id mismatch",
+			req:     &model.License{ID: otherID, Key: key, MaxSessions: 1},
+			wantErr: ErrInvalidInput,
+		},
+		{
+			name:    "invalid key",
+			req:     &model.License{ID: id, Key: key[:16], MaxSessions: 1},
+			wantErr: ErrInvalidInput,
+		},
+		{
+			name:    "invalid max sessions",
+			req:     &model.License{ID: id, Key: key},
+			wantErr: ErrInvalidInput,
+		},
+		{
+			name:    "nil",
+			wantErr: ErrInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importedLicense(li, tt.req, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_lookupKeyPrefix(t *testing.T) {
+	_, key, err := util.GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+	formatted := util.FormatKey(key)
+
+	tests := []struct {
+		name    string
+		key     string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "formatted", key: formatted, want: key},
+		{name: "base64", key: base64.StdEncoding.EncodeToString(key), want: key},
+		{name: "partial", key: formatted[:11], want: key[:6]},
+		{name: "partial lower", key: strings.ToLower(formatted[:11]), want: key[:6]},
+		{name: "too short", key: formatted[:5], wantErr: true},
+		{name: "invalid", key: "not a key!", wantErr: true},
+		{name: "empty", key: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupKeyPrefix(tt.key)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidInput)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// Returns SensitiveError
+func (c *Core) GetLicenseAddons(ctx context.Context, licenseID []byte) ([]*model.License, error) {
+	ll, err := c.db.SelectLicensesByParentID(ctx, licenseID)
+	return ll, handleErrDB(err, "getting license add-ons")
+}
+
+// GetActiveLicenseAddons returns add-ons of the license, which are active and
+// not expired.
+//
+// Returns SensitiveError
+func (c *Core) GetActiveLicenseAddons(ctx context.Context, l *model.License) ([]*model.License, error) {
+	ll, err := c.GetLicenseAddons(ctx, l.ID)
+	if err != nil {
+		return nil, err
+	}
+	return activeAddons(ll, time.Now()), nil
+}
+
+func activeAddons(ll []*model.License, now time.Time) []*model.License {
+	active := make([]*model.License, 0, len(ll))
+	for _, l := range ll {
+		if !l.Active || (l.ValidUntil != nil && l.ValidUntil.Before(now)) {
+			continue
+		}
+		active = append(active, l)
+	}
+	return active
+}
+
+// LicenseFeatures returns features of the license merged with features of
+// its edition and add-ons, without duplicates. Edition may be nil.
+func LicenseFeatures(l *model.License, e *model.ProductEdition, addons []*model.License) []string {
+	features := appendUnique(make([]string, 0, len(l.Features)), l.Features...)
+	if e != nil {
+		features = appendUnique(features, e.Features...)
+	}
+	for _, a := range addons {
+		features = appendUnique(features, a.Features...)
+	}
+	return features
+}
+
+// checkLicenseParent checks whether license can become an add-on of the
+// parent, i.e., parent exists, belongs to the issuer and isn't an add-on
+// itself.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) checkLicenseParent(ctx context.Context, licenseIssuerID int, licenseID, parentID []byte) error {
+	if bytes.Equal(licenseID, parentID) {
+		return fmt.Errorf("%w parent id", ErrInvalidInput)
+	}
+	parent, err := c.db.SelectLicenseByID(ctx, parentID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return handleErrDB(err, "getting parent license")
+	}
+	if err != nil || parent.IssuerID != licenseIssuerID || parent.ParentID != nil {
+		return fmt.Errorf("%w parent id", ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkLicenseParents checks parents of licenses inserted at once. Parent may
+// be one of the licenses inserted.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) checkLicenseParents(ctx context.Context, licenseIssuerID int, ll []*model.License) error {
+	inserted := make(map[string]*model.License, len(ll))
+	for _, l := range ll {
+		inserted[string(l.ID)] = l
+	}
+	checked := make(map[string]struct{})
+	for _, l := range ll {
+		if l.ParentID == nil {
+			continue
+		}
+		if parent, ok := inserted[string(l.ParentID)]; ok {
+			if parent.ParentID != nil || parent == l {
+				return fmt.Errorf("%w parent id", ErrInvalidInput)
+			}
+			continue
+		}
+		if _, ok := checked[string(l.ParentID)]; ok {
+			continue
+		}
+		err := c.checkLicenseParent(ctx, licenseIssuerID, l.ID, l.ParentID)
+		if err != nil {
+			return err
+		}
+		checked[string(l.ParentID)] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_activeAddons(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	active := &model.License{Name: "active", Active: true}
+	valid := &model.License{Name: "valid", Active: true, ValidUntil: &future}
+	ll := []*model.License{
+		active,
+		{Name: "inactive", Active: false},
+		{Name: "expired", Active: true, ValidUntil: &past},
+		valid,
+	}
+	assert.Equal(t, []*model.License{active, valid}, activeAddons(ll, now))
+	assert.Equal(t, []*model.License{}, activeAddons(nil, now))
+}
+
+func TestLicenseFeatures(t *testing.T) {
+	l := &model.License{Features: []string{"pro", "export"}}
+	addons := []*model.License{
+		{Features: []string{"export", "sync"}},
+		{Features: []string{}},
+		{Features: []string{"ai", "sync"}},
+	}
+	e := &model.ProductEdition{Features: []string{"reports", "pro"}}
+	assert.Equal(t, []string{"pro", "export", "sync", "ai"}, LicenseFeatures(l, nil, addons))
+	assert.Equal(t, []string{"pro", "export", "reports", "sync", "ai"}, LicenseFeatures(l, e, addons))
+	assert.Equal(t, []string{"pro", "export"}, LicenseFeatures(l, nil, nil))
+	assert.Equal(t, []string{}, LicenseFeatures(&model.License{}, nil, nil))
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	cryptorand "crypto/rand"
+
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+const (
+	creditReceiptIDLen = 16
+	maxCreditAmount    = 1 << 40
+)
+
+// GetLicenseCredits returns license's credit balances.
+//
+// Returns SensitiveError
+func (c *Core) GetLicenseCredits(ctx context.Context, licenseID []byte) ([]*model.LicenseCredit, error) {
+	cc, err := c.db.SelectLicenseCreditsByLicenseID(ctx, licenseID)
+	return cc, handleErrDB(err, "getting license credits")
+}
+
+// AddLicenseCredits tops up license's credit balance of the feature.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) AddLicenseCredits(ctx context.Context, licenseID []byte, feature string, amount int64) (*model.LicenseCredit, error) {
+	if !ValidLicenseFeatures([]string{feature}) {
+		return nil, fmt.Errorf("%w feature", ErrInvalidInput)
+	}
+	if amount < 1 || amount > maxCreditAmount {
+		return nil, fmt.Errorf("%w amount", ErrInvalidInput)
+	}
+	now := time.Now()
+	balance, err := c.db.AddLicenseCredits(ctx, licenseID, feature, amount, now)
+	if err != nil {
+		return nil, handleErrDB(err, "adding license credits")
+	}
+	return &model.LicenseCredit{
+		LicenseID: licenseID,
+		Feature:   feature,
+		Balance:   balance,
+		Updated:   now,
+	}, nil
+}
+
+// GetCreditReceipts returns license's credit receipts created in [from; to),
+// latest first. Nil bounds are ignored.
+//
+// Returns SensitiveError
+func (c *Core) GetCreditReceipts(ctx context.Context, licenseID []byte, from, to *time.Time) ([]*model.CreditReceipt, error) {
+	rr, err := c.db.SelectCreditReceiptsByLicenseID(ctx, licenseID, from, to)
+	return rr, handleErrDB(err, "getting credit receipts")
+}
+
+// ConsumeLicenseCredits atomically consumes amount of license's credits of
+// the feature. Returns receipt signed by the server key.
+//
+// Receipt ID is chosen by the client: consuming with ID of an existing
+// receipt returns that receipt without consuming again, so that requests can
+// be safely retried.
+//
+// Returns ErrTimeOutOfSync
+// Returns ErrInvalidInput
+// Returns ErrInsufficientCredits
+// Returns ErrLicenseExpired
+// Returns ErrLicenseInactive
+// Returns ErrLicenseIsAddon
+// Returns ErrProductInactive
+// Returns ErrLicenseIssuerDisabled
+// Returns ErrLicenseSessionExpired
+// Returns ErrDuplicate
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) ConsumeLicenseCredits(ctx context.Context, ls *model.LicenseSession, l *model.License, clientTime time.Time, receiptID []byte, feature string, amount int64) (receipt, sig []byte, err error) {
+	now := time.Now()
+	if !c.timeInSync(now, clientTime) {
+		return nil, nil, ErrTimeOutOfSync
+	}
+	if now.After(ls.Expire) {
+		return nil, nil, ErrLicenseSessionExpired
+	}
+	if len(receiptID) != creditReceiptIDLen {
+		return nil, nil, fmt.Errorf("%w receipt id", ErrInvalidInput)
+	}
+	if !ValidLicenseFeatures([]string{feature}) {
+		return nil, nil, fmt.Errorf("%w feature", ErrInvalidInput)
+	}
+	if amount < 1 || amount > maxCreditAmount {
+		return nil, nil, fmt.Errorf("%w amount", ErrInvalidInput)
+	}
+	err = c.checkLicenseUsable(ctx, l, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rc, err := c.db.SelectCreditReceiptByID(ctx, receiptID)
+	switch {
+	case err == nil:
+		// Retried request
+		if !sameCreditReceipt(rc, l.ID, feature, amount) {
+			return nil, nil, fmt.Errorf("%w receipt id", ErrInvalidInput)
+		}
+		return c.signCreditReceipt(rc)
+	case !errors.Is(err, db.ErrNotFound):
+		return nil, nil, handleErrDB(err, "getting credit receipt")
+	}
+
+	rc = &model.CreditReceipt{
+		ID:              receiptID,
+		LicenseID:       l.ID,
+		ClientSessionID: ls.ClientID,
+		Feature:         feature,
+		Amount:          amount,
+		Created:         now,
+	}
+	err = c.db.InTx(ctx, func(tx *db.Handler) error {
+		balance, ok, err := tx.ConsumeLicenseCredits(ctx, l.ID, feature, amount, now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInsufficientCredits
+		}
+		rc.Balance = balance
+		// Concurrent retry fails on duplicate ID and is rolled back.
+		return tx.InsertCreditReceipt(ctx, rc)
+	})
+	switch {
+	case errors.Is(err, ErrInsufficientCredits):
+		return nil, nil, err
+	case err != nil:
+		return nil, nil, handleErrDB(err, "consuming license credits")
+	}
+	return c.signCreditReceipt(rc)
+}
+
+// sameCreditReceipt reports whether receipt is of the same consumption.
+func sameCreditReceipt(rc *model.CreditReceipt, licenseID []byte, feature string, amount int64) bool {
+	return bytes.Equal(rc.LicenseID, licenseID) &&
+		rc.Feature == feature &&
+		rc.Amount == amount
+}
+
+func (c *Core) signCreditReceipt(rc *model.CreditReceipt) (receipt, sig []byte, err error) {
+	receipt, err = json.Marshal(rc)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err = util.Sign(cryptorand.Reader, c.serverKey, receipt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return receipt, sig, nil
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sameCreditReceipt(t *testing.T) {
+	rc := &model.CreditReceipt{
+		LicenseID: []byte{1, 2, 3},
+		Feature:   "export",
+		Amount:    3,
+	}
+	assert.True(t, sameCreditReceipt(rc, []byte{1, 2, 3}, "export", 3))
+	assert.False(t, sameCreditReceipt(rc, []byte{1, 2, 4}, "export", 3))
+	assert.False(t, sameCreditReceipt(rc, []byte{1, 2, 3}, "print", 3))
+	assert.False(t, sameCreditReceipt(rc, []byte{1, 2, 3}, "export", 4))
+}
+
+func TestCore_signCreditReceipt(t *testing.T) {
+	id, key, err := util.GenerateKey(bytes.NewReader(bytes.Repeat([]byte{7}, 32)))
+	require.NoError(t, err)
+	c := &Core{serverID: id, serverKey: key}
+	rc := &model.CreditReceipt{
+		ID:              bytes.Repeat([]byte{1}, creditReceiptIDLen),
+		LicenseID:       []byte{1, 2, 3},
+		ClientSessionID: []byte{4, 5, 6},
+		Feature:         "export",
+		Amount:          3,
+		Balance:         7,
+		Created:         time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	receipt, sig, err := c.signCreditReceipt(rc)
+	require.NoError(t, err)
+	assert.True(t, util.Verify(id, receipt, sig))
+
+	var got model.CreditReceipt
+	require.NoError(t, json.Unmarshal(receipt, &got))
+	assert.Equal(t, rc, &got)
+}
@@ -2,10 +2,12 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/sewiti/licensing-system/internal/core/auth"
+	"github.com/sewiti/licensing-system/internal/db"
 	"github.com/sewiti/licensing-system/internal/model"
 )
 
@@ -51,28 +53,13 @@ func (c *Core) NewLicenseIssuer(ctx context.Context, username, password, email,
 	return li, handleErrDB(err, "creating license issuer")
 }
 
+// Returns ErrInvalidInput
 // Returns SensitiveError
-func (c *Core) GetAllLicenseIssuers(ctx context.Context) ([]*model.LicenseIssuer, error) {
-	lii, err := c.db.SelectAllLicenseIssuers(ctx)
-	return lii, handleErrDB(err, "getting all license issuers")
+func (c *Core) GetLicenseIssuers(ctx context.Context, f *model.LicenseIssuerFilter, opts *model.ListOptions) ([]*model.LicenseIssuer, *model.Page, error) {
+	lii, page, err := c.db.SelectLicenseIssuers(ctx, f, opts)
+	return lii, page, handleErrDB(err, "getting license issuers")
 }
 
-// // Returns SensitiveError
-// func (c *Core) GetPartLicenseIssuers(ctx context.Context, limit, offset int) (lii []*model.LicenseIssuer, total int, err error) {
-// 	lii, err = c.db.SelectPartLicenseIssuers(ctx, limit, offset)
-// 	if err != nil {
-// 		return nil, 0, handleErrDB(err, "getting part of license issuers")
-// 	}
-// 	total, err = c.db.SelectCountLicenseIssuers(ctx)
-// 	return lii, total, handleErrDB(err, "getting count of license issuers")
-// }
-
-// // Returns SensitiveError
-// func (c *Core) SearchLicenseIssuers(ctx context.Context, username string, limit int) ([]*model.LicenseIssuer, error) {
-// 	lii, err := c.db.SelectLicenseIssuersContainUsername(ctx, username, limit)
-// 	return lii, handleErrDB(err, "searching license issuers by username")
-// }
-
 // Returns ErrNotFound
 // Returns SensitiveError
 func (c *Core) GetLicenseIssuerByUsername(ctx context.Context, licenseIssuerUsername string) (*model.LicenseIssuer, error) {
@@ -87,15 +74,20 @@ func (c *Core) GetLicenseIssuer(ctx context.Context, licenseIssuerID int) (*mode
 	return li, handleErrDB(err, "getting license issuer by id")
 }
 
+// UpdateLicenseIssuer applies changes to the license issuer. If ifMatch isn't
+// nil, issuer is updated only if it hasn't been updated since any of ifMatch
+// timestamps.
+//
 // Returns ErrSuperadminImmutable
 // Returns ErrInvalidInput
 // Returns ErrNotFound
+// Returns ErrPreconditionFailed
 // Returns SensitiveError
-func (c *Core) UpdateLicenseIssuer(ctx context.Context, li *model.LicenseIssuer, changes map[string]struct{}) error {
+func (c *Core) UpdateLicenseIssuer(ctx context.Context, li *model.LicenseIssuer, changes map[string]struct{}, ifMatch []time.Time) error {
 	if li.ID == 0 {
 		return ErrSuperadminImmutable
 	}
-	return c.updateLicenseIssuer(ctx, li, changes)
+	return c.updateLicenseIssuer(ctx, li, changes, ifMatch)
 }
 
 // UpdateLicenseIssuerBypass
@@ -104,10 +96,10 @@ func (c *Core) UpdateLicenseIssuer(ctx context.Context, li *model.LicenseIssuer,
 // Returns ErrNotFound
 // Returns SensitiveError
 func (c *Core) UpdateLicenseIssuerBypass(ctx context.Context, li *model.LicenseIssuer, changes map[string]struct{}) error {
-	return c.updateLicenseIssuer(ctx, li, changes)
+	return c.updateLicenseIssuer(ctx, li, changes, nil)
 }
 
-func (c *Core) updateLicenseIssuer(ctx context.Context, li *model.LicenseIssuer, changes map[string]struct{}) error {
+func (c *Core) updateLicenseIssuer(ctx context.Context, li *model.LicenseIssuer, changes map[string]struct{}, ifMatch []time.Time) error {
 	update := map[string]interface{}{
 		"updated": time.Now(),
 	}
@@ -144,21 +136,74 @@ func (c *Core) updateLicenseIssuer(ctx context.Context, li *model.LicenseIssuer,
 		update["max_licenses"] = li.MaxLicenses
 	}
 
-	err := c.db.UpdateLicenseIssuer(ctx, li.ID, update)
+	err := c.db.UpdateLicenseIssuer(ctx, li.ID, update, ifMatch)
 	return handleErrDB(err, "updating license issuer")
 }
 
+// DeleteLicenseIssuer moves the license issuer with its products and licenses
+// to trash and closes sessions of these licenses. If ifMatch isn't nil, issuer
+// is deleted only if it hasn't been updated since any of ifMatch timestamps.
+//
 // Returns ErrSuperadminImmutable
 // Returns ErrNotFound
+// Returns ErrPreconditionFailed
 // Returns SensitiveError
-func (c *Core) DeleteLicenseIssuer(ctx context.Context, licenseIssuerID int) error {
+func (c *Core) DeleteLicenseIssuer(ctx context.Context, licenseIssuerID int, ifMatch []time.Time) error {
 	if licenseIssuerID == 0 {
 		return ErrSuperadminImmutable
 	}
-	_, err := c.db.DeleteLicenseIssuerByID(ctx, licenseIssuerID)
+	now := time.Now()
+	err := c.db.InTx(ctx, func(tx *db.Handler) error {
+		_, err := tx.SoftDeleteLicenseIssuerByID(ctx, licenseIssuerID, now, ifMatch)
+		if err != nil {
+			return err
+		}
+		_, err = tx.SoftDeleteProductsByIssuerID(ctx, licenseIssuerID, now)
+		if err != nil {
+			return err
+		}
+		_, err = tx.SoftDeleteLicensesByIssuerID(ctx, licenseIssuerID, now)
+		if err != nil {
+			return err
+		}
+		_, err = tx.DeleteLicenseSessionsByIssuerID(ctx, licenseIssuerID, now)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil // No sessions
+		}
+		return err
+	})
 	return handleErrDB(err, "deleting license issuer")
 }
 
+// RestoreLicenseIssuer restores the license issuer from trash together with
+// products and licenses, which were deleted with it.
+//
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) RestoreLicenseIssuer(ctx context.Context, licenseIssuerID int) (*model.LicenseIssuer, error) {
+	err := c.db.InTx(ctx, func(tx *db.Handler) error {
+		li, err := tx.SelectDeletedLicenseIssuerByID(ctx, licenseIssuerID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.RestoreLicenseIssuerByID(ctx, li.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.RestoreProductsByIssuerID(ctx, li.ID, *li.Deleted)
+		if err != nil {
+			return err
+		}
+		_, err = tx.RestoreLicensesByIssuerID(ctx, li.ID, *li.Deleted)
+		return err
+	})
+	err = handleErrDB(err, "restoring license issuer")
+	if err != nil {
+		return nil, err
+	}
+	return c.GetLicenseIssuer(ctx, licenseIssuerID)
+}
+
 func (c *Core) AuthorizeLicenseIssuerUpdate(login *model.LicenseIssuer) (mask []string, delete bool) {
 	if c.IsPrivileged(login) {
 		// Privileged user can manage most of the account
@@ -8,18 +8,56 @@ import (
 	cryptorand "crypto/rand"
 	mathrand "math/rand"
 
+	"github.com/sewiti/licensing-system/internal/db"
 	"github.com/sewiti/licensing-system/internal/model"
 	"github.com/sewiti/licensing-system/pkg/util"
 )
 
+// LicenseEntitlement is what license session is served with besides the
+// license itself.
+type LicenseEntitlement struct {
+	Product       *model.Product        // Empty if license has no product.
+	Edition       *model.ProductEdition // Empty if license has no edition.
+	Addons        []*model.License      // Active add-ons.
+	QuotaExceeded []string
+}
+
+// licenseEntitlement loads entitlement of the license with the product given.
+// Quotas exceeded aren't loaded.
+//
+// Returns SensitiveError
+func (c *Core) licenseEntitlement(ctx context.Context, l *model.License, p *model.Product) (*LicenseEntitlement, error) {
+	addons, err := c.GetActiveLicenseAddons(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	e, err := c.GetLicenseEdition(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		e = &model.ProductEdition{}
+	}
+	return &LicenseEntitlement{
+		Product: p,
+		Edition: e,
+		Addons:  addons,
+	}, nil
+}
+
+// NewLicenseSession creates license session. Entitlement is loaded before the
+// session is created, so that nothing is left behind on failure.
+//
 // Returns ErrTimeOutOfSync
 // Returns ErrLicenseExpired
 // Returns ErrLicenseInactive
+// Returns ErrLicenseIsAddon
 // Returns ErrProductInactive
 // Returns ErrRateLimitReached
 // Returns ErrLicenseIssuerDisabled
 // Returns SensitiveError
-func (c *Core) NewLicenseSession(ctx context.Context, l *model.License, clientSessionID []byte, identifier string, machineID []byte, appVersion string, clientTime time.Time) (ls *model.LicenseSession, p *model.Product, refresh time.Time, err error) {
+func (c *Core) NewLicenseSession(ctx context.Context, l *model.License, clientSessionID []byte, identifier string, machineID []byte, appVersion string, clientTime time.Time) (ls *model.LicenseSession, ent *LicenseEntitlement, refresh time.Time, err error) {
+	defer func() { observeLicenseSession(opCreated, err) }()
 	if len(clientSessionID) != 32 {
 		return nil, nil, time.Time{}, fmt.Errorf("%w client session id", ErrInvalidInput)
 	}
@@ -30,11 +68,17 @@ func (c *Core) NewLicenseSession(ctx context.Context, l *model.License, clientSe
 	if !l.Active {
 		return nil, nil, time.Time{}, ErrLicenseInactive
 	}
+	if l.ParentID != nil {
+		return nil, nil, time.Time{}, ErrLicenseIsAddon
+	}
 	if l.ValidUntil != nil && l.ValidUntil.Before(now) {
 		return nil, nil, time.Time{}, ErrLicenseExpired
 	}
-	rl := c.lim.get(l)
-	if !rl.Allow() {
+	allowed, err := c.sessionLimiter().allow(ctx, l)
+	if err != nil {
+		return nil, nil, time.Time{}, handleErrDB(err, "limiting license sessions")
+	}
+	if !allowed {
 		return nil, nil, time.Time{}, ErrRateLimitReached
 	}
 	li, err := c.GetLicenseIssuer(ctx, l.IssuerID)
@@ -44,6 +88,7 @@ func (c *Core) NewLicenseSession(ctx context.Context, l *model.License, clientSe
 	if !li.Active {
 		return nil, nil, time.Time{}, ErrLicenseIssuerDisabled
 	}
+	var p *model.Product
 	if l.ProductID != nil {
 		p, err = c.GetProduct(ctx, *l.ProductID)
 		if err != nil {
@@ -57,6 +102,15 @@ func (c *Core) NewLicenseSession(ctx context.Context, l *model.License, clientSe
 	}
 	// Max sessions are taken care of by the cleanup routine.
 
+	ent, err = c.licenseEntitlement(ctx, l, p)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	ent.QuotaExceeded, err = quotaExceeded(ctx, c.db, l, now)
+	if err != nil {
+		return nil, nil, time.Time{}, handleErrDB(err, "getting license usage")
+	}
+
 	serverID, serverKey, err := util.GenerateKey(cryptorand.Reader)
 	if err != nil {
 		return nil, nil, time.Time{}, err
@@ -80,21 +134,27 @@ func (c *Core) NewLicenseSession(ctx context.Context, l *model.License, clientSe
 	// if err != nil && !errors.Is(err, ErrNotFound) {
 	// 	return nil, time.Time{}, err
 	// }
-	err = c.db.UpdateLicense(ctx, l.ID, l.IssuerID, map[string]interface{}{
-		"last_used": now,
+	err = c.db.InTx(ctx, func(tx *db.Handler) error {
+		err := tx.UpdateLicense(ctx, l.ID, l.IssuerID, map[string]interface{}{
+			"last_used": now,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		return tx.InsertLicenseSession(ctx, s)
 	})
-	err = handleErrDB(err, "updating license")
+	err = handleErrDB(err, "creating license session")
 	if err != nil {
 		return nil, nil, time.Time{}, err
 	}
-	err = c.db.InsertLicenseSession(ctx, s)
-	return s, p, refresh, handleErrDB(err, "creating license session")
+	return s, ent, refresh, nil
 }
 
+// Returns ErrInvalidInput
 // Returns SensitiveError
-func (c *Core) GetAllLicenseSessionsByLicense(ctx context.Context, licenseID []byte) ([]*model.LicenseSession, error) {
-	lss, err := c.db.SelectAllLicenseSessionsByLicenseID(ctx, licenseID)
-	return lss, handleErrDB(err, "getting all license sessions")
+func (c *Core) GetLicenseSessionsByLicense(ctx context.Context, licenseID []byte, f *model.LicenseSessionFilter, opts *model.ListOptions) ([]*model.LicenseSession, *model.Page, error) {
+	lss, page, err := c.db.SelectLicenseSessionsByLicenseID(ctx, licenseID, f, opts)
+	return lss, page, handleErrDB(err, "getting license sessions")
 }
 
 // Returns ErrNotFound
@@ -104,15 +164,26 @@ func (c *Core) GetLicenseSession(ctx context.Context, clientSessionID []byte) (*
 	return ls, handleErrDB(err, "getting license session")
 }
 
+// UpdateLicenseSession refreshes license session and adds usage reported by
+// its client in a single transaction. Entitlement is loaded before the
+// session is refreshed, quotas exceeded - within the transaction.
+//
+// Returns ErrInvalidInput
 // Returns ErrTimeOutOfSync
 // Returns ErrLicenseExpired
 // Returns ErrLicenseInactive
+// Returns ErrLicenseIsAddon
 // Returns ErrProductInactive
 // Returns ErrLicenseIssuerDisabled
 // Returns ErrLicenseSessionExpired
 // Returns ErrNotFound
 // Returns SensitiveError
-func (c *Core) UpdateLicenseSession(ctx context.Context, ls *model.LicenseSession, l *model.License, clientTime time.Time) (p *model.Product, refresh time.Time, err error) {
+func (c *Core) UpdateLicenseSession(ctx context.Context, ls *model.LicenseSession, l *model.License, clientTime time.Time, reportID []byte, usage map[string]int64) (ent *LicenseEntitlement, refresh time.Time, err error) {
+	defer func() { observeLicenseSession(opRefreshed, err) }()
+	err = validateUsageReport(reportID, usage)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 	now := time.Now()
 	if !c.timeInSync(now, clientTime) {
 		return nil, time.Time{}, ErrTimeOutOfSync
@@ -120,6 +191,9 @@ func (c *Core) UpdateLicenseSession(ctx context.Context, ls *model.LicenseSessio
 	if !l.Active {
 		return nil, time.Time{}, ErrLicenseInactive
 	}
+	if l.ParentID != nil {
+		return nil, time.Time{}, ErrLicenseIsAddon
+	}
 	if l.ValidUntil != nil && l.ValidUntil.Before(now) {
 		return nil, time.Time{}, ErrLicenseExpired
 	}
@@ -133,6 +207,7 @@ func (c *Core) UpdateLicenseSession(ctx context.Context, ls *model.LicenseSessio
 	if !li.Active {
 		return nil, time.Time{}, ErrLicenseIssuerDisabled
 	}
+	var p *model.Product
 	if l.ProductID != nil {
 		p, err = c.GetProduct(ctx, *l.ProductID)
 		if err != nil {
@@ -146,11 +221,56 @@ func (c *Core) UpdateLicenseSession(ctx context.Context, ls *model.LicenseSessio
 	}
 	// Max sessions are taken care of by the cleanup routine.
 
+	ent, err = c.licenseEntitlement(ctx, l, p)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
 	refresh, expiry := c.calcLicenseSessionTimes(ls.Created, now)
 	ls.Expire = expiry
 
-	err = c.db.UpdateLicenseSession(ctx, ls)
-	return p, refresh, handleErrDB(err, "updating license session")
+	err = c.db.InTx(ctx, func(tx *db.Handler) error {
+		err := tx.UpdateLicenseSession(ctx, ls)
+		if err != nil {
+			return err
+		}
+		err = addLicenseUsage(ctx, tx, l.ID, reportID, usage, now)
+		if err != nil {
+			return err
+		}
+		ent.QuotaExceeded, err = quotaExceeded(ctx, tx, l, now)
+		return err
+	})
+	err = handleErrDB(err, "updating license session")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return ent, refresh, nil
+}
+
+// CloseLicenseSession deletes license session and adds usage reported by its
+// client in a single transaction.
+//
+// Returns ErrInvalidInput
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) CloseLicenseSession(ctx context.Context, ls *model.LicenseSession, reportID []byte, usage map[string]int64) (err error) {
+	defer func() { observeLicenseSession(opClosed, err) }()
+	err = validateUsageReport(reportID, usage)
+	if err != nil {
+		return err
+	}
+	// We don't care about client time when closing session.
+	now := time.Now()
+	err = c.db.InTx(ctx, func(tx *db.Handler) error {
+		err := addLicenseUsage(ctx, tx, ls.LicenseID, reportID, usage, now)
+		if err != nil {
+			return err
+		}
+		_, err = tx.DeleteLicenseSessionBySessionID(ctx, ls.ClientID)
+		return err
+	})
+	return handleErrDB(err, "closing license session")
 }
 
 // Returns ErrNotFound
@@ -158,7 +278,9 @@ func (c *Core) UpdateLicenseSession(ctx context.Context, ls *model.LicenseSessio
 func (c *Core) DeleteLicenseSession(ctx context.Context, clientSessionID []byte) error {
 	// We don't care about client time when deleting session.
 	_, err := c.db.DeleteLicenseSessionBySessionID(ctx, clientSessionID)
-	return handleErrDB(err, "deleting license session")
+	err = handleErrDB(err, "deleting license session")
+	observeLicenseSession(opClosed, err)
+	return err
 }
 
 // timeInSync reports whether client time is in sync with server time, i. e,
@@ -174,8 +296,10 @@ func (c *Core) timeInSync(server, client time.Time) bool {
 //  Refresh time = 2 * uptime (+-jitter%, clamped to min-max)
 //  Expire time  = 2 * refresh time
 func (c *Core) calcLicenseSessionTimes(start, now time.Time) (refresh, expiry time.Time) {
+	conf := c.refreshConf()
+
 	// Random [-jitter; +jitter)
-	jitter := (2.0 * c.refresh.Jitter * mathrand.Float64()) - c.refresh.Jitter
+	jitter := (2.0 * conf.Jitter * mathrand.Float64()) - conf.Jitter
 
 	uptime := now.Sub(start)
 	delay := time.Duration(
@@ -183,10 +307,10 @@ func (c *Core) calcLicenseSessionTimes(start, now time.Time) (refresh, expiry ti
 	)
 
 	// Clamp to [min; max]
-	if delay < c.refresh.Min {
-		delay = c.refresh.Min
-	} else if delay > c.refresh.Max {
-		delay = c.refresh.Max
+	if delay < conf.Min {
+		delay = conf.Min
+	} else if delay > conf.Max {
+		delay = conf.Max
 	}
 	return now.Add(delay), now.Add(2 * delay)
 }
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) NewLicenseTemplate(ctx context.Context, li *model.LicenseIssuer, req *model.LicenseTemplate) (*model.LicenseTemplate, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w request", ErrInvalidInput)
+	}
+	err := validateLicenseTemplate(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ProductID != nil {
+		err = c.checkProductOwner(ctx, li.ID, *req.ProductID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	t := &model.LicenseTemplate{
+		Version:     1,
+		Name:        req.Name,
+		Tags:        req.Tags,
+		Features:    req.Features,
+		Note:        req.Note,
+		Data:        req.Data,
+		MaxSessions: req.MaxSessions,
+		ValidFor:    req.ValidFor,
+		Created:     now,
+		Updated:     now,
+		IssuerID:    li.ID,
+		ProductID:   req.ProductID,
+	}
+	t.ID, err = c.db.InsertLicenseTemplate(ctx, t)
+	return t, handleErrDB(err, "creating license template")
+}
+
+// Returns SensitiveError
+func (c *Core) GetAllLicenseTemplatesByIssuer(ctx context.Context, licenseIssuerID int) ([]*model.LicenseTemplate, error) {
+	tt, err := c.db.SelectAllLicenseTemplatesByIssuerID(ctx, licenseIssuerID)
+	return tt, handleErrDB(err, "getting all license templates by issuer")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) GetLicenseTemplate(ctx context.Context, licenseTemplateID int) (*model.LicenseTemplate, error) {
+	t, err := c.db.SelectLicenseTemplateByID(ctx, licenseTemplateID)
+	return t, handleErrDB(err, "getting license template")
+}
+
+// UpdateLicenseTemplate updates license template, its version is
+// incremented. Already issued licenses are not affected.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) UpdateLicenseTemplate(ctx context.Context, t *model.LicenseTemplate, changes map[string]struct{}) error {
+	update := map[string]interface{}{
+		"updated": time.Now(),
+	}
+
+	if _, ok := changes["name"]; ok {
+		if !ValidLicenseTemplateName(t.Name) {
+			return fmt.Errorf("%w name", ErrInvalidInput)
+		}
+		update["name"] = t.Name
+	}
+	if _, ok := changes["tags"]; ok {
+		if !ValidLicenseTags(t.Tags) {
+			return fmt.Errorf("%w tags", ErrInvalidInput)
+		}
+		update["tags"] = pq.Array(t.Tags)
+	}
+	if _, ok := changes["features"]; ok {
+		if !ValidLicenseFeatures(t.Features) {
+			return fmt.Errorf("%w features", ErrInvalidInput)
+		}
+		update["features"] = pq.Array(t.Features)
+	}
+	if _, ok := changes["note"]; ok {
+		if !ValidLicenseNote(t.Note) {
+			return fmt.Errorf("%w note", ErrInvalidInput)
+		}
+		update["note"] = t.Note
+	}
+	if _, ok := changes["data"]; ok {
+		update["data"] = t.Data
+	}
+	if _, ok := changes["maxSessions"]; ok {
+		if t.MaxSessions <= 0 {
+			return fmt.Errorf("%w max sessions", ErrInvalidInput)
+		}
+		update["max_sessions"] = t.MaxSessions
+	}
+	if _, ok := changes["validFor"]; ok {
+		if t.ValidFor != nil && *t.ValidFor <= 0 {
+			return fmt.Errorf("%w valid for", ErrInvalidInput)
+		}
+		update["valid_for"] = t.ValidFor
+	}
+	if _, ok := changes["productID"]; ok {
+		if t.ProductID != nil {
+			err := c.checkProductOwner(ctx, t.IssuerID, *t.ProductID)
+			if err != nil {
+				return err
+			}
+		}
+		update["product_id"] = t.ProductID
+	}
+
+	err := c.db.UpdateLicenseTemplate(ctx, t.ID, t.IssuerID, update)
+	return handleErrDB(err, "updating license template")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) DeleteLicenseTemplate(ctx context.Context, licenseTemplateID, licenseIssuerID int) error {
+	_, err := c.db.DeleteLicenseTemplateByID(ctx, licenseTemplateID, licenseIssuerID)
+	return handleErrDB(err, "deleting license template")
+}
+
+func (c *Core) AuthorizeLicenseTemplateUpdate(login *model.LicenseIssuer) (updateMask []string, delete bool) {
+	return []string{"name", "tags", "features", "note", "data", "maxSessions", "validFor", "productID"}, true
+}
+
+// ApplyLicenseTemplate fills license request fields, that aren't present in
+// changes, with defaults of the license template req.TemplateID. Template
+// must belong to the issuer.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) ApplyLicenseTemplate(ctx context.Context, licenseIssuerID int, req *model.License, changes map[string]struct{}) error {
+	if req.TemplateID == nil {
+		req.TemplateVersion = nil
+		return nil
+	}
+	t, err := c.GetLicenseTemplate(ctx, *req.TemplateID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err != nil || t.IssuerID != licenseIssuerID {
+		return fmt.Errorf("%w template", ErrInvalidInput)
+	}
+	applyLicenseTemplate(req, t, changes, time.Now())
+	return nil
+}
+
+func applyLicenseTemplate(req *model.License, t *model.LicenseTemplate, changes map[string]struct{}, now time.Time) {
+	if _, ok := changes["tags"]; !ok {
+		req.Tags = t.Tags
+	}
+	if _, ok := changes["features"]; !ok {
+		req.Features = t.Features
+	}
+	if _, ok := changes["note"]; !ok {
+		req.Note = t.Note
+	}
+	if _, ok := changes["data"]; !ok {
+		req.Data = t.Data
+	}
+	if _, ok := changes["maxSessions"]; !ok {
+		req.MaxSessions = t.MaxSessions
+	}
+	if _, ok := changes["validUntil"]; !ok && t.ValidFor != nil {
+		validUntil := now.Add(time.Duration(*t.ValidFor))
+		req.ValidUntil = &validUntil
+	}
+	if _, ok := changes["productID"]; !ok {
+		req.ProductID = t.ProductID
+	}
+	req.TemplateID = &t.ID
+	req.TemplateVersion = &t.Version
+}
+
+func validateLicenseTemplate(t *model.LicenseTemplate) error {
+	if !ValidLicenseTemplateName(t.Name) {
+		return fmt.Errorf("%w name", ErrInvalidInput)
+	}
+	if !ValidLicenseTags(t.Tags) {
+		return fmt.Errorf("%w tags", ErrInvalidInput)
+	}
+	if !ValidLicenseFeatures(t.Features) {
+		return fmt.Errorf("%w features", ErrInvalidInput)
+	}
+	if !ValidLicenseNote(t.Note) {
+		return fmt.Errorf("%w note", ErrInvalidInput)
+	}
+	if t.MaxSessions <= 0 {
+		return fmt.Errorf("%w max sessions", ErrInvalidInput)
+	}
+	if t.ValidFor != nil && *t.ValidFor <= 0 {
+		return fmt.Errorf("%w valid for", ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkProductOwner checks whether product exists and belongs to the issuer.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) checkProductOwner(ctx context.Context, licenseIssuerID, productID int) error {
+	p, err := c.db.SelectProductByID(ctx, productID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return handleErrDB(err, "getting product")
+	}
+	if err != nil || p.IssuerID != licenseIssuerID {
+		return fmt.Errorf("%w product", ErrInvalidInput)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyLicenseTemplate(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	yearLater := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	custom := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	validFor := model.Duration(365 * 24 * time.Hour)
+	productID := 4
+
+	tmpl := &model.LicenseTemplate{
+		ID:          2,
+		Version:     3,
+		Name:        "Yearly",
+		Tags:        []string{"yearly"},
+		Features:    []string{"pro"},
+		Note:        "Template note",
+		Data:        []byte(`{}`),
+		MaxSessions: 5,
+		ValidFor:    &validFor,
+		ProductID:   &productID,
+	}
+
+	tests := []struct {
+		name    string
+		req     *model.License
+		changes map[string]struct{}
+		want    *model.License
+	}{
+		{
+			name:    "defaults",
+			req:     &model.License{Name: "Customer", MaxSessions: 1},
+			changes: map[string]struct{}{"name": {}, "templateID": {}},
+			want: &model.License{
+				Name:            "Customer",
+				Tags:            []string{"yearly"},
+				Features:        []string{"pro"},
+				Note:            "Template note",
+				Data:            []byte(`{}`),
+				MaxSessions:     5,
+				ValidUntil:      &yearLater,
+				ProductID:       &productID,
+				TemplateID:      &tmpl.ID,
+				TemplateVersion: &tmpl.Version,
+			},
+		},
+		{
+			name: "overrides",
+			req: &model.License{
+				Tags:        []string{},
+				MaxSessions: 2,
+				ValidUntil:  &custom,
+			},
+			changes: map[string]struct{}{"tags": {}, "maxSessions": {}, "validUntil": {}, "productID": {}},
+			want: &model.License{
+				Tags:            []string{},
+				Features:        []string{"pro"},
+				Note:            "Template note",
+				Data:            []byte(`{}`),
+				MaxSessions:     2,
+				ValidUntil:      &custom,
+				TemplateID:      &tmpl.ID,
+				TemplateVersion: &tmpl.Version,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyLicenseTemplate(tt.req, tmpl, tt.changes, now)
+			assert.Equal(t, tt.want, tt.req)
+		})
+	}
+}
+
+func TestCore_ApplyLicenseTemplate_noTemplate(t *testing.T) {
+	version := 7
+	req := &model.License{Name: "Customer", MaxSessions: 1, TemplateVersion: &version}
+	changes := map[string]struct{}{"name": {}, "maxSessions": {}, "templateVersion": {}}
+
+	err := (&Core{}).ApplyLicenseTemplate(context.Background(), 1, req, changes)
+	assert.NoError(t, err)
+	assert.Equal(t, &model.License{Name: "Customer", MaxSessions: 1}, req)
+}
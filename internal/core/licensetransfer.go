@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// GetLicenseMachines returns machines with active sessions of the license,
+// most recently seen first.
+//
+// Returns ErrTimeOutOfSync
+// Returns SensitiveError
+func (c *Core) GetLicenseMachines(ctx context.Context, l *model.License, clientTime time.Time) ([]*model.LicenseMachine, error) {
+	now := time.Now()
+	if !c.timeInSync(now, clientTime) {
+		return nil, ErrTimeOutOfSync
+	}
+	lss, err := c.db.SelectAllLicenseSessionsByLicenseID(ctx, l.ID)
+	if err != nil {
+		return nil, handleErrDB(err, "getting license sessions")
+	}
+	return licenseMachines(lss, now), nil
+}
+
+// licenseMachines groups sessions not expired by now by their machines.
+func licenseMachines(lss []*model.LicenseSession, now time.Time) []*model.LicenseMachine {
+	machines := make([]*model.LicenseMachine, 0)
+	for _, ls := range lss {
+		if !ls.Expire.After(now) {
+			continue
+		}
+		var m *model.LicenseMachine
+		for _, v := range machines {
+			if bytes.Equal(v.MachineID, ls.MachineID) {
+				m = v
+				break
+			}
+		}
+		if m == nil {
+			m = &model.LicenseMachine{MachineID: ls.MachineID}
+			machines = append(machines, m)
+		}
+		m.Sessions++
+		if !ls.Created.Before(m.LastSeen) {
+			m.LastSeen = ls.Created
+			m.Identifier = ls.Identifier
+			m.AppVersion = ls.AppVersion
+		}
+	}
+	sort.SliceStable(machines, func(i, j int) bool {
+		return machines[i].LastSeen.After(machines[j].LastSeen)
+	})
+	return machines
+}
+
+// Returns SensitiveError
+func (c *Core) GetLicenseTransferStatus(ctx context.Context, l *model.License) (*model.LicenseTransferStatus, error) {
+	count, last, err := c.db.SelectLicenseTransfersStatsByLicenseID(ctx, l.ID)
+	if err != nil {
+		return nil, handleErrDB(err, "getting license transfers")
+	}
+	return licenseTransferStatus(l, count, last, time.Now()), nil
+}
+
+func licenseTransferStatus(l *model.License, count int, last *time.Time, now time.Time) *model.LicenseTransferStatus {
+	status := &model.LicenseTransferStatus{
+		Transfers:    count,
+		MaxTransfers: l.MaxTransfers,
+	}
+	if last != nil && l.TransferCooldown != nil {
+		next := last.Add(time.Duration(*l.TransferCooldown))
+		if next.After(now) {
+			status.NextTransfer = &next
+		}
+	}
+	return status
+}
+
+// checkTransfer reports whether license can be transferred now, given number
+// of its transfers and time of the latest one.
+//
+// Returns ErrTransferDisabled
+// Returns ErrTransferLimitReached
+// Returns ErrTransferCooldown
+func checkTransfer(l *model.License, count int, last *time.Time, now time.Time) error {
+	switch {
+	case l.MaxTransfers == 0:
+		return ErrTransferDisabled
+	case l.MaxTransfers > 0 && count >= l.MaxTransfers:
+		return ErrTransferLimitReached
+	}
+	if status := licenseTransferStatus(l, count, last, now); status.NextTransfer != nil {
+		return fmt.Errorf("%w until %s", ErrTransferCooldown, status.NextTransfer.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// DeactivateMachine closes all license sessions of the machine, freeing it
+// for another one. It is counted as a license transfer and is subject to
+// license's transfer limits.
+//
+// Returns ErrInvalidInput
+// Returns ErrTimeOutOfSync
+// Returns ErrTransferDisabled
+// Returns ErrTransferLimitReached
+// Returns ErrTransferCooldown
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) DeactivateMachine(ctx context.Context, l *model.License, machineID []byte, clientTime time.Time) error {
+	if len(machineID) == 0 {
+		return fmt.Errorf("%w machine id", ErrInvalidInput)
+	}
+	now := time.Now()
+	if !c.timeInSync(now, clientTime) {
+		return ErrTimeOutOfSync
+	}
+
+	var closed int
+	err := c.db.InTx(ctx, func(tx *db.Handler) error {
+		// Lock license, so that concurrent transfers can't exceed limits.
+		l, err := tx.SelectLicenseByIDForUpdate(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		count, last, err := tx.SelectLicenseTransfersStatsByLicenseID(ctx, l.ID)
+		if err != nil {
+			return err
+		}
+		err = checkTransfer(l, count, last, now)
+		if err != nil {
+			return err
+		}
+		closed, err = tx.DeleteLicenseSessionsByLicenseIDAndMachineID(ctx, l.ID, machineID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.InsertLicenseTransfer(ctx, &model.LicenseTransfer{
+			LicenseID: l.ID,
+			MachineID: machineID,
+			Created:   now,
+		})
+		return err
+	})
+	switch {
+	case errors.Is(err, ErrTransferDisabled),
+		errors.Is(err, ErrTransferLimitReached),
+		errors.Is(err, ErrTransferCooldown):
+		return err
+	case err != nil:
+		return handleErrDB(err, "deactivating machine")
+	}
+	for i := 0; i < closed; i++ {
+		observeLicenseSession(opClosed, nil)
+	}
+	return nil
+}
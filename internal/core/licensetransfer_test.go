@@ -0,0 +1,57 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_licenseMachines(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	lss := []*model.LicenseSession{
+		{MachineID: []byte{1}, Identifier: "a-old", AppVersion: "1.0", Created: now.Add(-3 * time.Hour), Expire: now.Add(time.Hour)},
+		{MachineID: []byte{2}, Identifier: "b", AppVersion: "2.0", Created: now.Add(-2 * time.Hour), Expire: now.Add(time.Hour)},
+		{MachineID: []byte{1}, Identifier: "a", AppVersion: "1.1", Created: now.Add(-time.Hour), Expire: now.Add(time.Hour)},
+		{MachineID: []byte{3}, Identifier: "c", AppVersion: "3.0", Created: now.Add(-time.Hour), Expire: now.Add(-time.Minute)},
+	}
+	want := []*model.LicenseMachine{
+		{MachineID: []byte{1}, Identifier: "a", AppVersion: "1.1", Sessions: 2, LastSeen: now.Add(-time.Hour)},
+		{MachineID: []byte{2}, Identifier: "b", AppVersion: "2.0", Sessions: 1, LastSeen: now.Add(-2 * time.Hour)},
+	}
+	assert.Equal(t, want, licenseMachines(lss, now))
+	assert.Equal(t, []*model.LicenseMachine{}, licenseMachines(nil, now))
+}
+
+func Test_checkTransfer(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	day := model.Duration(24 * time.Hour)
+	yesterday := now.Add(-25 * time.Hour)
+	recently := now.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		l       *model.License
+		count   int
+		last    *time.Time
+		wantErr error
+	}{
+		{name: "disabled", l: &model.License{MaxTransfers: 0}, wantErr: ErrTransferDisabled},
+		{name: "unlimited", l: &model.License{MaxTransfers: -1}, count: 100, last: &recently},
+		{name: "first", l: &model.License{MaxTransfers: 2, TransferCooldown: &day}},
+		{name: "limit", l: &model.License{MaxTransfers: 2}, count: 2, last: &yesterday, wantErr: ErrTransferLimitReached},
+		{name: "cooldown", l: &model.License{MaxTransfers: 2, TransferCooldown: &day}, count: 1, last: &recently, wantErr: ErrTransferCooldown},
+		{name: "cooled down", l: &model.License{MaxTransfers: 2, TransferCooldown: &day}, count: 1, last: &yesterday},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransfer(tt.l, tt.count, tt.last, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const usageReportIDLen = 16
+
+// validateUsageReport checks usage reported by license's client. Report ID is
+// required with usage.
+//
+// Returns ErrInvalidInput
+func validateUsageReport(reportID []byte, usage map[string]int64) error {
+	if !ValidLicenseUsage(usage) {
+		return fmt.Errorf("%w usage", ErrInvalidInput)
+	}
+	if len(usage) > 0 && len(reportID) != usageReportIDLen {
+		return fmt.Errorf("%w report id", ErrInvalidInput)
+	}
+	return nil
+}
+
+// addLicenseUsage adds usage report of license's client to the current usage
+// period within tx. Already added report is ignored, so that retried or
+// replayed reports are counted once.
+func addLicenseUsage(ctx context.Context, tx *db.Handler, licenseID, reportID []byte, usage map[string]int64, now time.Time) error {
+	nonZero := make(map[string]int64, len(usage))
+	for m, amount := range usage {
+		if amount > 0 {
+			nonZero[m] = amount
+		}
+	}
+	if len(nonZero) == 0 {
+		return nil
+	}
+	ok, err := tx.InsertUsageReport(ctx, &model.UsageReport{
+		ID:        reportID,
+		LicenseID: licenseID,
+		Created:   now,
+	})
+	if err != nil || !ok {
+		return err
+	}
+	return tx.AddLicenseUsage(ctx, licenseID, usagePeriod(now), nonZero, now)
+}
+
+// GetLicenseUsage returns license's usage of periods starting in [from; to),
+// latest first. Nil bounds are ignored.
+//
+// Returns SensitiveError
+func (c *Core) GetLicenseUsage(ctx context.Context, licenseID []byte, from, to *time.Time) ([]*model.LicenseUsage, error) {
+	uu, err := c.db.SelectLicenseUsageByLicenseID(ctx, licenseID, from, to)
+	return uu, handleErrDB(err, "getting license usage")
+}
+
+// quotaExceeded returns metrics whose usage of the period of now has reached
+// license's quota.
+func quotaExceeded(ctx context.Context, h *db.Handler, l *model.License, now time.Time) ([]string, error) {
+	if len(l.Quotas) == 0 {
+		return nil, nil
+	}
+	uu, err := h.SelectLicenseUsageByLicenseIDAndPeriod(ctx, l.ID, usagePeriod(now))
+	if err != nil {
+		return nil, err
+	}
+	return exceededQuotas(l.Quotas, uu), nil
+}
+
+// exceededQuotas returns sorted metrics whose usage has reached the quota.
+func exceededQuotas(quotas model.UsageQuotas, uu []*model.LicenseUsage) []string {
+	used := make(map[string]int64, len(uu))
+	for _, u := range uu {
+		used[u.Metric] += u.Amount
+	}
+	var exceeded []string
+	for m, quota := range quotas {
+		if used[m] >= quota {
+			exceeded = append(exceeded, m)
+		}
+	}
+	sort.Strings(exceeded)
+	return exceeded
+}
+
+// usagePeriod returns start of the usage period of t. Periods are calendar
+// months in UTC.
+func usagePeriod(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
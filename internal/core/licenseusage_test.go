@@ -0,0 +1,54 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_exceededQuotas(t *testing.T) {
+	quotas := model.UsageQuotas{"exports": 10, "api-calls": 1000, "minutes": 0}
+	uu := []*model.LicenseUsage{
+		{Metric: "exports", Amount: 10},
+		{Metric: "api-calls", Amount: 999},
+		{Metric: "unlimited", Amount: 1 << 30},
+	}
+	assert.Equal(t, []string{"exports", "minutes"}, exceededQuotas(quotas, uu))
+	assert.Nil(t, exceededQuotas(model.UsageQuotas{"exports": 1}, nil))
+}
+
+func Test_usagePeriod(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	tests := []struct {
+		t    time.Time
+		want time.Time
+	}{
+		{time.Date(2022, 3, 15, 12, 0, 0, 0, time.UTC), time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 3, 1, 1, 0, 0, 0, loc), time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.t.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, usagePeriod(tt.t))
+		})
+	}
+}
+
+func TestValidLicenseUsage(t *testing.T) {
+	assert.True(t, ValidLicenseUsage(nil))
+	assert.True(t, ValidLicenseUsage(map[string]int64{"exports": 3, "api-calls": 0}))
+	assert.False(t, ValidLicenseUsage(map[string]int64{"exports": -1}))
+	assert.False(t, ValidLicenseUsage(map[string]int64{"Exports": 1}))
+	assert.False(t, ValidLicenseUsage(map[string]int64{"": 1}))
+}
+
+func Test_validateUsageReport(t *testing.T) {
+	reportID := make([]byte, usageReportIDLen)
+	assert.NoError(t, validateUsageReport(nil, nil))
+	assert.NoError(t, validateUsageReport(reportID, map[string]int64{"exports": 3}))
+	assert.ErrorIs(t, validateUsageReport(nil, map[string]int64{"exports": 3}), ErrInvalidInput)
+	assert.ErrorIs(t, validateUsageReport([]byte{1}, map[string]int64{"exports": 3}), ErrInvalidInput)
+	assert.ErrorIs(t, validateUsageReport(reportID, map[string]int64{"exports": -1}), ErrInvalidInput)
+}
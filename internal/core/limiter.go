@@ -1,12 +1,14 @@
 package core
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
+	"github.com/sewiti/licensing-system/internal/db"
 	"github.com/sewiti/licensing-system/internal/model"
 	"golang.org/x/time/rate"
 )
@@ -16,10 +18,47 @@ type LimiterConf struct {
 	SessionEveryInit time.Duration
 	BurstTotal       time.Duration
 
+	// Shared limiter keeps its state in the database, so that multiple
+	// licensing server instances could share the same rate budget.
+	Shared bool
+
 	CacheExpiration      time.Duration
 	CacheCleanupInterval time.Duration
 }
 
+// sessionRate returns new sessions creation rate for a license with
+// maxSessions.
+func (conf LimiterConf) sessionRate(maxSessions int) (sessionEvery time.Duration, burst int) {
+	// For multi-session licenses, proportionally increase allowed session
+	// frequency.
+	sessionEvery = conf.SessionEvery / time.Duration(maxSessions)
+
+	// Allow bursts of BurstTotal worth of sessions time.
+	burst = int(conf.BurstTotal / sessionEvery)
+	return sessionEvery, burst
+}
+
+// sessionLimiter limits new license sessions creation rate.
+type sessionLimiter interface {
+	// allow reports whether a new license session is allowed to be created
+	// now.
+	allow(ctx context.Context, l *model.License) (bool, error)
+}
+
+func newSessionLimiter(dbh *db.Handler, conf LimiterConf) sessionLimiter {
+	if conf.Shared {
+		return &sharedLimiter{
+			conf: conf,
+			db:   dbh,
+		}
+	}
+	return &limiter{
+		conf:  conf,
+		cache: cache.New(conf.CacheExpiration, conf.CacheCleanupInterval),
+	}
+}
+
+// limiter is an in-process session limiter.
 type limiter struct {
 	conf LimiterConf
 
@@ -27,6 +66,10 @@ type limiter struct {
 	cache *cache.Cache
 }
 
+func (lim *limiter) allow(ctx context.Context, l *model.License) (bool, error) {
+	return lim.get(l).Allow(), nil
+}
+
 func (lim *limiter) get(l *model.License) *rate.Limiter {
 	id := fmt.Sprintf("%s:%d",
 		base64.StdEncoding.EncodeToString(l.ID), l.MaxSessions)
@@ -46,12 +89,7 @@ func (lim *limiter) get(l *model.License) *rate.Limiter {
 }
 
 func (lim *limiter) newRateLimiter(maxSessions int) *rate.Limiter {
-	// For multi-session licenses, proportionally increase allowed session
-	// frequency.
-	sessionEvery := lim.conf.SessionEvery / time.Duration(maxSessions)
-
-	// Allow bursts of BurstTotal worth of sessions time.
-	burst := int(lim.conf.BurstTotal / sessionEvery)
+	sessionEvery, burst := lim.conf.sessionRate(maxSessions)
 	rl := rate.NewLimiter(rate.Every(sessionEvery), burst)
 
 	// Make initial burst a minimum to support new session every SessionEveryInit.
@@ -62,3 +100,16 @@ func (lim *limiter) newRateLimiter(maxSessions int) *rate.Limiter {
 	// }
 	return rl
 }
+
+// sharedLimiter is a database backed token bucket session limiter, safe to
+// use by multiple licensing server instances.
+type sharedLimiter struct {
+	conf LimiterConf
+	db   *db.Handler
+}
+
+func (lim *sharedLimiter) allow(ctx context.Context, l *model.License) (bool, error) {
+	sessionEvery, burst := lim.conf.sessionRate(l.MaxSessions)
+	perSecond := float64(time.Second) / float64(sessionEvery)
+	return lim.db.TakeLicenseLimiterToken(ctx, l.ID, perSecond, burst, time.Now())
+}
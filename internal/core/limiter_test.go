@@ -0,0 +1,32 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterConf_sessionRate(t *testing.T) {
+	conf := LimiterConf{
+		SessionEvery: 10 * time.Minute,
+		BurstTotal:   8 * time.Hour,
+	}
+	tests := []struct {
+		maxSessions      int
+		wantSessionEvery time.Duration
+		wantBurst        int
+	}{
+		{1, 10 * time.Minute, 48},
+		{2, 5 * time.Minute, 96},
+		{10, time.Minute, 480},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.maxSessions), func(t *testing.T) {
+			gotSessionEvery, gotBurst := conf.sessionRate(tt.maxSessions)
+			assert.Equal(t, tt.wantSessionEvery, gotSessionEvery)
+			assert.Equal(t, tt.wantBurst, gotBurst)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/metrics"
+)
+
+// License session operations.
+const (
+	opCreated   = "created"
+	opRefreshed = "refreshed"
+	opClosed    = "closed"
+)
+
+// observeLicenseSession records license session operation result.
+func observeLicenseSession(operation string, err error) {
+	if err == nil {
+		metrics.LicenseSession(operation)
+		return
+	}
+	metrics.LicenseSessionRejected(operation, errReason(err))
+}
+
+// errReason returns short metrics friendly error reason.
+func errReason(err error) string {
+	switch {
+	case errors.Is(err, ErrRateLimitReached):
+		return "rate_limit_reached"
+	case errors.Is(err, ErrTimeOutOfSync):
+		return "time_out_of_sync"
+	case errors.Is(err, ErrLicenseExpired):
+		return "license_expired"
+	case errors.Is(err, ErrLicenseInactive):
+		return "license_inactive"
+	case errors.Is(err, ErrLicenseSessionExpired):
+		return "license_session_expired"
+	case errors.Is(err, ErrProductInactive):
+		return "product_inactive"
+	case errors.Is(err, ErrLicenseIssuerDisabled):
+		return "license_issuer_disabled"
+	case errors.Is(err, ErrInvalidInput):
+		return "invalid_input"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+// CountActiveLicenseSessions counts active license sessions per product.
+// Sessions of licenses without product are counted under zero product ID.
+//
+// Returns SensitiveError
+func (c *Core) CountActiveLicenseSessions(ctx context.Context) (map[int]int, error) {
+	counts, err := c.db.SelectActiveLicenseSessionsCountByProduct(ctx, time.Now())
+	return counts, handleErrDB(err, "counting active license sessions")
+}
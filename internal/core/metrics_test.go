@@ -0,0 +1,27 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_errReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrRateLimitReached, "rate_limit_reached"},
+		{ErrTimeOutOfSync, "time_out_of_sync"},
+		{ErrLicenseExpired, "license_expired"},
+		{fmt.Errorf("%w client session id", ErrInvalidInput), "invalid_input"},
+		{&SensitiveError{Message: "getting license", Err: errors.New("db")}, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.want, errReason(tt.err))
+		})
+	}
+}
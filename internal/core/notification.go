@@ -0,0 +1,651 @@
+package core
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/core/notify"
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/metrics"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+// notifyLockKey is an advisory lock key used for notify routine leader
+// election.
+const notifyLockKey int64 = 0x6c69632d6e7466 // "lic-ntf"
+
+// unsubscribeTokenPrefix separates unsubscribe token signatures from other
+// ones.
+const unsubscribeTokenPrefix = "unsubscribe-token:"
+
+const (
+	notifyBatchSize   = 100
+	notifySendTimeout = 30 * time.Second
+	maxRetryDelay     = 24 * time.Hour
+
+	maxNotificationTemplateLen = 16 * 1024
+
+	// overuseScanOverlap rescans usage updated shortly before the previous
+	// scan, as usage is timestamped before its transaction commits.
+	overuseScanOverlap = time.Minute
+)
+
+type NotifyCallback func(msg string, err error)
+
+func (cb NotifyCallback) call(msg string, err error) {
+	if cb != nil {
+		cb(msg, err)
+	}
+}
+
+// RunNotifyRoutine runs notifications routine. This routine periodically
+// queues expiry reminders and overuse notifications and sends queued
+// notifications, retrying failed ones with exponential backoff.
+//
+// Same as cleanup, routine is run by a single instance sharing the database.
+//
+// Calls callback with routine info and an error if any.
+//
+// Blocks until context is canceled.
+func (c *Core) RunNotifyRoutine(ctx context.Context, interval time.Duration, cb NotifyCallback) {
+	if c.notify.Sender == nil {
+		return
+	}
+	var lock *db.AdvisoryLock
+	defer func() {
+		if lock == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := lock.Release(ctx)
+		if err != nil {
+			cb.call("releasing notify leadership", err)
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var overuseSince time.Time
+	for {
+		lock = c.leadership(ctx, lock, notifyLockKey, "notify", cb.call)
+		if lock != nil {
+			c.queueExpiryReminders(ctx, time.Now(), cb)
+			overuseSince = c.queueOveruseNotifications(ctx, overuseSince, time.Now(), cb)
+			c.sendNotifications(ctx, cb)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// queueExpiryReminders queues reminders of licenses expiring within the
+// largest of reminder days. License gets a single reminder per reminder day
+// it has reached.
+func (c *Core) queueExpiryReminders(ctx context.Context, now time.Time, cb NotifyCallback) {
+	if len(c.notify.ReminderDays) == 0 {
+		return
+	}
+	days := make([]int, len(c.notify.ReminderDays))
+	copy(days, c.notify.ReminderDays)
+	sort.Ints(days)
+
+	ll, err := c.db.SelectLicensesExpiringBetween(ctx, now, now.Add(time.Duration(days[len(days)-1])*24*time.Hour))
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		cb.call("getting expiring licenses", err)
+		return
+	}
+	queued := 0
+	for _, l := range ll {
+		left := daysLeft(*l.ValidUntil, now)
+		reminder := reminderDay(days, left)
+		dedupKey := fmt.Sprintf("%s:%s:%d:%d", notify.KindExpiryReminder,
+			base64.StdEncoding.EncodeToString(l.ID), reminder, l.ValidUntil.Unix())
+		nn, err := c.notifyLicense(ctx, notify.KindExpiryReminder, l, &dedupKey, func(d *notify.Data) {
+			d.DaysLeft = left
+		})
+		if err != nil {
+			cb.call("queueing expiry reminder", err)
+			continue
+		}
+		queued += len(nn)
+	}
+	cb.call(fmt.Sprintf("queued %d expiry reminders", queued), nil)
+}
+
+// queueOveruseNotifications queues overuse notifications of licenses, whose
+// usage updated since given time has reached their quotas. Returns time to
+// scan from next, which is unchanged if licenses couldn't be scanned.
+func (c *Core) queueOveruseNotifications(ctx context.Context, since, now time.Time, cb NotifyCallback) time.Time {
+	ll, err := c.db.SelectLicensesOverusedSince(ctx, usagePeriod(now), since)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		cb.call("getting overused licenses", err)
+		return since
+	}
+	queued := 0
+	for _, l := range ll {
+		exceeded, err := quotaExceeded(ctx, c.db, l, now)
+		if err != nil {
+			cb.call("getting license usage", err)
+			continue
+		}
+		nn, err := c.notifyOveruse(ctx, l, exceeded, now)
+		if err != nil {
+			cb.call("queueing overuse notification", err)
+			continue
+		}
+		queued += len(nn)
+	}
+	cb.call(fmt.Sprintf("queued %d overuse notifications", queued), nil)
+	return now.Add(-overuseScanOverlap)
+}
+
+// daysLeft returns days until t, partial day counts as a whole one.
+func daysLeft(t, now time.Time) int {
+	const day = 24 * time.Hour
+	return int((t.Sub(now) + day - 1) / day)
+}
+
+// reminderDay returns the smallest of sorted reminder days, which is greater
+// or equal to days left.
+func reminderDay(days []int, left int) int {
+	i := sort.SearchInts(days, left)
+	if i == len(days) {
+		return days[len(days)-1]
+	}
+	return days[i]
+}
+
+// sendNotifications sends queued notifications, which are due, in batches.
+// Sending stops once an attempt can't be recorded, as the notification would
+// be selected and sent again.
+func (c *Core) sendNotifications(ctx context.Context, cb NotifyCallback) {
+	sent, failed := 0, 0
+	defer func() {
+		if sent > 0 || failed > 0 {
+			cb.call(fmt.Sprintf("sent %d notifications, %d failed", sent, failed), nil)
+		}
+	}()
+	for {
+		nn, err := c.db.SelectNotificationsPending(ctx, time.Now(), c.notify.MaxAttempts, notifyBatchSize)
+		if err != nil {
+			cb.call("getting pending notifications", err)
+			return
+		}
+		for _, n := range nn {
+			if ctx.Err() != nil {
+				return
+			}
+			sendErr, err := c.sendNotification(ctx, n)
+			if sendErr != nil {
+				cb.call(fmt.Sprintf("sending notification %d", n.ID), sendErr)
+				failed++
+			} else {
+				sent++
+			}
+			if err != nil {
+				cb.call(fmt.Sprintf("recording notification %d", n.ID), err)
+				return
+			}
+		}
+		if len(nn) < notifyBatchSize {
+			return
+		}
+	}
+}
+
+// sendNotification sends notification and records the attempt. Failed
+// notification is rescheduled unless it runs out of attempts.
+//
+// Returns error of sending and error of recording the attempt separately.
+func (c *Core) sendNotification(ctx context.Context, n *model.Notification) (sendErr, err error) {
+	m := &notify.Message{
+		To:      n.Recipient,
+		Subject: n.Subject,
+		Body:    n.Body,
+	}
+	if n.Kind != notify.KindLicenseDelivery {
+		m.Unsubscribe, sendErr = c.unsubscribeURL(n.IssuerID, n.Recipient)
+	}
+	if sendErr == nil {
+		sendCtx, cancel := context.WithTimeout(ctx, notifySendTimeout)
+		sendErr = c.notify.Sender.Send(sendCtx, m)
+		cancel()
+	}
+
+	now := time.Now()
+	update := map[string]interface{}{
+		"attempts": n.Attempts + 1,
+	}
+	if sendErr == nil {
+		update["sent"] = now
+		update["last_error"] = ""
+		metrics.Notification(n.Kind, "sent")
+	} else {
+		update["next_attempt"] = now.Add(retryDelay(c.notify.RetryDelay, n.Attempts))
+		update["last_error"] = sendErr.Error()
+		if n.Attempts+1 >= c.notify.MaxAttempts {
+			metrics.Notification(n.Kind, "abandoned")
+		} else {
+			metrics.Notification(n.Kind, "failed")
+		}
+	}
+	err = c.db.UpdateNotification(ctx, n.ID, update)
+	return sendErr, handleErrDB(err, "updating notification")
+}
+
+// retryDelay returns delay before the next attempt after failed attempts.
+func retryDelay(delay time.Duration, attempts int) time.Duration {
+	for i := 0; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
+
+// NotifyLicenseDelivery queues license key delivery to the end user of the
+// license. Opt-outs don't apply to deliveries.
+//
+// Returns ErrNotificationsDisabled
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) NotifyLicenseDelivery(ctx context.Context, l *model.License) ([]*model.Notification, error) {
+	if c.notify.Sender == nil {
+		return nil, ErrNotificationsDisabled
+	}
+	return c.notifyLicense(ctx, notify.KindLicenseDelivery, l, nil, func(d *notify.Data) {
+		d.LicenseKey = util.FormatKey(l.Key)
+	})
+}
+
+// NotifyLicenseDeactivated queues notification of license deactivation, it's
+// a no-op if notifications are disabled.
+//
+// Returns SensitiveError
+func (c *Core) NotifyLicenseDeactivated(ctx context.Context, l *model.License) error {
+	if c.notify.Sender == nil {
+		return nil
+	}
+	_, err := c.notifyLicense(ctx, notify.KindLicenseDeactivated, l, nil, nil)
+	return err
+}
+
+// notifyOveruse queues notification of exceeded quotas, which is sent once
+// per usage period and set of metrics.
+//
+// Returns SensitiveError
+func (c *Core) notifyOveruse(ctx context.Context, l *model.License, exceeded []string, now time.Time) ([]*model.Notification, error) {
+	if len(exceeded) == 0 {
+		return nil, nil
+	}
+	metricsSum := sha256.Sum256([]byte(strings.Join(exceeded, ",")))
+	dedupKey := fmt.Sprintf("%s:%s:%d:%x", notify.KindOveruse,
+		base64.StdEncoding.EncodeToString(l.ID), usagePeriod(now).Unix(), metricsSum[:8])
+	return c.notifyLicense(ctx, notify.KindOveruse, l, &dedupKey, func(d *notify.Data) {
+		d.Metrics = exceeded
+	})
+}
+
+// notifyLicense queues notification of the kind about the license to its end
+// users and, except for deliveries, its issuer. Set adjusts template data.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) notifyLicense(ctx context.Context, kind string, l *model.License, dedupKey *string, set func(d *notify.Data)) ([]*model.Notification, error) {
+	li, err := c.db.SelectLicenseIssuerByID(ctx, l.IssuerID)
+	if err != nil {
+		return nil, handleErrDB(err, "getting license issuer")
+	}
+	var cu *model.Customer
+	if l.CustomerID != nil {
+		cu, err = c.db.SelectCustomerByID(ctx, *l.CustomerID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return nil, handleErrDB(err, "getting customer")
+		}
+	}
+
+	recipients := licenseRecipients(l, cu)
+	if kind == notify.KindLicenseDelivery && len(recipients) == 0 {
+		return nil, fmt.Errorf("%w recipient: license has no end user email", ErrInvalidInput)
+	}
+	if kind != notify.KindLicenseDelivery && li.Email != "" {
+		recipients = normalizeEmails(append(recipients, li.Email))
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+	if kind != notify.KindLicenseDelivery {
+		optedOut, err := c.db.SelectNotificationOptedOut(ctx, li.ID, recipients)
+		if err != nil {
+			return nil, handleErrDB(err, "getting notification opt-outs")
+		}
+		recipients = excludeEmails(recipients, optedOut)
+	}
+
+	tmpl, err := c.notificationTemplate(ctx, li.ID, kind)
+	if err != nil {
+		return nil, err
+	}
+	d := &notify.Data{
+		Issuer:      li.Username,
+		IssuerEmail: li.Email,
+		LicenseID:   base64.StdEncoding.EncodeToString(l.ID),
+		LicenseName: l.Name,
+		ValidUntil:  l.ValidUntil,
+	}
+	if cu != nil {
+		d.CustomerName = cu.Name
+	}
+	if set != nil {
+		set(d)
+	}
+
+	now := time.Now()
+	var nn []*model.Notification
+	for _, to := range recipients {
+		d.UnsubscribeURL = ""
+		if kind != notify.KindLicenseDelivery {
+			d.UnsubscribeURL, err = c.unsubscribeURL(li.ID, to)
+			if err != nil {
+				return nil, err
+			}
+		}
+		subject, body, err := tmpl.Render(d)
+		if err != nil {
+			return nil, fmt.Errorf("%s template: %w", kind, err)
+		}
+		n := &model.Notification{
+			Kind:        kind,
+			Recipient:   to,
+			Subject:     subject,
+			Body:        body,
+			DedupKey:    dedupKey,
+			NextAttempt: now,
+			Created:     now,
+			IssuerID:    li.ID,
+			LicenseID:   l.ID,
+		}
+		var queued bool
+		n.ID, queued, err = c.db.InsertNotification(ctx, n)
+		if err != nil {
+			return nil, handleErrDB(err, "queueing notification")
+		}
+		if queued {
+			nn = append(nn, n)
+		}
+	}
+	return nn, nil
+}
+
+// licenseRecipients returns end user emails of the license and its customer.
+func licenseRecipients(l *model.License, cu *model.Customer) []string {
+	var emails []string
+	if l.EndUserEmail != "" {
+		emails = append(emails, l.EndUserEmail)
+	}
+	if cu != nil {
+		emails = append(emails, cu.Emails...)
+	}
+	return normalizeEmails(emails)
+}
+
+// excludeEmails returns emails, which aren't in excluded.
+func excludeEmails(emails, excluded []string) []string {
+	if len(excluded) == 0 {
+		return emails
+	}
+	skip := make(map[string]struct{}, len(excluded))
+	for _, e := range excluded {
+		skip[e] = struct{}{}
+	}
+	kept := make([]string, 0, len(emails))
+	for _, e := range emails {
+		if _, ok := skip[e]; !ok {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// notificationTemplate returns issuer's template of the kind or the default
+// one.
+//
+// Returns SensitiveError
+func (c *Core) notificationTemplate(ctx context.Context, licenseIssuerID int, kind string) (notify.Template, error) {
+	nt, err := c.db.SelectNotificationTemplate(ctx, licenseIssuerID, kind)
+	switch {
+	case err == nil:
+		return notify.Template{Subject: nt.Subject, Body: nt.Body}, nil
+	case errors.Is(err, db.ErrNotFound):
+		tmpl, _ := notify.DefaultTemplate(kind)
+		return tmpl, nil
+	default:
+		return notify.Template{}, handleErrDB(err, "getting notification template")
+	}
+}
+
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) GetNotificationsByIssuer(ctx context.Context, licenseIssuerID int, opts *model.ListOptions) ([]*model.Notification, *model.Page, error) {
+	nn, page, err := c.db.SelectNotificationsByIssuerID(ctx, licenseIssuerID, opts)
+	return nn, page, handleErrDB(err, "getting notifications by issuer")
+}
+
+// GetNotificationTemplates returns issuer's templates of all notification
+// kinds, default ones included.
+//
+// Returns SensitiveError
+func (c *Core) GetNotificationTemplates(ctx context.Context, licenseIssuerID int) ([]*model.NotificationTemplate, error) {
+	custom, err := c.db.SelectNotificationTemplatesByIssuerID(ctx, licenseIssuerID)
+	if err != nil {
+		return nil, handleErrDB(err, "getting notification templates")
+	}
+	byKind := make(map[string]*model.NotificationTemplate, len(custom))
+	for _, nt := range custom {
+		byKind[nt.Kind] = nt
+	}
+	tt := make([]*model.NotificationTemplate, len(notify.Kinds))
+	for i, kind := range notify.Kinds {
+		nt, ok := byKind[kind]
+		if !ok {
+			nt = defaultNotificationTemplate(licenseIssuerID, kind)
+		}
+		tt[i] = nt
+	}
+	return tt, nil
+}
+
+// GetNotificationTemplate returns issuer's template of the kind or the
+// default one.
+//
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) GetNotificationTemplate(ctx context.Context, licenseIssuerID int, kind string) (*model.NotificationTemplate, error) {
+	if !notify.ValidKind(kind) {
+		return nil, ErrNotFound
+	}
+	nt, err := c.db.SelectNotificationTemplate(ctx, licenseIssuerID, kind)
+	if errors.Is(err, db.ErrNotFound) {
+		return defaultNotificationTemplate(licenseIssuerID, kind), nil
+	}
+	return nt, handleErrDB(err, "getting notification template")
+}
+
+func defaultNotificationTemplate(licenseIssuerID int, kind string) *model.NotificationTemplate {
+	tmpl, _ := notify.DefaultTemplate(kind)
+	return &model.NotificationTemplate{
+		IssuerID: licenseIssuerID,
+		Kind:     kind,
+		Subject:  tmpl.Subject,
+		Body:     tmpl.Body,
+	}
+}
+
+// SetNotificationTemplate overrides the default template of the kind for the
+// issuer.
+//
+// Returns ErrNotFound
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) SetNotificationTemplate(ctx context.Context, li *model.LicenseIssuer, kind string, req *model.NotificationTemplate) (*model.NotificationTemplate, error) {
+	if !notify.ValidKind(kind) {
+		return nil, ErrNotFound
+	}
+	if req == nil {
+		return nil, fmt.Errorf("%w request", ErrInvalidInput)
+	}
+	if len(req.Subject) > maxNotificationTemplateLen || len(req.Body) > maxNotificationTemplateLen {
+		return nil, fmt.Errorf("%w template: too long", ErrInvalidInput)
+	}
+	err := notify.Template{Subject: req.Subject, Body: req.Body}.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w template: %v", ErrInvalidInput, err)
+	}
+
+	now := time.Now()
+	nt := &model.NotificationTemplate{
+		IssuerID: li.ID,
+		Kind:     kind,
+		Subject:  req.Subject,
+		Body:     req.Body,
+		Updated:  &now,
+	}
+	err = c.db.UpsertNotificationTemplate(ctx, nt)
+	return nt, handleErrDB(err, "setting notification template")
+}
+
+// ResetNotificationTemplate restores the default template of the kind.
+//
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) ResetNotificationTemplate(ctx context.Context, licenseIssuerID int, kind string) error {
+	_, err := c.db.DeleteNotificationTemplate(ctx, licenseIssuerID, kind)
+	return handleErrDB(err, "resetting notification template")
+}
+
+// Returns SensitiveError
+func (c *Core) GetNotificationOptOuts(ctx context.Context, licenseIssuerID int) ([]*model.NotificationOptOut, error) {
+	oo, err := c.db.SelectNotificationOptOutsByIssuerID(ctx, licenseIssuerID)
+	return oo, handleErrDB(err, "getting notification opt-outs")
+}
+
+// OptOutNotifications stops issuer's notifications to the email, except
+// license deliveries.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) OptOutNotifications(ctx context.Context, licenseIssuerID int, email string) (*model.NotificationOptOut, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !ValidEmail(email) {
+		return nil, fmt.Errorf("%w email", ErrInvalidInput)
+	}
+	o := &model.NotificationOptOut{
+		IssuerID: licenseIssuerID,
+		Email:    email,
+		Created:  time.Now(),
+	}
+	err := c.db.InsertNotificationOptOut(ctx, o)
+	return o, handleErrDB(err, "opting out of notifications")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) DeleteNotificationOptOut(ctx context.Context, licenseIssuerID int, email string) error {
+	_, err := c.db.DeleteNotificationOptOut(ctx, licenseIssuerID, strings.ToLower(email))
+	return handleErrDB(err, "deleting notification opt-out")
+}
+
+// UnsubscribeRecipient returns recipient of the unsubscribe token, without
+// opting it out.
+//
+// Returns ErrInvalidInput
+func (c *Core) UnsubscribeRecipient(token string) (string, error) {
+	t, err := c.parseUnsubscribeToken(token)
+	if err != nil {
+		return "", err
+	}
+	return t.Email, nil
+}
+
+// Unsubscribe opts recipient of the unsubscribe token out of issuer's
+// notifications.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) Unsubscribe(ctx context.Context, token string) (*model.NotificationOptOut, error) {
+	t, err := c.parseUnsubscribeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return c.OptOutNotifications(ctx, t.IssuerID, t.Email)
+}
+
+type unsubscribeToken struct {
+	IssuerID int    `json:"i"`
+	Email    string `json:"e"`
+}
+
+// unsubscribeURL returns link opting email out of issuer's notifications.
+// Empty string is returned if public URL isn't configured.
+func (c *Core) unsubscribeURL(licenseIssuerID int, email string) (string, error) {
+	if c.notify.PublicURL == "" {
+		return "", nil
+	}
+	payload, err := json.Marshal(unsubscribeToken{
+		IssuerID: licenseIssuerID,
+		Email:    email,
+	})
+	if err != nil {
+		return "", err
+	}
+	sig, err := util.Sign(cryptorand.Reader, c.serverKey, append([]byte(unsubscribeTokenPrefix), payload...))
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(sig)
+	return strings.TrimSuffix(c.notify.PublicURL, "/") + "/api/notifications/unsubscribe?" +
+		url.Values{"token": {token}}.Encode(), nil
+}
+
+// Returns ErrInvalidInput
+func (c *Core) parseUnsubscribeToken(token string) (*unsubscribeToken, error) {
+	invalid := fmt.Errorf("%w unsubscribe token", ErrInvalidInput)
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return nil, invalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return nil, invalid
+	}
+	if !util.Verify(c.serverID, append([]byte(unsubscribeTokenPrefix), payload...), sig) {
+		return nil, invalid
+	}
+	var t unsubscribeToken
+	err = json.Unmarshal(payload, &t)
+	if err != nil {
+		return nil, invalid
+	}
+	return &t, nil
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/core/notify"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_daysLeft(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 1, daysLeft(now.Add(time.Minute), now))
+	assert.Equal(t, 1, daysLeft(now.Add(24*time.Hour), now))
+	assert.Equal(t, 2, daysLeft(now.Add(24*time.Hour+time.Second), now))
+	assert.Equal(t, 30, daysLeft(now.Add(30*24*time.Hour), now))
+}
+
+func Test_reminderDay(t *testing.T) {
+	days := []int{1, 7, 30}
+	assert.Equal(t, 1, reminderDay(days, 1))
+	assert.Equal(t, 7, reminderDay(days, 2))
+	assert.Equal(t, 7, reminderDay(days, 7))
+	assert.Equal(t, 30, reminderDay(days, 8))
+	assert.Equal(t, 30, reminderDay(days, 31))
+}
+
+func Test_retryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, retryDelay(time.Minute, 0))
+	assert.Equal(t, 4*time.Minute, retryDelay(time.Minute, 2))
+	assert.Equal(t, maxRetryDelay, retryDelay(time.Minute, 20))
+}
+
+func Test_licenseRecipients(t *testing.T) {
+	l := &model.License{EndUserEmail: "John@ACME.com"}
+	assert.Equal(t, []string{"john@acme.com"}, licenseRecipients(l, nil))
+
+	cu := &model.Customer{Emails: []string{"john@acme.com", "billing@acme.com"}}
+	assert.Equal(t, []string{"john@acme.com", "billing@acme.com"}, licenseRecipients(l, cu))
+	assert.Equal(t, []string{}, licenseRecipients(&model.License{}, nil))
+}
+
+func Test_excludeEmails(t *testing.T) {
+	emails := []string{"a@example.com", "b@example.com", "c@example.com"}
+	assert.Equal(t, emails, excludeEmails(emails, nil))
+	assert.Equal(t, []string{"a@example.com", "c@example.com"}, excludeEmails(emails, []string{"b@example.com", "d@example.com"}))
+}
+
+func TestCore_unsubscribeURL(t *testing.T) {
+	id, key, err := util.GenerateKey(bytes.NewReader(bytes.Repeat([]byte{7}, 32)))
+	require.NoError(t, err)
+	c := &Core{serverID: id, serverKey: key}
+
+	link, err := c.unsubscribeURL(3, "user@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, link)
+
+	c.notify.PublicURL = "https://licensing.example.com/"
+	link, err = c.unsubscribeURL(3, "user@example.com")
+	require.NoError(t, err)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/notifications/unsubscribe", u.Path)
+
+	token := u.Query().Get("token")
+	got, err := c.parseUnsubscribeToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, &unsubscribeToken{IssuerID: 3, Email: "user@example.com"}, got)
+	email, err := c.UnsubscribeRecipient(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", email)
+
+	_, err = c.parseUnsubscribeToken("x" + token)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	_, err = c.parseUnsubscribeToken("invalid")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestCore_queueOveruseNotifications(t *testing.T) {
+	const query = "SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id, features, template_id, template_version, max_transfers, transfer_cooldown, parent_id, edition_id, channels, updates_until, quotas, customer_id, deleted FROM license WHERE (deleted IS NULL AND EXISTS (SELECT 1 FROM license_usage WHERE license_usage.license_id = license.id AND license_usage.period = ? AND license_usage.updated >= ? AND license_usage.amount >= (license.quotas ->> license_usage.metric)::bigint)) ORDER BY id"
+	now := time.Date(2022, 2, 14, 12, 0, 0, 0, time.UTC)
+	period := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	since := now.Add(-10 * time.Minute)
+
+	t.Run("scanned", func(t *testing.T) {
+		c, mock := newMockCore(t)
+		mock.ExpectQuery(query).
+			WithArgs(period, since).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		got := c.queueOveruseNotifications(context.Background(), since, now, nil)
+		assert.Equal(t, now.Add(-overuseScanOverlap), got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("failed", func(t *testing.T) {
+		c, mock := newMockCore(t)
+		mock.ExpectQuery(query).
+			WithArgs(period, since).
+			WillReturnError(errors.New("connection reset"))
+
+		var cbErr error
+		got := c.queueOveruseNotifications(context.Background(), since, now, func(_ string, err error) {
+			cbErr = err
+		})
+		assert.Equal(t, since, got, "failed scan must be retried from the same time")
+		assert.Error(t, cbErr)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+type countingSender struct {
+	sent []string
+}
+
+func (s *countingSender) Send(_ context.Context, m *notify.Message) error {
+	s.sent = append(s.sent, m.To)
+	return nil
+}
+
+func TestCore_sendNotifications_recordFailed(t *testing.T) {
+	c, mock := newMockCore(t)
+	sender := &countingSender{}
+	c.notify = NotifyConf{Sender: sender, MaxAttempts: 3, RetryDelay: time.Minute}
+
+	created := time.Date(2022, 2, 14, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "kind", "recipient", "subject", "body", "dedup_key", "attempts", "next_attempt", "last_error", "sent", "created", "issuer_id", "license_id"}).
+		AddRow(1, "overuse", "first@example.com", "Subject", "Body", nil, 0, created, "", nil, created, 3, []byte{1}).
+		AddRow(2, "overuse", "second@example.com", "Subject", "Body", nil, 0, created, "", nil, created, 3, []byte{1})
+	mock.ExpectQuery("SELECT id, kind, recipient, subject, body, dedup_key, attempts, next_attempt, last_error, sent, created, issuer_id, license_id FROM notification WHERE (sent IS NULL AND next_attempt <= ? AND attempts < ?) ORDER BY next_attempt, id LIMIT 100").
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnRows(rows)
+	mock.ExpectExec("UPDATE notification SET attempts = ?, last_error = ?, sent = ? WHERE id = ?").
+		WithArgs(1, "", sqlmock.AnyArg(), 1).
+		WillReturnError(errors.New("connection reset"))
+
+	var msgs []string
+	c.sendNotifications(context.Background(), func(msg string, err error) {
+		msgs = append(msgs, msg)
+	})
+	assert.Equal(t, []string{"first@example.com"}, sender.sent, "sending must stop once attempt isn't recorded")
+	assert.Equal(t, []string{"recording notification 1", "sent 1 notifications, 0 failed"}, msgs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
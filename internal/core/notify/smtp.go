@@ -0,0 +1,173 @@
+// Package notify composes and sends email notifications.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	cryptorand "crypto/rand"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+
+	// Unsubscribe is one-click unsubscribe URL (RFC 8058), empty omits
+	// List-Unsubscribe headers.
+	Unsubscribe string
+}
+
+// Sender sends email messages.
+type Sender interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// SMTPConf defines SMTP server messages are sent through.
+type SMTPConf struct {
+	Addr     string // host:port
+	Username string // Empty disables authentication.
+	Password string
+	From     string // Sender address, e.g., "Licensing <noreply@example.com>".
+}
+
+// SMTPSender sends messages through SMTP server. STARTTLS is used if server
+// supports it, authentication requires TLS unless server is on localhost.
+type SMTPSender struct {
+	conf SMTPConf
+	host string
+	from *mail.Address
+}
+
+func NewSMTPSender(conf SMTPConf) (*SMTPSender, error) {
+	host, _, err := net.SplitHostPort(conf.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp address: %w", err)
+	}
+	from, err := mail.ParseAddress(conf.From)
+	if err != nil {
+		return nil, fmt.Errorf("smtp from: %w", err)
+	}
+	return &SMTPSender{
+		conf: conf,
+		host: host,
+		from: from,
+	}, nil
+}
+
+func (s *SMTPSender) Send(ctx context.Context, m *Message) error {
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return fmt.Errorf("recipient: %w", err)
+	}
+	msg, err := compose(s.from, to, m, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.conf.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: s.host})
+		if err != nil {
+			return err
+		}
+	}
+	if s.conf.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", s.conf.Username, s.conf.Password, s.host))
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(s.from.Address)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to.Address)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose formats message with headers, body is quoted-printable encoded.
+func compose(from, to *mail.Address, m *Message, date time.Time) ([]byte, error) {
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return nil, errors.New("subject must be a single line")
+	}
+	if strings.ContainsAny(m.Unsubscribe, "\r\n<>") {
+		return nil, errors.New("invalid unsubscribe url")
+	}
+	id := make([]byte, 16)
+	_, err := cryptorand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndexByte(from.Address, '@')+1:]
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%x@%s>", id, domain))
+	if m.Unsubscribe != "" {
+		header("List-Unsubscribe", "<"+m.Unsubscribe+">")
+		header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(m.Body, "\r\n", "\n")
+	_, err = qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	if err != nil {
+		return nil, err
+	}
+	err = qp.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpStandIn is a minimal SMTP server accepting every message.
+type smtpStandIn struct {
+	ln   net.Listener
+	msgs chan receivedMsg
+}
+
+type receivedMsg struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpStandIn{
+		ln:   ln,
+		msgs: make(chan receivedMsg, 16),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = io.WriteString(conn, line+"\r\n")
+	}
+	reply("220 localhost ESMTP stand-in")
+
+	var msg receivedMsg
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = receivedMsg{from: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			s.msgs <- msg
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	s, err := NewSMTPSender(SMTPConf{
+		Addr: srv.ln.Addr().String(),
+		From: "Licensing <noreply@example.com>",
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = s.Send(ctx, &Message{
+		To:      "user@example.com",
+		Subject: "Licensė \"pro\"",
+		Body:    "License key: ABCD-EFGH\nLine with a very long text that must be wrapped by quoted-printable encoding = fine.\n",
+	})
+	require.NoError(t, err)
+
+	var got receivedMsg
+	select {
+	case got = <-srv.msgs:
+	case <-ctx.Done():
+		t.Fatal("message not received")
+	}
+	assert.Equal(t, "noreply@example.com", got.from)
+	assert.Equal(t, []string{"user@example.com"}, got.to)
+
+	m, err := mail.ReadMessage(strings.NewReader(got.data))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Licensė \"pro\"", subject)
+	assert.Equal(t, "<user@example.com>", m.Header.Get("To"))
+	assert.Empty(t, m.Header.Get("List-Unsubscribe"))
+	body, err := io.ReadAll(quotedprintable.NewReader(m.Body))
+	require.NoError(t, err)
+	assert.Equal(t, "License key: ABCD-EFGH\r\nLine with a very long text that must be wrapped by quoted-printable encoding = fine.\r\n", string(body))
+}
+
+func Test_compose_unsubscribe(t *testing.T) {
+	from := &mail.Address{Address: "noreply@example.com"}
+	to := &mail.Address{Address: "user@example.com"}
+	msg, err := compose(from, to, &Message{
+		To:          to.Address,
+		Subject:     "Overuse",
+		Body:        "Quota exceeded.",
+		Unsubscribe: "https://example.com/api/notifications/unsubscribe?token=abc.def",
+	}, time.Now())
+	require.NoError(t, err)
+
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	require.NoError(t, err)
+	assert.Equal(t, "<https://example.com/api/notifications/unsubscribe?token=abc.def>", m.Header.Get("List-Unsubscribe"))
+	assert.Equal(t, "List-Unsubscribe=One-Click", m.Header.Get("List-Unsubscribe-Post"))
+
+	_, err = compose(from, to, &Message{Subject: "Overuse", Unsubscribe: "https://example.com/\r\nBcc: x@example.com"}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestSMTPSender_Send_invalid(t *testing.T) {
+	_, err := NewSMTPSender(SMTPConf{Addr: "localhost", From: "noreply@example.com"})
+	assert.Error(t, err)
+	_, err = NewSMTPSender(SMTPConf{Addr: "localhost:25", From: "noreply"})
+	assert.Error(t, err)
+
+	s, err := NewSMTPSender(SMTPConf{Addr: "localhost:25", From: "noreply@example.com"})
+	require.NoError(t, err)
+	err = s.Send(context.Background(), &Message{To: "invalid"})
+	assert.Error(t, err)
+	err = s.Send(context.Background(), &Message{To: "user@example.com", Subject: "a\r\nBcc: other@example.com"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notification kinds.
+const (
+	KindLicenseDelivery    = "license-delivery"
+	KindExpiryReminder     = "expiry-reminder"
+	KindLicenseDeactivated = "license-deactivated"
+	KindOveruse            = "overuse"
+)
+
+// Kinds lists all notification kinds.
+var Kinds = []string{
+	KindLicenseDelivery,
+	KindExpiryReminder,
+	KindLicenseDeactivated,
+	KindOveruse,
+}
+
+func ValidKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Data is available to templates.
+type Data struct {
+	Issuer      string // Issuer's username.
+	IssuerEmail string
+
+	LicenseID    string // Base64 encoded.
+	LicenseName  string
+	LicenseKey   string     // Formatted, set for license delivery only.
+	ValidUntil   *time.Time // Nil if license doesn't expire.
+	DaysLeft     int        // Days until ValidUntil, set for expiry reminders.
+	Metrics      []string   // Metrics whose quota was exceeded, set for overuse.
+	CustomerName string
+
+	UnsubscribeURL string // Empty if unsubscribing isn't available.
+}
+
+// Template of a notification. Both subject and body are text/template
+// templates executed with Data.
+type Template struct {
+	Subject string
+	Body    string
+}
+
+var funcs = template.FuncMap{
+	"date": func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return t.UTC().Format("2006-01-02")
+	},
+	"join": strings.Join,
+}
+
+var defaultTemplates = map[string]Template{
+	KindLicenseDelivery: {
+		Subject: `Your license{{with .LicenseName}} "{{.}}"{{end}}`,
+		Body: `Hello{{with .CustomerName}} {{.}}{{end}},
+
+here is your license{{with .LicenseName}} "{{.}}"{{end}}.
+
+License key: {{.LicenseKey}}
+Valid until: {{date .ValidUntil}}
+
+Keep the key secret, anyone with it can use the license.
+{{with .IssuerEmail}}
+Contact {{.}} if you have any questions.
+{{end}}`,
+	},
+	KindExpiryReminder: {
+		Subject: `License{{with .LicenseName}} "{{.}}"{{end}} expires in {{.DaysLeft}} day(s)`,
+		Body: `Hello{{with .CustomerName}} {{.}}{{end}},
+
+license{{with .LicenseName}} "{{.}}"{{end}} ({{.LicenseID}}) expires on {{date .ValidUntil}}.
+{{with .IssuerEmail}}
+Contact {{.}} to renew it.
+{{end}}{{with .UnsubscribeURL}}
+Unsubscribe: {{.}}
+{{end}}`,
+	},
+	KindLicenseDeactivated: {
+		Subject: `License{{with .LicenseName}} "{{.}}"{{end}} has been deactivated`,
+		Body: `Hello{{with .CustomerName}} {{.}}{{end}},
+
+license{{with .LicenseName}} "{{.}}"{{end}} ({{.LicenseID}}) has been deactivated and can no longer be used.
+{{with .IssuerEmail}}
+Contact {{.}} if you have any questions.
+{{end}}{{with .UnsubscribeURL}}
+Unsubscribe: {{.}}
+{{end}}`,
+	},
+	KindOveruse: {
+		Subject: `License{{with .LicenseName}} "{{.}}"{{end}} has exceeded its quota`,
+		Body: `Hello{{with .CustomerName}} {{.}}{{end}},
+
+license{{with .LicenseName}} "{{.}}"{{end}} ({{.LicenseID}}) has reached its usage quota of: {{join .Metrics ", "}}.
+{{with .IssuerEmail}}
+Contact {{.}} to raise the quota.
+{{end}}{{with .UnsubscribeURL}}
+Unsubscribe: {{.}}
+{{end}}`,
+	},
+}
+
+// DefaultTemplate returns built-in template of the notification kind.
+func DefaultTemplate(kind string) (Template, bool) {
+	t, ok := defaultTemplates[kind]
+	return t, ok
+}
+
+// Validate parses the template and executes it with sample data.
+func (t Template) Validate() error {
+	now := time.Now()
+	_, _, err := t.Render(&Data{
+		Issuer:         "issuer",
+		IssuerEmail:    "issuer@example.com",
+		LicenseID:      "ID",
+		LicenseName:    "License",
+		LicenseKey:     "KEY",
+		ValidUntil:     &now,
+		DaysLeft:       1,
+		Metrics:        []string{"metric"},
+		CustomerName:   "Customer",
+		UnsubscribeURL: "https://example.com/unsubscribe",
+	})
+	return err
+}
+
+// Render executes the template. Subject is trimmed to a single line.
+func (t Template) Render(d *Data) (subject, body string, err error) {
+	subject, err = execute("subject", t.Subject, d)
+	if err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(strings.Join(strings.Fields(subject), " "))
+	if subject == "" {
+		return "", "", errors.New("subject: empty")
+	}
+	body, err = execute("body", t.Body, d)
+	if err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+func execute(name, text string, d *Data) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, d)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultTemplates(t *testing.T) {
+	for _, kind := range Kinds {
+		tmpl, ok := DefaultTemplate(kind)
+		require.True(t, ok, kind)
+		assert.NoError(t, tmpl.Validate(), kind)
+	}
+	_, ok := DefaultTemplate("unknown")
+	assert.False(t, ok)
+}
+
+func TestTemplate_Render(t *testing.T) {
+	validUntil := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	tmpl, _ := DefaultTemplate(KindExpiryReminder)
+	subject, body, err := tmpl.Render(&Data{
+		LicenseID:   "ID",
+		LicenseName: "Pro",
+		ValidUntil:  &validUntil,
+		DaysLeft:    7,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `License "Pro" expires in 7 day(s)`, subject)
+	assert.Equal(t, "Hello,\n\nlicense \"Pro\" (ID) expires on 2023-04-05.\n", body)
+
+	tests := []struct {
+		name string
+		tmpl Template
+	}{
+		{name: "syntax", tmpl: Template{Subject: "{{.LicenseName", Body: "body"}},
+		{name: "unknown field", tmpl: Template{Subject: "subject", Body: "{{.Unknown}}"}},
+		{name: "empty subject", tmpl: Template{Subject: " {{if false}}x{{end}}\n", Body: "body"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.tmpl.Validate())
+		})
+	}
+
+	subject, _, err = Template{Subject: "a\r\nb   c", Body: ""}.Render(&Data{})
+	require.NoError(t, err)
+	assert.Equal(t, "a b c", subject)
+}
@@ -2,9 +2,11 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/sewiti/licensing-system/internal/db"
 	"github.com/sewiti/licensing-system/internal/model"
 )
 
@@ -20,6 +22,17 @@ func (c *Core) NewProduct(ctx context.Context, li *model.LicenseIssuer, req *mod
 	if req.ContactEmail != "" && !ValidEmail(req.ContactEmail) {
 		return nil, fmt.Errorf("%w contact email", ErrInvalidInput)
 	}
+	schema := normalizeDataSchema(req.DataSchema)
+	if schema != nil {
+		s, err := compileDataSchema(schema)
+		if err != nil {
+			return nil, err
+		}
+		err = validateData(s, req.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	now := time.Now()
 	p := &model.Product{
@@ -30,16 +43,18 @@ func (c *Core) NewProduct(ctx context.Context, li *model.LicenseIssuer, req *mod
 		Created:      now,
 		Updated:      now,
 		IssuerID:     li.ID,
+		DataSchema:   schema,
 	}
 	var err error
 	p.ID, err = c.db.InsertProduct(ctx, p)
 	return p, handleErrDB(err, "creating product")
 }
 
+// Returns ErrInvalidInput
 // Returns SensitiveError
-func (c *Core) GetAllProductsByIssuer(ctx context.Context, licenseIssuerID int) ([]*model.Product, error) {
-	pp, err := c.db.SelectAllProductsByIssuerID(ctx, licenseIssuerID)
-	return pp, handleErrDB(err, "getting all products by issuer")
+func (c *Core) GetProductsByIssuer(ctx context.Context, licenseIssuerID int, f *model.ProductFilter, opts *model.ListOptions) ([]*model.Product, *model.Page, error) {
+	pp, page, err := c.db.SelectProductsByIssuerID(ctx, licenseIssuerID, f, opts)
+	return pp, page, handleErrDB(err, "getting products by issuer")
 }
 
 // Returns ErrNotFound
@@ -49,9 +64,14 @@ func (c *Core) GetProduct(ctx context.Context, productID int) (*model.Product, e
 	return p, handleErrDB(err, "getting product")
 }
 
+// UpdateProduct applies changes to the product. If ifMatch isn't nil, product
+// is updated only if it hasn't been updated since any of ifMatch timestamps.
+//
+// Returns ErrNotFound
 // Returns ErrInvalidInput
+// Returns ErrPreconditionFailed
 // Returns SensitiveError
-func (c *Core) UpdateProduct(ctx context.Context, p *model.Product, changes map[string]struct{}) error {
+func (c *Core) UpdateProduct(ctx context.Context, p *model.Product, changes map[string]struct{}, ifMatch []time.Time) error {
 	update := map[string]interface{}{
 		"updated": time.Now(),
 	}
@@ -71,21 +91,122 @@ func (c *Core) UpdateProduct(ctx context.Context, p *model.Product, changes map[
 		}
 		update["contact_email"] = p.ContactEmail
 	}
-	if _, ok := changes["data"]; ok {
+	_, schemaChanged := changes["dataSchema"]
+	_, dataChanged := changes["data"]
+	if schemaChanged || dataChanged {
+		err := c.validateProductData(ctx, p, schemaChanged, dataChanged)
+		if err != nil {
+			return err
+		}
+	}
+	if schemaChanged {
+		update["data_schema"] = p.DataSchema
+	}
+	if dataChanged {
 		update["data"] = p.Data
 	}
 
-	err := c.db.UpdateProduct(ctx, p.ID, update)
+	err := c.db.UpdateProduct(ctx, p.ID, update, ifMatch)
 	return handleErrDB(err, "updating product")
 }
 
+// validateProductData validates changed product data or data schema. Unchanged
+// one is taken from the current product. Licenses' data isn't validated, see
+// CheckProductLicensesData.
+//
+// Returns ErrNotFound
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) validateProductData(ctx context.Context, p *model.Product, schemaChanged, dataChanged bool) error {
+	if schemaChanged {
+		p.DataSchema = normalizeDataSchema(p.DataSchema)
+	}
+	schema, data := p.DataSchema, p.Data
+	if !schemaChanged || !dataChanged {
+		cur, err := c.GetProduct(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if !schemaChanged {
+			schema = cur.DataSchema
+		}
+		if !dataChanged {
+			data = cur.Data
+		}
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+	s, err := compileDataSchema(schema)
+	if err != nil {
+		return err
+	}
+	return validateData(s, data)
+}
+
+// DeleteProduct moves the product and its licenses to trash and closes
+// sessions of these licenses. If ifMatch isn't nil, product is deleted only if
+// it hasn't been updated since any of ifMatch timestamps.
+//
 // Returns ErrNotFound
+// Returns ErrPreconditionFailed
 // Returns SensitiveError
-func (c *Core) DeleteProduct(ctx context.Context, productID, licenseIssuerID int) error {
-	_, err := c.db.DeleteProductByID(ctx, productID, licenseIssuerID)
+func (c *Core) DeleteProduct(ctx context.Context, productID, licenseIssuerID int, ifMatch []time.Time) error {
+	now := time.Now()
+	err := c.db.InTx(ctx, func(tx *db.Handler) error {
+		_, err := tx.SoftDeleteProductByID(ctx, productID, licenseIssuerID, now, ifMatch)
+		if err != nil {
+			return err
+		}
+		_, err = tx.SoftDeleteLicensesByProductID(ctx, productID, now)
+		if err != nil {
+			return err
+		}
+		_, err = tx.DeleteLicenseSessionsByProductID(ctx, productID, now)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil // No sessions
+		}
+		return err
+	})
 	return handleErrDB(err, "deleting product")
 }
 
+// RestoreProduct restores the product from trash together with licenses,
+// which were deleted with it.
+//
+// Returns ErrNotFound
+// Returns ErrExceedsLimit
+// Returns SensitiveError
+func (c *Core) RestoreProduct(ctx context.Context, productID, licenseIssuerID int) (*model.Product, error) {
+	err := c.db.InTx(ctx, func(tx *db.Handler) error {
+		li, err := tx.SelectLicenseIssuerByIDForUpdate(ctx, licenseIssuerID)
+		if err != nil {
+			return err
+		}
+		p, err := tx.SelectDeletedProductByID(ctx, productID, li.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.RestoreProductByID(ctx, p.ID, li.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.RestoreLicensesByProductID(ctx, p.ID, *p.Deleted)
+		if err != nil {
+			return err
+		}
+		return c.checkMaxLicenses(ctx, tx, li)
+	})
+	if errors.Is(err, ErrExceedsLimit) {
+		return nil, err
+	}
+	err = handleErrDB(err, "restoring product")
+	if err != nil {
+		return nil, err
+	}
+	return c.GetProduct(ctx, productID)
+}
+
 func (c *Core) AuthorizeProductUpdate(login *model.LicenseIssuer) (updateMask []string, delete bool) {
-	return []string{"active", "name", "contactEmail", "data"}, true
+	return []string{"active", "name", "contactEmail", "data", "dataSchema"}, true
 }
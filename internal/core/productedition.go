@@ -0,0 +1,167 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/db"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) NewProductEdition(ctx context.Context, p *model.Product, req *model.ProductEdition) (*model.ProductEdition, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w request", ErrInvalidInput)
+	}
+	err := validateProductEdition(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	e := &model.ProductEdition{
+		Name:      req.Name,
+		Features:  req.Features,
+		Channels:  req.Channels,
+		Data:      req.Data,
+		Created:   now,
+		Updated:   now,
+		ProductID: p.ID,
+	}
+	e.ID, err = c.db.InsertProductEdition(ctx, e)
+	return e, handleErrDB(err, "creating product edition")
+}
+
+// Returns SensitiveError
+func (c *Core) GetAllProductEditions(ctx context.Context, productID int) ([]*model.ProductEdition, error) {
+	ee, err := c.db.SelectAllProductEditionsByProductID(ctx, productID)
+	return ee, handleErrDB(err, "getting all product editions")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) GetProductEdition(ctx context.Context, productEditionID int) (*model.ProductEdition, error) {
+	e, err := c.db.SelectProductEditionByID(ctx, productEditionID)
+	return e, handleErrDB(err, "getting product edition")
+}
+
+// GetLicenseEdition returns edition of the license, nil if license has none.
+//
+// Returns SensitiveError
+func (c *Core) GetLicenseEdition(ctx context.Context, l *model.License) (*model.ProductEdition, error) {
+	if l.EditionID == nil {
+		return nil, nil
+	}
+	e, err := c.db.SelectProductEditionByID(ctx, *l.EditionID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil // deleted concurrently
+	}
+	return e, handleErrDB(err, "getting license edition")
+}
+
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) UpdateProductEdition(ctx context.Context, e *model.ProductEdition, changes map[string]struct{}) error {
+	update := map[string]interface{}{
+		"updated": time.Now(),
+	}
+
+	if _, ok := changes["name"]; ok {
+		if !ValidProductEditionName(e.Name) {
+			return fmt.Errorf("%w name", ErrInvalidInput)
+		}
+		update["name"] = e.Name
+	}
+	if _, ok := changes["features"]; ok {
+		if !ValidLicenseFeatures(e.Features) {
+			return fmt.Errorf("%w features", ErrInvalidInput)
+		}
+		update["features"] = pq.Array(e.Features)
+	}
+	if _, ok := changes["channels"]; ok {
+		if !ValidReleaseChannels(e.Channels) {
+			return fmt.Errorf("%w channels", ErrInvalidInput)
+		}
+		update["channels"] = pq.Array(e.Channels)
+	}
+	if _, ok := changes["data"]; ok {
+		update["data"] = e.Data
+	}
+
+	err := c.db.UpdateProductEdition(ctx, e.ID, e.ProductID, update)
+	return handleErrDB(err, "updating product edition")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) DeleteProductEdition(ctx context.Context, productEditionID, productID int) error {
+	_, err := c.db.DeleteProductEditionByID(ctx, productEditionID, productID)
+	return handleErrDB(err, "deleting product edition")
+}
+
+func (c *Core) AuthorizeProductEditionUpdate(login *model.LicenseIssuer) (updateMask []string, delete bool) {
+	return []string{"name", "features", "channels", "data"}, true
+}
+
+func validateProductEdition(e *model.ProductEdition) error {
+	if !ValidProductEditionName(e.Name) {
+		return fmt.Errorf("%w name", ErrInvalidInput)
+	}
+	if !ValidLicenseFeatures(e.Features) {
+		return fmt.Errorf("%w features", ErrInvalidInput)
+	}
+	if !ValidReleaseChannels(e.Channels) {
+		return fmt.Errorf("%w channels", ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkLicenseEdition checks whether edition exists and is an edition of the
+// license's product.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) checkLicenseEdition(ctx context.Context, editionID int, productID *int) error {
+	if productID == nil {
+		return fmt.Errorf("%w edition: license has no product", ErrInvalidInput)
+	}
+	e, err := c.db.SelectProductEditionByID(ctx, editionID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return handleErrDB(err, "getting product edition")
+	}
+	if err != nil || e.ProductID != *productID {
+		return fmt.Errorf("%w edition", ErrInvalidInput)
+	}
+	return nil
+}
+
+// LicenseChannels returns release channels the license is entitled to, i.e.,
+// its own and its edition's, without duplicates. Edition may be nil.
+func LicenseChannels(l *model.License, e *model.ProductEdition) []string {
+	channels := appendUnique(make([]string, 0, len(l.Channels)), l.Channels...)
+	if e != nil {
+		channels = appendUnique(channels, e.Channels...)
+	}
+	return channels
+}
+
+// appendUnique appends elements not yet present in the slice.
+func appendUnique(s []string, elems ...string) []string {
+	for _, v := range elems {
+		found := false
+		for _, w := range s {
+			if v == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s = append(s, v)
+		}
+	}
+	return s
+}
@@ -0,0 +1,16 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLicenseChannels(t *testing.T) {
+	l := &model.License{Channels: []string{"beta"}}
+	e := &model.ProductEdition{Channels: []string{"stable", "beta"}}
+	assert.Equal(t, []string{"beta", "stable"}, LicenseChannels(l, e))
+	assert.Equal(t, []string{"beta"}, LicenseChannels(l, nil))
+	assert.Equal(t, []string{}, LicenseChannels(&model.License{}, nil))
+}
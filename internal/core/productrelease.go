@@ -0,0 +1,635 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	cryptorand "crypto/rand"
+
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+// DefaultReleaseChannel is the release channel of licenses entitled to no
+// channels.
+const DefaultReleaseChannel = "stable"
+
+const maxReleaseArtifacts = 20
+
+// downloadTokenPrefix separates download token signatures from release
+// manifest ones.
+const downloadTokenPrefix = "download-token:"
+
+// Returns ErrInvalidInput
+// Returns ErrReleasesDisabled
+// Returns ErrDuplicate
+// Returns SensitiveError
+func (c *Core) NewProductRelease(ctx context.Context, p *model.Product, req *model.ProductRelease) (*model.ProductRelease, error) {
+	if req == nil {
+		return nil, fmt.Errorf("%w request", ErrInvalidInput)
+	}
+	err := validateProductRelease(req)
+	if err != nil {
+		return nil, err
+	}
+	err = c.checkReleaseEditions(ctx, req.EditionIDs, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := c.releaseArtifacts(p.IssuerID, req.Artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pr := &model.ProductRelease{
+		Version:       req.Version,
+		Channel:       req.Channel,
+		Notes:         req.Notes,
+		EditionIDs:    req.EditionIDs,
+		MinAppVersion: req.MinAppVersion,
+		Artifacts:     artifacts,
+		Released:      req.Released,
+		Created:       now,
+		Updated:       now,
+		ProductID:     p.ID,
+	}
+	if pr.Released.IsZero() {
+		pr.Released = now
+	}
+	pr.ID, err = c.db.InsertProductRelease(ctx, pr)
+	return pr, handleErrDB(err, "creating product release")
+}
+
+// Returns SensitiveError
+func (c *Core) GetAllProductReleases(ctx context.Context, productID int) ([]*model.ProductRelease, error) {
+	rr, err := c.db.SelectAllProductReleasesByProductID(ctx, productID)
+	return rr, handleErrDB(err, "getting all product releases")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) GetProductRelease(ctx context.Context, productReleaseID int) (*model.ProductRelease, error) {
+	pr, err := c.db.SelectProductReleaseByID(ctx, productReleaseID)
+	return pr, handleErrDB(err, "getting product release")
+}
+
+// Returns ErrInvalidInput
+// Returns ErrReleasesDisabled
+// Returns ErrDuplicate
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) UpdateProductRelease(ctx context.Context, pr *model.ProductRelease, changes map[string]struct{}) error {
+	update := map[string]interface{}{
+		"updated": time.Now(),
+	}
+
+	if _, ok := changes["version"]; ok {
+		if !ValidReleaseVersion(pr.Version) {
+			return fmt.Errorf("%w version", ErrInvalidInput)
+		}
+		update["version"] = pr.Version
+	}
+	if _, ok := changes["channel"]; ok {
+		if !ValidReleaseChannels([]string{pr.Channel}) {
+			return fmt.Errorf("%w channel", ErrInvalidInput)
+		}
+		update["channel"] = pr.Channel
+	}
+	if _, ok := changes["notes"]; ok {
+		update["notes"] = pr.Notes
+	}
+	if _, ok := changes["editionIDs"]; ok {
+		if pr.EditionIDs == nil {
+			pr.EditionIDs = make([]int64, 0)
+		}
+		err := c.checkReleaseEditions(ctx, pr.EditionIDs, pr.ProductID)
+		if err != nil {
+			return err
+		}
+		update["edition_ids"] = pq.Array(pr.EditionIDs)
+	}
+	if _, ok := changes["minAppVersion"]; ok {
+		if pr.MinAppVersion != "" && !ValidReleaseVersion(pr.MinAppVersion) {
+			return fmt.Errorf("%w min app version", ErrInvalidInput)
+		}
+		update["min_app_version"] = pr.MinAppVersion
+	}
+	if _, ok := changes["artifacts"]; ok {
+		p, err := c.GetProduct(ctx, pr.ProductID)
+		if err != nil {
+			return err
+		}
+		artifacts, err := c.releaseArtifacts(p.IssuerID, pr.Artifacts)
+		if err != nil {
+			return err
+		}
+		update["artifacts"] = artifacts
+	}
+	if _, ok := changes["released"]; ok {
+		if pr.Released.IsZero() {
+			return fmt.Errorf("%w released", ErrInvalidInput)
+		}
+		update["released"] = pr.Released
+	}
+
+	err := c.db.UpdateProductRelease(ctx, pr.ID, pr.ProductID, update)
+	return handleErrDB(err, "updating product release")
+}
+
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) DeleteProductRelease(ctx context.Context, productReleaseID, productID int) error {
+	_, err := c.db.DeleteProductReleaseByID(ctx, productReleaseID, productID)
+	return handleErrDB(err, "deleting product release")
+}
+
+func (c *Core) AuthorizeProductReleaseUpdate(login *model.LicenseIssuer) (updateMask []string, delete bool) {
+	return []string{"version", "channel", "notes", "editionIDs", "minAppVersion", "artifacts", "released"}, true
+}
+
+// ReleaseManifest returns manifest of releases license session is entitled to
+// along with its signature made by the server key.
+//
+// Returns ErrTimeOutOfSync
+// Returns ErrLicenseExpired
+// Returns ErrLicenseInactive
+// Returns ErrLicenseIsAddon
+// Returns ErrProductInactive
+// Returns ErrLicenseIssuerDisabled
+// Returns ErrLicenseSessionExpired
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) ReleaseManifest(ctx context.Context, ls *model.LicenseSession, l *model.License, clientTime time.Time) (manifest, sig []byte, err error) {
+	now := time.Now()
+	if !c.timeInSync(now, clientTime) {
+		return nil, nil, ErrTimeOutOfSync
+	}
+	if now.After(ls.Expire) {
+		return nil, nil, ErrLicenseSessionExpired
+	}
+	err = c.checkLicenseUsable(ctx, l, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := &model.ReleaseManifest{
+		LicenseID:  l.ID,
+		EditionID:  l.EditionID,
+		AppVersion: ls.AppVersion,
+		Issued:     now,
+		Expire:     now.Add(c.downloadExpiry),
+		Releases:   make([]model.ManifestRelease, 0),
+	}
+	if l.ProductID != nil {
+		m.ProductID = *l.ProductID
+		rr, err := c.GetAllProductReleases(ctx, *l.ProductID)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := c.GetLicenseEdition(ctx, l)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pr := range entitledReleases(rr, l, e, ls.AppVersion, now) {
+			mr := model.ManifestRelease{
+				Version:   pr.Version,
+				Channel:   pr.Channel,
+				Notes:     pr.Notes,
+				Released:  pr.Released,
+				Artifacts: make([]model.ManifestArtifact, len(pr.Artifacts)),
+			}
+			for i, a := range pr.Artifacts {
+				token, err := c.downloadToken(pr.ID, a.Name, m.Expire)
+				if err != nil {
+					return nil, nil, err
+				}
+				mr.Artifacts[i] = model.ManifestArtifact{
+					Name:   a.Name,
+					Size:   a.Size,
+					SHA256: a.SHA256,
+					Token:  token,
+				}
+			}
+			m.Releases = append(m.Releases, mr)
+		}
+	}
+
+	manifest, err = json.Marshal(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err = util.Sign(cryptorand.Reader, c.serverKey, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, sig, nil
+}
+
+// OpenReleaseArtifact opens release artifact of the download token. Caller
+// must close the file.
+//
+// Returns ErrReleasesDisabled
+// Returns ErrInvalidInput
+// Returns ErrDownloadExpired
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) OpenReleaseArtifact(ctx context.Context, token string) (*model.ReleaseArtifact, *os.File, error) {
+	if c.releasesDir == "" {
+		return nil, nil, ErrReleasesDisabled
+	}
+	t, err := c.parseDownloadToken(token, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	pr, err := c.GetProductRelease(ctx, t.ReleaseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := c.GetProduct(ctx, pr.ProductID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, a := range pr.Artifacts {
+		if a.Name != t.Artifact {
+			continue
+		}
+		name, err := c.artifactFile(p.IssuerID, a.Path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, nil, ErrNotFound
+			}
+			return nil, nil, &SensitiveError{Message: "resolving release artifact", Err: err}
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, nil, ErrNotFound
+			}
+			return nil, nil, &SensitiveError{Message: "opening release artifact", Err: err}
+		}
+		return &a, f, nil
+	}
+	return nil, nil, ErrNotFound
+}
+
+// checkLicenseUsable checks whether license can be used by its sessions.
+//
+// Returns ErrLicenseExpired
+// Returns ErrLicenseInactive
+// Returns ErrLicenseIsAddon
+// Returns ErrProductInactive
+// Returns ErrLicenseIssuerDisabled
+// Returns ErrNotFound
+// Returns SensitiveError
+func (c *Core) checkLicenseUsable(ctx context.Context, l *model.License, now time.Time) error {
+	if !l.Active {
+		return ErrLicenseInactive
+	}
+	if l.ParentID != nil {
+		return ErrLicenseIsAddon
+	}
+	if l.ValidUntil != nil && l.ValidUntil.Before(now) {
+		return ErrLicenseExpired
+	}
+	li, err := c.GetLicenseIssuer(ctx, l.IssuerID)
+	if err != nil {
+		return err
+	}
+	if !li.Active {
+		return ErrLicenseIssuerDisabled
+	}
+	if l.ProductID != nil {
+		p, err := c.GetProduct(ctx, *l.ProductID)
+		if err != nil {
+			return err
+		}
+		if !p.Active {
+			return ErrProductInactive
+		}
+	}
+	return nil
+}
+
+func validateProductRelease(pr *model.ProductRelease) error {
+	if !ValidReleaseVersion(pr.Version) {
+		return fmt.Errorf("%w version", ErrInvalidInput)
+	}
+	if !ValidReleaseChannels([]string{pr.Channel}) {
+		return fmt.Errorf("%w channel", ErrInvalidInput)
+	}
+	if pr.MinAppVersion != "" && !ValidReleaseVersion(pr.MinAppVersion) {
+		return fmt.Errorf("%w min app version", ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkReleaseEditions checks whether editions exist and are editions of the
+// product.
+//
+// Returns ErrInvalidInput
+// Returns SensitiveError
+func (c *Core) checkReleaseEditions(ctx context.Context, editionIDs []int64, productID int) error {
+	for _, id := range editionIDs {
+		err := c.checkLicenseEdition(ctx, int(id), &productID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseArtifacts resolves artifact files in issuer's releases directory,
+// calculating their sizes and hashes.
+//
+// Returns ErrInvalidInput
+// Returns ErrReleasesDisabled
+// Returns SensitiveError
+func (c *Core) releaseArtifacts(issuerID int, req model.ReleaseArtifacts) (model.ReleaseArtifacts, error) {
+	if len(req) == 0 {
+		return model.ReleaseArtifacts{}, nil
+	}
+	if c.releasesDir == "" {
+		return nil, ErrReleasesDisabled
+	}
+	if len(req) > maxReleaseArtifacts {
+		return nil, fmt.Errorf("%w artifacts: too many", ErrInvalidInput)
+	}
+
+	names := make(map[string]struct{}, len(req))
+	artifacts := make(model.ReleaseArtifacts, len(req))
+	for i, a := range req {
+		path, ok := localArtifactPath(a.Path)
+		if !ok {
+			return nil, fmt.Errorf("%w artifact %d path", ErrInvalidInput, i)
+		}
+		name := a.Name
+		if name == "" {
+			name = filepath.Base(filepath.FromSlash(path))
+		}
+		if len(name) > 255 || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("%w artifact %d name", ErrInvalidInput, i)
+		}
+		if _, ok := names[name]; ok {
+			return nil, fmt.Errorf("%w artifact %d name: duplicate", ErrInvalidInput, i)
+		}
+		names[name] = struct{}{}
+
+		var size int64
+		var hash []byte
+		file, err := c.artifactFile(issuerID, path)
+		if err == nil {
+			size, hash, err = hashFile(file)
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("%w artifact %d path: file not found", ErrInvalidInput, i)
+			}
+			return nil, &SensitiveError{Message: "hashing release artifact", Err: err}
+		}
+		artifacts[i] = model.ReleaseArtifact{
+			Name:   name,
+			Path:   path,
+			Size:   size,
+			SHA256: hash,
+		}
+	}
+	return artifacts, nil
+}
+
+// localArtifactPath cleans slash separated path and reports whether it stays
+// within the releases directory.
+func localArtifactPath(path string) (string, bool) {
+	if path == "" || strings.HasPrefix(path, "/") || strings.Contains(path, `\`) {
+		return "", false
+	}
+	path = filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+	if path == "." || path == ".." || strings.HasPrefix(path, "../") || filepath.IsAbs(path) {
+		return "", false
+	}
+	return path, true
+}
+
+// artifactFile returns file name of artifact path in issuer's releases
+// directory, `<releasesDir>/<issuerID>/<path>`. Symbolic links are followed
+// only within the issuer's directory, ones leading outside of it are treated
+// as missing files.
+func (c *Core) artifactFile(issuerID int, path string) (string, error) {
+	dir, err := filepath.EvalSymlinks(filepath.Join(c.releasesDir, strconv.Itoa(issuerID)))
+	if err != nil {
+		return "", err
+	}
+	name, err := filepath.EvalSymlinks(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(name, dir+string(filepath.Separator)) {
+		return "", os.ErrNotExist
+	}
+	return name, nil
+}
+
+func hashFile(name string) (size int64, hash []byte, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return 0, nil, os.ErrNotExist
+	}
+	h := sha256.New()
+	size, err = io.Copy(h, f)
+	if err != nil {
+		return 0, nil, err
+	}
+	return size, h.Sum(nil), nil
+}
+
+// entitledReleases filters releases the license is entitled to. Edition may be
+// nil.
+func entitledReleases(rr []*model.ProductRelease, l *model.License, e *model.ProductEdition, appVersion string, now time.Time) []*model.ProductRelease {
+	channels := LicenseChannels(l, e)
+	if len(channels) == 0 {
+		channels = []string{DefaultReleaseChannel}
+	}
+
+	var entitled []*model.ProductRelease
+	for _, pr := range rr {
+		if pr.Released.After(now) {
+			continue // not released yet
+		}
+		if l.UpdatesUntil != nil && pr.Released.After(*l.UpdatesUntil) {
+			continue // subscription of updates has ended
+		}
+		if !containsString(channels, pr.Channel) {
+			continue
+		}
+		if len(pr.EditionIDs) > 0 && (l.EditionID == nil || !containsInt64(pr.EditionIDs, int64(*l.EditionID))) {
+			continue
+		}
+		if pr.MinAppVersion != "" && (appVersion == "" || compareVersions(appVersion, pr.MinAppVersion) < 0) {
+			continue
+		}
+		entitled = append(entitled, pr)
+	}
+	return entitled
+}
+
+func containsString(s []string, v string) bool {
+	for _, w := range s {
+		if v == w {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt64(s []int64, v int64) bool {
+	for _, w := range s {
+		if v == w {
+			return true
+		}
+	}
+	return false
+}
+
+// compareVersions compares versions part by part, numerically where
+// possible, e.g., 1.10 > 1.9. Leading "v" and build metadata are ignored,
+// pre-release precedes its release, e.g., 1.0.0-rc.1 < 1.0.0.
+//
+// Returns -1 if a < b, 0 if a == b and +1 if a > b.
+func compareVersions(a, b string) int {
+	a, aPre := splitVersion(a)
+	b, bPre := splitVersion(b)
+	if cmp := compareVersionParts(a, b); cmp != 0 {
+		return cmp
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	default:
+		return compareVersionParts(aPre, bPre)
+	}
+}
+
+// splitVersion splits version into its release and pre-release parts.
+func splitVersion(v string) (release, pre string) {
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+// compareVersionParts compares dot separated parts, missing parts are zeros.
+func compareVersionParts(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := "0", "0"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case xErr == nil:
+			return -1 // numeric parts precede alphanumeric ones
+		case yErr == nil:
+			return 1
+		default:
+			if cmp := strings.Compare(x, y); cmp != 0 {
+				return cmp
+			}
+		}
+	}
+	return 0
+}
+
+type downloadToken struct {
+	ReleaseID int    `json:"r"`
+	Artifact  string `json:"a"`
+	Expire    int64  `json:"e"` // Unix seconds
+}
+
+// downloadToken returns token granting download of release artifact until it
+// expires. Token is signed by the server key.
+func (c *Core) downloadToken(releaseID int, artifact string, expire time.Time) (string, error) {
+	payload, err := json.Marshal(downloadToken{
+		ReleaseID: releaseID,
+		Artifact:  artifact,
+		Expire:    expire.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	sig, err := util.Sign(cryptorand.Reader, c.serverKey, append([]byte(downloadTokenPrefix), payload...))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Returns ErrInvalidInput
+// Returns ErrDownloadExpired
+func (c *Core) parseDownloadToken(token string, now time.Time) (*downloadToken, error) {
+	invalid := fmt.Errorf("%w download token", ErrInvalidInput)
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return nil, invalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return nil, invalid
+	}
+	if !util.Verify(c.serverID, append([]byte(downloadTokenPrefix), payload...), sig) {
+		return nil, invalid
+	}
+	var t downloadToken
+	err = json.Unmarshal(payload, &t)
+	if err != nil {
+		return nil, invalid
+	}
+	if now.Unix() >= t.Expire {
+		return nil, ErrDownloadExpired
+	}
+	return &t, nil
+}
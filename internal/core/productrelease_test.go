@@ -0,0 +1,197 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_compareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0", "1.0.0", 0},
+		{"1.0.0+build.1", "1.0.0", 0},
+		{"1.9.0", "1.10.0", -1},
+		{"2.0", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, compareVersions(tt.a, tt.b))
+			assert.Equal(t, -tt.want, compareVersions(tt.b, tt.a))
+		})
+	}
+}
+
+func Test_entitledReleases(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	updatesUntil := now.Add(-24 * time.Hour)
+	pro := 2
+
+	stable := &model.ProductRelease{ID: 1, Version: "1.0.0", Channel: "stable", Released: now.Add(-48 * time.Hour)}
+	beta := &model.ProductRelease{ID: 2, Version: "1.1.0-beta.1", Channel: "beta", Released: now.Add(-48 * time.Hour)}
+	proOnly := &model.ProductRelease{ID: 3, Version: "1.0.1", Channel: "stable", EditionIDs: []int64{2}, Released: now.Add(-48 * time.Hour)}
+	gated := &model.ProductRelease{ID: 4, Version: "2.0.0", Channel: "stable", MinAppVersion: "1.5", Released: now.Add(-48 * time.Hour)}
+	late := &model.ProductRelease{ID: 5, Version: "1.0.2", Channel: "stable", Released: now.Add(-time.Hour)}
+	future := &model.ProductRelease{ID: 6, Version: "3.0.0", Channel: "stable", Released: now.Add(time.Hour)}
+	rr := []*model.ProductRelease{stable, beta, proOnly, gated, late, future}
+
+	tests := []struct {
+		name       string
+		l          *model.License
+		e          *model.ProductEdition
+		appVersion string
+		want       []*model.ProductRelease
+	}{
+		{
+			name:       "default channel",
+			l:          &model.License{},
+			appVersion: "1.0.0",
+			want:       []*model.ProductRelease{stable, late},
+		},
+		{
+			name:       "edition channels",
+			l:          &model.License{EditionID: &pro},
+			e:          &model.ProductEdition{ID: pro, Channels: []string{"stable", "beta"}},
+			appVersion: "1.6",
+			want:       []*model.ProductRelease{stable, beta, proOnly, gated, late},
+		},
+		{
+			name: "unknown app version",
+			l:    &model.License{Channels: []string{"stable"}},
+			want: []*model.ProductRelease{stable, late},
+		},
+		{
+			name:       "updates until",
+			l:          &model.License{UpdatesUntil: &updatesUntil},
+			appVersion: "2.0.0",
+			want:       []*model.ProductRelease{stable, gated},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, entitledReleases(rr, tt.l, tt.e, tt.appVersion, now))
+		})
+	}
+}
+
+func Test_localArtifactPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{"app/1.0.0/app.tar.gz", "app/1.0.0/app.tar.gz", true},
+		{"app/../app.zip", "app.zip", true},
+		{"./app.zip", "app.zip", true},
+		{"", "", false},
+		{".", "", false},
+		{"/etc/passwd", "", false},
+		{"../secret", "", false},
+		{"app/../../secret", "", false},
+		{`app\..\..\secret`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := localArtifactPath(tt.path)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_releaseArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("release artifact")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "3", "app", "1.0.0"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "3", "app", "1.0.0", "app.tar.gz"), content, 0644))
+	hash := sha256.Sum256(content)
+
+	c := &Core{releasesDir: dir}
+	got, err := c.releaseArtifacts(3, model.ReleaseArtifacts{{Path: "app/1.0.0/app.tar.gz"}})
+	require.NoError(t, err)
+	assert.Equal(t, model.ReleaseArtifacts{{
+		Name:   "app.tar.gz",
+		Path:   "app/1.0.0/app.tar.gz",
+		Size:   int64(len(content)),
+		SHA256: hash[:],
+	}}, got)
+
+	_, err = c.releaseArtifacts(3, model.ReleaseArtifacts{{Path: "app/1.0.0/missing.tar.gz"}})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	_, err = c.releaseArtifacts(3, model.ReleaseArtifacts{{Path: "app/1.0.0"}})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	_, err = c.releaseArtifacts(3, model.ReleaseArtifacts{{Path: "app/1.0.0/app.tar.gz"}, {Path: "app/1.0.0/app.tar.gz"}})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = (&Core{}).releaseArtifacts(3, model.ReleaseArtifacts{{Path: "app/1.0.0/app.tar.gz"}})
+	assert.ErrorIs(t, err, ErrReleasesDisabled)
+}
+
+func Test_releaseArtifacts_otherIssuer(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "3", "app"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "4"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "5"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "3", "app", "app.tar.gz"), []byte("issuer 3 artifact"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "3", "app"), filepath.Join(dir, "4", "app")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(dir, "4", "secret.txt")))
+
+	c := &Core{releasesDir: dir}
+	tests := []struct {
+		name     string
+		issuerID int
+		path     string
+	}{
+		{"other issuer's path", 5, "app/app.tar.gz"},
+		{"traversal", 5, "../3/app/app.tar.gz"},
+		{"symlink to other issuer", 4, "app/app.tar.gz"},
+		{"symlink outside", 4, "secret.txt"},
+		{"no issuer directory", 6, "app/app.tar.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.releaseArtifacts(tt.issuerID, model.ReleaseArtifacts{{Path: tt.path}})
+			assert.ErrorIs(t, err, ErrInvalidInput)
+		})
+	}
+
+	_, err := c.releaseArtifacts(3, model.ReleaseArtifacts{{Path: "app/app.tar.gz"}})
+	assert.NoError(t, err)
+}
+
+func TestCore_downloadToken(t *testing.T) {
+	id, key, err := util.GenerateKey(bytes.NewReader(bytes.Repeat([]byte{7}, 32)))
+	require.NoError(t, err)
+	c := &Core{serverID: id, serverKey: key}
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	token, err := c.downloadToken(3, "app.tar.gz", now.Add(time.Hour))
+	require.NoError(t, err)
+
+	got, err := c.parseDownloadToken(token, now)
+	require.NoError(t, err)
+	assert.Equal(t, &downloadToken{ReleaseID: 3, Artifact: "app.tar.gz", Expire: now.Add(time.Hour).Unix()}, got)
+
+	_, err = c.parseDownloadToken(token, now.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrDownloadExpired)
+	_, err = c.parseDownloadToken("x"+token, now)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	_, err = c.parseDownloadToken("invalid", now)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
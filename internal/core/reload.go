@@ -0,0 +1,78 @@
+package core
+
+import "errors"
+
+// ReloadConf defines settings, which can be changed while licensing server is
+// running.
+type ReloadConf struct {
+	MinPasswdEntropy float64
+	Refresh          RefreshConf
+	Limiter          LimiterConf
+}
+
+func (cfg ReloadConf) validate() error {
+	if cfg.MinPasswdEntropy < 0 {
+		return errors.New("minimum password entropy must be greater or equal to zero")
+	}
+	if cfg.Refresh.Min <= 0 {
+		return errors.New("refresh min must be greater than zero")
+	}
+	if cfg.Refresh.Max < cfg.Refresh.Min {
+		return errors.New("refresh max must be greater or equal to refresh min")
+	}
+	if cfg.Refresh.Jitter < 0 || cfg.Refresh.Jitter > 1 {
+		return errors.New("refresh jitter must be between 0 and 1")
+	}
+	if cfg.Limiter.SessionEvery <= 0 {
+		return errors.New("limiter session every must be greater than zero")
+	}
+	if cfg.Limiter.BurstTotal < 0 {
+		return errors.New("limiter burst total must be greater or equal to zero")
+	}
+	return nil
+}
+
+// Reload applies new settings. Session limiter state is reset only if limiter
+// settings have changed.
+//
+// Returns error if settings are invalid, in which case none of them are
+// applied.
+func (c *Core) Reload(cfg ReloadConf) error {
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.minPasswdEntropy = cfg.MinPasswdEntropy
+	c.refresh = cfg.Refresh
+	if c.lim == nil || c.limiter != cfg.Limiter {
+		c.lim = newSessionLimiter(c.db, cfg.Limiter)
+		c.limiter = cfg.Limiter
+	}
+	return nil
+}
+
+func (c *Core) refreshConf() RefreshConf {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.refresh
+}
+
+func (c *Core) limiterConf() LimiterConf {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.limiter
+}
+
+func (c *Core) sessionLimiter() sessionLimiter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lim
+}
+
+func (c *Core) passwdEntropy() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.minPasswdEntropy
+}
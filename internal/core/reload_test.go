@@ -0,0 +1,89 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCore_Reload(t *testing.T) {
+	valid := ReloadConf{
+		MinPasswdEntropy: 30,
+		Refresh:          RefreshConf{Min: 5 * time.Minute, Max: 2 * time.Hour, Jitter: 0.1},
+		Limiter:          LimiterConf{SessionEvery: 10 * time.Minute, BurstTotal: 8 * time.Hour},
+	}
+	tests := []struct {
+		name    string
+		modify  func(cfg *ReloadConf)
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			modify: func(cfg *ReloadConf) {},
+		},
+		{
+			name:    "negative entropy",
+			modify:  func(cfg *ReloadConf) { cfg.MinPasswdEntropy = -1 },
+			wantErr: true,
+		},
+		{
+			name:    "zero refresh min",
+			modify:  func(cfg *ReloadConf) { cfg.Refresh.Min = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "refresh max below min",
+			modify:  func(cfg *ReloadConf) { cfg.Refresh.Max = time.Minute },
+			wantErr: true,
+		},
+		{
+			name:    "jitter out of range",
+			modify:  func(cfg *ReloadConf) { cfg.Refresh.Jitter = 1.5 },
+			wantErr: true,
+		},
+		{
+			name:    "zero session every",
+			modify:  func(cfg *ReloadConf) { cfg.Limiter.SessionEvery = 0 },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			c := &Core{minPasswdEntropy: 10}
+			err := c.Reload(cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, 10.0, c.passwdEntropy(), "settings must be left intact")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, cfg.MinPasswdEntropy, c.passwdEntropy())
+			assert.Equal(t, cfg.Refresh, c.refreshConf())
+			assert.Equal(t, cfg.Limiter, c.limiterConf())
+		})
+	}
+}
+
+func TestCore_Reload_limiter(t *testing.T) {
+	cfg := ReloadConf{
+		Refresh: RefreshConf{Min: 5 * time.Minute, Max: 2 * time.Hour},
+		Limiter: LimiterConf{SessionEvery: 10 * time.Minute, BurstTotal: 8 * time.Hour},
+	}
+	c := &Core{}
+	require.NoError(t, c.Reload(cfg))
+	lim := c.sessionLimiter()
+	require.NotNil(t, lim)
+
+	cfg.MinPasswdEntropy = 20
+	require.NoError(t, c.Reload(cfg))
+	assert.Same(t, lim, c.sessionLimiter(), "unchanged limiter settings must keep limiter state")
+
+	cfg.Limiter.SessionEvery = 5 * time.Minute
+	require.NoError(t, c.Reload(cfg))
+	assert.NotSame(t, lim, c.sessionLimiter())
+}
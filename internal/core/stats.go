@@ -0,0 +1,16 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// GetServerStats counts licensing server's resources.
+//
+// Returns SensitiveError
+func (c *Core) GetServerStats(ctx context.Context) (*model.ServerStats, error) {
+	s, err := c.db.SelectServerStats(ctx, time.Now())
+	return s, handleErrDB(err, "getting server stats")
+}
@@ -1,8 +1,11 @@
 package core
 
 import (
+	"crypto/sha256"
 	"net/mail"
 	"strings"
+
+	"github.com/sewiti/licensing-system/internal/model"
 )
 
 func ValidUsername(username string) bool {
@@ -61,6 +64,44 @@ func ValidLicenseTags(tags []string) bool {
 	return true
 }
 
+func ValidLicenseTemplateName(name string) bool {
+	const (
+		minLen = 1
+		maxLen = 64
+	)
+	return len(name) >= minLen && len(name) <= maxLen
+}
+
+// ValidLicenseFeatures reports whether features are valid. Feature names may
+// contain only [A-Za-z0-9_.:-] characters.
+func ValidLicenseFeatures(features []string) bool {
+	const (
+		maxFeatures = 50
+
+		minFeatureLen = 1
+		maxFeatureLen = 64
+	)
+	if len(features) > maxFeatures {
+		return false
+	}
+	for _, f := range features {
+		if len(f) < minFeatureLen || len(f) > maxFeatureLen {
+			return false
+		}
+		for _, r := range f {
+			switch {
+			case strings.ContainsRune("_.:-", r),
+				r >= 'a' && r <= 'z',
+				r >= 'A' && r <= 'Z',
+				r >= '0' && r <= '9':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func ValidEmail(email string) bool {
 	const maxLen = 128
 	if len(email) > maxLen {
@@ -119,3 +160,208 @@ func ValidProductName(name string) bool {
 	const maxLen = 64
 	return len(name) <= maxLen
 }
+
+func ValidProductEditionName(name string) bool {
+	const (
+		minLen = 1
+		maxLen = 64
+	)
+	return len(name) >= minLen && len(name) <= maxLen
+}
+
+// ValidReleaseChannels reports whether release channels are valid. Channel
+// names may contain only [a-z0-9_.-] characters.
+func ValidReleaseChannels(channels []string) bool {
+	const (
+		maxChannels = 10
+
+		minChannelLen = 1
+		maxChannelLen = 32
+	)
+	if len(channels) > maxChannels {
+		return false
+	}
+	for _, ch := range channels {
+		if len(ch) < minChannelLen || len(ch) > maxChannelLen {
+			return false
+		}
+		for _, r := range ch {
+			switch {
+			case strings.ContainsRune("_.-", r),
+				r >= 'a' && r <= 'z',
+				r >= '0' && r <= '9':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ValidReleaseVersion reports whether release version is valid, e.g., 1.2.0
+// or v2.0.0-rc.1. Versions may contain only [A-Za-z0-9_.+-] characters.
+func ValidReleaseVersion(version string) bool {
+	const (
+		minLen = 1
+		maxLen = 64
+	)
+	if len(version) < minLen || len(version) > maxLen {
+		return false
+	}
+	for _, r := range version {
+		switch {
+		case strings.ContainsRune("_.+-", r),
+			r >= 'a' && r <= 'z',
+			r >= 'A' && r <= 'Z',
+			r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidUsageMetric reports whether usage metric name is valid. Metric names
+// may contain only [a-z0-9_.:-] characters.
+func ValidUsageMetric(metric string) bool {
+	const (
+		minLen = 1
+		maxLen = 64
+	)
+	if len(metric) < minLen || len(metric) > maxLen {
+		return false
+	}
+	for _, r := range metric {
+		switch {
+		case strings.ContainsRune("_.:-", r),
+			r >= 'a' && r <= 'z',
+			r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// maxUsageMetrics limits number of metrics reported at once or having quotas.
+const maxUsageMetrics = 32
+
+// ValidLicenseUsage reports whether usage reported by a client is valid.
+func ValidLicenseUsage(usage map[string]int64) bool {
+	const maxAmount = 1 << 40
+	if len(usage) > maxUsageMetrics {
+		return false
+	}
+	for m, amount := range usage {
+		if !ValidUsageMetric(m) || amount < 0 || amount > maxAmount {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidUsageQuotas reports whether license's usage quotas are valid.
+func ValidUsageQuotas(quotas model.UsageQuotas) bool {
+	if len(quotas) > maxUsageMetrics {
+		return false
+	}
+	for m, quota := range quotas {
+		if !ValidUsageMetric(m) || quota < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidMaxTransfers reports whether license's max transfers is valid. Zero
+// disables transfers and -1 means unlimited.
+func ValidMaxTransfers(maxTransfers int) bool {
+	const limit = 10000
+	return maxTransfers >= -1 && maxTransfers <= limit
+}
+
+func ValidCustomerName(name string) bool {
+	const (
+		minLen = 1
+		maxLen = 128
+	)
+	return len(name) >= minLen && len(name) <= maxLen
+}
+
+func ValidCustomerCompany(company string) bool {
+	const maxLen = 128
+	return len(company) <= maxLen
+}
+
+// ValidCustomerEmails reports whether customer's emails are valid, they must
+// be lower case.
+func ValidCustomerEmails(emails []string) bool {
+	const maxEmails = 10
+	if len(emails) > maxEmails {
+		return false
+	}
+	for _, e := range emails {
+		if !ValidEmail(e) || e != strings.ToLower(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func ValidCustomerExternalID(externalID string) bool {
+	const maxLen = 128
+	return len(externalID) <= maxLen
+}
+
+// ValidIdempotencyKey reports whether key consists of printable ASCII
+// characters.
+func ValidIdempotencyKey(key string) bool {
+	const (
+		minLen = 1
+		maxLen = 255
+	)
+	if len(key) < minLen || len(key) > maxLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidClientCertFingerprint reports whether fingerprint is a lower case hex
+// SHA-256 digest.
+func ValidClientCertFingerprint(fingerprint string) bool {
+	if len(fingerprint) != 2*sha256.Size {
+		return false
+	}
+	for _, r := range fingerprint {
+		switch {
+		case r >= '0' && r <= '9',
+			r >= 'a' && r <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidClientCertSAN reports whether subject alternative name consists of
+// printable ASCII characters.
+func ValidClientCertSAN(san string) bool {
+	const (
+		minLen = 1
+		maxLen = 255
+	)
+	if len(san) < minLen || len(san) > maxLen {
+		return false
+	}
+	for i := 0; i < len(san); i++ {
+		if san[i] < '!' || san[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 
@@ -42,6 +43,24 @@ func TestValidLicenseTags(t *testing.T) {
 	}
 }
 
+func TestValidLicenseFeatures(t *testing.T) {
+	tests := []struct {
+		features []string
+		want     bool
+	}{
+		{[]string{"export", "pro.reports", "api:v2"}, true},
+		{[]string{}, true},
+		{[]string{""}, false},
+		{[]string{"with space"}, false},
+		{[]string{"maxlengthmaxlengthmaxlengthmaxlengthmaxlengthmaxlengthmaxlengthmaxlengthmaxlength"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.features, ";"), func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidLicenseFeatures(tt.features))
+		})
+	}
+}
+
 func TestValidEmail(t *testing.T) {
 	tests := []struct {
 		email string
@@ -77,3 +96,133 @@ func TestValidPhoneNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestValidReleaseChannels(t *testing.T) {
+	tests := []struct {
+		channels []string
+		want     bool
+	}{
+		{[]string{"stable", "beta", "lts-2.x"}, true},
+		{[]string{}, true},
+		{[]string{""}, false},
+		{[]string{"Beta"}, false},
+		{[]string{"with space"}, false},
+		{[]string{"maxlengthmaxlengthmaxlengthmaxlength"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.channels, ";"), func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidReleaseChannels(tt.channels))
+		})
+	}
+}
+
+func TestValidReleaseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"1.2.0", true},
+		{"v2.0.0-rc.1+build.5", true},
+		{"", false},
+		{"1.0 beta", false},
+		{"1.0/2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidReleaseVersion(tt.version))
+		})
+	}
+}
+
+func TestValidCustomerEmails(t *testing.T) {
+	tests := []struct {
+		emails []string
+		want   bool
+	}{
+		{[]string{"john@acme.com", "billing@acme.com"}, true},
+		{[]string{}, true},
+		{[]string{""}, false},
+		{[]string{"John@acme.com"}, false},
+		{[]string{"not an email"}, false},
+		{[]string{"a@b.c", "a@b.c", "a@b.c", "a@b.c", "a@b.c", "a@b.c", "a@b.c", "a@b.c", "a@b.c", "a@b.c", "a@b.c"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.emails, ";"), func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidCustomerEmails(tt.emails))
+		})
+	}
+}
+
+func TestValidIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"order-1234", true},
+		{"9f3c2b1e-6f0a-4c55-9c1e-0d2b7a8e4f10", true},
+		{"", false},
+		{"order 1234", false},
+		{"užsakymas", false},
+		{strings.Repeat("k", 256), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidIdempotencyKey(tt.key))
+		})
+	}
+}
+
+func TestValidMaxTransfers(t *testing.T) {
+	tests := []struct {
+		maxTransfers int
+		want         bool
+	}{
+		{-1, true},
+		{0, true},
+		{3, true},
+		{10000, true},
+		{-2, false},
+		{10001, false},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.maxTransfers), func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidMaxTransfers(tt.maxTransfers))
+		})
+	}
+}
+
+func TestValidClientCertFingerprint(t *testing.T) {
+	tests := []struct {
+		fingerprint string
+		want        bool
+	}{
+		{strings.Repeat("ab", 32), true},
+		{strings.Repeat("AB", 32), false},
+		{strings.Repeat("ab", 31), false},
+		{strings.Repeat("zz", 32), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fingerprint, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidClientCertFingerprint(tt.fingerprint))
+		})
+	}
+}
+
+func TestValidClientCertSAN(t *testing.T) {
+	tests := []struct {
+		san  string
+		want bool
+	}{
+		{"backend.example.com", true},
+		{"spiffe://example.com/backend", true},
+		{"", false},
+		{"back end", false},
+		{strings.Repeat("a", 256), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.san, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidClientCertSAN(tt.san))
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const clientCertTable = "client_cert"
+
+func (h *Handler) InsertClientCert(ctx context.Context, cc *model.ClientCert) (int, error) {
+	const (
+		action = "Insert"
+		scope  = clientCertTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"fingerprint": cc.Fingerprint,
+			"san":         cc.SAN,
+			"note":        cc.Note,
+			"created":     cc.Created,
+			"issuer_id":   cc.IssuerID,
+		}).Suffix("RETURNING id")
+
+	var id int
+	return id, h.execInsert(ctx, sq, scope, action, &id)
+}
+
+func (h *Handler) SelectAllClientCertsByIssuerID(ctx context.Context, licenseIssuerID int) ([]*model.ClientCert, error) {
+	return h.selectClientCerts(ctx, "SelectAllByIssuerID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"issuer_id": licenseIssuerID,
+			}).OrderBy("id")
+		})
+}
+
+func (h *Handler) SelectClientCertByID(ctx context.Context, clientCertID int) (*model.ClientCert, error) {
+	return h.selectClientCert(ctx, "SelectByID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id": clientCertID,
+			})
+		})
+}
+
+// SelectClientCertsByIdentity selects client certs registered with either
+// the fingerprint or any of subject alternative names.
+func (h *Handler) SelectClientCertsByIdentity(ctx context.Context, fingerprint string, sans []string) ([]*model.ClientCert, error) {
+	return h.selectClientCerts(ctx, "SelectByIdentity",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Or{
+				squirrel.Eq{"fingerprint": fingerprint},
+				squirrel.Eq{"san": sans},
+			}).OrderBy("id")
+		})
+}
+
+func (h *Handler) selectClientCert(ctx context.Context, action string, d selectDecorator) (*model.ClientCert, error) {
+	cc, err := h.selectClientCerts(ctx, action, d)
+	if err != nil {
+		return nil, err
+	}
+	if len(cc) == 0 {
+		return nil, &Error{err: ErrNotFound, Scope: clientCertTable, Action: action}
+	}
+	return cc[0], nil
+}
+
+func (h *Handler) selectClientCerts(ctx context.Context, action string, d selectDecorator) ([]*model.ClientCert, error) {
+	const scope = clientCertTable
+
+	sq := h.sq.Select(
+		"id",
+		"fingerprint",
+		"san",
+		"note",
+		"created",
+		"issuer_id",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var cc []*model.ClientCert
+	for rows.Next() {
+		c := &model.ClientCert{}
+		err = rows.Scan(
+			&c.ID,
+			&c.Fingerprint,
+			&c.SAN,
+			&c.Note,
+			&c.Created,
+			&c.IssuerID,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		cc = append(cc, c)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return cc, nil
+}
+
+func (h *Handler) DeleteClientCertByID(ctx context.Context, clientCertID, licenseIssuerID int) (int, error) {
+	const scope = clientCertTable
+	sq := h.sq.Delete(scope).
+		Where(squirrel.Eq{
+			"id":        clientCertID,
+			"issuer_id": licenseIssuerID,
+		})
+	return h.execDelete(ctx, sq, scope, "DeleteByID")
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertClientCert(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	san := "backend.example.com"
+	cc := &model.ClientCert{
+		SAN:      &san,
+		Note:     "Backend",
+		Created:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		IssuerID: 5,
+	}
+
+	mock.ExpectQuery("INSERT INTO client_cert (created,fingerprint,issuer_id,note,san) VALUES ($1,$2,$3,$4,$5) RETURNING id").
+		WithArgs(cc.Created, nil, cc.IssuerID, cc.Note, san).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	id, err := h.InsertClientCert(context.Background(), cc)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+}
+
+func TestHandler_SelectClientCertsByIdentity(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	san := "backend.example.com"
+	fingerprint := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := []*model.ClientCert{
+		{ID: 2, SAN: &san, Created: created, IssuerID: 5},
+		{ID: 3, Fingerprint: &fingerprint, Created: created, IssuerID: 5},
+	}
+
+	mock.ExpectQuery("SELECT id, fingerprint, san, note, created, issuer_id FROM client_cert WHERE (fingerprint = $1 OR san IN ($2,$3)) ORDER BY id").
+		WithArgs(fingerprint, san, "admin@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "fingerprint", "san", "note", "created", "issuer_id"}).
+			AddRow(2, nil, san, "", created, 5).
+			AddRow(3, fingerprint, nil, "", created, 5))
+
+	got, err := h.SelectClientCertsByIdentity(context.Background(), fingerprint, []string{san, "admin@example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestHandler_SelectClientCertsByIdentity_none(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mock.ExpectQuery("SELECT id, fingerprint, san, note, created, issuer_id FROM client_cert WHERE (fingerprint = $1 OR (1=0)) ORDER BY id").
+		WithArgs("ab").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "fingerprint", "san", "note", "created", "issuer_id"}))
+
+	got, err := h.SelectClientCertsByIdentity(context.Background(), "ab", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestHandler_DeleteClientCertByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mock.ExpectExec("DELETE FROM client_cert WHERE id = $1 AND issuer_id = $2").
+		WithArgs(2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := h.DeleteClientCertByID(context.Background(), 2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const customerTable = "customer"
+
+var customerList = &listSpec{
+	id: sortKey{
+		column: "id",
+		value:  func(item interface{}) interface{} { return item.(*model.Customer).ID },
+		decode: decodeInt,
+	},
+	sortKeys: map[string]sortKey{
+		"id": {
+			column: "id",
+			value:  func(item interface{}) interface{} { return item.(*model.Customer).ID },
+			decode: decodeInt,
+		},
+		"created": {
+			column: "created",
+			value:  func(item interface{}) interface{} { return item.(*model.Customer).Created },
+			decode: decodeTime,
+		},
+		"updated": {
+			column: "updated",
+			value:  func(item interface{}) interface{} { return item.(*model.Customer).Updated },
+			decode: decodeTime,
+		},
+		"name": {
+			column: "name",
+			value:  func(item interface{}) interface{} { return item.(*model.Customer).Name },
+			decode: decodeString,
+		},
+		"company": {
+			column: "company",
+			value:  func(item interface{}) interface{} { return item.(*model.Customer).Company },
+			decode: decodeString,
+		},
+	},
+	defaultSort:  "id",
+	defaultOrder: []string{"name", "id"},
+	search:       "name || ' ' || company || ' ' || external_id",
+}
+
+func (h *Handler) InsertCustomer(ctx context.Context, cu *model.Customer) (int, error) {
+	const (
+		action = "Insert"
+		scope  = customerTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"name":        cu.Name,
+			"company":     cu.Company,
+			"emails":      pq.Array(cu.Emails),
+			"external_id": cu.ExternalID,
+			"note":        cu.Note,
+			"created":     cu.Created,
+			"updated":     cu.Updated,
+			"issuer_id":   cu.IssuerID,
+		}).Suffix("RETURNING id")
+
+	var id int
+	return id, h.execInsert(ctx, sq, scope, action, &id)
+}
+
+// SelectCustomersByIssuerID selects a page of issuer's customers matching the
+// filter.
+func (h *Handler) SelectCustomersByIssuerID(ctx context.Context, licenseIssuerID int, f *model.CustomerFilter, opts *model.ListOptions) ([]*model.Customer, *model.Page, error) {
+	const (
+		scope  = customerTable
+		action = "SelectByIssuerID"
+	)
+	where := squirrel.And{
+		squirrel.Eq{"issuer_id": licenseIssuerID},
+	}
+	if f != nil && f.Email != "" {
+		where = append(where, squirrel.Expr("? = ANY(emails)", strings.ToLower(f.Email)))
+	}
+	if f != nil && f.ExternalID != "" {
+		where = append(where, squirrel.Eq{"external_id": f.ExternalID})
+	}
+	q, err := customerList.query(where, opts)
+	if err != nil {
+		return nil, nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	cc, err := h.selectCustomers(ctx, action, q.decorate)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, n, err := h.listPage(ctx, scope, action, q, len(cc), func(i int) interface{} { return cc[i] })
+	if err != nil {
+		return nil, nil, err
+	}
+	return cc[:n], page, nil
+}
+
+func (h *Handler) SelectCustomerByID(ctx context.Context, customerID int) (*model.Customer, error) {
+	const action = "SelectByID"
+	cc, err := h.selectCustomers(ctx, action,
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id": customerID,
+			})
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(cc) == 0 {
+		return nil, &Error{err: ErrNotFound, Scope: customerTable, Action: action}
+	}
+	return cc[0], nil
+}
+
+func (h *Handler) selectCustomers(ctx context.Context, action string, d selectDecorator) ([]*model.Customer, error) {
+	const scope = customerTable
+
+	sq := h.sq.Select(
+		"id",
+		"name",
+		"company",
+		"emails",
+		"external_id",
+		"note",
+		"created",
+		"updated",
+		"issuer_id",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var cc []*model.Customer
+	for rows.Next() {
+		cu := &model.Customer{}
+		err = rows.Scan(
+			&cu.ID,
+			&cu.Name,
+			&cu.Company,
+			pq.Array(&cu.Emails),
+			&cu.ExternalID,
+			&cu.Note,
+			&cu.Created,
+			&cu.Updated,
+			&cu.IssuerID,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		cc = append(cc, cu)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return cc, nil
+}
+
+func (h *Handler) UpdateCustomer(ctx context.Context, customerID int, update map[string]interface{}) error {
+	const (
+		action = "Update"
+		scope  = customerTable
+	)
+	sq := h.sq.Update(scope).
+		SetMap(update).
+		Where(squirrel.Eq{
+			"id": customerID,
+		})
+	return h.execUpdate(ctx, sq, scope, action)
+}
+
+func (h *Handler) DeleteCustomerByID(ctx context.Context, customerID, licenseIssuerID int) (int, error) {
+	const scope = customerTable
+	sq := h.sq.Delete(scope).
+		Where(squirrel.Eq{
+			"id":        customerID,
+			"issuer_id": licenseIssuerID,
+		})
+	return h.execDelete(ctx, sq, scope, "DeleteByID")
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertCustomer(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	cu := &model.Customer{
+		ID:         4,
+		Name:       "John Doe",
+		Company:    "ACME",
+		Emails:     []string{"john@acme.com"},
+		ExternalID: "crm-42",
+		Note:       "VIP",
+		Created:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Updated:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		IssuerID:   3,
+	}
+
+	mock.ExpectQuery("INSERT INTO customer (company,created,emails,external_id,issuer_id,name,note,updated) VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id").
+		WithArgs(
+			cu.Company,
+			cu.Created,
+			pq.Array(cu.Emails),
+			cu.ExternalID,
+			cu.IssuerID,
+			cu.Name,
+			cu.Note,
+			cu.Updated,
+		).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(cu.ID))
+
+	id, err := h.InsertCustomer(context.Background(), cu)
+	assert.NoError(t, err)
+	assert.Equal(t, cu.ID, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectCustomersByIssuerID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	issuerID := 5
+	expected := []*model.Customer{
+		{
+			ID:       2,
+			Name:     "John Doe",
+			Company:  "ACME",
+			Emails:   []string{"john@acme.com", "billing@acme.com"},
+			Created:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			Updated:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			IssuerID: issuerID,
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "name", "company", "emails", "external_id", "note", "created", "updated", "issuer_id"})
+	for _, cu := range expected {
+		rows.AddRow(cu.ID, cu.Name, cu.Company, pq.Array(cu.Emails), cu.ExternalID, cu.Note, cu.Created, cu.Updated, cu.IssuerID)
+	}
+	mock.ExpectQuery("SELECT id, name, company, emails, external_id, note, created, updated, issuer_id FROM customer WHERE (issuer_id = $1 AND $2 = ANY(emails)) ORDER BY name, id").
+		WithArgs(issuerID, "john@acme.com").
+		WillReturnRows(rows)
+
+	got, page, err := h.SelectCustomersByIssuerID(context.Background(), issuerID, &model.CustomerFilter{Email: "John@ACME.com"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.Equal(t, &model.Page{Total: 1}, page)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectCustomerByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	const query = "SELECT id, name, company, emails, external_id, note, created, updated, issuer_id FROM customer WHERE id = $1"
+	columns := []string{"id", "name", "company", "emails", "external_id", "note", "created", "updated", "issuer_id"}
+	expected := &model.Customer{
+		ID:         2,
+		Name:       "John Doe",
+		Emails:     []string{},
+		ExternalID: "crm-42",
+		Created:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Updated:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		IssuerID:   5,
+	}
+	mock.ExpectQuery(query).
+		WithArgs(expected.ID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			expected.ID, expected.Name, expected.Company, "{}", expected.ExternalID,
+			expected.Note, expected.Created, expected.Updated, expected.IssuerID,
+		))
+
+	got, err := h.SelectCustomerByID(context.Background(), expected.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+
+	mock.ExpectQuery(query).
+		WithArgs(expected.ID).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	_, err = h.SelectCustomerByID(context.Background(), expected.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_DeleteCustomerByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mock.ExpectExec("DELETE FROM customer WHERE id = $1 AND issuer_id = $2").
+		WithArgs(2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := h.DeleteCustomerByID(context.Background(), 2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
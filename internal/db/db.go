@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"strings"
 
@@ -41,3 +42,34 @@ func Open(dataSource string) (*Handler, error) {
 func (h *Handler) Close() error {
 	return h.db.Close()
 }
+
+func (h *Handler) Ping(ctx context.Context) error {
+	err := h.db.PingContext(ctx)
+	if err != nil {
+		return &Error{err: err, Scope: "db", Action: "Ping"}
+	}
+	return nil
+}
+
+// InTx runs fn in a transaction, which is committed if fn returns nil and
+// rolled back otherwise. Handler given to fn must not be used outside of it.
+func (h *Handler) InTx(ctx context.Context, fn func(tx *Handler) error) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return &Error{err: err, Scope: "db", Action: "Begin"}
+	}
+	defer tx.Rollback() // no-op after commit
+
+	err = fn(&Handler{
+		db: h.db,
+		sq: h.sq.RunWith(tx),
+	})
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return &Error{err: err, Scope: "db", Action: "Commit"}
+	}
+	return nil
+}
@@ -8,6 +8,13 @@ import (
 var (
 	ErrNotFound  = errors.New("not found")
 	ErrDuplicate = errors.New("duplicate")
+	ErrLocked    = errors.New("locked")
+
+	// ErrPreconditionFailed is returned when conditional update or delete
+	// didn't match the current row.
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	ErrInvalidArgument = errors.New("invalid argument")
 )
 
 type Error struct {
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const idempotencyKeyTable = "idempotency_key"
+
+// InsertIdempotencyKey inserts idempotency key, unless issuer already has
+// one with the same key.
+//
+// Reports whether key has been inserted.
+func (h *Handler) InsertIdempotencyKey(ctx context.Context, k *model.IdempotencyKey) (bool, error) {
+	const (
+		action = "Insert"
+		scope  = idempotencyKeyTable
+	)
+	defer observeQuery(scope, action, time.Now())
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"issuer_id":    k.IssuerID,
+			"key":          k.Key,
+			"request_hash": k.RequestHash,
+			"status_code":  k.StatusCode,
+			"header":       k.Header,
+			"body":         k.Body,
+			"created":      k.Created,
+			"locked_until": k.LockedUntil,
+		}).
+		Suffix("ON CONFLICT (issuer_id, key) DO NOTHING")
+
+	res, err := sq.ExecContext(ctx)
+	if err != nil {
+		return false, &Error{err: err, Scope: scope, Action: action}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{err: err, Scope: scope, Action: action}
+	}
+	return n > 0, nil
+}
+
+func (h *Handler) SelectIdempotencyKey(ctx context.Context, licenseIssuerID int, key string) (*model.IdempotencyKey, error) {
+	const (
+		action = "Select"
+		scope  = idempotencyKeyTable
+	)
+	defer observeQuery(scope, action, time.Now())
+	sq := h.sq.Select(
+		"issuer_id",
+		"key",
+		"request_hash",
+		"status_code",
+		"header",
+		"body",
+		"created",
+		"locked_until",
+	).From(scope).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"key":       key,
+		})
+
+	k := &model.IdempotencyKey{}
+	err := sq.QueryRowContext(ctx).Scan(
+		&k.IssuerID,
+		&k.Key,
+		&k.RequestHash,
+		&k.StatusCode,
+		&k.Header,
+		&k.Body,
+		&k.Created,
+		&k.LockedUntil,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &Error{err: ErrNotFound, Scope: scope, Action: action}
+		}
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return k, nil
+}
+
+// UpdateIdempotencyKey updates issuer's key created at the time, so that the
+// same key taken over by another request isn't updated.
+func (h *Handler) UpdateIdempotencyKey(ctx context.Context, licenseIssuerID int, key string, created time.Time, update map[string]interface{}) error {
+	const (
+		action = "Update"
+		scope  = idempotencyKeyTable
+	)
+	sq := h.sq.Update(scope).
+		SetMap(update).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"key":       key,
+			"created":   created,
+		})
+	return h.execUpdate(ctx, sq, scope, action)
+}
+
+// TakeOverIdempotencyKey reserves issuer's key of a request in progress,
+// whose lease is lockedUntil, for another request. Key is taken over only if
+// it's still in progress with the same lease, i.e., nobody took it over or
+// finished it meanwhile.
+//
+// Reports whether key has been taken over.
+func (h *Handler) TakeOverIdempotencyKey(ctx context.Context, k *model.IdempotencyKey, lockedUntil *time.Time) (bool, error) {
+	const scope = idempotencyKeyTable
+	sq := h.sq.Update(scope).
+		SetMap(map[string]interface{}{
+			"created":      k.Created,
+			"locked_until": k.LockedUntil,
+		}).
+		Where(squirrel.Eq{
+			"issuer_id":    k.IssuerID,
+			"key":          k.Key,
+			"request_hash": k.RequestHash,
+			"status_code":  0,
+			"locked_until": lockedUntil,
+		})
+	n, err := h.execUpdateCount(ctx, sq, scope, "TakeOver")
+	return n > 0, err
+}
+
+// RenewIdempotencyKey extends lease of issuer's key reserved by a request in
+// progress until lockedUntil.
+//
+// Reports whether key is still reserved by the request, i.e., it hasn't been
+// taken over, finished or deleted meanwhile.
+func (h *Handler) RenewIdempotencyKey(ctx context.Context, k *model.IdempotencyKey, lockedUntil time.Time) (bool, error) {
+	const scope = idempotencyKeyTable
+	sq := h.sq.Update(scope).
+		Set("locked_until", lockedUntil).
+		Where(squirrel.Eq{
+			"issuer_id":   k.IssuerID,
+			"key":         k.Key,
+			"created":     k.Created,
+			"status_code": 0,
+		})
+	n, err := h.execUpdateCount(ctx, sq, scope, "Renew")
+	return n > 0, err
+}
+
+// DeleteIdempotencyKey deletes issuer's key created at the time, so that the
+// same key reserved again by another request isn't deleted.
+func (h *Handler) DeleteIdempotencyKey(ctx context.Context, licenseIssuerID int, key string, created time.Time) (int, error) {
+	sq := h.sq.Delete(idempotencyKeyTable).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"key":       key,
+			"created":   created,
+		})
+	return h.execDelete(ctx, sq, idempotencyKeyTable, "Delete")
+}
+
+// DeleteIdempotencyKeysCreatedBefore deletes expired keys created before t.
+func (h *Handler) DeleteIdempotencyKeysCreatedBefore(ctx context.Context, t time.Time) (int, error) {
+	sq := h.sq.Delete(idempotencyKeyTable).
+		Where(squirrel.Lt{
+			"created": t,
+		})
+	return h.execDelete(ctx, sq, idempotencyKeyTable, "DeleteCreatedBefore")
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertIdempotencyKey(t *testing.T) {
+	const query = "INSERT INTO idempotency_key (body,created,header,issuer_id,key,locked_until,request_hash,status_code) VALUES ($1,$2,$3,$4,$5,$6,$7,$8) ON CONFLICT (issuer_id, key) DO NOTHING"
+
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	lockedUntil := time.Date(2022, 1, 5, 0, 5, 0, 0, time.UTC)
+	k := &model.IdempotencyKey{
+		IssuerID:    3,
+		Key:         "order-1234",
+		RequestHash: []byte{1, 2, 3},
+		Created:     time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+		LockedUntil: &lockedUntil,
+	}
+
+	mock.ExpectExec(query).
+		WithArgs(k.Body, k.Created, nil, k.IssuerID, k.Key, k.LockedUntil, k.RequestHash, k.StatusCode).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err := h.InsertIdempotencyKey(context.Background(), k)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectExec(query).
+		WithArgs(k.Body, k.Created, nil, k.IssuerID, k.Key, k.LockedUntil, k.RequestHash, k.StatusCode).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	ok, err = h.InsertIdempotencyKey(context.Background(), k)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectIdempotencyKey(t *testing.T) {
+	const query = "SELECT issuer_id, key, request_hash, status_code, header, body, created, locked_until FROM idempotency_key WHERE issuer_id = $1 AND key = $2"
+
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	expected := &model.IdempotencyKey{
+		IssuerID:    3,
+		Key:         "order-1234",
+		RequestHash: []byte{1, 2, 3},
+		StatusCode:  201,
+		Header:      model.ResponseHeader{"Etag": {`"abc"`}},
+		Body:        []byte(`{"id":1}`),
+		Created:     time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+
+	mock.ExpectQuery(query).
+		WithArgs(expected.IssuerID, expected.Key).
+		WillReturnRows(sqlmock.NewRows([]string{"issuer_id", "key", "request_hash", "status_code", "header", "body", "created", "locked_until"}).
+			AddRow(expected.IssuerID, expected.Key, expected.RequestHash, expected.StatusCode, []byte(`{"Etag":["\"abc\""]}`), expected.Body, expected.Created, nil))
+	got, err := h.SelectIdempotencyKey(context.Background(), expected.IssuerID, expected.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+
+	mock.ExpectQuery(query).
+		WithArgs(expected.IssuerID, "missing").
+		WillReturnError(sql.ErrNoRows)
+	_, err = h.SelectIdempotencyKey(context.Background(), expected.IssuerID, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_DeleteIdempotencyKey(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	created := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM idempotency_key WHERE created = $1 AND issuer_id = $2 AND key = $3").
+		WithArgs(created, 3, "order-1234").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := h.DeleteIdempotencyKey(context.Background(), 3, "order-1234", created)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_UpdateIdempotencyKey(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	created := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE idempotency_key SET status_code = $1 WHERE created = $2 AND issuer_id = $3 AND key = $4").
+		WithArgs(201, created, 3, "order-1234").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = h.UpdateIdempotencyKey(context.Background(), 3, "order-1234", created, map[string]interface{}{"status_code": 201})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_TakeOverIdempotencyKey(t *testing.T) {
+	const query = "UPDATE idempotency_key SET created = $1, locked_until = $2 WHERE issuer_id = $3 AND key = $4 AND locked_until = $5 AND request_hash = $6 AND status_code = $7"
+
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	prevLockedUntil := time.Date(2022, 1, 5, 0, 5, 0, 0, time.UTC)
+	lockedUntil := time.Date(2022, 1, 5, 1, 5, 0, 0, time.UTC)
+	k := &model.IdempotencyKey{
+		IssuerID:    3,
+		Key:         "order-1234",
+		RequestHash: []byte{1, 2, 3},
+		Created:     time.Date(2022, 1, 5, 1, 0, 0, 0, time.UTC),
+		LockedUntil: &lockedUntil,
+	}
+
+	mock.ExpectExec(query).
+		WithArgs(k.Created, k.LockedUntil, k.IssuerID, k.Key, prevLockedUntil, k.RequestHash, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err := h.TakeOverIdempotencyKey(context.Background(), k, &prevLockedUntil)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectExec("UPDATE idempotency_key SET created = $1, locked_until = $2 WHERE issuer_id = $3 AND key = $4 AND locked_until IS NULL AND request_hash = $5 AND status_code = $6").
+		WithArgs(k.Created, k.LockedUntil, k.IssuerID, k.Key, k.RequestHash, 0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	ok, err = h.TakeOverIdempotencyKey(context.Background(), k, nil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_RenewIdempotencyKey(t *testing.T) {
+	const query = "UPDATE idempotency_key SET locked_until = $1 WHERE created = $2 AND issuer_id = $3 AND key = $4 AND status_code = $5"
+
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	lockedUntil := time.Date(2022, 1, 5, 0, 10, 0, 0, time.UTC)
+	k := &model.IdempotencyKey{
+		IssuerID: 3,
+		Key:      "order-1234",
+		Created:  time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+
+	mock.ExpectExec(query).
+		WithArgs(lockedUntil, k.Created, k.IssuerID, k.Key, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err := h.RenewIdempotencyKey(context.Background(), k, lockedUntil)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectExec(query).
+		WithArgs(lockedUntil, k.Created, k.IssuerID, k.Key, 0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	ok, err = h.RenewIdempotencyKey(context.Background(), k, lockedUntil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
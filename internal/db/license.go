@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/lib/pq"
@@ -10,25 +11,84 @@ import (
 
 const licenseTable = "license"
 
+var licenseList = &listSpec{
+	id: sortKey{
+		column: "id",
+		value:  func(item interface{}) interface{} { return item.(*model.License).ID },
+		decode: decodeBytes,
+	},
+	sortKeys: map[string]sortKey{
+		"created": {
+			column: "created",
+			value:  func(item interface{}) interface{} { return item.(*model.License).Created },
+			decode: decodeTime,
+		},
+		"updated": {
+			column: "updated",
+			value:  func(item interface{}) interface{} { return item.(*model.License).Updated },
+			decode: decodeTime,
+		},
+		"name": {
+			column: "name",
+			value:  func(item interface{}) interface{} { return item.(*model.License).Name },
+			decode: decodeString,
+		},
+		"active": {
+			column: "active",
+			value:  func(item interface{}) interface{} { return item.(*model.License).Active },
+			decode: decodeBool,
+		},
+		"maxSessions": {
+			column: "max_sessions",
+			value:  func(item interface{}) interface{} { return item.(*model.License).MaxSessions },
+			decode: decodeInt,
+		},
+		"validUntil": {
+			column: "COALESCE(valid_until, 'infinity')",
+			value:  func(item interface{}) interface{} { return item.(*model.License).ValidUntil },
+			decode: decodeNullTime("infinity"),
+		},
+		"lastUsed": {
+			column: "COALESCE(last_used, '-infinity')",
+			value:  func(item interface{}) interface{} { return item.(*model.License).LastUsed },
+			decode: decodeNullTime("-infinity"),
+		},
+	},
+	defaultSort:  "created",
+	defaultOrder: []string{"active DESC", "last_used", "updated DESC"},
+	search:       "name || ' ' || note",
+}
+
 func (h *Handler) InsertLicense(ctx context.Context, l *model.License) error {
 	const action = "Insert"
 	sq := h.sq.Insert(licenseTable).
 		SetMap(map[string]interface{}{
-			"id":             l.ID,
-			"key":            l.Key,
-			"active":         l.Active,
-			"name":           l.Name,
-			"tags":           pq.Array(l.Tags),
-			"end_user_email": l.EndUserEmail,
-			"note":           l.Note,
-			"data":           l.Data,
-			"max_sessions":   l.MaxSessions,
-			"valid_until":    l.ValidUntil,
-			"created":        l.Created,
-			"updated":        l.Updated,
-			"last_used":      l.LastUsed,
-			"issuer_id":      l.IssuerID,
-			"product_id":     l.ProductID,
+			"id":                l.ID,
+			"key":               l.Key,
+			"active":            l.Active,
+			"name":              l.Name,
+			"tags":              pq.Array(l.Tags),
+			"end_user_email":    l.EndUserEmail,
+			"note":              l.Note,
+			"data":              l.Data,
+			"max_sessions":      l.MaxSessions,
+			"valid_until":       l.ValidUntil,
+			"created":           l.Created,
+			"updated":           l.Updated,
+			"last_used":         l.LastUsed,
+			"issuer_id":         l.IssuerID,
+			"product_id":        l.ProductID,
+			"features":          pq.Array(l.Features),
+			"template_id":       l.TemplateID,
+			"template_version":  l.TemplateVersion,
+			"max_transfers":     l.MaxTransfers,
+			"transfer_cooldown": l.TransferCooldown,
+			"parent_id":         l.ParentID,
+			"edition_id":        l.EditionID,
+			"channels":          pq.Array(l.Channels),
+			"updates_until":     l.UpdatesUntil,
+			"quotas":            l.Quotas,
+			"customer_id":       l.CustomerID,
 		})
 
 	_, err := sq.ExecContext(ctx)
@@ -38,24 +98,244 @@ func (h *Handler) InsertLicense(ctx context.Context, l *model.License) error {
 	return nil
 }
 
+// insertBatchSize is the number of rows inserted by a single statement, keeps
+// number of parameters below PostgreSQL limit.
+const insertBatchSize = 1000
+
+// InsertLicenses inserts licenses in batches. Should be run in a transaction.
+func (h *Handler) InsertLicenses(ctx context.Context, ll []*model.License) error {
+	const (
+		scope  = licenseTable
+		action = "InsertMany"
+	)
+	for i := 0; i < len(ll); i += insertBatchSize {
+		end := i + insertBatchSize
+		if end > len(ll) {
+			end = len(ll)
+		}
+		sq := h.sq.Insert(scope).Columns(
+			"id",
+			"key",
+			"active",
+			"name",
+			"tags",
+			"end_user_email",
+			"note",
+			"data",
+			"max_sessions",
+			"valid_until",
+			"created",
+			"updated",
+			"last_used",
+			"issuer_id",
+			"product_id",
+			"features",
+			"template_id",
+			"template_version",
+			"max_transfers",
+			"transfer_cooldown",
+			"parent_id",
+			"edition_id",
+			"channels",
+			"updates_until",
+			"quotas",
+			"customer_id",
+		)
+		for _, l := range ll[i:end] {
+			sq = sq.Values(
+				l.ID,
+				l.Key,
+				l.Active,
+				l.Name,
+				pq.Array(l.Tags),
+				l.EndUserEmail,
+				l.Note,
+				l.Data,
+				l.MaxSessions,
+				l.ValidUntil,
+				l.Created,
+				l.Updated,
+				l.LastUsed,
+				l.IssuerID,
+				l.ProductID,
+				pq.Array(l.Features),
+				l.TemplateID,
+				l.TemplateVersion,
+				l.MaxTransfers,
+				l.TransferCooldown,
+				l.ParentID,
+				l.EditionID,
+				pq.Array(l.Channels),
+				l.UpdatesUntil,
+				l.Quotas,
+				l.CustomerID,
+			)
+		}
+		err := h.execInsertMany(ctx, sq, scope, action)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *Handler) SelectAllLicensesByIssuerID(ctx context.Context, licenseIssuerID int) ([]*model.License, error) {
 	return h.selectLicenses(ctx, "SelectAllByIssuerID",
 		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
 			return sq.Where(squirrel.Eq{
 				"issuer_id": licenseIssuerID,
+				"deleted":   nil,
 			}).OrderBy("active DESC", "last_used", "updated DESC")
 		})
 }
 
+// SelectLicensesByIssuerID selects a page of issuer's licenses matching the
+// filter.
+func (h *Handler) SelectLicensesByIssuerID(ctx context.Context, licenseIssuerID int, f *model.LicenseFilter, opts *model.ListOptions) ([]*model.License, *model.Page, error) {
+	const (
+		scope  = licenseTable
+		action = "SelectByIssuerID"
+	)
+	q, err := licenseList.query(licenseFilter(licenseIssuerID, f), opts)
+	if err != nil {
+		return nil, nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	ll, err := h.selectLicenses(ctx, action, q.decorate)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, n, err := h.listPage(ctx, scope, action, q, len(ll), func(i int) interface{} { return ll[i] })
+	if err != nil {
+		return nil, nil, err
+	}
+	return ll[:n], page, nil
+}
+
+func licenseFilter(licenseIssuerID int, f *model.LicenseFilter) squirrel.And {
+	where := squirrel.And{
+		squirrel.Eq{"issuer_id": licenseIssuerID},
+	}
+	if f == nil {
+		return append(where, notDeleted)
+	}
+	if f.Deleted {
+		where = append(where, squirrel.NotEq{"deleted": nil})
+	} else {
+		where = append(where, notDeleted)
+	}
+	if f.Active != nil {
+		where = append(where, squirrel.Eq{"active": *f.Active})
+	}
+	if f.ProductID != nil {
+		where = append(where, squirrel.Eq{"product_id": *f.ProductID})
+	}
+	if f.CustomerID != nil {
+		where = append(where, squirrel.Eq{"customer_id": *f.CustomerID})
+	}
+	if f.Tag != "" {
+		where = append(where, squirrel.Expr("? = ANY(tags)", f.Tag))
+	}
+	if f.EndUserEmail != "" {
+		where = append(where, squirrel.Expr("lower(end_user_email) = lower(?)", f.EndUserEmail))
+	}
+	if f.ExpiringBefore != nil {
+		where = append(where, squirrel.Lt{"valid_until": *f.ExpiringBefore})
+	}
+	if f.LastUsedBefore != nil {
+		where = append(where, squirrel.Lt{"last_used": *f.LastUsedBefore})
+	}
+	if f.LastUsedAfter != nil {
+		where = append(where, squirrel.GtOrEq{"last_used": *f.LastUsedAfter})
+	}
+	return where
+}
+
+// SelectLicensesByIssuerIDAndKeyPrefix selects issuer's licenses whose key
+// starts with the prefix, at most limit of them.
+func (h *Handler) SelectLicensesByIssuerIDAndKeyPrefix(ctx context.Context, licenseIssuerID int, prefix []byte, limit int) ([]*model.License, error) {
+	return h.selectLicenses(ctx, "SelectByIssuerIDAndKeyPrefix",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.And{
+				squirrel.Eq{"issuer_id": licenseIssuerID},
+				notDeleted,
+				squirrel.Expr("substring(key from 1 for ?) = ?", len(prefix), prefix),
+			}).OrderBy("created", "id").Limit(uint64(limit))
+		})
+}
+
+// SelectLicensesByParentID selects add-ons of the base license.
+func (h *Handler) SelectLicensesByParentID(ctx context.Context, parentID []byte) ([]*model.License, error) {
+	return h.selectLicenses(ctx, "SelectByParentID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"parent_id": parentID,
+				"deleted":   nil,
+			}).OrderBy("created", "id")
+		})
+}
+
+// SelectLicensesExpiringBetween selects active licenses, which expire in
+// (from; to].
+func (h *Handler) SelectLicensesExpiringBetween(ctx context.Context, from, to time.Time) ([]*model.License, error) {
+	return h.selectLicenses(ctx, "SelectExpiringBetween",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.And{
+				squirrel.Eq{"active": true},
+				notDeleted,
+				squirrel.Gt{"valid_until": from},
+				squirrel.LtOrEq{"valid_until": to},
+			}).OrderBy("valid_until", "id")
+		})
+}
+
+// SelectLicensesOverusedSince selects licenses, whose usage of the period
+// updated since given time has reached any of their quotas.
+func (h *Handler) SelectLicensesOverusedSince(ctx context.Context, period, since time.Time) ([]*model.License, error) {
+	return h.selectLicenses(ctx, "SelectOverusedSince",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.And{
+				notDeleted,
+				squirrel.Expr("EXISTS (SELECT 1 FROM license_usage WHERE license_usage.license_id = license.id AND license_usage.period = ? AND license_usage.updated >= ? AND license_usage.amount >= (license.quotas ->> license_usage.metric)::bigint)", period, since),
+			}).OrderBy("id")
+		})
+}
+
 func (h *Handler) SelectLicenseByID(ctx context.Context, licenseID []byte) (*model.License, error) {
 	return h.selectLicense(ctx, "SelectByID",
 		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
 			return sq.Where(squirrel.Eq{
-				"id": licenseID,
+				"id":      licenseID,
+				"deleted": nil,
+			})
+		})
+}
+
+// SelectDeletedLicenseByID selects soft deleted license of the issuer.
+func (h *Handler) SelectDeletedLicenseByID(ctx context.Context, licenseID []byte, licenseIssuerID int) (*model.License, error) {
+	return h.selectLicense(ctx, "SelectDeletedByID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.And{
+				squirrel.Eq{
+					"id":        licenseID,
+					"issuer_id": licenseIssuerID,
+				},
+				squirrel.NotEq{"deleted": nil},
 			})
 		})
 }
 
+// SelectLicenseByIDForUpdate selects license locking its row until the end of
+// transaction. Serializes license's machine transfers.
+func (h *Handler) SelectLicenseByIDForUpdate(ctx context.Context, licenseID []byte) (*model.License, error) {
+	return h.selectLicense(ctx, "SelectByIDForUpdate",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id":      licenseID,
+				"deleted": nil,
+			}).Suffix("FOR UPDATE")
+		})
+}
+
 func (h *Handler) selectLicense(ctx context.Context, action string, d selectDecorator) (*model.License, error) {
 	ll, err := h.selectLicenses(ctx, action, d)
 	if err != nil {
@@ -86,6 +366,18 @@ func (h *Handler) selectLicenses(ctx context.Context, action string, d selectDec
 		"last_used",
 		"issuer_id",
 		"product_id",
+		"features",
+		"template_id",
+		"template_version",
+		"max_transfers",
+		"transfer_cooldown",
+		"parent_id",
+		"edition_id",
+		"channels",
+		"updates_until",
+		"quotas",
+		"customer_id",
+		"deleted",
 	).From(scope)
 
 	rows, err := d(sq).QueryContext(ctx)
@@ -113,6 +405,18 @@ func (h *Handler) selectLicenses(ctx context.Context, action string, d selectDec
 			&l.LastUsed,
 			&l.IssuerID,
 			&l.ProductID,
+			pq.Array(&l.Features),
+			&l.TemplateID,
+			&l.TemplateVersion,
+			&l.MaxTransfers,
+			&l.TransferCooldown,
+			&l.ParentID,
+			&l.EditionID,
+			pq.Array(&l.Channels),
+			&l.UpdatesUntil,
+			&l.Quotas,
+			&l.CustomerID,
+			&l.Deleted,
 		)
 		if err != nil {
 			return nil, &Error{err: err, Scope: scope, Action: action}
@@ -136,6 +440,7 @@ func (h *Handler) SelectLicensesCountByIssuerID(ctx context.Context, licenseIssu
 		From(scope).
 		Where(squirrel.Eq{
 			"issuer_id": licenseIssuerID,
+			"deleted":   nil,
 		})
 
 	row := sq.QueryRowContext(ctx)
@@ -147,7 +452,9 @@ func (h *Handler) SelectLicensesCountByIssuerID(ctx context.Context, licenseIssu
 	return count, nil
 }
 
-func (h *Handler) UpdateLicense(ctx context.Context, licenseID []byte, licenseIssuerID int, update map[string]interface{}) error {
+// UpdateLicense updates license. If ifMatch isn't nil, license is updated only
+// if its updated timestamp is one of ifMatch.
+func (h *Handler) UpdateLicense(ctx context.Context, licenseID []byte, licenseIssuerID int, update map[string]interface{}, ifMatch []time.Time) error {
 	const (
 		action = "Update"
 		scope  = licenseTable
@@ -157,21 +464,127 @@ func (h *Handler) UpdateLicense(ctx context.Context, licenseID []byte, licenseIs
 		Where(squirrel.Eq{
 			"id":        licenseID,
 			"issuer_id": licenseIssuerID,
+			"deleted":   nil,
 		})
-
-	_, err := sq.ExecContext(ctx)
-	if err != nil {
-		return &Error{err: err, Scope: scope, Action: action}
-	}
-	return nil
+	return h.execUpdateIfMatch(ctx, sq, scope, action, ifMatch)
 }
 
-func (h *Handler) DeleteLicenseByID(ctx context.Context, licenseID []byte, licenseIssuerID int) (int, error) {
+// SoftDeleteLicenseByID marks license deleted at the time. If ifMatch isn't
+// nil, license is deleted only if its updated timestamp is one of ifMatch.
+func (h *Handler) SoftDeleteLicenseByID(ctx context.Context, licenseID []byte, licenseIssuerID int, deleted time.Time, ifMatch []time.Time) (int, error) {
 	const scope = licenseTable
-	sq := h.sq.Delete(scope).
+	sq := h.sq.Update(scope).
+		Set("deleted", deleted).
 		Where(squirrel.Eq{
 			"id":        licenseID,
 			"issuer_id": licenseIssuerID,
+			"deleted":   nil,
+		})
+	return h.execSoftDelete(ctx, sq, scope, "SoftDeleteByID", ifMatch)
+}
+
+// SoftDeleteLicensesByParentID marks add-ons of the base license deleted at
+// the time.
+func (h *Handler) SoftDeleteLicensesByParentID(ctx context.Context, parentID []byte, deleted time.Time) (int, error) {
+	const scope = licenseTable
+	sq := h.sq.Update(scope).
+		Set("deleted", deleted).
+		Where(squirrel.Eq{
+			"parent_id": parentID,
+			"deleted":   nil,
 		})
-	return h.execDelete(ctx, sq, scope, "DeleteByID")
+	return h.execUpdateCount(ctx, sq, scope, "SoftDeleteByParentID")
+}
+
+// SoftDeleteLicensesByProductID marks product's licenses deleted at the time.
+func (h *Handler) SoftDeleteLicensesByProductID(ctx context.Context, productID int, deleted time.Time) (int, error) {
+	const scope = licenseTable
+	sq := h.sq.Update(scope).
+		Set("deleted", deleted).
+		Where(squirrel.Eq{
+			"product_id": productID,
+			"deleted":    nil,
+		})
+	return h.execUpdateCount(ctx, sq, scope, "SoftDeleteByProductID")
+}
+
+// SoftDeleteLicensesByIssuerID marks issuer's licenses deleted at the time.
+func (h *Handler) SoftDeleteLicensesByIssuerID(ctx context.Context, licenseIssuerID int, deleted time.Time) (int, error) {
+	const scope = licenseTable
+	sq := h.sq.Update(scope).
+		Set("deleted", deleted).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"deleted":   nil,
+		})
+	return h.execUpdateCount(ctx, sq, scope, "SoftDeleteByIssuerID")
+}
+
+// RestoreLicenseByID restores soft deleted license.
+func (h *Handler) RestoreLicenseByID(ctx context.Context, licenseID []byte, licenseIssuerID int) (int, error) {
+	const scope = licenseTable
+	sq := h.sq.Update(scope).
+		Set("deleted", nil).
+		Where(squirrel.And{
+			squirrel.Eq{
+				"id":        licenseID,
+				"issuer_id": licenseIssuerID,
+			},
+			squirrel.NotEq{"deleted": nil},
+		})
+	return h.execSoftDelete(ctx, sq, scope, "RestoreByID", nil)
+}
+
+// RestoreLicensesByParentID restores add-ons of the base license deleted at
+// the time, i.e., together with the base license.
+func (h *Handler) RestoreLicensesByParentID(ctx context.Context, parentID []byte, deleted time.Time) (int, error) {
+	const scope = licenseTable
+	sq := h.sq.Update(scope).
+		Set("deleted", nil).
+		Where(squirrel.Eq{
+			"parent_id": parentID,
+			"deleted":   deleted,
+		})
+	return h.execUpdateCount(ctx, sq, scope, "RestoreByParentID")
+}
+
+// RestoreLicensesByProductID restores product's licenses deleted at the time,
+// i.e., together with the product.
+func (h *Handler) RestoreLicensesByProductID(ctx context.Context, productID int, deleted time.Time) (int, error) {
+	const scope = licenseTable
+	sq := h.sq.Update(scope).
+		Set("deleted", nil).
+		Where(squirrel.Eq{
+			"product_id": productID,
+			"deleted":    deleted,
+		})
+	return h.execUpdateCount(ctx, sq, scope, "RestoreByProductID")
+}
+
+// RestoreLicensesByIssuerID restores issuer's licenses deleted at the time,
+// i.e., together with the issuer.
+func (h *Handler) RestoreLicensesByIssuerID(ctx context.Context, licenseIssuerID int, deleted time.Time) (int, error) {
+	const scope = licenseTable
+	sq := h.sq.Update(scope).
+		Set("deleted", nil).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"deleted":   deleted,
+		})
+	return h.execUpdateCount(ctx, sq, scope, "RestoreByIssuerID")
+}
+
+// PurgeLicensesDeletedBefore permanently deletes licenses soft deleted before
+// t. Base licenses with add-ons, which aren't to be purged yet, are kept, as
+// deleting them would cascade to the add-ons.
+func (h *Handler) PurgeLicensesDeletedBefore(ctx context.Context, t time.Time) (int, error) {
+	sq := h.sq.Delete(licenseTable).
+		Where(squirrel.Lt{
+			"deleted": t,
+		}).
+		Where(squirrel.Expr(
+			"NOT EXISTS (SELECT 1 FROM license AS addon WHERE addon.parent_id = license.id AND (addon.deleted IS NULL OR addon.deleted >= ?))",
+			t,
+		))
+	return h.execDelete(ctx, sq, licenseTable, "PurgeDeletedBefore")
 }
@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
 	"testing"
 	"time"
 
@@ -25,6 +27,7 @@ func TestHandler_InsertLicense(t *testing.T) {
 		Key:          base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
 		Name:         "Testing license",
 		Tags:         []string{"testing", "dev"},
+		Features:     []string{"pro"},
 		Note:         "Note",
 		Data:         []byte(`{"extraJsonData":true}`),
 		MaxSessions:  4,
@@ -38,22 +41,33 @@ func TestHandler_InsertLicense(t *testing.T) {
 		ProductID:    &productID,
 	}
 
-	mock.ExpectExec("INSERT INTO license (active,created,data,end_user_email,id,issuer_id,key,last_used,max_sessions,name,note,product_id,tags,updated,valid_until) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15)").
+	mock.ExpectExec("INSERT INTO license (active,channels,created,customer_id,data,edition_id,end_user_email,features,id,issuer_id,key,last_used,max_sessions,max_transfers,name,note,parent_id,product_id,quotas,tags,template_id,template_version,transfer_cooldown,updated,updates_until,valid_until) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26)").
 		WithArgs(
 			l.Active,
+			pq.Array(l.Channels),
 			l.Created,
+			l.CustomerID,
 			l.Data,
+			l.EditionID,
 			l.EndUserEmail,
+			pq.Array(l.Features),
 			l.ID,
 			l.IssuerID,
 			l.Key,
 			l.LastUsed,
 			l.MaxSessions,
+			l.MaxTransfers,
 			l.Name,
 			l.Note,
+			l.ParentID,
 			l.ProductID,
+			l.Quotas,
 			pq.Array(l.Tags),
+			l.TemplateID,
+			l.TemplateVersion,
+			l.TransferCooldown,
 			l.Updated,
+			l.UpdatesUntil,
 			l.ValidUntil,
 		).
 		WillReturnResult(sqlmock.NewResult(0, 1))
@@ -68,31 +82,37 @@ func TestHandler_SelectAllLicensesByIssuerID(t *testing.T) {
 	defer h.Close()
 
 	productID := 5
+	templateID, templateVersion := 2, 3
 	validUntil := time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)
 	lastUsed := time.Date(2022, 2, 3, 0, 0, 0, 0, time.UTC)
 	expected := []*model.License{
 		{
-			ID:           base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
-			Key:          base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
-			Name:         "Testing license",
-			Tags:         []string{"testing", "dev"},
-			Note:         "Note",
-			Data:         []byte(`{"extraJsonData":true}`),
-			MaxSessions:  4,
-			ValidUntil:   &validUntil,
-			Created:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
-			Updated:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
-			LastUsed:     &lastUsed,
-			IssuerID:     0,
-			Active:       true,
-			EndUserEmail: "email@test.com",
-			ProductID:    &productID,
+			ID:              base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+			Key:             base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
+			Name:            "Testing license",
+			Tags:            []string{"testing", "dev"},
+			Features:        []string{"pro"},
+			TemplateID:      &templateID,
+			TemplateVersion: &templateVersion,
+			Note:            "Note",
+			Data:            []byte(`{"extraJsonData":true}`),
+			MaxSessions:     4,
+			ValidUntil:      &validUntil,
+			Created:         time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			Updated:         time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			LastUsed:        &lastUsed,
+			IssuerID:        0,
+			Active:          true,
+			EndUserEmail:    "email@test.com",
+			ProductID:       &productID,
+			Quotas:          model.UsageQuotas{},
 		},
 		{
 			ID:           base64Key("wf0SXXMDQ03VwgwIIf5TiUO8gT/VzkzihcZ2Z17qomM="),
 			Key:          base64Key("7/OninN+j5dqMfQmrQoGkpjTCSdUmLhEHjUarm7qH+Q="),
 			Name:         "Testing license 2",
 			Tags:         []string{"testing"},
+			Features:     []string{},
 			Note:         "Note 2",
 			Data:         nil,
 			MaxSessions:  1,
@@ -103,6 +123,7 @@ func TestHandler_SelectAllLicensesByIssuerID(t *testing.T) {
 			Active:       true,
 			EndUserEmail: "email@test.com",
 			ProductID:    &productID,
+			Quotas:       model.UsageQuotas{},
 		},
 	}
 
@@ -122,6 +143,18 @@ func TestHandler_SelectAllLicensesByIssuerID(t *testing.T) {
 		"issuer_id",
 		"last_used",
 		"product_id",
+		"features",
+		"template_id",
+		"template_version",
+		"max_transfers",
+		"transfer_cooldown",
+		"parent_id",
+		"edition_id",
+		"channels",
+		"updates_until",
+		"quotas",
+		"customer_id",
+		"deleted",
 	})
 	for _, v := range expected {
 		rows.AddRow(
@@ -140,10 +173,22 @@ func TestHandler_SelectAllLicensesByIssuerID(t *testing.T) {
 			v.LastUsed,
 			v.IssuerID,
 			v.ProductID,
+			pq.Array(v.Features),
+			v.TemplateID,
+			v.TemplateVersion,
+			v.MaxTransfers,
+			nil,
+			v.ParentID,
+			v.EditionID,
+			pq.Array(v.Channels),
+			v.UpdatesUntil,
+			v.Quotas,
+			v.CustomerID,
+			v.Deleted,
 		)
 	}
 
-	mock.ExpectQuery("SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id FROM license WHERE issuer_id = $1 ORDER BY active DESC, last_used, updated DESC").
+	mock.ExpectQuery("SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id, features, template_id, template_version, max_transfers, transfer_cooldown, parent_id, edition_id, channels, updates_until, quotas, customer_id, deleted FROM license WHERE deleted IS NULL AND issuer_id = $1 ORDER BY active DESC, last_used, updated DESC").
 		WithArgs(0).
 		WillReturnRows(rows)
 
@@ -160,22 +205,32 @@ func TestHandler_SelectLicenseByID(t *testing.T) {
 	productID := 5
 	validUntil := time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)
 	lastUsed := time.Date(2022, 3, 2, 0, 0, 0, 0, time.UTC)
+	cooldown := model.Duration(7 * 24 * time.Hour)
+	editionID := 3
+	customerID := 9
 	expected := &model.License{
-		ID:           base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
-		Key:          base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
-		Note:         "Note",
-		Data:         []byte(`{"extraJsonData":true}`),
-		MaxSessions:  4,
-		ValidUntil:   &validUntil,
-		Created:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
-		Updated:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
-		IssuerID:     0,
-		Active:       true,
-		EndUserEmail: "email@test.com",
-		ProductID:    &productID,
-		Name:         "hello",
-		Tags:         []string{"tag-1", "tag-2"},
-		LastUsed:     &lastUsed,
+		ID:               base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+		Key:              base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
+		Note:             "Note",
+		Data:             []byte(`{"extraJsonData":true}`),
+		MaxSessions:      4,
+		ValidUntil:       &validUntil,
+		Created:          time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Updated:          time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		IssuerID:         0,
+		Active:           true,
+		EndUserEmail:     "email@test.com",
+		ProductID:        &productID,
+		Name:             "hello",
+		Tags:             []string{"tag-1", "tag-2"},
+		Features:         []string{"pro", "export"},
+		LastUsed:         &lastUsed,
+		MaxTransfers:     2,
+		TransferCooldown: &cooldown,
+		EditionID:        &editionID,
+		Channels:         []string{"stable", "beta"},
+		Quotas:           model.UsageQuotas{"exports": 100},
+		CustomerID:       &customerID,
 	}
 
 	rows := sqlmock.NewRows([]string{
@@ -194,6 +249,18 @@ func TestHandler_SelectLicenseByID(t *testing.T) {
 		"last_used",
 		"issuer_id",
 		"product_id",
+		"features",
+		"template_id",
+		"template_version",
+		"max_transfers",
+		"transfer_cooldown",
+		"parent_id",
+		"edition_id",
+		"channels",
+		"updates_until",
+		"quotas",
+		"customer_id",
+		"deleted",
 	}).AddRow(
 		expected.ID,
 		expected.Key,
@@ -210,9 +277,21 @@ func TestHandler_SelectLicenseByID(t *testing.T) {
 		expected.LastUsed,
 		expected.IssuerID,
 		expected.ProductID,
+		pq.Array(expected.Features),
+		expected.TemplateID,
+		expected.TemplateVersion,
+		expected.MaxTransfers,
+		int64(7*24*60*60),
+		expected.ParentID,
+		expected.EditionID,
+		pq.Array(expected.Channels),
+		expected.UpdatesUntil,
+		expected.Quotas,
+		expected.CustomerID,
+		expected.Deleted,
 	)
 
-	mock.ExpectQuery("SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id FROM license WHERE id = $1").
+	mock.ExpectQuery("SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id, features, template_id, template_version, max_transfers, transfer_cooldown, parent_id, edition_id, channels, updates_until, quotas, customer_id, deleted FROM license WHERE deleted IS NULL AND id = $1").
 		WithArgs(expected.ID).
 		WillReturnRows(rows)
 
@@ -234,7 +313,7 @@ func TestHandler_SelectLicensesCountByIssuerID(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"count"}).
 		AddRow(count)
 
-	mock.ExpectQuery("SELECT COUNT(*) FROM license WHERE issuer_id = $1").
+	mock.ExpectQuery("SELECT COUNT(*) FROM license WHERE deleted IS NULL AND issuer_id = $1").
 		WithArgs(issuerID).
 		WillReturnRows(rows)
 
@@ -257,7 +336,7 @@ func TestHandler_UpdateLicense(t *testing.T) {
 		"valid_until":  (*time.Time)(nil),
 	}
 
-	mock.ExpectExec("UPDATE license SET data = $1, max_sessions = $2, note = $3, valid_until = $4 WHERE id = $5 AND issuer_id = $6").
+	mock.ExpectExec("UPDATE license SET data = $1, max_sessions = $2, note = $3, valid_until = $4 WHERE deleted IS NULL AND id = $5 AND issuer_id = $6").
 		WithArgs(
 			update["data"],
 			update["max_sessions"],
@@ -268,11 +347,11 @@ func TestHandler_UpdateLicense(t *testing.T) {
 		).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = h.UpdateLicense(context.Background(), licenseID, licenseIssuerID, update)
+	err = h.UpdateLicense(context.Background(), licenseID, licenseIssuerID, update, nil)
 	assert.NoError(t, err)
 }
 
-func TestHandler_DeleteLicenseByID(t *testing.T) {
+func TestHandler_SoftDeleteLicenseByID(t *testing.T) {
 	h, mock, err := newMock()
 	require.NoError(t, err)
 	defer h.Close()
@@ -280,12 +359,322 @@ func TestHandler_DeleteLicenseByID(t *testing.T) {
 	const deleted = 1
 	licenseID := base64Key("IgI/tBu0hfqrWiOgNpoyz1gMRfTlBrRiltbecCbTrjY=")
 	licenseIssuerID := 4
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	mock.ExpectExec("DELETE FROM license WHERE id = $1 AND issuer_id = $2").
-		WithArgs(licenseID, licenseIssuerID).
+	mock.ExpectExec("UPDATE license SET deleted = $1 WHERE deleted IS NULL AND id = $2 AND issuer_id = $3").
+		WithArgs(now, licenseID, licenseIssuerID).
 		WillReturnResult(sqlmock.NewResult(0, deleted))
 
-	got, err := h.DeleteLicenseByID(context.Background(), licenseID, licenseIssuerID)
+	got, err := h.SoftDeleteLicenseByID(context.Background(), licenseID, licenseIssuerID, now, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, deleted, got)
 }
+
+func TestHandler_RestoreLicenseByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("IgI/tBu0hfqrWiOgNpoyz1gMRfTlBrRiltbecCbTrjY=")
+	licenseIssuerID := 4
+
+	mock.ExpectExec("UPDATE license SET deleted = $1 WHERE (id = $2 AND issuer_id = $3 AND deleted IS NOT NULL)").
+		WithArgs(nil, licenseID, licenseIssuerID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = h.RestoreLicenseByID(context.Background(), licenseID, licenseIssuerID)
+	assert.NoError(t, err)
+
+	mock.ExpectExec("UPDATE license SET deleted = $1 WHERE (id = $2 AND issuer_id = $3 AND deleted IS NOT NULL)").
+		WithArgs(nil, licenseID, licenseIssuerID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	_, err = h.RestoreLicenseByID(context.Background(), licenseID, licenseIssuerID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SoftDeleteLicensesByParentID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	parentID := base64Key("IgI/tBu0hfqrWiOgNpoyz1gMRfTlBrRiltbecCbTrjY=")
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("UPDATE license SET deleted = $1 WHERE deleted IS NULL AND parent_id = $2").
+		WithArgs(now, parentID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	got, err := h.SoftDeleteLicensesByParentID(context.Background(), parentID, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_RestoreLicensesByParentID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	parentID := base64Key("IgI/tBu0hfqrWiOgNpoyz1gMRfTlBrRiltbecCbTrjY=")
+	deleted := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("UPDATE license SET deleted = $1 WHERE deleted = $2 AND parent_id = $3").
+		WithArgs(nil, deleted, parentID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	got, err := h.RestoreLicensesByParentID(context.Background(), parentID, deleted)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_PurgeLicensesDeletedBefore(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	before := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("DELETE FROM license WHERE deleted < $1 AND NOT EXISTS (SELECT 1 FROM license AS addon WHERE addon.parent_id = license.id AND (addon.deleted IS NULL OR addon.deleted >= $2))").
+		WithArgs(before, before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	got, err := h.PurgeLicensesDeletedBefore(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got)
+}
+
+func TestHandler_UpdateLicense_ifMatch(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("4k3r5hHKR+PRcaQbjc3yA1cIrZsz3Wixqlv2gouK/y8=")
+	licenseIssuerID := 2
+	updated := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	update := map[string]interface{}{
+		"note": "new note",
+	}
+
+	mock.ExpectExec("UPDATE license SET note = $1 WHERE deleted IS NULL AND id = $2 AND issuer_id = $3 AND updated IN ($4)").
+		WithArgs(update["note"], licenseID, licenseIssuerID, updated).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err = h.UpdateLicense(context.Background(), licenseID, licenseIssuerID, update, []time.Time{updated})
+	assert.NoError(t, err)
+
+	mock.ExpectExec("UPDATE license SET note = $1 WHERE deleted IS NULL AND id = $2 AND issuer_id = $3 AND updated IN ($4)").
+		WithArgs(update["note"], licenseID, licenseIssuerID, updated).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = h.UpdateLicense(context.Background(), licenseID, licenseIssuerID, update, []time.Time{updated})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SoftDeleteLicenseByID_ifMatch(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("IgI/tBu0hfqrWiOgNpoyz1gMRfTlBrRiltbecCbTrjY=")
+	licenseIssuerID := 4
+	updated := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("UPDATE license SET deleted = $1 WHERE deleted IS NULL AND id = $2 AND issuer_id = $3 AND updated IN ($4)").
+		WithArgs(updated, licenseID, licenseIssuerID, updated).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = h.SoftDeleteLicenseByID(context.Background(), licenseID, licenseIssuerID, updated, []time.Time{updated})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_InsertLicenses(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	ll := []*model.License{
+		{
+			ID:          base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+			Key:         base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
+			Active:      true,
+			Tags:        []string{},
+			Features:    []string{},
+			MaxSessions: 1,
+			Created:     created,
+			Updated:     created,
+			IssuerID:    5,
+		},
+		{
+			ID:          base64Key("U2Ffxv1lYvxI+dBnqs+PjOjrkJ1pdE13a+Qz3cF5v3c="),
+			Key:         base64Key("0FdoMZ1xNwpgXHzhATJ3mQ6KVe5Ak8ZkcSW9RmmGdVQ="),
+			Active:      true,
+			Tags:        []string{"bulk"},
+			Features:    []string{"pro"},
+			MaxSessions: 2,
+			Created:     created,
+			Updated:     created,
+			IssuerID:    5,
+		},
+	}
+
+	args := make([]driver.Value, 0, 50)
+	for _, l := range ll {
+		args = append(args, l.ID, l.Key, l.Active, l.Name, pq.Array(l.Tags), l.EndUserEmail, l.Note, l.Data, l.MaxSessions, l.ValidUntil, l.Created, l.Updated, l.LastUsed, l.IssuerID, l.ProductID, pq.Array(l.Features), l.TemplateID, l.TemplateVersion, l.MaxTransfers, l.TransferCooldown, l.ParentID, l.EditionID, pq.Array(l.Channels), l.UpdatesUntil, l.Quotas, l.CustomerID)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, active, username, password_hash, email, phone_number, max_licenses, created, updated, deleted FROM license_issuer WHERE deleted IS NULL AND id = $1 FOR UPDATE").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "active", "username", "password_hash", "email", "phone_number", "max_licenses", "created", "updated", "deleted"}).
+			AddRow(5, true, "issuer", "hash", "", "", 10, created, created, nil))
+	mock.ExpectExec("INSERT INTO license (id,key,active,name,tags,end_user_email,note,data,max_sessions,valid_until,created,updated,last_used,issuer_id,product_id,features,template_id,template_version,max_transfers,transfer_cooldown,parent_id,edition_id,channels,updates_until,quotas,customer_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26),($27,$28,$29,$30,$31,$32,$33,$34,$35,$36,$37,$38,$39,$40,$41,$42,$43,$44,$45,$46,$47,$48,$49,$50,$51,$52)").
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = h.InTx(context.Background(), func(tx *Handler) error {
+		_, err := tx.SelectLicenseIssuerByIDForUpdate(context.Background(), 5)
+		if err != nil {
+			return err
+		}
+		return tx.InsertLicenses(context.Background(), ll)
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_InTx_rollback(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	errTest := errors.New("test")
+	err = h.InTx(context.Background(), func(tx *Handler) error {
+		return errTest
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectLicensesByIssuerIDAndKeyPrefix(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	expected := []*model.License{
+		{
+			ID:       base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+			Key:      base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
+			Tags:     []string{},
+			Features: []string{},
+			IssuerID: 3,
+			Quotas:   model.UsageQuotas{},
+		},
+	}
+	prefix := expected[0].Key[:6]
+
+	rows := sqlmock.NewRows([]string{
+		"id", "key", "active", "name", "tags", "end_user_email", "note", "data", "max_sessions", "valid_until",
+		"created", "updated", "last_used", "issuer_id", "product_id", "features", "template_id", "template_version", "max_transfers", "transfer_cooldown", "parent_id", "edition_id", "channels", "updates_until", "quotas", "customer_id", "deleted",
+	})
+	for _, l := range expected {
+		rows.AddRow(l.ID, l.Key, l.Active, l.Name, pq.Array(l.Tags), l.EndUserEmail, l.Note, l.Data, l.MaxSessions, l.ValidUntil,
+			l.Created, l.Updated, l.LastUsed, l.IssuerID, l.ProductID, pq.Array(l.Features), l.TemplateID, l.TemplateVersion, l.MaxTransfers, nil, l.ParentID, l.EditionID, pq.Array(l.Channels), l.UpdatesUntil, l.Quotas, l.CustomerID, l.Deleted)
+	}
+
+	mock.ExpectQuery("SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id, features, template_id, template_version, max_transfers, transfer_cooldown, parent_id, edition_id, channels, updates_until, quotas, customer_id, deleted FROM license WHERE (issuer_id = $1 AND deleted IS NULL AND substring(key from 1 for $2) = $3) ORDER BY created, id LIMIT 20").
+		WithArgs(3, len(prefix), prefix).
+		WillReturnRows(rows)
+
+	got, err := h.SelectLicensesByIssuerIDAndKeyPrefix(context.Background(), 3, prefix, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectLicensesByParentID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	parentID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	expected := []*model.License{
+		{
+			ID:       base64Key("U2Ffxv1lYvxI+dBnqs+PjOjrkJ1pdE13a+Qz3cF5v3c="),
+			Key:      base64Key("0FdoMZ1xNwpgXHzhATJ3mQ6KVe5Ak8ZkcSW9RmmGdVQ="),
+			Active:   true,
+			Name:     "Export add-on",
+			Tags:     []string{},
+			Features: []string{"export"},
+			IssuerID: 3,
+			ParentID: parentID,
+			Quotas:   model.UsageQuotas{},
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{
+		"id", "key", "active", "name", "tags", "end_user_email", "note", "data", "max_sessions", "valid_until",
+		"created", "updated", "last_used", "issuer_id", "product_id", "features", "template_id", "template_version",
+		"max_transfers", "transfer_cooldown", "parent_id", "edition_id", "channels", "updates_until", "quotas", "customer_id", "deleted",
+	})
+	for _, l := range expected {
+		rows.AddRow(l.ID, l.Key, l.Active, l.Name, pq.Array(l.Tags), l.EndUserEmail, l.Note, l.Data, l.MaxSessions, l.ValidUntil,
+			l.Created, l.Updated, l.LastUsed, l.IssuerID, l.ProductID, pq.Array(l.Features), l.TemplateID, l.TemplateVersion,
+			l.MaxTransfers, nil, l.ParentID, l.EditionID, pq.Array(l.Channels), l.UpdatesUntil, l.Quotas, l.CustomerID, l.Deleted)
+	}
+
+	mock.ExpectQuery("SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id, features, template_id, template_version, max_transfers, transfer_cooldown, parent_id, edition_id, channels, updates_until, quotas, customer_id, deleted FROM license WHERE deleted IS NULL AND parent_id = $1 ORDER BY created, id").
+		WithArgs(parentID).
+		WillReturnRows(rows)
+
+	got, err := h.SelectLicensesByParentID(context.Background(), parentID)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectLicensesOverusedSince(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	period := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	since := time.Date(2022, 2, 14, 0, 0, 0, 0, time.UTC)
+	expected := []*model.License{
+		{
+			ID:       base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+			Key:      base64Key("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0="),
+			Active:   true,
+			Name:     "Metered license",
+			Tags:     []string{},
+			Features: []string{},
+			IssuerID: 3,
+			Quotas:   model.UsageQuotas{"api_calls": 1000},
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{
+		"id", "key", "active", "name", "tags", "end_user_email", "note", "data", "max_sessions", "valid_until",
+		"created", "updated", "last_used", "issuer_id", "product_id", "features", "template_id", "template_version",
+		"max_transfers", "transfer_cooldown", "parent_id", "edition_id", "channels", "updates_until", "quotas", "customer_id", "deleted",
+	})
+	for _, l := range expected {
+		rows.AddRow(l.ID, l.Key, l.Active, l.Name, pq.Array(l.Tags), l.EndUserEmail, l.Note, l.Data, l.MaxSessions, l.ValidUntil,
+			l.Created, l.Updated, l.LastUsed, l.IssuerID, l.ProductID, pq.Array(l.Features), l.TemplateID, l.TemplateVersion,
+			l.MaxTransfers, nil, l.ParentID, l.EditionID, pq.Array(l.Channels), l.UpdatesUntil, l.Quotas, l.CustomerID, l.Deleted)
+	}
+
+	mock.ExpectQuery("SELECT id, key, active, name, tags, end_user_email, note, data, max_sessions, valid_until, created, updated, last_used, issuer_id, product_id, features, template_id, template_version, max_transfers, transfer_cooldown, parent_id, edition_id, channels, updates_until, quotas, customer_id, deleted FROM license WHERE (deleted IS NULL AND EXISTS (SELECT 1 FROM license_usage WHERE license_usage.license_id = license.id AND license_usage.period = $1 AND license_usage.updated >= $2 AND license_usage.amount >= (license.quotas ->> license_usage.metric)::bigint)) ORDER BY id").
+		WithArgs(period, since).
+		WillReturnRows(rows)
+
+	got, err := h.SelectLicensesOverusedSince(context.Background(), period, since)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const (
+	licenseCreditTable = "license_credit"
+	creditReceiptTable = "credit_receipt"
+)
+
+// AddLicenseCredits adds amount to license's credit balance of the feature.
+// Returns new balance.
+func (h *Handler) AddLicenseCredits(ctx context.Context, licenseID []byte, feature string, amount int64, updated time.Time) (int64, error) {
+	const (
+		action = "Add"
+		scope  = licenseCreditTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"license_id": licenseID,
+			"feature":    feature,
+			"balance":    amount,
+			"updated":    updated,
+		}).
+		Suffix("ON CONFLICT (license_id, feature) DO UPDATE SET balance = license_credit.balance + EXCLUDED.balance, updated = EXCLUDED.updated").
+		Suffix("RETURNING balance")
+
+	var balance int64
+	err := h.execInsert(ctx, sq, scope, action, &balance)
+	return balance, err
+}
+
+// ConsumeLicenseCredits atomically takes amount from license's credit balance
+// of the feature, unless balance is insufficient.
+//
+// Reports whether credits have been taken and returns remaining balance.
+func (h *Handler) ConsumeLicenseCredits(ctx context.Context, licenseID []byte, feature string, amount int64, updated time.Time) (int64, bool, error) {
+	const (
+		action = "Consume"
+		scope  = licenseCreditTable
+	)
+	sq := h.sq.Update(scope).
+		Set("balance", squirrel.Expr("balance - ?", amount)).
+		Set("updated", updated).
+		Where(squirrel.And{
+			squirrel.Eq{
+				"license_id": licenseID,
+				"feature":    feature,
+			},
+			squirrel.GtOrEq{"balance": amount},
+		}).
+		Suffix("RETURNING balance")
+
+	defer observeQuery(scope, action, time.Now())
+	var balance int64
+	err := sq.QueryRowContext(ctx).Scan(&balance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, &Error{err: err, Scope: scope, Action: action}
+	}
+	return balance, true, nil
+}
+
+func (h *Handler) SelectLicenseCreditsByLicenseID(ctx context.Context, licenseID []byte) ([]*model.LicenseCredit, error) {
+	const (
+		action = "SelectByLicenseID"
+		scope  = licenseCreditTable
+	)
+	sq := h.sq.Select(
+		"license_id",
+		"feature",
+		"balance",
+		"updated",
+	).
+		From(scope).
+		Where(squirrel.Eq{"license_id": licenseID}).
+		OrderBy("feature")
+
+	rows, err := sq.QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var cc []*model.LicenseCredit
+	for rows.Next() {
+		lc := &model.LicenseCredit{}
+		err = rows.Scan(
+			&lc.LicenseID,
+			&lc.Feature,
+			&lc.Balance,
+			&lc.Updated,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		cc = append(cc, lc)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return cc, nil
+}
+
+func (h *Handler) InsertCreditReceipt(ctx context.Context, rc *model.CreditReceipt) error {
+	sq := h.sq.Insert(creditReceiptTable).
+		SetMap(map[string]interface{}{
+			"id":                rc.ID,
+			"license_id":        rc.LicenseID,
+			"client_session_id": rc.ClientSessionID,
+			"feature":           rc.Feature,
+			"amount":            rc.Amount,
+			"balance":           rc.Balance,
+			"created":           rc.Created,
+		})
+	return h.execInsertMany(ctx, sq, creditReceiptTable, "Insert")
+}
+
+func (h *Handler) SelectCreditReceiptByID(ctx context.Context, id []byte) (*model.CreditReceipt, error) {
+	const action = "SelectByID"
+	rr, err := h.selectCreditReceipts(ctx, action,
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id": id,
+			})
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(rr) == 0 {
+		return nil, &Error{err: ErrNotFound, Scope: creditReceiptTable, Action: action}
+	}
+	return rr[0], nil
+}
+
+// SelectCreditReceiptsByLicenseID selects license's receipts created in
+// [from; to), latest first. Nil bounds are ignored.
+func (h *Handler) SelectCreditReceiptsByLicenseID(ctx context.Context, licenseID []byte, from, to *time.Time) ([]*model.CreditReceipt, error) {
+	return h.selectCreditReceipts(ctx, "SelectByLicenseID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			where := squirrel.And{
+				squirrel.Eq{"license_id": licenseID},
+			}
+			if from != nil {
+				where = append(where, squirrel.GtOrEq{"created": *from})
+			}
+			if to != nil {
+				where = append(where, squirrel.Lt{"created": *to})
+			}
+			return sq.Where(where).OrderBy("created DESC")
+		})
+}
+
+func (h *Handler) selectCreditReceipts(ctx context.Context, action string, d selectDecorator) ([]*model.CreditReceipt, error) {
+	const scope = creditReceiptTable
+
+	sq := h.sq.Select(
+		"id",
+		"license_id",
+		"client_session_id",
+		"feature",
+		"amount",
+		"balance",
+		"created",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var rr []*model.CreditReceipt
+	for rows.Next() {
+		rc := &model.CreditReceipt{}
+		err = rows.Scan(
+			&rc.ID,
+			&rc.LicenseID,
+			&rc.ClientSessionID,
+			&rc.Feature,
+			&rc.Amount,
+			&rc.Balance,
+			&rc.Created,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		rr = append(rr, rc)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return rr, nil
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_AddLicenseCredits(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	updated := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("INSERT INTO license_credit (balance,feature,license_id,updated) VALUES ($1,$2,$3,$4) ON CONFLICT (license_id, feature) DO UPDATE SET balance = license_credit.balance + EXCLUDED.balance, updated = EXCLUDED.updated RETURNING balance").
+		WithArgs(int64(100), "export", licenseID, updated).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(int64(105)))
+
+	balance, err := h.AddLicenseCredits(context.Background(), licenseID, "export", 100, updated)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(105), balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_ConsumeLicenseCredits(t *testing.T) {
+	const query = "UPDATE license_credit SET balance = balance - $1, updated = $2 WHERE (feature = $3 AND license_id = $4 AND balance >= $5) RETURNING balance"
+
+	licenseID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	updated := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		expect      func(mock sqlmock.Sqlmock)
+		wantBalance int64
+		wantOK      bool
+		assertion   assert.ErrorAssertionFunc
+	}{
+		{
+			name: "taken",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(int64(3), updated, "export", licenseID, int64(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(int64(7)))
+			},
+			wantBalance: 7,
+			wantOK:      true,
+			assertion:   assert.NoError,
+		},
+		{
+			name: "insufficient",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(int64(3), updated, "export", licenseID, int64(3)).
+					WillReturnError(sql.ErrNoRows)
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "error",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(int64(3), updated, "export", licenseID, int64(3)).
+					WillReturnError(sql.ErrConnDone)
+			},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock, err := newMock()
+			require.NoError(t, err)
+			defer h.Close()
+			tt.expect(mock)
+
+			balance, ok, err := h.ConsumeLicenseCredits(context.Background(), licenseID, "export", 3, updated)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.wantBalance, balance)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestHandler_InsertCreditReceipt(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	rc := &model.CreditReceipt{
+		ID:              []byte("0123456789abcdef"),
+		LicenseID:       base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+		ClientSessionID: base64Key("NCF+dvZP5ww/+9H7m2HFxaHRz/jXnXUMM20Au0Ujg1s="),
+		Feature:         "export",
+		Amount:          3,
+		Balance:         7,
+		Created:         time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+	mock.ExpectExec("INSERT INTO credit_receipt (amount,balance,client_session_id,created,feature,id,license_id) VALUES ($1,$2,$3,$4,$5,$6,$7)").
+		WithArgs(rc.Amount, rc.Balance, rc.ClientSessionID, rc.Created, rc.Feature, rc.ID, rc.LicenseID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = h.InsertCreditReceipt(context.Background(), rc)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectCreditReceiptByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	const query = "SELECT id, license_id, client_session_id, feature, amount, balance, created FROM credit_receipt WHERE id = $1"
+	columns := []string{"id", "license_id", "client_session_id", "feature", "amount", "balance", "created"}
+	expected := &model.CreditReceipt{
+		ID:              []byte("0123456789abcdef"),
+		LicenseID:       base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+		ClientSessionID: base64Key("NCF+dvZP5ww/+9H7m2HFxaHRz/jXnXUMM20Au0Ujg1s="),
+		Feature:         "export",
+		Amount:          3,
+		Balance:         7,
+		Created:         time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+	mock.ExpectQuery(query).
+		WithArgs(expected.ID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(
+			expected.ID, expected.LicenseID, expected.ClientSessionID, expected.Feature,
+			expected.Amount, expected.Balance, expected.Created,
+		))
+
+	got, err := h.SelectCreditReceiptByID(context.Background(), expected.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+
+	mock.ExpectQuery(query).
+		WithArgs(expected.ID).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	_, err = h.SelectCreditReceiptByID(context.Background(), expected.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/sewiti/licensing-system/internal/model"
@@ -9,6 +10,44 @@ import (
 
 const licenseIssuerTable = "license_issuer"
 
+var licenseIssuerList = &listSpec{
+	id: sortKey{
+		column: "id",
+		value:  func(item interface{}) interface{} { return item.(*model.LicenseIssuer).ID },
+		decode: decodeInt,
+	},
+	sortKeys: map[string]sortKey{
+		"id": {
+			column: "id",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseIssuer).ID },
+			decode: decodeInt,
+		},
+		"created": {
+			column: "created",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseIssuer).Created },
+			decode: decodeTime,
+		},
+		"updated": {
+			column: "updated",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseIssuer).Updated },
+			decode: decodeTime,
+		},
+		"username": {
+			column: "username",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseIssuer).Username },
+			decode: decodeString,
+		},
+		"active": {
+			column: "active",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseIssuer).Active },
+			decode: decodeBool,
+		},
+	},
+	defaultSort:  "id",
+	defaultOrder: []string{"active DESC", "id"},
+	search:       "username || ' ' || email",
+}
+
 func (h *Handler) InsertLicenseIssuer(ctx context.Context, li *model.LicenseIssuer) (int, error) {
 	const (
 		action = "Insert"
@@ -32,15 +71,46 @@ func (h *Handler) InsertLicenseIssuer(ctx context.Context, li *model.LicenseIssu
 
 func (h *Handler) SelectAllLicenseIssuers(ctx context.Context) ([]*model.LicenseIssuer, error) {
 	return h.selectLicenseIssuers(ctx, "SelectAll", func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
-		return sq.OrderBy("active DESC", "id")
+		return sq.Where(notDeleted).OrderBy("active DESC", "id")
 	})
 }
 
+// SelectLicenseIssuers selects a page of license issuers matching the filter.
+func (h *Handler) SelectLicenseIssuers(ctx context.Context, f *model.LicenseIssuerFilter, opts *model.ListOptions) ([]*model.LicenseIssuer, *model.Page, error) {
+	const (
+		scope  = licenseIssuerTable
+		action = "Select"
+	)
+	var where squirrel.And
+	if f != nil && f.Deleted {
+		where = append(where, squirrel.NotEq{"deleted": nil})
+	} else {
+		where = append(where, notDeleted)
+	}
+	if f != nil && f.Active != nil {
+		where = append(where, squirrel.Eq{"active": *f.Active})
+	}
+	q, err := licenseIssuerList.query(where, opts)
+	if err != nil {
+		return nil, nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	lii, err := h.selectLicenseIssuers(ctx, action, q.decorate)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, n, err := h.listPage(ctx, scope, action, q, len(lii), func(i int) interface{} { return lii[i] })
+	if err != nil {
+		return nil, nil, err
+	}
+	return lii[:n], page, nil
+}
+
 func (h *Handler) SelectLicenseIssuerByUsername(ctx context.Context, licenseIssuerUsername string) (*model.LicenseIssuer, error) {
 	return h.selectLicenseIssuer(ctx, "SelectByUsername",
 		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
 			return sq.Where(squirrel.Eq{
 				"username": licenseIssuerUsername,
+				"deleted":  nil,
 			})
 		})
 }
@@ -49,11 +119,24 @@ func (h *Handler) SelectLicenseIssuerByID(ctx context.Context, licenseIssuerID i
 	return h.selectLicenseIssuer(ctx, "SelectByID",
 		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
 			return sq.Where(squirrel.Eq{
-				"id": licenseIssuerID,
+				"id":      licenseIssuerID,
+				"deleted": nil,
 			})
 		})
 }
 
+// SelectLicenseIssuerByIDForUpdate selects license issuer locking its row
+// until the end of transaction. Serializes license creation of the issuer.
+func (h *Handler) SelectLicenseIssuerByIDForUpdate(ctx context.Context, licenseIssuerID int) (*model.LicenseIssuer, error) {
+	return h.selectLicenseIssuer(ctx, "SelectByIDForUpdate",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id":      licenseIssuerID,
+				"deleted": nil,
+			}).Suffix("FOR UPDATE")
+		})
+}
+
 func (h *Handler) selectLicenseIssuer(ctx context.Context, action string, d selectDecorator) (*model.LicenseIssuer, error) {
 	lii, err := h.selectLicenseIssuers(ctx, action, d)
 	if err != nil {
@@ -78,6 +161,7 @@ func (h *Handler) selectLicenseIssuers(ctx context.Context, action string, d sel
 		"max_licenses",
 		"created",
 		"updated",
+		"deleted",
 	).From(scope)
 
 	rows, err := d(sq).QueryContext(ctx)
@@ -99,6 +183,7 @@ func (h *Handler) selectLicenseIssuers(ctx context.Context, action string, d sel
 			&li.MaxLicenses,
 			&li.Created,
 			&li.Updated,
+			&li.Deleted,
 		)
 		if err != nil {
 			return nil, &Error{err: err, Scope: scope, Action: action}
@@ -113,7 +198,9 @@ func (h *Handler) selectLicenseIssuers(ctx context.Context, action string, d sel
 	return lii, nil
 }
 
-func (h *Handler) UpdateLicenseIssuer(ctx context.Context, licenseIssuerID int, update map[string]interface{}) error {
+// UpdateLicenseIssuer updates license issuer. If ifMatch isn't nil, issuer is
+// updated only if its updated timestamp is one of ifMatch.
+func (h *Handler) UpdateLicenseIssuer(ctx context.Context, licenseIssuerID int, update map[string]interface{}, ifMatch []time.Time) error {
 	const (
 		action = "Update"
 		scope  = licenseIssuerTable
@@ -121,9 +208,10 @@ func (h *Handler) UpdateLicenseIssuer(ctx context.Context, licenseIssuerID int,
 	sq := h.sq.Update(scope).
 		SetMap(update).
 		Where(squirrel.Eq{
-			"id": licenseIssuerID,
+			"id":      licenseIssuerID,
+			"deleted": nil,
 		})
-	return h.execUpdate(ctx, sq, scope, action)
+	return h.execUpdateIfMatch(ctx, sq, scope, action, ifMatch)
 }
 
 func (h *Handler) UpdateLicenseIssuerByUsername(ctx context.Context, username string, update map[string]interface{}) error {
@@ -135,6 +223,7 @@ func (h *Handler) UpdateLicenseIssuerByUsername(ctx context.Context, username st
 		SetMap(update).
 		Where(squirrel.Eq{
 			"username": username,
+			"deleted":  nil,
 		})
 
 	_, err := sq.ExecContext(ctx)
@@ -144,11 +233,49 @@ func (h *Handler) UpdateLicenseIssuerByUsername(ctx context.Context, username st
 	return nil
 }
 
-func (h *Handler) DeleteLicenseIssuerByID(ctx context.Context, licenseIssuerID int) (int, error) {
+// SoftDeleteLicenseIssuerByID marks license issuer deleted at the time. If
+// ifMatch isn't nil, issuer is deleted only if its updated timestamp is one of
+// ifMatch.
+func (h *Handler) SoftDeleteLicenseIssuerByID(ctx context.Context, licenseIssuerID int, deleted time.Time, ifMatch []time.Time) (int, error) {
 	const scope = licenseIssuerTable
-	sq := h.sq.Delete(scope).
+	sq := h.sq.Update(scope).
+		Set("deleted", deleted).
 		Where(squirrel.Eq{
-			"id": licenseIssuerID,
+			"id":      licenseIssuerID,
+			"deleted": nil,
+		})
+	return h.execSoftDelete(ctx, sq, scope, "SoftDeleteByID", ifMatch)
+}
+
+// SelectDeletedLicenseIssuerByID selects soft deleted license issuer.
+func (h *Handler) SelectDeletedLicenseIssuerByID(ctx context.Context, licenseIssuerID int) (*model.LicenseIssuer, error) {
+	return h.selectLicenseIssuer(ctx, "SelectDeletedByID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.And{
+				squirrel.Eq{"id": licenseIssuerID},
+				squirrel.NotEq{"deleted": nil},
+			})
+		})
+}
+
+// RestoreLicenseIssuerByID restores soft deleted license issuer.
+func (h *Handler) RestoreLicenseIssuerByID(ctx context.Context, licenseIssuerID int) (int, error) {
+	const scope = licenseIssuerTable
+	sq := h.sq.Update(scope).
+		Set("deleted", nil).
+		Where(squirrel.And{
+			squirrel.Eq{"id": licenseIssuerID},
+			squirrel.NotEq{"deleted": nil},
+		})
+	return h.execSoftDelete(ctx, sq, scope, "RestoreByID", nil)
+}
+
+// PurgeLicenseIssuersDeletedBefore permanently deletes license issuers soft
+// deleted before t.
+func (h *Handler) PurgeLicenseIssuersDeletedBefore(ctx context.Context, t time.Time) (int, error) {
+	sq := h.sq.Delete(licenseIssuerTable).
+		Where(squirrel.Lt{
+			"deleted": t,
 		})
-	return h.execDelete(ctx, sq, scope, "DeleteByID")
+	return h.execDelete(ctx, sq, licenseIssuerTable, "PurgeDeletedBefore")
 }
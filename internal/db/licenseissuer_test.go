@@ -85,6 +85,7 @@ func TestHandler_SelectAllLicenseIssuers(t *testing.T) {
 		"max_licenses",
 		"created",
 		"updated",
+		"deleted",
 	})
 	for _, v := range expected {
 		rows.AddRow(
@@ -97,10 +98,11 @@ func TestHandler_SelectAllLicenseIssuers(t *testing.T) {
 			v.MaxLicenses,
 			v.Created,
 			v.Updated,
+			v.Deleted,
 		)
 	}
 
-	mock.ExpectQuery("SELECT id, active, username, password_hash, email, phone_number, max_licenses, created, updated FROM license_issuer ORDER BY active DESC, id").
+	mock.ExpectQuery("SELECT id, active, username, password_hash, email, phone_number, max_licenses, created, updated, deleted FROM license_issuer WHERE deleted IS NULL ORDER BY active DESC, id").
 		WillReturnRows(rows)
 
 	got, err := h.SelectAllLicenseIssuers(context.Background())
@@ -135,6 +137,7 @@ func TestHandler_SelectLicenseIssuerByUsername(t *testing.T) {
 		"max_licenses",
 		"created",
 		"updated",
+		"deleted",
 	}).AddRow(
 		expected.ID,
 		expected.Active,
@@ -145,9 +148,10 @@ func TestHandler_SelectLicenseIssuerByUsername(t *testing.T) {
 		expected.MaxLicenses,
 		expected.Created,
 		expected.Updated,
+		expected.Deleted,
 	)
 
-	mock.ExpectQuery("SELECT id, active, username, password_hash, email, phone_number, max_licenses, created, updated FROM license_issuer WHERE username = $1").
+	mock.ExpectQuery("SELECT id, active, username, password_hash, email, phone_number, max_licenses, created, updated, deleted FROM license_issuer WHERE deleted IS NULL AND username = $1").
 		WithArgs(expected.Username).
 		WillReturnRows(rows)
 
@@ -183,6 +187,7 @@ func TestHandler_SelectLicenseIssuerByID(t *testing.T) {
 		"max_licenses",
 		"created",
 		"updated",
+		"deleted",
 	}).AddRow(
 		expected.ID,
 		expected.Active,
@@ -193,9 +198,10 @@ func TestHandler_SelectLicenseIssuerByID(t *testing.T) {
 		expected.MaxLicenses,
 		expected.Created,
 		expected.Updated,
+		expected.Deleted,
 	)
 
-	mock.ExpectQuery("SELECT id, active, username, password_hash, email, phone_number, max_licenses, created, updated FROM license_issuer WHERE id = $1").
+	mock.ExpectQuery("SELECT id, active, username, password_hash, email, phone_number, max_licenses, created, updated, deleted FROM license_issuer WHERE deleted IS NULL AND id = $1").
 		WithArgs(expected.ID).
 		WillReturnRows(rows)
 
@@ -218,7 +224,7 @@ func TestHandler_UpdateLicenseIssuer(t *testing.T) {
 		"updated":      time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
 	}
 
-	mock.ExpectExec("UPDATE license_issuer SET active = $1, created = $2, max_licenses = $3, updated = $4, username = $5 WHERE id = $6").
+	mock.ExpectExec("UPDATE license_issuer SET active = $1, created = $2, max_licenses = $3, updated = $4, username = $5 WHERE deleted IS NULL AND id = $6").
 		WithArgs(
 			update["active"],
 			update["created"],
@@ -229,7 +235,7 @@ func TestHandler_UpdateLicenseIssuer(t *testing.T) {
 		).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = h.UpdateLicenseIssuer(context.Background(), licenseIssuerID, update)
+	err = h.UpdateLicenseIssuer(context.Background(), licenseIssuerID, update, nil)
 	assert.NoError(t, err)
 }
 
@@ -247,7 +253,7 @@ func TestHandler_UpdateLicenseIssuerByUsername(t *testing.T) {
 		"updated":      time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
 	}
 
-	mock.ExpectExec("UPDATE license_issuer SET active = $1, created = $2, max_licenses = $3, updated = $4, username = $5 WHERE username = $6").
+	mock.ExpectExec("UPDATE license_issuer SET active = $1, created = $2, max_licenses = $3, updated = $4, username = $5 WHERE deleted IS NULL AND username = $6").
 		WithArgs(
 			update["active"],
 			update["created"],
@@ -262,7 +268,7 @@ func TestHandler_UpdateLicenseIssuerByUsername(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestHandler_DeleteLicenseIssuerByID(t *testing.T) {
+func TestHandler_SoftDeleteLicenseIssuerByID(t *testing.T) {
 	h, mock, err := newMock()
 	require.NoError(t, err)
 	defer h.Close()
@@ -271,12 +277,13 @@ func TestHandler_DeleteLicenseIssuerByID(t *testing.T) {
 		deleted         = 1
 		licenseIssuerID = 69
 	)
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	mock.ExpectExec("DELETE FROM license_issuer WHERE id = $1").
-		WithArgs(licenseIssuerID).
+	mock.ExpectExec("UPDATE license_issuer SET deleted = $1 WHERE deleted IS NULL AND id = $2").
+		WithArgs(now, licenseIssuerID).
 		WillReturnResult(sqlmock.NewResult(0, deleted))
 
-	got, err := h.DeleteLicenseIssuerByID(context.Background(), licenseIssuerID)
+	got, err := h.SoftDeleteLicenseIssuerByID(context.Background(), licenseIssuerID, now, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, deleted, got)
 }
@@ -11,6 +11,39 @@ import (
 
 const licenseSessionTable = "license_session"
 
+var licenseSessionList = &listSpec{
+	id: sortKey{
+		column: "client_session_id",
+		value:  func(item interface{}) interface{} { return item.(*model.LicenseSession).ClientID },
+		decode: decodeBytes,
+	},
+	sortKeys: map[string]sortKey{
+		"created": {
+			column: "created",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseSession).Created },
+			decode: decodeTime,
+		},
+		"expire": {
+			column: "expire",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseSession).Expire },
+			decode: decodeTime,
+		},
+		"identifier": {
+			column: "identifier",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseSession).Identifier },
+			decode: decodeString,
+		},
+		"appVersion": {
+			column: "app_version",
+			value:  func(item interface{}) interface{} { return item.(*model.LicenseSession).AppVersion },
+			decode: decodeString,
+		},
+	},
+	defaultSort:  "created",
+	defaultOrder: []string{"created"},
+	search:       "identifier || ' ' || app_version",
+}
+
 func (h *Handler) InsertLicenseSession(ctx context.Context, ls *model.LicenseSession) error {
 	const (
 		action = "Insert"
@@ -44,6 +77,34 @@ func (h *Handler) SelectAllLicenseSessionsByLicenseID(ctx context.Context, licen
 		})
 }
 
+// SelectLicenseSessionsByLicenseID selects a page of license's sessions
+// matching the filter.
+func (h *Handler) SelectLicenseSessionsByLicenseID(ctx context.Context, licenseID []byte, f *model.LicenseSessionFilter, opts *model.ListOptions) ([]*model.LicenseSession, *model.Page, error) {
+	const (
+		scope  = licenseSessionTable
+		action = "SelectByLicenseID"
+	)
+	where := squirrel.And{
+		squirrel.Eq{"license_id": licenseID},
+	}
+	if f != nil && f.AppVersion != "" {
+		where = append(where, squirrel.Eq{"app_version": f.AppVersion})
+	}
+	q, err := licenseSessionList.query(where, opts)
+	if err != nil {
+		return nil, nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	lss, err := h.selectLicenseSessions(ctx, action, q.decorate)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, n, err := h.listPage(ctx, scope, action, q, len(lss), func(i int) interface{} { return lss[i] })
+	if err != nil {
+		return nil, nil, err
+	}
+	return lss[:n], page, nil
+}
+
 func (h *Handler) SelectLicenseSessionByID(ctx context.Context, clientSessionID []byte) (*model.LicenseSession, error) {
 	return h.selectLicenseSession(ctx, "SelectByID",
 		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
@@ -112,6 +173,49 @@ func (h *Handler) selectLicenseSessions(ctx context.Context, action string, d se
 	return lss, nil
 }
 
+// SelectActiveLicenseSessionsCountByProduct counts license sessions not
+// expired by now, grouped by license product. Sessions of licenses without
+// product are counted under zero product ID. Sessions of deleted licenses are
+// not counted.
+func (h *Handler) SelectActiveLicenseSessionsCountByProduct(ctx context.Context, now time.Time) (map[int]int, error) {
+	const (
+		scope  = licenseSessionTable
+		action = "SelectActiveCountByProduct"
+	)
+	sq := h.sq.Select("COALESCE(license.product_id, 0)", "COUNT(*)").
+		From(scope).
+		Join("license ON license.id = license_session.license_id").
+		Where(squirrel.Gt{
+			"license_session.expire": now,
+		}).
+		Where(squirrel.Eq{
+			"license.deleted": nil,
+		}).
+		GroupBy("license.product_id")
+
+	rows, err := sq.QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var productID, count int
+		err = rows.Scan(&productID, &count)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		counts[productID] = count
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return counts, nil
+}
+
 func (h *Handler) UpdateLicenseSession(ctx context.Context, ls *model.LicenseSession) error {
 	const (
 		action = "Update"
@@ -147,6 +251,47 @@ func (h *Handler) DeleteLicenseSessionBySessionID(ctx context.Context, clientSes
 	return h.execDelete(ctx, sq, licenseSessionTable, "DeleteBySessionID")
 }
 
+func (h *Handler) DeleteLicenseSessionsByLicenseID(ctx context.Context, licenseID []byte) (int, error) {
+	sq := h.sq.Delete(licenseSessionTable).
+		Where(squirrel.Eq{
+			"license_id": licenseID,
+		})
+	return h.execDelete(ctx, sq, licenseSessionTable, "DeleteByLicenseID")
+}
+
+// DeleteLicenseSessionsByProductID deletes sessions of product's licenses,
+// which were deleted at the time.
+func (h *Handler) DeleteLicenseSessionsByProductID(ctx context.Context, productID int, deleted time.Time) (int, error) {
+	sq := h.sq.Delete(licenseSessionTable).
+		Where(squirrel.Expr(
+			"license_id IN (SELECT id FROM license WHERE product_id = ? AND deleted = ?)",
+			productID, deleted,
+		))
+	return h.execDelete(ctx, sq, licenseSessionTable, "DeleteByProductID")
+}
+
+// DeleteLicenseSessionsByIssuerID deletes sessions of issuer's licenses, which
+// were deleted at the time.
+func (h *Handler) DeleteLicenseSessionsByIssuerID(ctx context.Context, licenseIssuerID int, deleted time.Time) (int, error) {
+	sq := h.sq.Delete(licenseSessionTable).
+		Where(squirrel.Expr(
+			"license_id IN (SELECT id FROM license WHERE issuer_id = ? AND deleted = ?)",
+			licenseIssuerID, deleted,
+		))
+	return h.execDelete(ctx, sq, licenseSessionTable, "DeleteByIssuerID")
+}
+
+// DeleteLicenseSessionsByParentID deletes sessions of base license's add-ons,
+// which were deleted at the time.
+func (h *Handler) DeleteLicenseSessionsByParentID(ctx context.Context, parentID []byte, deleted time.Time) (int, error) {
+	sq := h.sq.Delete(licenseSessionTable).
+		Where(squirrel.Expr(
+			"license_id IN (SELECT id FROM license WHERE parent_id = ? AND deleted = ?)",
+			parentID, deleted,
+		))
+	return h.execDelete(ctx, sq, licenseSessionTable, "DeleteByParentID")
+}
+
 func (h *Handler) DeleteLicenseSessionsByLicenseIDAndMachineID(ctx context.Context, licenseID []byte, machineID []byte) (int, error) {
 	sq := h.sq.Delete(licenseSessionTable).
 		Where(squirrel.Eq{
@@ -284,3 +284,99 @@ func TestHandler_DeleteLicenseSessionsOverused(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected, got)
 }
+
+func TestHandler_SelectActiveLicenseSessionsCountByProduct(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	now := time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)
+	expected := map[int]int{
+		0: 3,
+		5: 12,
+	}
+
+	mock.ExpectQuery("SELECT COALESCE(license.product_id, 0), COUNT(*) FROM license_session JOIN license ON license.id = license_session.license_id WHERE license_session.expire > $1 AND license.deleted IS NULL GROUP BY license.product_id").
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce", "count"}).
+			AddRow(0, 3).
+			AddRow(5, 12))
+
+	got, err := h.SelectActiveLicenseSessionsCountByProduct(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestHandler_DeleteLicenseSessionsByLicenseID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	mock.ExpectExec("DELETE FROM license_session WHERE license_id = $1").
+		WithArgs(licenseID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := h.DeleteLicenseSessionsByLicenseID(context.Background(), licenseID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_DeleteLicenseSessionsByProductID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	productID := 3
+	deleted := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM license_session WHERE license_id IN (SELECT id FROM license WHERE product_id = $1 AND deleted = $2)").
+		WithArgs(productID, deleted).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := h.DeleteLicenseSessionsByProductID(context.Background(), productID, deleted)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	mock.ExpectExec("DELETE FROM license_session WHERE license_id IN (SELECT id FROM license WHERE product_id = $1 AND deleted = $2)").
+		WithArgs(productID, deleted).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = h.DeleteLicenseSessionsByProductID(context.Background(), productID, deleted)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_DeleteLicenseSessionsByIssuerID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseIssuerID := 4
+	deleted := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM license_session WHERE license_id IN (SELECT id FROM license WHERE issuer_id = $1 AND deleted = $2)").
+		WithArgs(licenseIssuerID, deleted).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+
+	n, err := h.DeleteLicenseSessionsByIssuerID(context.Background(), licenseIssuerID, deleted)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_DeleteLicenseSessionsByParentID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	parentID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	deleted := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM license_session WHERE license_id IN (SELECT id FROM license WHERE parent_id = $1 AND deleted = $2)").
+		WithArgs(parentID, deleted).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := h.DeleteLicenseSessionsByParentID(context.Background(), parentID, deleted)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const licenseTemplateTable = "license_template"
+
+func (h *Handler) InsertLicenseTemplate(ctx context.Context, t *model.LicenseTemplate) (int, error) {
+	const (
+		action = "Insert"
+		scope  = licenseTemplateTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"version":      t.Version,
+			"name":         t.Name,
+			"tags":         pq.Array(t.Tags),
+			"features":     pq.Array(t.Features),
+			"note":         t.Note,
+			"data":         t.Data,
+			"max_sessions": t.MaxSessions,
+			"valid_for":    t.ValidFor,
+			"created":      t.Created,
+			"updated":      t.Updated,
+			"issuer_id":    t.IssuerID,
+			"product_id":   t.ProductID,
+		}).Suffix("RETURNING id")
+
+	var id int
+	return id, h.execInsert(ctx, sq, scope, action, &id)
+}
+
+func (h *Handler) SelectAllLicenseTemplatesByIssuerID(ctx context.Context, licenseIssuerID int) ([]*model.LicenseTemplate, error) {
+	return h.selectLicenseTemplates(ctx, "SelectAllByIssuerID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"issuer_id": licenseIssuerID,
+			}).OrderBy("name", "id")
+		})
+}
+
+func (h *Handler) SelectLicenseTemplateByID(ctx context.Context, licenseTemplateID int) (*model.LicenseTemplate, error) {
+	return h.selectLicenseTemplate(ctx, "SelectByID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id": licenseTemplateID,
+			})
+		})
+}
+
+func (h *Handler) selectLicenseTemplate(ctx context.Context, action string, d selectDecorator) (*model.LicenseTemplate, error) {
+	tt, err := h.selectLicenseTemplates(ctx, action, d)
+	if err != nil {
+		return nil, err
+	}
+	if len(tt) == 0 {
+		return nil, &Error{err: ErrNotFound, Scope: licenseTemplateTable, Action: action}
+	}
+	return tt[0], nil
+}
+
+func (h *Handler) selectLicenseTemplates(ctx context.Context, action string, d selectDecorator) ([]*model.LicenseTemplate, error) {
+	const scope = licenseTemplateTable
+
+	sq := h.sq.Select(
+		"id",
+		"version",
+		"name",
+		"tags",
+		"features",
+		"note",
+		"data",
+		"max_sessions",
+		"valid_for",
+		"created",
+		"updated",
+		"issuer_id",
+		"product_id",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var tt []*model.LicenseTemplate
+	for rows.Next() {
+		t := &model.LicenseTemplate{}
+		err = rows.Scan(
+			&t.ID,
+			&t.Version,
+			&t.Name,
+			pq.Array(&t.Tags),
+			pq.Array(&t.Features),
+			&t.Note,
+			&t.Data,
+			&t.MaxSessions,
+			&t.ValidFor,
+			&t.Created,
+			&t.Updated,
+			&t.IssuerID,
+			&t.ProductID,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		tt = append(tt, t)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return tt, nil
+}
+
+// UpdateLicenseTemplate updates license template and increments its version.
+func (h *Handler) UpdateLicenseTemplate(ctx context.Context, licenseTemplateID, licenseIssuerID int, update map[string]interface{}) error {
+	const (
+		action = "Update"
+		scope  = licenseTemplateTable
+	)
+	sq := h.sq.Update(scope).
+		SetMap(update).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(squirrel.Eq{
+			"id":        licenseTemplateID,
+			"issuer_id": licenseIssuerID,
+		})
+	return h.execUpdate(ctx, sq, scope, action)
+}
+
+func (h *Handler) DeleteLicenseTemplateByID(ctx context.Context, licenseTemplateID, licenseIssuerID int) (int, error) {
+	const scope = licenseTemplateTable
+	sq := h.sq.Delete(scope).
+		Where(squirrel.Eq{
+			"id":        licenseTemplateID,
+			"issuer_id": licenseIssuerID,
+		})
+	return h.execDelete(ctx, sq, scope, "DeleteByID")
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertLicenseTemplate(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	productID := 5
+	validFor := model.Duration(365 * 24 * time.Hour)
+	lt := &model.LicenseTemplate{
+		Version:     1,
+		Name:        "Yearly",
+		Tags:        []string{"yearly"},
+		Features:    []string{"pro"},
+		Note:        "Note",
+		Data:        []byte(`{"extraJsonData":true}`),
+		MaxSessions: 2,
+		ValidFor:    &validFor,
+		Created:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Updated:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		IssuerID:    3,
+		ProductID:   &productID,
+	}
+
+	mock.ExpectQuery("INSERT INTO license_template (created,data,features,issuer_id,max_sessions,name,note,product_id,tags,updated,valid_for,version) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING id").
+		WithArgs(
+			lt.Created,
+			lt.Data,
+			pq.Array(lt.Features),
+			lt.IssuerID,
+			lt.MaxSessions,
+			lt.Name,
+			lt.Note,
+			lt.ProductID,
+			pq.Array(lt.Tags),
+			lt.Updated,
+			int64(365*24*60*60),
+			lt.Version,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	id, err := h.InsertLicenseTemplate(context.Background(), lt)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, id)
+}
+
+func TestHandler_SelectLicenseTemplateByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	validFor := model.Duration(30 * 24 * time.Hour)
+	expected := &model.LicenseTemplate{
+		ID:          7,
+		Version:     2,
+		Name:        "Monthly",
+		Tags:        []string{"monthly"},
+		Features:    []string{},
+		MaxSessions: 1,
+		ValidFor:    &validFor,
+		Created:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Updated:     time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+		IssuerID:    3,
+	}
+
+	rows := sqlmock.NewRows([]string{
+		"id",
+		"version",
+		"name",
+		"tags",
+		"features",
+		"note",
+		"data",
+		"max_sessions",
+		"valid_for",
+		"created",
+		"updated",
+		"issuer_id",
+		"product_id",
+	}).AddRow(
+		expected.ID,
+		expected.Version,
+		expected.Name,
+		pq.Array(expected.Tags),
+		pq.Array(expected.Features),
+		expected.Note,
+		expected.Data,
+		expected.MaxSessions,
+		int64(30*24*60*60),
+		expected.Created,
+		expected.Updated,
+		expected.IssuerID,
+		expected.ProductID,
+	)
+
+	mock.ExpectQuery("SELECT id, version, name, tags, features, note, data, max_sessions, valid_for, created, updated, issuer_id, product_id FROM license_template WHERE id = $1").
+		WithArgs(expected.ID).
+		WillReturnRows(rows)
+
+	got, err := h.SelectLicenseTemplateByID(context.Background(), expected.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestHandler_UpdateLicenseTemplate(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	update := map[string]interface{}{
+		"name":    "Renamed",
+		"updated": time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	mock.ExpectExec("UPDATE license_template SET name = $1, updated = $2, version = version + 1 WHERE id = $3 AND issuer_id = $4").
+		WithArgs(update["name"], update["updated"], 7, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = h.UpdateLicenseTemplate(context.Background(), 7, 3, update)
+	assert.NoError(t, err)
+}
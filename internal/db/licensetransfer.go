@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const licenseTransferTable = "license_transfer"
+
+func (h *Handler) InsertLicenseTransfer(ctx context.Context, t *model.LicenseTransfer) (int, error) {
+	const (
+		action = "Insert"
+		scope  = licenseTransferTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"license_id": t.LicenseID,
+			"machine_id": t.MachineID,
+			"created":    t.Created,
+		}).Suffix("RETURNING id")
+
+	var id int
+	return id, h.execInsert(ctx, sq, scope, action, &id)
+}
+
+// SelectLicenseTransfersStatsByLicenseID selects number of license's
+// transfers and time of the latest one, nil if there are none.
+func (h *Handler) SelectLicenseTransfersStatsByLicenseID(ctx context.Context, licenseID []byte) (count int, last *time.Time, err error) {
+	const (
+		action = "SelectStatsByLicenseID"
+		scope  = licenseTransferTable
+	)
+	row := h.sq.Select("COUNT(*)", "MAX(created)").
+		From(scope).
+		Where(squirrel.Eq{
+			"license_id": licenseID,
+		}).
+		QueryRowContext(ctx)
+
+	err = row.Scan(&count, &last)
+	if err != nil {
+		return 0, nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return count, last, nil
+}
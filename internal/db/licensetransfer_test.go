@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertLicenseTransfer(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	lt := &model.LicenseTransfer{
+		LicenseID: base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+		MachineID: []byte{0x9, 0xb7, 0xed, 0xfc},
+		Created:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	mock.ExpectQuery("INSERT INTO license_transfer (created,license_id,machine_id) VALUES ($1,$2,$3) RETURNING id").
+		WithArgs(lt.Created, lt.LicenseID, lt.MachineID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	id, err := h.InsertLicenseTransfer(context.Background(), lt)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, id)
+}
+
+func TestHandler_SelectLicenseTransfersStatsByLicenseID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	last := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT COUNT(*), MAX(created) FROM license_transfer WHERE license_id = $1").
+		WithArgs(licenseID).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(2, last))
+	mock.ExpectQuery("SELECT COUNT(*), MAX(created) FROM license_transfer WHERE license_id = $1").
+		WithArgs(licenseID).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(0, nil))
+
+	count, got, err := h.SelectLicenseTransfersStatsByLicenseID(context.Background(), licenseID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, &last, got)
+
+	count, got, err = h.SelectLicenseTransfersStatsByLicenseID(context.Background(), licenseID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Nil(t, got)
+}
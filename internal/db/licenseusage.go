@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const (
+	licenseUsageTable = "license_usage"
+	usageReportTable  = "usage_report"
+)
+
+// AddLicenseUsage adds usage amounts to license's usage of the period.
+func (h *Handler) AddLicenseUsage(ctx context.Context, licenseID []byte, period time.Time, usage map[string]int64, updated time.Time) error {
+	const (
+		action = "Add"
+		scope  = licenseUsageTable
+	)
+	if len(usage) == 0 {
+		return nil
+	}
+	metrics := make([]string, 0, len(usage))
+	for m := range usage {
+		metrics = append(metrics, m)
+	}
+	sort.Strings(metrics) // Consistent lock order.
+
+	sq := h.sq.Insert(scope).Columns(
+		"license_id",
+		"metric",
+		"period",
+		"amount",
+		"updated",
+	)
+	for _, m := range metrics {
+		sq = sq.Values(licenseID, m, period, usage[m], updated)
+	}
+	sq = sq.Suffix("ON CONFLICT (license_id, period, metric) DO UPDATE SET amount = license_usage.amount + EXCLUDED.amount, updated = EXCLUDED.updated")
+	return h.execInsertMany(ctx, sq, scope, action)
+}
+
+// InsertUsageReport inserts usage report, unless license already has one with
+// the same ID.
+//
+// Reports whether report has been inserted.
+func (h *Handler) InsertUsageReport(ctx context.Context, r *model.UsageReport) (bool, error) {
+	const (
+		action = "Insert"
+		scope  = usageReportTable
+	)
+	defer observeQuery(scope, action, time.Now())
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"id":         r.ID,
+			"license_id": r.LicenseID,
+			"created":    r.Created,
+		}).
+		Suffix("ON CONFLICT (license_id, id) DO NOTHING")
+
+	res, err := sq.ExecContext(ctx)
+	if err != nil {
+		return false, &Error{err: err, Scope: scope, Action: action}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, &Error{err: err, Scope: scope, Action: action}
+	}
+	return n > 0, nil
+}
+
+// DeleteUsageReportsCreatedBefore deletes usage reports created before t.
+func (h *Handler) DeleteUsageReportsCreatedBefore(ctx context.Context, t time.Time) (int, error) {
+	sq := h.sq.Delete(usageReportTable).
+		Where(squirrel.Lt{
+			"created": t,
+		})
+	return h.execDelete(ctx, sq, usageReportTable, "DeleteCreatedBefore")
+}
+
+// SelectLicenseUsageByLicenseID selects license's usage of periods starting
+// in [from; to), latest first. Nil bounds are ignored.
+func (h *Handler) SelectLicenseUsageByLicenseID(ctx context.Context, licenseID []byte, from, to *time.Time) ([]*model.LicenseUsage, error) {
+	return h.selectLicenseUsage(ctx, "SelectByLicenseID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			where := squirrel.And{
+				squirrel.Eq{"license_id": licenseID},
+			}
+			if from != nil {
+				where = append(where, squirrel.GtOrEq{"period": *from})
+			}
+			if to != nil {
+				where = append(where, squirrel.Lt{"period": *to})
+			}
+			return sq.Where(where).OrderBy("period DESC", "metric")
+		})
+}
+
+func (h *Handler) SelectLicenseUsageByLicenseIDAndPeriod(ctx context.Context, licenseID []byte, period time.Time) ([]*model.LicenseUsage, error) {
+	return h.selectLicenseUsage(ctx, "SelectByLicenseIDAndPeriod",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"license_id": licenseID,
+				"period":     period,
+			}).OrderBy("metric")
+		})
+}
+
+func (h *Handler) selectLicenseUsage(ctx context.Context, action string, d selectDecorator) ([]*model.LicenseUsage, error) {
+	const scope = licenseUsageTable
+
+	sq := h.sq.Select(
+		"license_id",
+		"metric",
+		"period",
+		"amount",
+		"updated",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var uu []*model.LicenseUsage
+	for rows.Next() {
+		u := &model.LicenseUsage{}
+		err = rows.Scan(
+			&u.LicenseID,
+			&u.Metric,
+			&u.Period,
+			&u.Amount,
+			&u.Updated,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		uu = append(uu, u)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return uu, nil
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_AddLicenseUsage(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	period := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("INSERT INTO license_usage (license_id,metric,period,amount,updated) VALUES ($1,$2,$3,$4,$5),($6,$7,$8,$9,$10) ON CONFLICT (license_id, period, metric) DO UPDATE SET amount = license_usage.amount + EXCLUDED.amount, updated = EXCLUDED.updated").
+		WithArgs(
+			licenseID, "api-calls", period, int64(10), updated,
+			licenseID, "exports", period, int64(3), updated,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = h.AddLicenseUsage(context.Background(), licenseID, period, map[string]int64{"exports": 3, "api-calls": 10}, updated)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Nothing to add
+	err = h.AddLicenseUsage(context.Background(), licenseID, period, nil, updated)
+	assert.NoError(t, err)
+}
+
+func TestHandler_InsertUsageReport(t *testing.T) {
+	const query = "INSERT INTO usage_report (created,id,license_id) VALUES ($1,$2,$3) ON CONFLICT (license_id, id) DO NOTHING"
+
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	r := &model.UsageReport{
+		ID:        []byte{1, 2, 3},
+		LicenseID: base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+		Created:   time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+
+	mock.ExpectExec(query).
+		WithArgs(r.Created, r.ID, r.LicenseID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err := h.InsertUsageReport(context.Background(), r)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Retried or replayed report
+	mock.ExpectExec(query).
+		WithArgs(r.Created, r.ID, r.LicenseID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	ok, err = h.InsertUsageReport(context.Background(), r)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_DeleteUsageReportsCreatedBefore(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	before := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM usage_report WHERE created < $1").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := h.DeleteUsageReportsCreatedBefore(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectLicenseUsageByLicenseID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	licenseID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := []*model.LicenseUsage{
+		{LicenseID: licenseID, Metric: "exports", Period: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC), Amount: 4, Updated: time.Date(2022, 2, 3, 0, 0, 0, 0, time.UTC)},
+		{LicenseID: licenseID, Metric: "exports", Period: from, Amount: 12, Updated: time.Date(2022, 1, 30, 0, 0, 0, 0, time.UTC)},
+	}
+
+	rows := sqlmock.NewRows([]string{"license_id", "metric", "period", "amount", "updated"})
+	for _, u := range expected {
+		rows.AddRow(u.LicenseID, u.Metric, u.Period, u.Amount, u.Updated)
+	}
+	mock.ExpectQuery("SELECT license_id, metric, period, amount, updated FROM license_usage WHERE (license_id = $1 AND period >= $2) ORDER BY period DESC, metric").
+		WithArgs(licenseID, from).
+		WillReturnRows(rows)
+
+	got, err := h.SelectLicenseUsageByLicenseID(context.Background(), licenseID, &from, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
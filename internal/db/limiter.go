@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+const licenseLimiterTable = "license_limiter"
+
+// TakeLicenseLimiterToken atomically takes a single token from license's token
+// bucket. Bucket is refilled with rate tokens per second and holds at most
+// burst tokens.
+//
+// Reports whether token has been taken.
+func (h *Handler) TakeLicenseLimiterToken(ctx context.Context, licenseID []byte, rate float64, burst int, now time.Time) (bool, error) {
+	const (
+		action = "TakeToken"
+		scope  = licenseLimiterTable
+	)
+	if burst < 1 {
+		return false, nil
+	}
+	// Tokens available in the bucket at the time of now.
+	const available = "LEAST(?::double precision, license_limiter.tokens + ?::double precision * " +
+		"GREATEST(0, EXTRACT(EPOCH FROM (?::timestamp with time zone - license_limiter.updated)))::double precision)"
+
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"license_id": licenseID,
+			"tokens":     burst - 1,
+			"updated":    now,
+		}).
+		Suffix("ON CONFLICT (license_id) DO UPDATE SET tokens = "+available+" - 1, updated = ?",
+			burst, rate, now, now).
+		Suffix("WHERE "+available+" >= 1", burst, rate, now).
+		Suffix("RETURNING tokens")
+
+	var tokens float64
+	err := sq.QueryRowContext(ctx).Scan(&tokens)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, &Error{err: err, Scope: scope, Action: action}
+	}
+	return true, nil
+}
+
+func (h *Handler) DeleteLicenseLimitersUpdatedBefore(ctx context.Context, t time.Time) (int, error) {
+	sq := h.sq.Delete(licenseLimiterTable).
+		Where(squirrel.Lt{
+			"updated": t,
+		})
+	return h.execDelete(ctx, sq, licenseLimiterTable, "DeleteUpdatedBefore")
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_TakeLicenseLimiterToken(t *testing.T) {
+	const query = "INSERT INTO license_limiter (license_id,tokens,updated) VALUES ($1,$2,$3) " +
+		"ON CONFLICT (license_id) DO UPDATE SET tokens = LEAST($4::double precision, license_limiter.tokens + $5::double precision * " +
+		"GREATEST(0, EXTRACT(EPOCH FROM ($6::timestamp with time zone - license_limiter.updated)))::double precision) - 1, updated = $7 " +
+		"WHERE LEAST($8::double precision, license_limiter.tokens + $9::double precision * " +
+		"GREATEST(0, EXTRACT(EPOCH FROM ($10::timestamp with time zone - license_limiter.updated)))::double precision) >= 1 " +
+		"RETURNING tokens"
+
+	licenseID := base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo=")
+	now := time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)
+	const (
+		rate  = 0.5
+		burst = 48
+	)
+
+	tests := []struct {
+		name      string
+		burst     int
+		expect    func(mock sqlmock.Sqlmock)
+		want      bool
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "taken",
+			burst: burst,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(licenseID, burst-1, now, burst, rate, now, now, burst, rate, now).
+					WillReturnRows(sqlmock.NewRows([]string{"tokens"}).AddRow(12.5))
+			},
+			want:      true,
+			assertion: assert.NoError,
+		},
+		{
+			name:  "empty bucket",
+			burst: burst,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(licenseID, burst-1, now, burst, rate, now, now, burst, rate, now).
+					WillReturnError(sql.ErrNoRows)
+			},
+			want:      false,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "zero burst",
+			burst:     0,
+			expect:    func(mock sqlmock.Sqlmock) {},
+			want:      false,
+			assertion: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock, err := newMock()
+			require.NoError(t, err)
+			defer h.Close()
+
+			tt.expect(mock)
+			got, err := h.TakeLicenseLimiterToken(context.Background(), licenseID, rate, tt.burst, now)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestHandler_DeleteLicenseLimitersUpdatedBefore(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	const expected = 7
+	before := time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("DELETE FROM license_limiter WHERE updated < $1").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, expected))
+
+	got, err := h.DeleteLicenseLimitersUpdatedBefore(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// listSpec describes how table rows are listed using keyset pagination.
+type listSpec struct {
+	id           sortKey            // Unique key, breaks sort ties.
+	sortKeys     map[string]sortKey // Sort keys by name.
+	defaultSort  string             // Sort key used when paginating without one.
+	defaultOrder []string           // Order used when not paginating at all.
+	search       string             // Expression of full-text searched text.
+}
+
+// sortKey is a column rows can be sorted and paginated by.
+type sortKey struct {
+	column string                                         // Expression, must not be NULL.
+	value  func(item interface{}) interface{}             // Value of the column for cursor.
+	decode func(raw json.RawMessage) (interface{}, error) // Decodes cursor value.
+}
+
+// cursor points to the last item of a page.
+type cursor struct {
+	Sort  string          `json:"s"`
+	Desc  bool            `json:"d,omitempty"`
+	Value json.RawMessage `json:"v"`
+	ID    json.RawMessage `json:"id"`
+}
+
+// listQuery is a list query of a single page.
+type listQuery struct {
+	spec  *listSpec
+	opts  *model.ListOptions
+	sort  string
+	key   sortKey
+	where squirrel.And // Filters and search, applies to count as well.
+	after squirrel.Sqlizer
+}
+
+// query prepares list query of a page. Filters are given in where.
+//
+// Returns ErrInvalidArgument
+func (s *listSpec) query(where squirrel.And, opts *model.ListOptions) (*listQuery, error) {
+	if opts == nil {
+		opts = &model.ListOptions{}
+	}
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("%w: limit", ErrInvalidArgument)
+	}
+	q := &listQuery{
+		spec:  s,
+		opts:  opts,
+		sort:  opts.Sort,
+		where: where,
+	}
+	if q.sort == "" && q.paginated() {
+		q.sort = s.defaultSort
+	}
+	if q.sort != "" {
+		key, ok := s.sortKeys[q.sort]
+		if !ok {
+			return nil, fmt.Errorf("%w: sort key %q", ErrInvalidArgument, q.sort)
+		}
+		q.key = key
+	}
+
+	if cond := searchCond(s.search, opts.Search); cond != nil {
+		q.where = append(q.where, cond)
+	}
+	if opts.Cursor != "" {
+		after, err := q.decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.after = after
+	}
+	return q, nil
+}
+
+// paginated reports whether the query is a part of paginated listing.
+func (q *listQuery) paginated() bool {
+	return q.opts.Sort != "" || q.opts.Desc || q.opts.Limit > 0 || q.opts.Cursor != ""
+}
+
+// decorate applies filters, search, keyset and ordering to select query.
+// One more item than the limit is selected, to know whether there is a next
+// page.
+func (q *listQuery) decorate(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+	if len(q.where) > 0 {
+		sq = sq.Where(q.where)
+	}
+	if q.after != nil {
+		sq = sq.Where(q.after)
+	}
+	if q.sort == "" {
+		return sq.OrderBy(q.spec.defaultOrder...)
+	}
+	dir := " ASC"
+	if q.opts.Desc {
+		dir = " DESC"
+	}
+	sq = sq.OrderBy(q.key.column+dir, q.spec.id.column+dir)
+	if q.opts.Limit > 0 {
+		sq = sq.Limit(uint64(q.opts.Limit) + 1)
+	}
+	return sq
+}
+
+// decorateCount applies filters and search to count query.
+func (q *listQuery) decorateCount(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+	if len(q.where) > 0 {
+		sq = sq.Where(q.where)
+	}
+	return sq
+}
+
+// needsCount reports whether total count must be queried separately.
+func (q *listQuery) needsCount() bool {
+	return q.opts.Limit > 0 || q.opts.Cursor != ""
+}
+
+// hasNext reports whether selected number of items has a next page.
+func (q *listQuery) hasNext(n int) bool {
+	return q.opts.Limit > 0 && n > q.opts.Limit
+}
+
+// encodeCursor returns cursor pointing to the item given.
+func (q *listQuery) encodeCursor(item interface{}) (string, error) {
+	v, err := json.Marshal(q.key.value(item))
+	if err != nil {
+		return "", err
+	}
+	id, err := json.Marshal(q.spec.id.value(item))
+	if err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(cursor{
+		Sort:  q.sort,
+		Desc:  q.opts.Desc,
+		Value: v,
+		ID:    id,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// decodeCursor returns keyset condition selecting items after the cursor.
+//
+// Returns ErrInvalidArgument
+func (q *listQuery) decodeCursor(s string) (squirrel.Sqlizer, error) {
+	invalid := fmt.Errorf("%w: cursor", ErrInvalidArgument)
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalid
+	}
+	var cur cursor
+	err = json.Unmarshal(bs, &cur)
+	if err != nil || cur.Sort != q.sort || cur.Desc != q.opts.Desc {
+		return nil, invalid
+	}
+	v, err := q.key.decode(cur.Value)
+	if err != nil {
+		return nil, invalid
+	}
+	id, err := q.spec.id.decode(cur.ID)
+	if err != nil {
+		return nil, invalid
+	}
+	op := ">"
+	if q.opts.Desc {
+		op = "<"
+	}
+	return squirrel.Expr(fmt.Sprintf("(%s, %s) %s (?, ?)", q.key.column, q.spec.id.column, op), v, id), nil
+}
+
+// searchCond returns full-text search condition matching words of the
+// query as prefixes. Nil is returned if query has no words.
+func searchCond(expr, query string) squirrel.Sqlizer {
+	if expr == "" {
+		return nil
+	}
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return squirrel.Expr(
+		fmt.Sprintf("to_tsvector('simple', %s) @@ to_tsquery('simple', ?)", expr),
+		strings.Join(words, " & "),
+	)
+}
+
+func (h *Handler) selectCount(ctx context.Context, scope, action string, d selectDecorator) (int, error) {
+	sq := d(h.sq.Select("COUNT(*)").From(scope))
+	row := sq.QueryRowContext(ctx)
+	var count int
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, &Error{err: err, Scope: scope, Action: action}
+	}
+	return count, nil
+}
+
+func decodeTime(raw json.RawMessage) (interface{}, error) {
+	var t time.Time
+	err := json.Unmarshal(raw, &t)
+	return t, err
+}
+
+// decodeNullTime decodes nullable time, null is decoded as inf given.
+func decodeNullTime(inf string) func(raw json.RawMessage) (interface{}, error) {
+	return func(raw json.RawMessage) (interface{}, error) {
+		var t *time.Time
+		err := json.Unmarshal(raw, &t)
+		if err != nil || t == nil {
+			return inf, err
+		}
+		return *t, nil
+	}
+}
+
+func decodeString(raw json.RawMessage) (interface{}, error) {
+	var s string
+	err := json.Unmarshal(raw, &s)
+	return s, err
+}
+
+func decodeInt(raw json.RawMessage) (interface{}, error) {
+	var i int
+	err := json.Unmarshal(raw, &i)
+	return i, err
+}
+
+func decodeInt64(raw json.RawMessage) (interface{}, error) {
+	var i int64
+	err := json.Unmarshal(raw, &i)
+	return i, err
+}
+
+func decodeBool(raw json.RawMessage) (interface{}, error) {
+	var b bool
+	err := json.Unmarshal(raw, &b)
+	return b, err
+}
+
+func decodeBytes(raw json.RawMessage) (interface{}, error) {
+	var bs []byte
+	err := json.Unmarshal(raw, &bs)
+	if err == nil && bs == nil {
+		return nil, fmt.Errorf("null bytes")
+	}
+	return bs, err
+}
+
+// listPage trims the extra item selected, encodes next page cursor and counts
+// total items if needed. Returns page and number of items to keep.
+func (h *Handler) listPage(ctx context.Context, scope, action string, q *listQuery, n int, item func(i int) interface{}) (*model.Page, int, error) {
+	page := &model.Page{Total: n}
+	if q.hasNext(n) {
+		n = q.opts.Limit
+		next, err := q.encodeCursor(item(n - 1))
+		if err != nil {
+			return nil, 0, &Error{err: err, Scope: scope, Action: action}
+		}
+		page.Next = next
+	}
+	if q.needsCount() {
+		total, err := h.selectCount(ctx, scope, action, q.decorateCount)
+		if err != nil {
+			return nil, 0, err
+		}
+		page.Total = total
+	}
+	return page, n, nil
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchCond(t *testing.T) {
+	tests := []struct {
+		query    string
+		wantNil  bool
+		wantArgs []interface{}
+	}{
+		{"", true, nil},
+		{" &|!():* ", true, nil},
+		{"pro", false, []interface{}{"pro:*"}},
+		{"pro lic'ense", false, []interface{}{"pro:* & lic:* & ense:*"}},
+		{"Žalgiris 2022", false, []interface{}{"Žalgiris:* & 2022:*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			cond := searchCond("name", tt.query)
+			if tt.wantNil {
+				assert.Nil(t, cond)
+				return
+			}
+			sql, args, err := cond.ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, "to_tsvector('simple', name) @@ to_tsquery('simple', ?)", sql)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+const lockScope = "advisory_lock"
+
+// AdvisoryLock is a session level PostgreSQL advisory lock. Lock is held on a
+// dedicated connection, and is released as soon as the connection is lost.
+type AdvisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryAdvisoryLock tries to acquire session level advisory lock without
+// waiting.
+//
+// Returns ErrLocked if the lock is held by someone else.
+func (h *Handler) TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	const action = "TryLock"
+	conn, err := h.db.Conn(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: lockScope, Action: action}
+	}
+	var acquired bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return nil, &Error{err: err, Scope: lockScope, Action: action}
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, &Error{err: ErrLocked, Scope: lockScope, Action: action}
+	}
+	return &AdvisoryLock{
+		conn: conn,
+		key:  key,
+	}, nil
+}
+
+// Check checks whether the connection holding the lock is still alive, i.e.,
+// lock is still held.
+func (l *AdvisoryLock) Check(ctx context.Context) error {
+	err := l.conn.PingContext(ctx)
+	if err != nil {
+		return &Error{err: err, Scope: lockScope, Action: "Check"}
+	}
+	return nil
+}
+
+// Release releases the lock and closes underlying connection.
+func (l *AdvisoryLock) Release(ctx context.Context) error {
+	const action = "Release"
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	errClose := l.conn.Close()
+	if err != nil {
+		return &Error{err: err, Scope: lockScope, Action: action}
+	}
+	if errClose != nil {
+		return &Error{err: errClose, Scope: lockScope, Action: action}
+	}
+	return nil
+}
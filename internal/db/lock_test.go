@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_TryAdvisoryLock(t *testing.T) {
+	const key = 42
+
+	t.Run("acquired", func(t *testing.T) {
+		h, mock, err := newMock()
+		require.NoError(t, err)
+		defer h.Close()
+
+		mock.ExpectQuery("SELECT pg_try_advisory_lock($1)").
+			WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectExec("SELECT pg_advisory_unlock($1)").
+			WithArgs(key).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		lock, err := h.TryAdvisoryLock(context.Background(), key)
+		require.NoError(t, err)
+		assert.NoError(t, lock.Release(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		h, mock, err := newMock()
+		require.NoError(t, err)
+		defer h.Close()
+
+		mock.ExpectQuery("SELECT pg_try_advisory_lock($1)").
+			WithArgs(key).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+		lock, err := h.TryAdvisoryLock(context.Background(), key)
+		assert.ErrorIs(t, err, ErrLocked)
+		assert.Nil(t, lock)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,9 +1,12 @@
 package db
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
@@ -45,3 +48,46 @@ func MigrateUp(dataSource string) (migrated bool, err error) {
 	}
 	return migrated, nil
 }
+
+// LatestMigrationVersion returns the latest embedded migration version, i.e.,
+// schema version expected by the application.
+func LatestMigrationVersion() (uint, error) {
+	entries, err := migrationsDir.ReadDir("migrations")
+	if err != nil {
+		return 0, fmt.Errorf("migrations source: %w", err)
+	}
+	var latest uint
+	for _, e := range entries {
+		name := e.Name()
+		i := strings.IndexRune(name, '_')
+		if i < 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(name[:i], 10, 0)
+		if err != nil {
+			return 0, fmt.Errorf("migrations source: %s: %w", name, err)
+		}
+		if uint(v) > latest {
+			latest = uint(v)
+		}
+	}
+	return latest, nil
+}
+
+// MigrationVersion returns current database schema version and whether last
+// migration has failed (dirty).
+func (h *Handler) MigrationVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	const (
+		scope  = "schema_migrations"
+		action = "SelectVersion"
+	)
+	row := h.sq.Select("version", "dirty").
+		From(scope).
+		Limit(1).
+		QueryRowContext(ctx)
+	err = row.Scan(&version, &dirty)
+	if err != nil {
+		return 0, false, &Error{err: err, Scope: scope, Action: action}
+	}
+	return version, dirty, nil
+}
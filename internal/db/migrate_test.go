@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestMigrationVersion(t *testing.T) {
+	got, err := LatestMigrationVersion()
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, got, uint(8))
+}
+
+func TestHandler_MigrationVersion(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(8, false))
+
+	version, dirty, err := h.MigrationVersion(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint(8), version)
+	assert.False(t, dirty)
+}
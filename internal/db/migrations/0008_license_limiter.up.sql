@@ -0,0 +1,13 @@
+CREATE TABLE license_limiter
+(
+    license_id bytea                    NOT NULL,
+    tokens     double precision         NOT NULL,
+    updated    timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT license_limiter_pkey            PRIMARY KEY (license_id),
+    CONSTRAINT license_limiter_license_id_fkey FOREIGN KEY (license_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
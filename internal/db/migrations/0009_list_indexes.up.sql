@@ -0,0 +1,12 @@
+CREATE INDEX license_issuer_id_created_idx ON license (issuer_id, created, id);
+
+CREATE INDEX license_search_idx ON license
+    USING GIN (to_tsvector('simple', name || ' ' || note));
+
+CREATE INDEX product_search_idx ON product
+    USING GIN (to_tsvector('simple', name));
+
+CREATE INDEX license_issuer_search_idx ON license_issuer
+    USING GIN (to_tsvector('simple', username || ' ' || email));
+
+CREATE INDEX license_session_license_id_created_idx ON license_session (license_id, created, client_session_id);
@@ -0,0 +1,44 @@
+CREATE TABLE license_template
+(
+    id           serial                   NOT NULL,
+    version      integer                  NOT NULL DEFAULT 1,
+    name         character varying(64)    NOT NULL,
+    tags         character varying(64)[]  NOT NULL DEFAULT '{}',
+    features     character varying(64)[]  NOT NULL DEFAULT '{}',
+    note         character varying(500)   NOT NULL DEFAULT '',
+    data         bytea,
+    max_sessions integer                  NOT NULL DEFAULT 1,
+    valid_for    bigint,
+    created      timestamp with time zone NOT NULL DEFAULT NOW(),
+    updated      timestamp with time zone NOT NULL DEFAULT NOW(),
+    issuer_id    integer                  NOT NULL,
+    product_id   integer,
+
+    CONSTRAINT license_template_pkey            PRIMARY KEY (id),
+    CONSTRAINT license_template_issuer_id_fkey  FOREIGN KEY (issuer_id)
+        REFERENCES license_issuer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID,
+    CONSTRAINT license_template_product_id_fkey FOREIGN KEY (product_id)
+        REFERENCES product (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE SET NULL
+        NOT VALID
+);
+
+ALTER TABLE license
+    ADD COLUMN features character varying(64)[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE license
+    ADD COLUMN template_id integer DEFAULT NULL;
+
+ALTER TABLE license
+    ADD COLUMN template_version integer DEFAULT NULL;
+
+ALTER TABLE license
+    ADD CONSTRAINT license_template_id_fkey FOREIGN KEY (template_id)
+        REFERENCES license_template (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE SET NULL
+        NOT VALID;
@@ -0,0 +1,22 @@
+ALTER TABLE license
+    ADD COLUMN max_transfers integer NOT NULL DEFAULT 0;
+
+ALTER TABLE license
+    ADD COLUMN transfer_cooldown bigint DEFAULT NULL;
+
+CREATE TABLE license_transfer
+(
+    id         serial                   NOT NULL,
+    license_id bytea                    NOT NULL,
+    machine_id bytea                    NOT NULL,
+    created    timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT license_transfer_pkey            PRIMARY KEY (id),
+    CONSTRAINT license_transfer_license_id_fkey FOREIGN KEY (license_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE INDEX license_transfer_license_id_created_idx ON license_transfer (license_id, created);
@@ -0,0 +1,11 @@
+ALTER TABLE license
+    ADD COLUMN parent_id bytea DEFAULT NULL;
+
+ALTER TABLE license
+    ADD CONSTRAINT license_parent_id_fkey FOREIGN KEY (parent_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID;
+
+CREATE INDEX license_parent_id_idx ON license (parent_id) WHERE parent_id IS NOT NULL;
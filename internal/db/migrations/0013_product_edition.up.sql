@@ -0,0 +1,31 @@
+CREATE TABLE product_edition
+(
+    id         serial                   NOT NULL,
+    name       character varying(64)    NOT NULL,
+    features   character varying(64)[]  NOT NULL DEFAULT '{}',
+    channels   character varying(32)[]  NOT NULL DEFAULT '{}',
+    data       bytea,
+    created    timestamp with time zone NOT NULL DEFAULT NOW(),
+    updated    timestamp with time zone NOT NULL DEFAULT NOW(),
+    product_id integer                  NOT NULL,
+
+    CONSTRAINT product_edition_pkey            PRIMARY KEY (id),
+    CONSTRAINT product_edition_product_id_fkey FOREIGN KEY (product_id)
+        REFERENCES product (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+ALTER TABLE license
+    ADD COLUMN edition_id integer DEFAULT NULL;
+
+ALTER TABLE license
+    ADD COLUMN channels character varying(32)[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE license
+    ADD CONSTRAINT license_edition_id_fkey FOREIGN KEY (edition_id)
+        REFERENCES product_edition (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE SET NULL
+        NOT VALID;
@@ -0,0 +1,25 @@
+CREATE TABLE product_release
+(
+    id              serial                   NOT NULL,
+    version         character varying(64)    NOT NULL,
+    channel         character varying(32)    NOT NULL,
+    notes           text                     NOT NULL DEFAULT '',
+    edition_ids     integer[]                NOT NULL DEFAULT '{}',
+    min_app_version character varying(64)    NOT NULL DEFAULT '',
+    artifacts       jsonb                    NOT NULL DEFAULT '[]',
+    released        timestamp with time zone NOT NULL DEFAULT NOW(),
+    created         timestamp with time zone NOT NULL DEFAULT NOW(),
+    updated         timestamp with time zone NOT NULL DEFAULT NOW(),
+    product_id      integer                  NOT NULL,
+
+    CONSTRAINT product_release_pkey                       PRIMARY KEY (id),
+    CONSTRAINT product_release_product_id_channel_version UNIQUE (product_id, channel, version),
+    CONSTRAINT product_release_product_id_fkey            FOREIGN KEY (product_id)
+        REFERENCES product (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+ALTER TABLE license
+    ADD COLUMN updates_until timestamp with time zone DEFAULT NULL;
@@ -0,0 +1,18 @@
+CREATE TABLE license_usage
+(
+    license_id bytea                    NOT NULL,
+    metric     character varying(64)    NOT NULL,
+    period     timestamp with time zone NOT NULL,
+    amount     bigint                   NOT NULL DEFAULT 0,
+    updated    timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT license_usage_pkey            PRIMARY KEY (license_id, period, metric),
+    CONSTRAINT license_usage_license_id_fkey FOREIGN KEY (license_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+ALTER TABLE license
+    ADD COLUMN quotas jsonb NOT NULL DEFAULT '{}';
@@ -0,0 +1,35 @@
+CREATE TABLE license_credit
+(
+    license_id bytea                    NOT NULL,
+    feature    character varying(64)    NOT NULL,
+    balance    bigint                   NOT NULL DEFAULT 0,
+    updated    timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT license_credit_pkey            PRIMARY KEY (license_id, feature),
+    CONSTRAINT license_credit_balance_check   CHECK (balance >= 0),
+    CONSTRAINT license_credit_license_id_fkey FOREIGN KEY (license_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE TABLE credit_receipt
+(
+    id                bytea                    NOT NULL,
+    license_id        bytea                    NOT NULL,
+    client_session_id bytea                    NOT NULL,
+    feature           character varying(64)    NOT NULL,
+    amount            bigint                   NOT NULL,
+    balance           bigint                   NOT NULL,
+    created           timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT credit_receipt_pkey            PRIMARY KEY (id),
+    CONSTRAINT credit_receipt_license_id_fkey FOREIGN KEY (license_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE INDEX credit_receipt_license_id_created_idx ON credit_receipt (license_id, created DESC);
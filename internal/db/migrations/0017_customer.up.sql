@@ -0,0 +1,55 @@
+CREATE TABLE customer
+(
+    id          serial                   NOT NULL,
+    name        character varying(128)   NOT NULL,
+    company     character varying(128)   NOT NULL DEFAULT '',
+    emails      character varying(128)[] NOT NULL DEFAULT '{}',
+    external_id character varying(128)   NOT NULL DEFAULT '',
+    note        text                     NOT NULL DEFAULT '',
+    created     timestamp with time zone NOT NULL DEFAULT NOW(),
+    updated     timestamp with time zone NOT NULL DEFAULT NOW(),
+    issuer_id   integer                  NOT NULL,
+
+    CONSTRAINT customer_pkey           PRIMARY KEY (id),
+    CONSTRAINT customer_issuer_id_fkey FOREIGN KEY (issuer_id)
+        REFERENCES license_issuer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE UNIQUE INDEX customer_issuer_id_external_id_idx ON customer (issuer_id, external_id)
+    WHERE external_id <> '';
+
+CREATE INDEX customer_search_idx ON customer
+    USING GIN (to_tsvector('simple', name || ' ' || company || ' ' || external_id));
+
+ALTER TABLE license
+    ADD COLUMN customer_id integer DEFAULT NULL;
+
+ALTER TABLE license
+    ADD CONSTRAINT license_customer_id_fkey FOREIGN KEY (customer_id)
+        REFERENCES customer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE SET NULL
+        NOT VALID;
+
+CREATE INDEX license_customer_id_idx ON license (customer_id);
+
+-- Turn distinct end user emails of each issuer into customers.
+INSERT INTO customer (name, emails, created, updated, issuer_id)
+SELECT email, ARRAY[email], created, NOW(), issuer_id
+FROM (
+    SELECT issuer_id, lower(end_user_email) AS email, MIN(created) AS created
+    FROM license
+    WHERE end_user_email <> ''
+    GROUP BY issuer_id, lower(end_user_email)
+) e
+ORDER BY issuer_id, created;
+
+UPDATE license
+SET customer_id = customer.id
+FROM customer
+WHERE license.end_user_email <> ''
+  AND customer.issuer_id = license.issuer_id
+  AND customer.emails[1] = lower(license.end_user_email);
@@ -0,0 +1,64 @@
+CREATE TABLE notification
+(
+    id           bigserial                NOT NULL,
+    kind         character varying(32)    NOT NULL,
+    recipient    character varying(128)   NOT NULL,
+    subject      text                     NOT NULL,
+    body         text                     NOT NULL,
+    dedup_key    character varying(256)   DEFAULT NULL,
+    attempts     integer                  NOT NULL DEFAULT 0,
+    next_attempt timestamp with time zone NOT NULL DEFAULT NOW(),
+    last_error   text                     NOT NULL DEFAULT '',
+    sent         timestamp with time zone DEFAULT NULL,
+    created      timestamp with time zone NOT NULL DEFAULT NOW(),
+    issuer_id    integer                  NOT NULL,
+    license_id   bytea                    DEFAULT NULL,
+
+    CONSTRAINT notification_pkey                PRIMARY KEY (id),
+    CONSTRAINT notification_dedup_key_recipient UNIQUE (dedup_key, recipient),
+    CONSTRAINT notification_issuer_id_fkey      FOREIGN KEY (issuer_id)
+        REFERENCES license_issuer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID,
+    CONSTRAINT notification_license_id_fkey     FOREIGN KEY (license_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE SET NULL
+        NOT VALID
+);
+
+CREATE INDEX notification_pending_idx ON notification (next_attempt)
+    WHERE sent IS NULL;
+
+CREATE INDEX notification_issuer_id_created_idx ON notification (issuer_id, created DESC);
+
+CREATE TABLE notification_template
+(
+    issuer_id integer                  NOT NULL,
+    kind      character varying(32)    NOT NULL,
+    subject   text                     NOT NULL,
+    body      text                     NOT NULL,
+    updated   timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT notification_template_pkey           PRIMARY KEY (issuer_id, kind),
+    CONSTRAINT notification_template_issuer_id_fkey FOREIGN KEY (issuer_id)
+        REFERENCES license_issuer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE TABLE notification_opt_out
+(
+    issuer_id integer                  NOT NULL,
+    email     character varying(128)   NOT NULL,
+    created   timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT notification_opt_out_pkey           PRIMARY KEY (issuer_id, email),
+    CONSTRAINT notification_opt_out_issuer_id_fkey FOREIGN KEY (issuer_id)
+        REFERENCES license_issuer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
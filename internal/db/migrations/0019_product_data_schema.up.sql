@@ -0,0 +1,2 @@
+ALTER TABLE product
+    ADD COLUMN data_schema bytea DEFAULT NULL;
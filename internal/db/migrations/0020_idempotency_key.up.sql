@@ -0,0 +1,18 @@
+CREATE TABLE idempotency_key
+(
+    issuer_id    integer                  NOT NULL,
+    key          character varying(255)   NOT NULL,
+    request_hash bytea                    NOT NULL,
+    status_code  integer                  NOT NULL DEFAULT 0,
+    body         bytea                    DEFAULT NULL,
+    created      timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT idempotency_key_pkey           PRIMARY KEY (issuer_id, key),
+    CONSTRAINT idempotency_key_issuer_id_fkey FOREIGN KEY (issuer_id)
+        REFERENCES license_issuer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE INDEX idempotency_key_created_idx ON idempotency_key (created);
@@ -0,0 +1,12 @@
+ALTER TABLE license_issuer
+    ADD COLUMN deleted timestamp with time zone DEFAULT NULL;
+
+ALTER TABLE product
+    ADD COLUMN deleted timestamp with time zone DEFAULT NULL;
+
+ALTER TABLE license
+    ADD COLUMN deleted timestamp with time zone DEFAULT NULL;
+
+CREATE INDEX license_issuer_deleted_idx ON license_issuer (deleted) WHERE deleted IS NOT NULL;
+CREATE INDEX product_deleted_idx ON product (deleted) WHERE deleted IS NOT NULL;
+CREATE INDEX license_deleted_idx ON license (deleted) WHERE deleted IS NOT NULL;
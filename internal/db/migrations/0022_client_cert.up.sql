@@ -0,0 +1,25 @@
+CREATE TABLE client_cert
+(
+    id          serial                   NOT NULL,
+    fingerprint character(64)            DEFAULT NULL,
+    san         character varying(255)   DEFAULT NULL,
+    note        text                     NOT NULL DEFAULT '',
+    created     timestamp with time zone NOT NULL DEFAULT NOW(),
+    issuer_id   integer                  NOT NULL,
+
+    CONSTRAINT client_cert_pkey           PRIMARY KEY (id),
+    CONSTRAINT client_cert_identity_check CHECK ((fingerprint IS NULL) <> (san IS NULL)),
+    CONSTRAINT client_cert_issuer_id_fkey FOREIGN KEY (issuer_id)
+        REFERENCES license_issuer (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+-- Certificate identifies a single issuer.
+CREATE UNIQUE INDEX client_cert_fingerprint_idx ON client_cert (fingerprint)
+    WHERE fingerprint IS NOT NULL;
+CREATE UNIQUE INDEX client_cert_san_idx ON client_cert (san)
+    WHERE san IS NOT NULL;
+
+CREATE INDEX client_cert_issuer_id_idx ON client_cert (issuer_id);
@@ -0,0 +1,15 @@
+CREATE TABLE usage_report
+(
+    id         bytea                    NOT NULL,
+    license_id bytea                    NOT NULL,
+    created    timestamp with time zone NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT usage_report_pkey            PRIMARY KEY (license_id, id),
+    CONSTRAINT usage_report_license_id_fkey FOREIGN KEY (license_id)
+        REFERENCES license (id) MATCH SIMPLE
+        ON UPDATE RESTRICT
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE INDEX usage_report_created_idx ON usage_report (created);
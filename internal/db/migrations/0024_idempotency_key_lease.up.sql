@@ -0,0 +1,3 @@
+ALTER TABLE idempotency_key
+    ADD COLUMN locked_until timestamp with time zone DEFAULT NULL,
+    ADD COLUMN header       bytea                    DEFAULT NULL;
@@ -0,0 +1,392 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const (
+	notificationTable         = "notification"
+	notificationTemplateTable = "notification_template"
+	notificationOptOutTable   = "notification_opt_out"
+)
+
+var notificationList = &listSpec{
+	id: sortKey{
+		column: "id",
+		value:  func(item interface{}) interface{} { return item.(*model.Notification).ID },
+		decode: decodeInt64,
+	},
+	sortKeys: map[string]sortKey{
+		"id": {
+			column: "id",
+			value:  func(item interface{}) interface{} { return item.(*model.Notification).ID },
+			decode: decodeInt64,
+		},
+		"created": {
+			column: "created",
+			value:  func(item interface{}) interface{} { return item.(*model.Notification).Created },
+			decode: decodeTime,
+		},
+		"nextAttempt": {
+			column: "next_attempt",
+			value:  func(item interface{}) interface{} { return item.(*model.Notification).NextAttempt },
+			decode: decodeTime,
+		},
+	},
+	defaultSort:  "id",
+	defaultOrder: []string{"id DESC"},
+	search:       "recipient || ' ' || subject",
+}
+
+// InsertNotification queues notification for sending. Notification with the
+// same dedup key and recipient is queued only once.
+//
+// Reports whether notification has been queued.
+func (h *Handler) InsertNotification(ctx context.Context, n *model.Notification) (int64, bool, error) {
+	const (
+		action = "Insert"
+		scope  = notificationTable
+	)
+	defer observeQuery(scope, action, time.Now())
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"kind":         n.Kind,
+			"recipient":    n.Recipient,
+			"subject":      n.Subject,
+			"body":         n.Body,
+			"dedup_key":    n.DedupKey,
+			"next_attempt": n.NextAttempt,
+			"created":      n.Created,
+			"issuer_id":    n.IssuerID,
+			"license_id":   n.LicenseID,
+		}).
+		Suffix("ON CONFLICT (dedup_key, recipient) DO NOTHING").
+		Suffix("RETURNING id")
+
+	var id int64
+	err := sq.QueryRowContext(ctx).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, &Error{err: err, Scope: scope, Action: action}
+	}
+	return id, true, nil
+}
+
+// SelectNotificationsByIssuerID selects a page of issuer's notifications.
+func (h *Handler) SelectNotificationsByIssuerID(ctx context.Context, licenseIssuerID int, opts *model.ListOptions) ([]*model.Notification, *model.Page, error) {
+	const (
+		scope  = notificationTable
+		action = "SelectByIssuerID"
+	)
+	where := squirrel.And{
+		squirrel.Eq{"issuer_id": licenseIssuerID},
+	}
+	q, err := notificationList.query(where, opts)
+	if err != nil {
+		return nil, nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	nn, err := h.selectNotifications(ctx, action, q.decorate)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, n, err := h.listPage(ctx, scope, action, q, len(nn), func(i int) interface{} { return nn[i] })
+	if err != nil {
+		return nil, nil, err
+	}
+	return nn[:n], page, nil
+}
+
+// SelectNotificationsPending selects at most limit unsent notifications due
+// by now, which have been attempted less than maxAttempts times.
+func (h *Handler) SelectNotificationsPending(ctx context.Context, now time.Time, maxAttempts, limit int) ([]*model.Notification, error) {
+	return h.selectNotifications(ctx, "SelectPending",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.And{
+				squirrel.Eq{"sent": nil},
+				squirrel.LtOrEq{"next_attempt": now},
+				squirrel.Lt{"attempts": maxAttempts},
+			}).OrderBy("next_attempt", "id").Limit(uint64(limit))
+		})
+}
+
+func (h *Handler) selectNotifications(ctx context.Context, action string, d selectDecorator) ([]*model.Notification, error) {
+	const scope = notificationTable
+
+	sq := h.sq.Select(
+		"id",
+		"kind",
+		"recipient",
+		"subject",
+		"body",
+		"dedup_key",
+		"attempts",
+		"next_attempt",
+		"last_error",
+		"sent",
+		"created",
+		"issuer_id",
+		"license_id",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var nn []*model.Notification
+	for rows.Next() {
+		n := &model.Notification{}
+		err = rows.Scan(
+			&n.ID,
+			&n.Kind,
+			&n.Recipient,
+			&n.Subject,
+			&n.Body,
+			&n.DedupKey,
+			&n.Attempts,
+			&n.NextAttempt,
+			&n.LastError,
+			&n.Sent,
+			&n.Created,
+			&n.IssuerID,
+			&n.LicenseID,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		nn = append(nn, n)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return nn, nil
+}
+
+func (h *Handler) UpdateNotification(ctx context.Context, notificationID int64, update map[string]interface{}) error {
+	const (
+		action = "Update"
+		scope  = notificationTable
+	)
+	sq := h.sq.Update(scope).
+		SetMap(update).
+		Where(squirrel.Eq{
+			"id": notificationID,
+		})
+	return h.execUpdate(ctx, sq, scope, action)
+}
+
+// DeleteNotificationsCreatedBefore deletes sent and abandoned notifications
+// created before t.
+func (h *Handler) DeleteNotificationsCreatedBefore(ctx context.Context, t time.Time) (int, error) {
+	sq := h.sq.Delete(notificationTable).
+		Where(squirrel.Lt{
+			"created": t,
+		})
+	return h.execDelete(ctx, sq, notificationTable, "DeleteCreatedBefore")
+}
+
+// UpsertNotificationTemplate inserts issuer's template of the kind or
+// replaces an existing one.
+func (h *Handler) UpsertNotificationTemplate(ctx context.Context, nt *model.NotificationTemplate) error {
+	const (
+		action = "Upsert"
+		scope  = notificationTemplateTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"issuer_id": nt.IssuerID,
+			"kind":      nt.Kind,
+			"subject":   nt.Subject,
+			"body":      nt.Body,
+			"updated":   nt.Updated,
+		}).
+		Suffix("ON CONFLICT (issuer_id, kind) DO UPDATE SET subject = EXCLUDED.subject, body = EXCLUDED.body, updated = EXCLUDED.updated")
+	return h.execInsertMany(ctx, sq, scope, action)
+}
+
+func (h *Handler) SelectNotificationTemplatesByIssuerID(ctx context.Context, licenseIssuerID int) ([]*model.NotificationTemplate, error) {
+	return h.selectNotificationTemplates(ctx, "SelectByIssuerID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"issuer_id": licenseIssuerID,
+			}).OrderBy("kind")
+		})
+}
+
+func (h *Handler) SelectNotificationTemplate(ctx context.Context, licenseIssuerID int, kind string) (*model.NotificationTemplate, error) {
+	const action = "Select"
+	tt, err := h.selectNotificationTemplates(ctx, action,
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"issuer_id": licenseIssuerID,
+				"kind":      kind,
+			})
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(tt) == 0 {
+		return nil, &Error{err: ErrNotFound, Scope: notificationTemplateTable, Action: action}
+	}
+	return tt[0], nil
+}
+
+func (h *Handler) selectNotificationTemplates(ctx context.Context, action string, d selectDecorator) ([]*model.NotificationTemplate, error) {
+	const scope = notificationTemplateTable
+
+	sq := h.sq.Select(
+		"issuer_id",
+		"kind",
+		"subject",
+		"body",
+		"updated",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var tt []*model.NotificationTemplate
+	for rows.Next() {
+		nt := &model.NotificationTemplate{}
+		err = rows.Scan(
+			&nt.IssuerID,
+			&nt.Kind,
+			&nt.Subject,
+			&nt.Body,
+			&nt.Updated,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		tt = append(tt, nt)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return tt, nil
+}
+
+func (h *Handler) DeleteNotificationTemplate(ctx context.Context, licenseIssuerID int, kind string) (int, error) {
+	sq := h.sq.Delete(notificationTemplateTable).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"kind":      kind,
+		})
+	return h.execDelete(ctx, sq, notificationTemplateTable, "Delete")
+}
+
+// InsertNotificationOptOut opts email out of issuer's notifications, opting
+// out more than once is a no-op.
+func (h *Handler) InsertNotificationOptOut(ctx context.Context, o *model.NotificationOptOut) error {
+	const (
+		action = "Insert"
+		scope  = notificationOptOutTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"issuer_id": o.IssuerID,
+			"email":     o.Email,
+			"created":   o.Created,
+		}).
+		Suffix("ON CONFLICT (issuer_id, email) DO NOTHING")
+	return h.execInsertMany(ctx, sq, scope, action)
+}
+
+func (h *Handler) SelectNotificationOptOutsByIssuerID(ctx context.Context, licenseIssuerID int) ([]*model.NotificationOptOut, error) {
+	const (
+		action = "SelectByIssuerID"
+		scope  = notificationOptOutTable
+	)
+	rows, err := h.sq.Select(
+		"issuer_id",
+		"email",
+		"created",
+	).From(scope).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+		}).
+		OrderBy("email").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var oo []*model.NotificationOptOut
+	for rows.Next() {
+		o := &model.NotificationOptOut{}
+		err = rows.Scan(&o.IssuerID, &o.Email, &o.Created)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		oo = append(oo, o)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return oo, nil
+}
+
+// SelectNotificationOptedOut selects emails, which have opted out of
+// issuer's notifications.
+func (h *Handler) SelectNotificationOptedOut(ctx context.Context, licenseIssuerID int, emails []string) ([]string, error) {
+	const (
+		action = "SelectOptedOut"
+		scope  = notificationOptOutTable
+	)
+	rows, err := h.sq.Select("email").
+		From(scope).
+		Where(squirrel.And{
+			squirrel.Eq{"issuer_id": licenseIssuerID},
+			squirrel.Expr("email = ANY(?)", pq.Array(emails)),
+		}).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var optedOut []string
+	for rows.Next() {
+		var email string
+		err = rows.Scan(&email)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		optedOut = append(optedOut, email)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return optedOut, nil
+}
+
+func (h *Handler) DeleteNotificationOptOut(ctx context.Context, licenseIssuerID int, email string) (int, error) {
+	sq := h.sq.Delete(notificationOptOutTable).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"email":     email,
+		})
+	return h.execDelete(ctx, sq, notificationOptOutTable, "Delete")
+}
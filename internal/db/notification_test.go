@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertNotification(t *testing.T) {
+	const query = "INSERT INTO notification (body,created,dedup_key,issuer_id,kind,license_id,next_attempt,recipient,subject) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) ON CONFLICT (dedup_key, recipient) DO NOTHING RETURNING id"
+
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	now := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+	dedupKey := "expiry-reminder:ID:7:1641340800"
+	n := &model.Notification{
+		Kind:        "expiry-reminder",
+		Recipient:   "user@example.com",
+		Subject:     "Subject",
+		Body:        "Body",
+		DedupKey:    &dedupKey,
+		NextAttempt: now,
+		Created:     now,
+		IssuerID:    3,
+		LicenseID:   base64Key("sswRe+P3j0nKqTcCLJ+cPk/8VyjrJzNyxcHCUoXYDFo="),
+	}
+	args := []driver.Value{n.Body, n.Created, n.DedupKey, n.IssuerID, n.Kind, n.LicenseID, n.NextAttempt, n.Recipient, n.Subject}
+
+	mock.ExpectQuery(query).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(12)))
+	id, queued, err := h.InsertNotification(context.Background(), n)
+	assert.NoError(t, err)
+	assert.True(t, queued)
+	assert.Equal(t, int64(12), id)
+
+	mock.ExpectQuery(query).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, queued, err = h.InsertNotification(context.Background(), n)
+	assert.NoError(t, err)
+	assert.False(t, queued)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectNotificationsPending(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	now := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+	expected := []*model.Notification{
+		{
+			ID:          4,
+			Kind:        "overuse",
+			Recipient:   "user@example.com",
+			Subject:     "Subject",
+			Body:        "Body",
+			Attempts:    1,
+			NextAttempt: now.Add(-time.Minute),
+			LastError:   "connection refused",
+			Created:     now.Add(-time.Hour),
+			IssuerID:    3,
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "kind", "recipient", "subject", "body", "dedup_key", "attempts", "next_attempt", "last_error", "sent", "created", "issuer_id", "license_id"})
+	for _, n := range expected {
+		rows.AddRow(n.ID, n.Kind, n.Recipient, n.Subject, n.Body, n.DedupKey, n.Attempts, n.NextAttempt, n.LastError, n.Sent, n.Created, n.IssuerID, n.LicenseID)
+	}
+	mock.ExpectQuery("SELECT id, kind, recipient, subject, body, dedup_key, attempts, next_attempt, last_error, sent, created, issuer_id, license_id FROM notification WHERE (sent IS NULL AND next_attempt <= $1 AND attempts < $2) ORDER BY next_attempt, id LIMIT 100").
+		WithArgs(now, 8).
+		WillReturnRows(rows)
+
+	got, err := h.SelectNotificationsPending(context.Background(), now, 8, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectNotificationOptedOut(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	emails := []string{"user@example.com", "other@example.com"}
+	mock.ExpectQuery("SELECT email FROM notification_opt_out WHERE (issuer_id = $1 AND email = ANY($2))").
+		WithArgs(3, pq.Array(emails)).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("other@example.com"))
+
+	got, err := h.SelectNotificationOptedOut(context.Background(), 3, emails)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other@example.com"}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/sewiti/licensing-system/internal/model"
@@ -9,6 +10,44 @@ import (
 
 const productTable = "product"
 
+var productList = &listSpec{
+	id: sortKey{
+		column: "id",
+		value:  func(item interface{}) interface{} { return item.(*model.Product).ID },
+		decode: decodeInt,
+	},
+	sortKeys: map[string]sortKey{
+		"id": {
+			column: "id",
+			value:  func(item interface{}) interface{} { return item.(*model.Product).ID },
+			decode: decodeInt,
+		},
+		"created": {
+			column: "created",
+			value:  func(item interface{}) interface{} { return item.(*model.Product).Created },
+			decode: decodeTime,
+		},
+		"updated": {
+			column: "updated",
+			value:  func(item interface{}) interface{} { return item.(*model.Product).Updated },
+			decode: decodeTime,
+		},
+		"name": {
+			column: "name",
+			value:  func(item interface{}) interface{} { return item.(*model.Product).Name },
+			decode: decodeString,
+		},
+		"active": {
+			column: "active",
+			value:  func(item interface{}) interface{} { return item.(*model.Product).Active },
+			decode: decodeBool,
+		},
+	},
+	defaultSort:  "id",
+	defaultOrder: []string{"active DESC", "id"},
+	search:       "name",
+}
+
 func (h *Handler) InsertProduct(ctx context.Context, p *model.Product) (int, error) {
 	const (
 		action = "Insert"
@@ -23,6 +62,7 @@ func (h *Handler) InsertProduct(ctx context.Context, p *model.Product) (int, err
 			"created":       p.Created,
 			"updated":       p.Updated,
 			"issuer_id":     p.IssuerID,
+			"data_schema":   p.DataSchema,
 		}).Suffix("RETURNING id")
 
 	var id int
@@ -33,15 +73,64 @@ func (h *Handler) SelectAllProductsByIssuerID(ctx context.Context, licenseIssuer
 	return h.selectProducts(ctx, "SelectAllByIssuerID", func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
 		return sq.Where(squirrel.Eq{
 			"issuer_id": licenseIssuerID,
+			"deleted":   nil,
 		}).OrderBy("active DESC", "id")
 	})
 }
 
+// SelectProductsByIssuerID selects a page of issuer's products matching the
+// filter.
+func (h *Handler) SelectProductsByIssuerID(ctx context.Context, licenseIssuerID int, f *model.ProductFilter, opts *model.ListOptions) ([]*model.Product, *model.Page, error) {
+	const (
+		scope  = productTable
+		action = "SelectByIssuerID"
+	)
+	where := squirrel.And{
+		squirrel.Eq{"issuer_id": licenseIssuerID},
+	}
+	if f != nil && f.Deleted {
+		where = append(where, squirrel.NotEq{"deleted": nil})
+	} else {
+		where = append(where, notDeleted)
+	}
+	if f != nil && f.Active != nil {
+		where = append(where, squirrel.Eq{"active": *f.Active})
+	}
+	q, err := productList.query(where, opts)
+	if err != nil {
+		return nil, nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	pp, err := h.selectProducts(ctx, action, q.decorate)
+	if err != nil {
+		return nil, nil, err
+	}
+	page, n, err := h.listPage(ctx, scope, action, q, len(pp), func(i int) interface{} { return pp[i] })
+	if err != nil {
+		return nil, nil, err
+	}
+	return pp[:n], page, nil
+}
+
 func (h *Handler) SelectProductByID(ctx context.Context, productID int) (*model.Product, error) {
 	return h.selectProduct(ctx, "SelectByID",
 		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
 			return sq.Where(squirrel.Eq{
-				"id": productID,
+				"id":      productID,
+				"deleted": nil,
+			})
+		})
+}
+
+// SelectDeletedProductByID selects soft deleted product of the issuer.
+func (h *Handler) SelectDeletedProductByID(ctx context.Context, productID, licenseIssuerID int) (*model.Product, error) {
+	return h.selectProduct(ctx, "SelectDeletedByID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.And{
+				squirrel.Eq{
+					"id":        productID,
+					"issuer_id": licenseIssuerID,
+				},
+				squirrel.NotEq{"deleted": nil},
 			})
 		})
 }
@@ -69,6 +158,8 @@ func (h *Handler) selectProducts(ctx context.Context, action string, d selectDec
 		"created",
 		"updated",
 		"issuer_id",
+		"data_schema",
+		"deleted",
 	).From(scope)
 
 	rows, err := d(sq).QueryContext(ctx)
@@ -89,6 +180,8 @@ func (h *Handler) selectProducts(ctx context.Context, action string, d selectDec
 			&p.Created,
 			&p.Updated,
 			&p.IssuerID,
+			&p.DataSchema,
+			&p.Deleted,
 		)
 		if err != nil {
 			return nil, &Error{err: err, Scope: scope, Action: action}
@@ -103,7 +196,9 @@ func (h *Handler) selectProducts(ctx context.Context, action string, d selectDec
 	return pp, nil
 }
 
-func (h *Handler) UpdateProduct(ctx context.Context, productID int, update map[string]interface{}) error {
+// UpdateProduct updates product. If ifMatch isn't nil, product is updated only
+// if its updated timestamp is one of ifMatch.
+func (h *Handler) UpdateProduct(ctx context.Context, productID int, update map[string]interface{}, ifMatch []time.Time) error {
 	const (
 		action = "Update"
 		scope  = productTable
@@ -111,17 +206,72 @@ func (h *Handler) UpdateProduct(ctx context.Context, productID int, update map[s
 	sq := h.sq.Update(scope).
 		SetMap(update).
 		Where(squirrel.Eq{
-			"id": productID,
+			"id":      productID,
+			"deleted": nil,
 		})
-	return h.execUpdate(ctx, sq, scope, action)
+	return h.execUpdateIfMatch(ctx, sq, scope, action, ifMatch)
 }
 
-func (h *Handler) DeleteProductByID(ctx context.Context, productID, licenseIssuerID int) (int, error) {
+// SoftDeleteProductByID marks product deleted at the time. If ifMatch isn't
+// nil, product is deleted only if its updated timestamp is one of ifMatch.
+func (h *Handler) SoftDeleteProductByID(ctx context.Context, productID, licenseIssuerID int, deleted time.Time, ifMatch []time.Time) (int, error) {
 	const scope = productTable
-	sq := h.sq.Delete(scope).
+	sq := h.sq.Update(scope).
+		Set("deleted", deleted).
 		Where(squirrel.Eq{
 			"id":        productID,
 			"issuer_id": licenseIssuerID,
+			"deleted":   nil,
+		})
+	return h.execSoftDelete(ctx, sq, scope, "SoftDeleteByID", ifMatch)
+}
+
+// SoftDeleteProductsByIssuerID marks issuer's products deleted at the time.
+func (h *Handler) SoftDeleteProductsByIssuerID(ctx context.Context, licenseIssuerID int, deleted time.Time) (int, error) {
+	const scope = productTable
+	sq := h.sq.Update(scope).
+		Set("deleted", deleted).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"deleted":   nil,
+		})
+	return h.execUpdateCount(ctx, sq, scope, "SoftDeleteByIssuerID")
+}
+
+// RestoreProductByID restores soft deleted product.
+func (h *Handler) RestoreProductByID(ctx context.Context, productID, licenseIssuerID int) (int, error) {
+	const scope = productTable
+	sq := h.sq.Update(scope).
+		Set("deleted", nil).
+		Where(squirrel.And{
+			squirrel.Eq{
+				"id":        productID,
+				"issuer_id": licenseIssuerID,
+			},
+			squirrel.NotEq{"deleted": nil},
+		})
+	return h.execSoftDelete(ctx, sq, scope, "RestoreByID", nil)
+}
+
+// RestoreProductsByIssuerID restores issuer's products deleted at the time,
+// i.e., together with the issuer.
+func (h *Handler) RestoreProductsByIssuerID(ctx context.Context, licenseIssuerID int, deleted time.Time) (int, error) {
+	const scope = productTable
+	sq := h.sq.Update(scope).
+		Set("deleted", nil).
+		Where(squirrel.Eq{
+			"issuer_id": licenseIssuerID,
+			"deleted":   deleted,
+		})
+	return h.execUpdateCount(ctx, sq, scope, "RestoreByIssuerID")
+}
+
+// PurgeProductsDeletedBefore permanently deletes products soft deleted before
+// t.
+func (h *Handler) PurgeProductsDeletedBefore(ctx context.Context, t time.Time) (int, error) {
+	sq := h.sq.Delete(productTable).
+		Where(squirrel.Lt{
+			"deleted": t,
 		})
-	return h.execDelete(ctx, sq, scope, "DeleteByID")
+	return h.execDelete(ctx, sq, productTable, "PurgeDeletedBefore")
 }
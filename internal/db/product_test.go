@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -27,12 +28,13 @@ func TestHandler_InsertProduct(t *testing.T) {
 		Updated:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
 	}
 
-	mock.ExpectQuery("INSERT INTO product (active,contact_email,created,data,issuer_id,name,updated) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id").
+	mock.ExpectQuery("INSERT INTO product (active,contact_email,created,data,data_schema,issuer_id,name,updated) VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id").
 		WithArgs(
 			p.Active,
 			p.ContactEmail,
 			p.Created,
 			p.Data,
+			p.DataSchema,
 			p.IssuerID,
 			p.Name,
 			p.Updated,
@@ -81,6 +83,8 @@ func TestHandler_SelectAllProductsByIssuerID(t *testing.T) {
 		"created",
 		"updated",
 		"issuer_id",
+		"data_schema",
+		"deleted",
 	})
 	for _, v := range expected {
 		rows.AddRow(
@@ -92,10 +96,12 @@ func TestHandler_SelectAllProductsByIssuerID(t *testing.T) {
 			v.Created,
 			v.Updated,
 			v.IssuerID,
+			v.DataSchema,
+			v.Deleted,
 		)
 	}
 
-	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id FROM product WHERE issuer_id = $1 ORDER BY active DESC, id").
+	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id, data_schema, deleted FROM product WHERE deleted IS NULL AND issuer_id = $1 ORDER BY active DESC, id").
 		WillReturnRows(rows)
 
 	got, err := h.SelectAllProductsByIssuerID(context.Background(), issuerID)
@@ -128,6 +134,8 @@ func TestHandler_SelectProductByID(t *testing.T) {
 		"created",
 		"updated",
 		"issuer_id",
+		"data_schema",
+		"deleted",
 	}).AddRow(
 		expected.ID,
 		expected.Active,
@@ -137,9 +145,11 @@ func TestHandler_SelectProductByID(t *testing.T) {
 		expected.Created,
 		expected.Updated,
 		expected.IssuerID,
+		expected.DataSchema,
+		expected.Deleted,
 	)
 
-	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id FROM product WHERE id = $1").
+	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id, data_schema, deleted FROM product WHERE deleted IS NULL AND id = $1").
 		WithArgs(expected.ID).
 		WillReturnRows(rows)
 
@@ -162,7 +172,7 @@ func TestHandler_UpdateProduct(t *testing.T) {
 		"updated":       time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
 	}
 
-	mock.ExpectExec("UPDATE product SET active = $1, contact_email = $2, created = $3, name = $4, updated = $5 WHERE id = $6").
+	mock.ExpectExec("UPDATE product SET active = $1, contact_email = $2, created = $3, name = $4, updated = $5 WHERE deleted IS NULL AND id = $6").
 		WithArgs(
 			update["active"],
 			update["contact_email"],
@@ -173,11 +183,11 @@ func TestHandler_UpdateProduct(t *testing.T) {
 		).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = h.UpdateProduct(context.Background(), productID, update)
+	err = h.UpdateProduct(context.Background(), productID, update, nil)
 	assert.NoError(t, err)
 }
 
-func TestHandler_DeleteProductByID(t *testing.T) {
+func TestHandler_SoftDeleteProductByID(t *testing.T) {
 	h, mock, err := newMock()
 	require.NoError(t, err)
 	defer h.Close()
@@ -187,12 +197,137 @@ func TestHandler_DeleteProductByID(t *testing.T) {
 		licenseIssuerID = 3
 		productID       = 69
 	)
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	mock.ExpectExec("DELETE FROM product WHERE id = $1 AND issuer_id = $2").
-		WithArgs(productID, licenseIssuerID).
+	mock.ExpectExec("UPDATE product SET deleted = $1 WHERE deleted IS NULL AND id = $2 AND issuer_id = $3").
+		WithArgs(now, productID, licenseIssuerID).
 		WillReturnResult(sqlmock.NewResult(0, deleted))
 
-	got, err := h.DeleteProductByID(context.Background(), productID, licenseIssuerID)
+	got, err := h.SoftDeleteProductByID(context.Background(), productID, licenseIssuerID, now, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, deleted, got)
 }
+
+func TestHandler_RestoreLicensesByProductID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	const productID = 69
+	deleted := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("UPDATE license SET deleted = $1 WHERE deleted = $2 AND product_id = $3").
+		WithArgs(nil, deleted, productID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	got, err := h.RestoreLicensesByProductID(context.Background(), productID, deleted)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got)
+}
+
+func TestHandler_SelectProductsByIssuerID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	issuerID := 5
+	active := true
+	products := []*model.Product{
+		{ID: 1, Active: true, Name: "first", Created: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), Updated: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), IssuerID: issuerID},
+		{ID: 2, Active: true, Name: "second", Created: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC), Updated: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC), IssuerID: issuerID},
+		{ID: 3, Active: true, Name: "third", Created: time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), Updated: time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), IssuerID: issuerID},
+	}
+	newRows := func(pp []*model.Product) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "active", "name", "contact_email", "data", "created", "updated", "issuer_id", "data_schema", "deleted"})
+		for _, v := range pp {
+			rows.AddRow(v.ID, v.Active, v.Name, v.ContactEmail, v.Data, v.Created, v.Updated, v.IssuerID, v.DataSchema, v.Deleted)
+		}
+		return rows
+	}
+	filter := &model.ProductFilter{Active: &active}
+
+	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id, data_schema, deleted FROM product WHERE (issuer_id = $1 AND deleted IS NULL AND active = $2 AND to_tsvector('simple', name) @@ to_tsquery('simple', $3)) ORDER BY created ASC, id ASC LIMIT 3").
+		WithArgs(issuerID, true, "th:* & s:*").
+		WillReturnRows(newRows(products[:3]))
+	mock.ExpectQuery("SELECT COUNT(*) FROM product WHERE (issuer_id = $1 AND deleted IS NULL AND active = $2 AND to_tsvector('simple', name) @@ to_tsquery('simple', $3))").
+		WithArgs(issuerID, true, "th:* & s:*").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	opts := &model.ListOptions{Limit: 2, Sort: "created", Search: "th | s"}
+	got, page, err := h.SelectProductsByIssuerID(context.Background(), issuerID, filter, opts)
+	require.NoError(t, err)
+	assert.Equal(t, products[:2], got)
+	assert.Equal(t, 3, page.Total)
+	require.NotEmpty(t, page.Next)
+
+	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id, data_schema, deleted FROM product WHERE (issuer_id = $1 AND deleted IS NULL AND active = $2 AND to_tsvector('simple', name) @@ to_tsquery('simple', $3)) AND (created, id) > ($4, $5) ORDER BY created ASC, id ASC LIMIT 3").
+		WithArgs(issuerID, true, "th:* & s:*", products[1].Created, products[1].ID).
+		WillReturnRows(newRows(products[2:]))
+	mock.ExpectQuery("SELECT COUNT(*) FROM product WHERE (issuer_id = $1 AND deleted IS NULL AND active = $2 AND to_tsvector('simple', name) @@ to_tsquery('simple', $3))").
+		WithArgs(issuerID, true, "th:* & s:*").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	opts.Cursor = page.Next
+	got, page, err = h.SelectProductsByIssuerID(context.Background(), issuerID, filter, opts)
+	require.NoError(t, err)
+	assert.Equal(t, products[2:], got)
+	assert.Equal(t, 3, page.Total)
+	assert.Empty(t, page.Next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	opts.Sort = "name" // cursor belongs to a different sort
+	_, _, err = h.SelectProductsByIssuerID(context.Background(), issuerID, filter, opts)
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	opts.Sort, opts.Cursor = "contactEmail", ""
+	_, _, err = h.SelectProductsByIssuerID(context.Background(), issuerID, filter, opts)
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestHandler_SelectProductsByIssuerID_deleted(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	issuerID := 5
+	deleted := time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC)
+	expected := []*model.Product{
+		{ID: 1, Name: "first", Created: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), Updated: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), IssuerID: issuerID, Deleted: &deleted},
+	}
+	rows := sqlmock.NewRows([]string{"id", "active", "name", "contact_email", "data", "created", "updated", "issuer_id", "data_schema", "deleted"})
+	for _, v := range expected {
+		rows.AddRow(v.ID, v.Active, v.Name, v.ContactEmail, v.Data, v.Created, v.Updated, v.IssuerID, v.DataSchema, v.Deleted)
+	}
+
+	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id, data_schema, deleted FROM product WHERE (issuer_id = $1 AND deleted IS NOT NULL) ORDER BY active DESC, id").
+		WithArgs(issuerID).
+		WillReturnRows(rows)
+
+	got, _, err := h.SelectProductsByIssuerID(context.Background(), issuerID, &model.ProductFilter{Deleted: true}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandler_SelectProductsByIssuerID_unpaginated(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	issuerID := 5
+	rows := sqlmock.NewRows([]string{"id", "active", "name", "contact_email", "data", "created", "updated", "issuer_id", "data_schema", "deleted"})
+	for i := 1; i <= 250; i++ {
+		rows.AddRow(i, true, fmt.Sprintf("product %d", i), "", []byte(nil), time.Time{}, time.Time{}, issuerID, []byte(nil), nil)
+	}
+
+	mock.ExpectQuery("SELECT id, active, name, contact_email, data, created, updated, issuer_id, data_schema, deleted FROM product WHERE (issuer_id = $1 AND deleted IS NULL) ORDER BY active DESC, id").
+		WithArgs(issuerID).
+		WillReturnRows(rows)
+
+	got, page, err := h.SelectProductsByIssuerID(context.Background(), issuerID, &model.ProductFilter{}, &model.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, got, 250)
+	assert.Equal(t, 250, page.Total)
+	assert.Empty(t, page.Next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
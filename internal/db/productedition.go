@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const productEditionTable = "product_edition"
+
+func (h *Handler) InsertProductEdition(ctx context.Context, e *model.ProductEdition) (int, error) {
+	const (
+		action = "Insert"
+		scope  = productEditionTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"name":       e.Name,
+			"features":   pq.Array(e.Features),
+			"channels":   pq.Array(e.Channels),
+			"data":       e.Data,
+			"created":    e.Created,
+			"updated":    e.Updated,
+			"product_id": e.ProductID,
+		}).Suffix("RETURNING id")
+
+	var id int
+	return id, h.execInsert(ctx, sq, scope, action, &id)
+}
+
+func (h *Handler) SelectAllProductEditionsByProductID(ctx context.Context, productID int) ([]*model.ProductEdition, error) {
+	return h.selectProductEditions(ctx, "SelectAllByProductID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"product_id": productID,
+			}).OrderBy("name", "id")
+		})
+}
+
+func (h *Handler) SelectProductEditionByID(ctx context.Context, productEditionID int) (*model.ProductEdition, error) {
+	return h.selectProductEdition(ctx, "SelectByID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id": productEditionID,
+			})
+		})
+}
+
+func (h *Handler) selectProductEdition(ctx context.Context, action string, d selectDecorator) (*model.ProductEdition, error) {
+	ee, err := h.selectProductEditions(ctx, action, d)
+	if err != nil {
+		return nil, err
+	}
+	if len(ee) == 0 {
+		return nil, &Error{err: ErrNotFound, Scope: productEditionTable, Action: action}
+	}
+	return ee[0], nil
+}
+
+func (h *Handler) selectProductEditions(ctx context.Context, action string, d selectDecorator) ([]*model.ProductEdition, error) {
+	const scope = productEditionTable
+
+	sq := h.sq.Select(
+		"id",
+		"name",
+		"features",
+		"channels",
+		"data",
+		"created",
+		"updated",
+		"product_id",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var ee []*model.ProductEdition
+	for rows.Next() {
+		e := &model.ProductEdition{}
+		err = rows.Scan(
+			&e.ID,
+			&e.Name,
+			pq.Array(&e.Features),
+			pq.Array(&e.Channels),
+			&e.Data,
+			&e.Created,
+			&e.Updated,
+			&e.ProductID,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		ee = append(ee, e)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return ee, nil
+}
+
+func (h *Handler) UpdateProductEdition(ctx context.Context, productEditionID, productID int, update map[string]interface{}) error {
+	const (
+		action = "Update"
+		scope  = productEditionTable
+	)
+	sq := h.sq.Update(scope).
+		SetMap(update).
+		Where(squirrel.Eq{
+			"id":         productEditionID,
+			"product_id": productID,
+		})
+	return h.execUpdate(ctx, sq, scope, action)
+}
+
+func (h *Handler) DeleteProductEditionByID(ctx context.Context, productEditionID, productID int) (int, error) {
+	const scope = productEditionTable
+	sq := h.sq.Delete(scope).
+		Where(squirrel.Eq{
+			"id":         productEditionID,
+			"product_id": productID,
+		})
+	return h.execDelete(ctx, sq, scope, "DeleteByID")
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertProductEdition(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	e := &model.ProductEdition{
+		Name:      "Pro",
+		Features:  []string{"pro", "export"},
+		Channels:  []string{"stable", "beta"},
+		Data:      []byte(`{"extraJsonData":true}`),
+		Created:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Updated:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		ProductID: 5,
+	}
+
+	mock.ExpectQuery("INSERT INTO product_edition (channels,created,data,features,name,product_id,updated) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id").
+		WithArgs(
+			pq.Array(e.Channels),
+			e.Created,
+			e.Data,
+			pq.Array(e.Features),
+			e.Name,
+			e.ProductID,
+			e.Updated,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	id, err := h.InsertProductEdition(context.Background(), e)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+}
+
+func TestHandler_SelectAllProductEditionsByProductID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	expected := []*model.ProductEdition{
+		{
+			ID:        2,
+			Name:      "Pro",
+			Features:  []string{"pro"},
+			Channels:  []string{"stable", "beta"},
+			Created:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			Updated:   time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+			ProductID: 5,
+		},
+		{
+			ID:        1,
+			Name:      "Standard",
+			Features:  []string{},
+			Channels:  []string{"stable"},
+			Data:      []byte(`{"seats":1}`),
+			Created:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			Updated:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			ProductID: 5,
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "name", "features", "channels", "data", "created", "updated", "product_id"})
+	for _, e := range expected {
+		rows.AddRow(e.ID, e.Name, pq.Array(e.Features), pq.Array(e.Channels), e.Data, e.Created, e.Updated, e.ProductID)
+	}
+
+	mock.ExpectQuery("SELECT id, name, features, channels, data, created, updated, product_id FROM product_edition WHERE product_id = $1 ORDER BY name, id").
+		WithArgs(5).
+		WillReturnRows(rows)
+
+	got, err := h.SelectAllProductEditionsByProductID(context.Background(), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestHandler_DeleteProductEditionByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mock.ExpectExec("DELETE FROM product_edition WHERE id = $1 AND product_id = $2").
+		WithArgs(2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = h.DeleteProductEditionByID(context.Background(), 2, 5)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
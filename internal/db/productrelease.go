@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const productReleaseTable = "product_release"
+
+func (h *Handler) InsertProductRelease(ctx context.Context, pr *model.ProductRelease) (int, error) {
+	const (
+		action = "Insert"
+		scope  = productReleaseTable
+	)
+	sq := h.sq.Insert(scope).
+		SetMap(map[string]interface{}{
+			"version":         pr.Version,
+			"channel":         pr.Channel,
+			"notes":           pr.Notes,
+			"edition_ids":     pq.Array(pr.EditionIDs),
+			"min_app_version": pr.MinAppVersion,
+			"artifacts":       pr.Artifacts,
+			"released":        pr.Released,
+			"created":         pr.Created,
+			"updated":         pr.Updated,
+			"product_id":      pr.ProductID,
+		}).Suffix("RETURNING id")
+
+	var id int
+	return id, h.execInsert(ctx, sq, scope, action, &id)
+}
+
+// SelectAllProductReleasesByProductID selects product's releases, latest
+// first.
+func (h *Handler) SelectAllProductReleasesByProductID(ctx context.Context, productID int) ([]*model.ProductRelease, error) {
+	return h.selectProductReleases(ctx, "SelectAllByProductID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"product_id": productID,
+			}).OrderBy("released DESC", "id DESC")
+		})
+}
+
+func (h *Handler) SelectProductReleaseByID(ctx context.Context, productReleaseID int) (*model.ProductRelease, error) {
+	return h.selectProductRelease(ctx, "SelectByID",
+		func(sq squirrel.SelectBuilder) squirrel.SelectBuilder {
+			return sq.Where(squirrel.Eq{
+				"id": productReleaseID,
+			})
+		})
+}
+
+func (h *Handler) selectProductRelease(ctx context.Context, action string, d selectDecorator) (*model.ProductRelease, error) {
+	rr, err := h.selectProductReleases(ctx, action, d)
+	if err != nil {
+		return nil, err
+	}
+	if len(rr) == 0 {
+		return nil, &Error{err: ErrNotFound, Scope: productReleaseTable, Action: action}
+	}
+	return rr[0], nil
+}
+
+func (h *Handler) selectProductReleases(ctx context.Context, action string, d selectDecorator) ([]*model.ProductRelease, error) {
+	const scope = productReleaseTable
+
+	sq := h.sq.Select(
+		"id",
+		"version",
+		"channel",
+		"notes",
+		"edition_ids",
+		"min_app_version",
+		"artifacts",
+		"released",
+		"created",
+		"updated",
+		"product_id",
+	).From(scope)
+
+	rows, err := d(sq).QueryContext(ctx)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	defer rows.Close()
+
+	var rr []*model.ProductRelease
+	for rows.Next() {
+		pr := &model.ProductRelease{}
+		err = rows.Scan(
+			&pr.ID,
+			&pr.Version,
+			&pr.Channel,
+			&pr.Notes,
+			pq.Array(&pr.EditionIDs),
+			&pr.MinAppVersion,
+			&pr.Artifacts,
+			&pr.Released,
+			&pr.Created,
+			&pr.Updated,
+			&pr.ProductID,
+		)
+		if err != nil {
+			return nil, &Error{err: err, Scope: scope, Action: action}
+		}
+		rr = append(rr, pr)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return rr, nil
+}
+
+func (h *Handler) UpdateProductRelease(ctx context.Context, productReleaseID, productID int, update map[string]interface{}) error {
+	const (
+		action = "Update"
+		scope  = productReleaseTable
+	)
+	sq := h.sq.Update(scope).
+		SetMap(update).
+		Where(squirrel.Eq{
+			"id":         productReleaseID,
+			"product_id": productID,
+		})
+	return h.execUpdate(ctx, sq, scope, action)
+}
+
+func (h *Handler) DeleteProductReleaseByID(ctx context.Context, productReleaseID, productID int) (int, error) {
+	const scope = productReleaseTable
+	sq := h.sq.Delete(scope).
+		Where(squirrel.Eq{
+			"id":         productReleaseID,
+			"product_id": productID,
+		})
+	return h.execDelete(ctx, sq, scope, "DeleteByID")
+}
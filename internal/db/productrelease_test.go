@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_InsertProductRelease(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	pr := &model.ProductRelease{
+		Version:       "1.2.0",
+		Channel:       "stable",
+		Notes:         "Bug fixes",
+		EditionIDs:    []int64{2},
+		MinAppVersion: "1.0",
+		Artifacts: model.ReleaseArtifacts{
+			{Name: "app.tar.gz", Path: "app/1.2.0/app.tar.gz", Size: 3, SHA256: []byte{1, 2, 3}},
+		},
+		Released:  time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Created:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Updated:   time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		ProductID: 5,
+	}
+
+	mock.ExpectQuery("INSERT INTO product_release (artifacts,channel,created,edition_ids,min_app_version,notes,product_id,released,updated,version) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id").
+		WithArgs(
+			pr.Artifacts,
+			pr.Channel,
+			pr.Created,
+			pq.Array(pr.EditionIDs),
+			pr.MinAppVersion,
+			pr.Notes,
+			pr.ProductID,
+			pr.Released,
+			pr.Updated,
+			pr.Version,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	id, err := h.InsertProductRelease(context.Background(), pr)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+}
+
+func TestHandler_SelectAllProductReleasesByProductID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	expected := []*model.ProductRelease{
+		{
+			ID:         2,
+			Version:    "1.1.0-beta.1",
+			Channel:    "beta",
+			EditionIDs: []int64{},
+			Artifacts: model.ReleaseArtifacts{
+				{Name: "app.tar.gz", Path: "app/1.1.0-beta.1/app.tar.gz", Size: 3, SHA256: []byte{1, 2, 3}},
+			},
+			Released:  time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+			Created:   time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+			Updated:   time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+			ProductID: 5,
+		},
+		{
+			ID:            1,
+			Version:       "1.0.0",
+			Channel:       "stable",
+			Notes:         "Initial release",
+			EditionIDs:    []int64{1, 2},
+			MinAppVersion: "0.9",
+			Artifacts:     model.ReleaseArtifacts{},
+			Released:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			Created:       time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			Updated:       time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			ProductID:     5,
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "version", "channel", "notes", "edition_ids", "min_app_version", "artifacts", "released", "created", "updated", "product_id"})
+	for _, pr := range expected {
+		artifacts, err := pr.Artifacts.Value()
+		require.NoError(t, err)
+		rows.AddRow(pr.ID, pr.Version, pr.Channel, pr.Notes, pq.Array(pr.EditionIDs), pr.MinAppVersion, artifacts, pr.Released, pr.Created, pr.Updated, pr.ProductID)
+	}
+
+	mock.ExpectQuery("SELECT id, version, channel, notes, edition_ids, min_app_version, artifacts, released, created, updated, product_id FROM product_release WHERE product_id = $1 ORDER BY released DESC, id DESC").
+		WithArgs(5).
+		WillReturnRows(rows)
+
+	got, err := h.SelectAllProductReleasesByProductID(context.Background(), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestHandler_DeleteProductReleaseByID(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	mock.ExpectExec("DELETE FROM product_release WHERE id = $1 AND product_id = $2").
+		WithArgs(2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := h.DeleteProductReleaseByID(context.Background(), 2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
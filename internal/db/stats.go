@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// SelectServerStats counts resources, license sessions are counted if they
+// haven't expired by now.
+func (h *Handler) SelectServerStats(ctx context.Context, now time.Time) (*model.ServerStats, error) {
+	const (
+		scope  = "stats"
+		action = "Select"
+	)
+	defer observeQuery(scope, action, time.Now())
+	sq := h.sq.Select(
+		"(SELECT COUNT(*) FROM license_issuer WHERE deleted IS NULL)",
+		"(SELECT COUNT(*) FROM product WHERE deleted IS NULL)",
+		"(SELECT COUNT(*) FROM license WHERE deleted IS NULL)",
+		"(SELECT COUNT(*) FROM license WHERE deleted IS NULL AND active)",
+	).Column("(SELECT COUNT(*) FROM license_session WHERE expire > ?)", now)
+
+	s := &model.ServerStats{}
+	err := sq.QueryRowContext(ctx).Scan(
+		&s.LicenseIssuers,
+		&s.Products,
+		&s.Licenses,
+		&s.ActiveLicenses,
+		&s.ActiveLicenseSessions,
+	)
+	if err != nil {
+		return nil, &Error{err: err, Scope: scope, Action: action}
+	}
+	return s, nil
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_SelectServerStats(t *testing.T) {
+	h, mock, err := newMock()
+	require.NoError(t, err)
+	defer h.Close()
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := &model.ServerStats{
+		LicenseIssuers:        2,
+		Products:              3,
+		Licenses:              10,
+		ActiveLicenses:        8,
+		ActiveLicenseSessions: 5,
+	}
+
+	mock.ExpectQuery("SELECT (SELECT COUNT(*) FROM license_issuer WHERE deleted IS NULL), (SELECT COUNT(*) FROM product WHERE deleted IS NULL), (SELECT COUNT(*) FROM license WHERE deleted IS NULL), (SELECT COUNT(*) FROM license WHERE deleted IS NULL AND active), (SELECT COUNT(*) FROM license_session WHERE expire > $1)").
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"issuers", "products", "licenses", "active", "sessions"}).
+			AddRow(2, 3, 10, 8, 5))
+
+	got, err := h.SelectServerStats(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,14 +3,20 @@ package db
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/lib/pq"
+	"github.com/sewiti/licensing-system/internal/metrics"
 )
 
 type selectDecorator func(sq squirrel.SelectBuilder) squirrel.SelectBuilder
 
+// notDeleted matches rows, which haven't been soft deleted.
+var notDeleted = squirrel.Eq{"deleted": nil}
+
 func (h *Handler) execInsert(ctx context.Context, sq squirrel.InsertBuilder, scope, action string, id interface{}) error {
+	defer observeQuery(scope, action, time.Now())
 	row := sq.QueryRowContext(ctx)
 	err := row.Scan(id)
 	if err != nil {
@@ -26,7 +32,8 @@ func (h *Handler) execInsert(ctx context.Context, sq squirrel.InsertBuilder, sco
 	return nil
 }
 
-func (h *Handler) execUpdate(ctx context.Context, sq squirrel.UpdateBuilder, scope, action string) error {
+func (h *Handler) execInsertMany(ctx context.Context, sq squirrel.InsertBuilder, scope, action string) error {
+	defer observeQuery(scope, action, time.Now())
 	_, err := sq.ExecContext(ctx)
 	if err != nil {
 		pqErr := &pq.Error{}
@@ -41,7 +48,49 @@ func (h *Handler) execUpdate(ctx context.Context, sq squirrel.UpdateBuilder, sco
 	return nil
 }
 
+func (h *Handler) execUpdate(ctx context.Context, sq squirrel.UpdateBuilder, scope, action string) error {
+	_, err := h.execUpdateCount(ctx, sq, scope, action)
+	return err
+}
+
+// execUpdateIfMatch updates row only if its updated timestamp is one of
+// ifMatch, checked atomically. Nil ifMatch updates unconditionally.
+func (h *Handler) execUpdateIfMatch(ctx context.Context, sq squirrel.UpdateBuilder, scope, action string, ifMatch []time.Time) error {
+	if ifMatch == nil {
+		return h.execUpdate(ctx, sq, scope, action)
+	}
+	n, err := h.execUpdateCount(ctx, sq.Where(squirrel.Eq{"updated": ifMatch}), scope, action)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &Error{err: ErrPreconditionFailed, Scope: scope, Action: action}
+	}
+	return nil
+}
+
+func (h *Handler) execUpdateCount(ctx context.Context, sq squirrel.UpdateBuilder, scope, action string) (int, error) {
+	defer observeQuery(scope, action, time.Now())
+	res, err := sq.ExecContext(ctx)
+	if err != nil {
+		pqErr := &pq.Error{}
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return 0, &Error{err: ErrDuplicate, Scope: scope, Action: action}
+			}
+		}
+		return 0, &Error{err: err, Scope: scope, Action: action}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, &Error{err: err, Scope: scope, Action: action}
+	}
+	return int(n), nil
+}
+
 func (h *Handler) execDelete(ctx context.Context, sq squirrel.DeleteBuilder, scope, action string) (int, error) {
+	defer observeQuery(scope, action, time.Now())
 	res, err := sq.ExecContext(ctx)
 	if err != nil {
 		return 0, &Error{err: err, Scope: scope, Action: action}
@@ -55,3 +104,31 @@ func (h *Handler) execDelete(ctx context.Context, sq squirrel.DeleteBuilder, sco
 	}
 	return int(n), nil
 }
+
+// execSoftDelete executes update, which soft deletes or restores a row. If
+// ifMatch isn't nil, row is updated only if its updated timestamp is one of
+// ifMatch.
+//
+// Returns ErrNotFound if nothing was updated, ErrPreconditionFailed if ifMatch
+// is given.
+func (h *Handler) execSoftDelete(ctx context.Context, sq squirrel.UpdateBuilder, scope, action string, ifMatch []time.Time) (int, error) {
+	if ifMatch != nil {
+		sq = sq.Where(squirrel.Eq{"updated": ifMatch})
+	}
+	n, err := h.execUpdateCount(ctx, sq, scope, action)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		if ifMatch != nil {
+			return 0, &Error{err: ErrPreconditionFailed, Scope: scope, Action: action}
+		}
+		return 0, &Error{err: ErrNotFound, Scope: scope, Action: action}
+	}
+	return n, nil
+}
+
+// observeQuery records query latency, should be deferred.
+func observeQuery(scope, action string, start time.Time) {
+	metrics.ObserveDBQuery(scope, action, time.Since(start))
+}
@@ -0,0 +1,87 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+
+	cryptorand "crypto/rand"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted key layout: version || salt || nonce || secretbox.
+const (
+	encryptedVersion = 1
+	saltSize         = 16
+	nonceSize        = 24
+)
+
+// scrypt parameters, recommended for interactive logins as of 2017.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// Encrypt encrypts key with passphrase. Returns keys file line, which can be
+// loaded by Load.
+func Encrypt(key, passphrase []byte) (string, error) {
+	if len(passphrase) == 0 {
+		return "", errors.New("passphrase must not be empty")
+	}
+	header := make([]byte, 1+saltSize+nonceSize)
+	header[0] = encryptedVersion
+	_, err := io.ReadFull(cryptorand.Reader, header[1:])
+	if err != nil {
+		return "", err
+	}
+	salt := header[1 : 1+saltSize]
+	var nonce [nonceSize]byte
+	copy(nonce[:], header[1+saltSize:])
+
+	secret, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	sealed := secretbox.Seal(header, key, &nonce, secret)
+	return prefixEncrypted + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts key encrypted by Encrypt.
+//
+// Returns ErrWrongPassphrase.
+func Decrypt(encrypted, passphrase []byte) ([]byte, error) {
+	if len(encrypted) < 1+saltSize+nonceSize+secretbox.Overhead {
+		return nil, errors.New("encrypted key is too short")
+	}
+	if encrypted[0] != encryptedVersion {
+		return nil, errors.New("unsupported encrypted key version")
+	}
+	salt := encrypted[1 : 1+saltSize]
+	var nonce [nonceSize]byte
+	copy(nonce[:], encrypted[1+saltSize:])
+
+	secret, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := secretbox.Open(nil, encrypted[1+saltSize+nonceSize:], &nonce, secret)
+	if !ok {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+func deriveKey(passphrase, salt []byte) (*[32]byte, error) {
+	bs, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	copy(key[:], bs)
+	return &key, nil
+}
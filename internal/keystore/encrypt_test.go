@@ -0,0 +1,38 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncrypt(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	line, err := Encrypt(key, []byte("passphrase"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, prefixEncrypted))
+
+	encrypted, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, prefixEncrypted))
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), string(key))
+
+	got, err := Decrypt(encrypted, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	_, err = Decrypt(encrypted, []byte("Passphrase"))
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+
+	_, err = Decrypt(encrypted[:10], []byte("passphrase"))
+	assert.Error(t, err)
+
+	other, err := Encrypt(key, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.NotEqual(t, line, other, "salt and nonce must be random")
+
+	_, err = Encrypt(key, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+// Package keystore loads licensing server key from files, systemd credentials
+// or external secret stores.
+package keystore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size of licensing server key.
+const KeySize = 32
+
+// Line prefixes of keys file, as printed by generate-keys.
+const (
+	prefixBase64    = "key:base64:"
+	prefixHex       = "key:hex:"
+	prefixEncrypted = "key:encrypted:"
+)
+
+var ErrPassphraseRequired = errors.New("key is encrypted, passphrase is required")
+
+// Provider provides secret, e.g., licensing server key.
+type Provider interface {
+	Secret(ctx context.Context) ([]byte, error)
+}
+
+// PassphraseFunc returns passphrase of encrypted key. It's only called if key
+// is encrypted.
+type PassphraseFunc func() ([]byte, error)
+
+// Load gets key from provider, decrypting it with passphrase if needed.
+// Passphrase may be nil, in which case encrypted keys are rejected.
+//
+// Secret can be either:
+//   - keys file, as printed by generate-keys,
+//   - base64 or hex encoded key,
+//   - raw key.
+func Load(ctx context.Context, p Provider, passphrase PassphraseFunc) ([]byte, error) {
+	data, err := p.Secret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, encrypted, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted != nil {
+		if passphrase == nil {
+			return nil, ErrPassphraseRequired
+		}
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		key, err = Decrypt(encrypted, pass)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be of length %d", KeySize)
+	}
+	return key, nil
+}
+
+// parse parses secret, returning either plain or encrypted key.
+func parse(data []byte) (key, encrypted []byte, err error) {
+	if len(data) == KeySize {
+		return data, nil, nil
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, prefixEncrypted):
+			encrypted, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(line, prefixEncrypted))
+			if err != nil {
+				return nil, nil, fmt.Errorf("encrypted key: %w", err)
+			}
+			return nil, encrypted, nil
+		case strings.HasPrefix(line, prefixBase64):
+			key, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(line, prefixBase64))
+			return key, nil, err
+		case strings.HasPrefix(line, prefixHex):
+			key, err = hex.DecodeString(strings.TrimPrefix(line, prefixHex))
+			return key, nil, err
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Hex encoded key is valid base64 as well, hence the length check.
+	s := string(bytes.TrimSpace(data))
+	key, err = base64.StdEncoding.DecodeString(s)
+	if err == nil && len(key) == KeySize {
+		return key, nil, nil
+	}
+	key, err = hex.DecodeString(s)
+	if err == nil && len(key) == KeySize {
+		return key, nil, nil
+	}
+	return nil, nil, errors.New("unrecognized key format")
+}
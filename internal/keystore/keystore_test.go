@@ -0,0 +1,92 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticProvider []byte
+
+func (p staticProvider) Secret(ctx context.Context) ([]byte, error) {
+	return p, nil
+}
+
+func TestLoad(t *testing.T) {
+	key := bytes.Repeat([]byte{0xab}, KeySize)
+	b64 := base64.StdEncoding.EncodeToString(key)
+	hx := hex.EncodeToString(key)
+	encrypted, err := Encrypt(key, []byte("secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		data       string
+		passphrase PassphraseFunc
+		wantErr    error
+		wantAnyErr bool
+	}{
+		{
+			name: "raw",
+			data: string(key),
+		},
+		{
+			name: "base64",
+			data: b64 + "\n",
+		},
+		{
+			name: "hex",
+			data: hx,
+		},
+		{
+			name: "keys file",
+			data: "id:hex:00\nid:base64:AA==\nkey:hex:" + hx + "\nkey:base64:" + b64 + "\n",
+		},
+		{
+			name:       "encrypted",
+			data:       "id:base64:AA==\n" + encrypted + "\n",
+			passphrase: func() ([]byte, error) { return []byte("secret"), nil },
+		},
+		{
+			name:    "encrypted without passphrase",
+			data:    encrypted,
+			wantErr: ErrPassphraseRequired,
+		},
+		{
+			name:       "encrypted with wrong passphrase",
+			data:       encrypted,
+			passphrase: func() ([]byte, error) { return []byte("wrong"), nil },
+			wantErr:    ErrWrongPassphrase,
+		},
+		{
+			name:       "short key",
+			data:       "key:base64:AAAA",
+			wantAnyErr: true,
+		},
+		{
+			name:       "garbage",
+			data:       "not a key",
+			wantAnyErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(context.Background(), staticProvider(tt.data), tt.passphrase)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if tt.wantAnyErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, key, got)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// File provides secret from a file, "-" is stdin. File must be owned by the
+// current user and must not be accessible by group or others.
+type File string
+
+func (f File) Secret(ctx context.Context) ([]byte, error) {
+	if f == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	err = checkPerm(fi)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+	return io.ReadAll(file)
+}
+
+func checkPerm(fi fs.FileInfo) error {
+	if !fi.Mode().IsRegular() {
+		return errors.New("not a regular file")
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("permissions %04o are too open, must not be accessible by group or others", perm)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("must be owned by uid %d", os.Geteuid())
+	}
+	return nil
+}
+
+// Credential provides secret from systemd credential, passed to the service
+// with LoadCredential= or LoadCredentialEncrypted=. Permissions are managed
+// by systemd.
+//
+// Returns error wrapping fs.ErrNotExist if credential isn't passed.
+type Credential string
+
+func (c Credential) Secret(ctx context.Context) ([]byte, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return nil, fmt.Errorf("credential %s: %w", c, fs.ErrNotExist)
+	}
+	return os.ReadFile(filepath.Join(dir, string(c)))
+}
+
+// Command provides secret from standard output of a shell command, e.g., a
+// secret store client. Standard error is passed through.
+type Command string
+
+func (c Command) Secret(ctx context.Context) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", string(c))
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("secret command: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package keystore
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Secret(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		perm    fs.FileMode
+		wantErr bool
+	}{
+		{
+			name: "owner only",
+			perm: 0600,
+		},
+		{
+			name: "read only",
+			perm: 0400,
+		},
+		{
+			name:    "group readable",
+			perm:    0640,
+			wantErr: true,
+		},
+		{
+			name:    "world readable",
+			perm:    0604,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			require.NoError(t, os.WriteFile(path, []byte("secret"), 0600))
+			require.NoError(t, os.Chmod(path, tt.perm))
+
+			got, err := File(path).Secret(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []byte("secret"), got)
+		})
+	}
+
+	_, err := File(dir).Secret(context.Background())
+	assert.Error(t, err, "directory")
+}
+
+func TestCredential_Secret(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "server-key"), []byte("secret"), 0400))
+
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	_, err := Credential("server-key").Secret(context.Background())
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	got, err := Credential("server-key").Secret(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), got)
+
+	_, err = Credential("other").Secret(context.Background())
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestCommand_Secret(t *testing.T) {
+	got, err := Command("printf secret").Secret(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), got)
+
+	_, err = Command("exit 1").Secret(context.Background())
+	assert.Error(t, err)
+}
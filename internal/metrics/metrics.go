@@ -0,0 +1,167 @@
+// Package metrics provides licensing server Prometheus metrics.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "licensing"
+
+// registry holds all licensing server metrics.
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests handled, partitioned by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP requests latencies, partitioned by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	licenseSessions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "license_sessions",
+		Name:      "total",
+		Help:      "Number of successful license session operations, partitioned by operation (created, refreshed, closed).",
+	}, []string{"operation"})
+
+	licenseSessionsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "license_sessions",
+		Name:      "rejected_total",
+		Help:      "Number of rejected license session operations, partitioned by operation and reason.",
+	}, []string{"operation", "reason"})
+
+	cleanupDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cleanup",
+		Name:      "deleted_total",
+		Help:      "Number of rows deleted by the cleanup routine, partitioned by kind.",
+	}, []string{"kind"})
+
+	notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "total",
+		Help:      "Number of notification sending attempts, partitioned by kind and result (sent, failed, abandoned).",
+	}, []string{"kind", "result"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database queries latencies, partitioned by scope (table) and action.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"scope", "action"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		licenseSessions,
+		licenseSessionsRejected,
+		cleanupDeleted,
+		notifications,
+		dbQueryDuration,
+	)
+}
+
+// Handler returns HTTP handler exposing metrics in Prometheus format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Register registers additional collector.
+func Register(c prometheus.Collector) error {
+	return registry.Register(c)
+}
+
+func ObserveHTTPRequest(route, method string, code int, d time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	httpRequestDuration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+func LicenseSession(operation string) {
+	licenseSessions.WithLabelValues(operation).Inc()
+}
+
+func LicenseSessionRejected(operation, reason string) {
+	licenseSessionsRejected.WithLabelValues(operation, reason).Inc()
+}
+
+func CleanupDeleted(kind string, n int) {
+	cleanupDeleted.WithLabelValues(kind).Add(float64(n))
+}
+
+func Notification(kind, result string) {
+	notifications.WithLabelValues(kind, result).Inc()
+}
+
+func ObserveDBQuery(scope, action string, d time.Duration) {
+	dbQueryDuration.WithLabelValues(scope, action).Observe(d.Seconds())
+}
+
+// ActiveSessionsFunc returns active license sessions count per product. Key
+// is a product ID, licenses without product are counted under zero key.
+type ActiveSessionsFunc func(ctx context.Context) (map[int]int, error)
+
+// activeSessionsCollector collects active license sessions per product on
+// every scrape.
+type activeSessionsCollector struct {
+	desc    *prometheus.Desc
+	timeout time.Duration
+	count   ActiveSessionsFunc
+}
+
+// NewActiveSessionsCollector returns a collector, which calls count on every
+// scrape.
+func NewActiveSessionsCollector(count ActiveSessionsFunc, timeout time.Duration) prometheus.Collector {
+	return &activeSessionsCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "license_sessions", "active"),
+			"Number of active license sessions, partitioned by product.",
+			[]string{"product_id"}, nil,
+		),
+		timeout: timeout,
+		count:   count,
+	}
+}
+
+func (c *activeSessionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *activeSessionsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	counts, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for productID, n := range counts {
+		label := "none"
+		if productID != 0 {
+			label = strconv.Itoa(productID)
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), label)
+	}
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ClientCert maps TLS client certificates to license issuer, either by
+// fingerprint of a single certificate or by subject alternative name of any
+// certificate issued by trusted CA.
+type ClientCert struct {
+	ID          int       `json:"id"`
+	Fingerprint *string   `json:"fingerprint,omitempty"` // Lower case hex SHA-256 of DER encoded certificate.
+	SAN         *string   `json:"san,omitempty"`         // DNS name, email address or URI.
+	Note        string    `json:"note"`
+	Created     time.Time `json:"created"`
+	IssuerID    int       `json:"-"`
+}
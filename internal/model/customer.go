@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Customer is an end user of issuer's licenses.
+type Customer struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Company    string    `json:"company"`
+	Emails     []string  `json:"emails"`
+	ExternalID string    `json:"externalID"` // ID in external CRM.
+	Note       string    `json:"note"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+	IssuerID   int       `json:"-"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Duration is a time duration stored in whole seconds. In JSON it's a string
+// like "365d" or "12h30m", or a number of seconds.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	v := time.Duration(d)
+	if v != 0 && v%day == 0 {
+		return strconv.FormatInt(int64(v/day), 10) + "d"
+	}
+	return v.String()
+}
+
+// ParseDuration parses duration, additionally to time.ParseDuration formats
+// whole number of days like "365d" is accepted.
+func ParseDuration(s string) (Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %q", s)
+		}
+		return Duration(time.Duration(n) * day), nil
+	}
+	v, err := time.ParseDuration(s)
+	return Duration(v), err
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(bs []byte) error {
+	if d == nil {
+		return errors.New("duration is nil")
+	}
+	var s string
+	err := json.Unmarshal(bs, &s)
+	if err != nil {
+		var seconds int64
+		err = json.Unmarshal(bs, &seconds)
+		if err != nil {
+			return errors.New("duration must be a string or a number of seconds")
+		}
+		*d = Duration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+	*d, err = ParseDuration(s)
+	return err
+}
+
+// Value returns duration in whole seconds.
+func (d Duration) Value() (driver.Value, error) {
+	return int64(time.Duration(d) / time.Second), nil
+}
+
+// Scan scans duration from whole seconds.
+func (d *Duration) Scan(src interface{}) error {
+	seconds, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("unsupported duration type: %T", src)
+	}
+	*d = Duration(time.Duration(seconds) * time.Second)
+	return nil
+}
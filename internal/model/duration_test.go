@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuration_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		arg  Duration
+		want string
+	}{
+		{Duration(365 * day), `"365d"`},
+		{Duration(36 * time.Hour), `"36h0m0s"`},
+		{Duration(90 * time.Second), `"1m30s"`},
+		{0, `"0s"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := json.Marshal(tt.arg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    Duration
+		wantErr bool
+	}{
+		{`"365d"`, Duration(365 * day), false},
+		{`"12h30m"`, Duration(12*time.Hour + 30*time.Minute), false},
+		{`3600`, Duration(time.Hour), false},
+		{`"1.5d"`, 0, true},
+		{`"day"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			var got Duration
+			err := json.Unmarshal([]byte(tt.arg), &got)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKey is a client supplied key of a create request. Response of
+// the request is stored and replayed to retries with the same key.
+type IdempotencyKey struct {
+	IssuerID    int            // Issuer, which sent the request.
+	Key         string         // Idempotency-Key header.
+	RequestHash []byte         // Hash of method, path and body of the request.
+	StatusCode  int            // Zero while request is in progress.
+	Header      ResponseHeader // Response headers.
+	Body        []byte         // Response body.
+	Created     time.Time      // Keys expire after retention.
+
+	// LockedUntil is the lease of the request in progress, after which
+	// another request may take the key over.
+	LockedUntil *time.Time
+}
+
+// ResponseHeader is a stored response header.
+type ResponseHeader map[string][]string
+
+// Value returns header as json, nil header is stored as NULL.
+func (h ResponseHeader) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	return json.Marshal(h)
+}
+
+// Scan scans header from json.
+func (h *ResponseHeader) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, h)
+	case string:
+		return json.Unmarshal([]byte(src), h)
+	default:
+		return fmt.Errorf("unsupported response header type: %T", src)
+	}
+}
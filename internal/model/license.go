@@ -1,7 +1,10 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/sewiti/licensing-system/pkg/util"
 )
 
 type License struct {
@@ -10,6 +13,7 @@ type License struct {
 	Active       bool       `json:"active"`
 	Name         string     `json:"name"`
 	Tags         []string   `json:"tags"`
+	Features     []string   `json:"features"`
 	EndUserEmail string     `json:"endUserEmail"`
 	Note         string     `json:"note"`
 	Data         []byte     `json:"data"`
@@ -20,4 +24,42 @@ type License struct {
 	LastUsed     *time.Time `json:"lastUsed"`
 	IssuerID     int        `json:"-"`
 	ProductID    *int       `json:"productID"`
+
+	TemplateID      *int `json:"templateID"`
+	TemplateVersion *int `json:"templateVersion"`
+
+	// MaxTransfers is the maximum number of self-service machine
+	// deactivations, up to 10000. Zero disables them and -1 means unlimited.
+	MaxTransfers     int       `json:"maxTransfers"`
+	TransferCooldown *Duration `json:"transferCooldown"` // Minimum time between transfers.
+
+	// ParentID is the base license of an add-on license. Add-ons share
+	// sessions of their base license and contribute their features and data.
+	ParentID []byte `json:"parentID"`
+
+	EditionID *int     `json:"editionID"` // Edition of the product.
+	Channels  []string `json:"channels"`  // Release channels in addition to edition's.
+
+	// UpdatesUntil ends subscription of updates, releases published after it
+	// aren't offered to the license. Nil means no limit.
+	UpdatesUntil *time.Time `json:"updatesUntil"`
+
+	Quotas UsageQuotas `json:"quotas"` // Usage quotas per period.
+
+	CustomerID *int `json:"customerID"`
+
+	// Deleted is the time license was moved to trash, nil if it wasn't.
+	Deleted *time.Time `json:"deleted,omitempty"`
+}
+
+// MarshalJSON adds human-friendly formatted key to the license.
+func (l License) MarshalJSON() ([]byte, error) {
+	type license License // Drops methods, avoids recursion.
+	return json.Marshal(struct {
+		license
+		FormattedKey string `json:"formattedKey,omitempty"`
+	}{
+		license:      license(l),
+		FormattedKey: util.FormatKey(l.Key),
+	})
 }
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/sewiti/licensing-system/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLicense_MarshalJSON(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString("YFxMq0722e2v2f3tg3+QpkIrV3dlqjCQQv9X7LhMZG0=")
+	require.NoError(t, err)
+
+	bs, err := json.Marshal(&License{Key: key, Name: "hello"})
+	require.NoError(t, err)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(bs, &got))
+	assert.Equal(t, "hello", got["name"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString(key), got["key"])
+	assert.Equal(t, util.FormatKey(key), got["formattedKey"])
+
+	var l License
+	require.NoError(t, json.Unmarshal(bs, &l))
+	assert.Equal(t, key, l.Key)
+}
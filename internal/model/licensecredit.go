@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// LicenseCredit is license's balance of credits consumable by a feature.
+type LicenseCredit struct {
+	LicenseID []byte    `json:"-"`
+	Feature   string    `json:"feature"`
+	Balance   int64     `json:"balance"`
+	Updated   time.Time `json:"updated"`
+}
+
+// CreditReceipt records consumption of license's credits. Its ID is chosen by
+// the client, so that retried consumption isn't charged twice.
+type CreditReceipt struct {
+	ID              []byte    `json:"id"`
+	LicenseID       []byte    `json:"licenseID"`
+	ClientSessionID []byte    `json:"clientSessionID"`
+	Feature         string    `json:"feature"`
+	Amount          int64     `json:"amount"`
+	Balance         int64     `json:"balance"` // Remaining balance.
+	Created         time.Time `json:"created"`
+}
@@ -12,4 +12,7 @@ type LicenseIssuer struct {
 	MaxLicenses  Limit     `json:"maxLicenses"`
 	Created      time.Time `json:"created"`
 	Updated      time.Time `json:"updated"`
+
+	// Deleted is the time issuer was moved to trash, nil if it wasn't.
+	Deleted *time.Time `json:"deleted,omitempty"`
 }
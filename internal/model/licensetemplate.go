@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// LicenseTemplate holds defaults of licenses issued from it. Version is
+// incremented on every update.
+type LicenseTemplate struct {
+	ID          int       `json:"id"`
+	Version     int       `json:"version"`
+	Name        string    `json:"name"`
+	Tags        []string  `json:"tags"`
+	Features    []string  `json:"features"`
+	Note        string    `json:"note"`
+	Data        []byte    `json:"data"`
+	MaxSessions int       `json:"maxSessions"`
+	ValidFor    *Duration `json:"validFor"` // Validity from issuance, nil means forever.
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+	IssuerID    int       `json:"-"`
+	ProductID   *int      `json:"productID"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// LicenseTransfer records self-service deactivation of license's machine,
+// freeing it for another one.
+type LicenseTransfer struct {
+	ID        int       `json:"id"`
+	LicenseID []byte    `json:"-"`
+	MachineID []byte    `json:"machineID"`
+	Created   time.Time `json:"created"`
+}
+
+// LicenseMachine is a machine with active sessions of a license.
+type LicenseMachine struct {
+	MachineID  []byte    `json:"machineID"`
+	Identifier string    `json:"identifier"`
+	AppVersion string    `json:"appVersion"`
+	Sessions   int       `json:"sessions"`
+	LastSeen   time.Time `json:"lastSeen"` // Creation time of the latest session.
+}
+
+// LicenseTransferStatus describes how many transfers license has left.
+type LicenseTransferStatus struct {
+	Transfers    int        `json:"transfers"`
+	MaxTransfers int        `json:"maxTransfers"`           // Zero means disabled, -1 - unlimited.
+	NextTransfer *time.Time `json:"nextTransfer,omitempty"` // Earliest time of next transfer, nil means now.
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// LicenseUsage is usage of a metric reported by license's clients, aggregated
+// per period.
+type LicenseUsage struct {
+	LicenseID []byte    `json:"-"`
+	Metric    string    `json:"metric"`
+	Period    time.Time `json:"period"` // Start of the period.
+	Amount    int64     `json:"amount"`
+	Updated   time.Time `json:"updated"`
+}
+
+// UsageReport is a usage report of license's client, which has been added to
+// license's usage. Reports are identified by client generated ID, so that
+// retried or replayed reports are added once.
+type UsageReport struct {
+	ID        []byte
+	LicenseID []byte
+	Created   time.Time
+}
+
+// UsageQuotas maps metrics to maximum usage per period.
+type UsageQuotas map[string]int64
+
+// Value returns quotas as json.
+func (q UsageQuotas) Value() (driver.Value, error) {
+	if q == nil {
+		q = UsageQuotas{}
+	}
+	return json.Marshal(q)
+}
+
+// Scan scans quotas from json.
+func (q *UsageQuotas) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, q)
+	case string:
+		return json.Unmarshal([]byte(src), q)
+	default:
+		return fmt.Errorf("unsupported quotas type: %T", src)
+	}
+}
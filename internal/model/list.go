@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// ListOptions defines pagination, sorting and search of list queries.
+type ListOptions struct {
+	Limit  int    // Maximum number of items, zero means no limit.
+	Cursor string // Opaque cursor of the next page, returned by previous query.
+	Sort   string // Sort key, empty means default order.
+	Desc   bool   // Sort in descending order.
+	Search string // Full-text search query.
+}
+
+// Page is a single page of list query.
+type Page struct {
+	Total int    // Total number of items matching filters and search.
+	Next  string // Cursor of the next page, empty if it's the last page.
+}
+
+// LicenseFilter filters licenses. Zero values are ignored.
+type LicenseFilter struct {
+	Active         *bool
+	ProductID      *int
+	CustomerID     *int
+	Tag            string
+	EndUserEmail   string
+	ExpiringBefore *time.Time
+	LastUsedBefore *time.Time
+	LastUsedAfter  *time.Time
+	Deleted        bool // Lists trash instead.
+}
+
+// ProductFilter filters products. Zero values are ignored.
+type ProductFilter struct {
+	Active  *bool
+	Deleted bool // Lists trash instead.
+}
+
+// CustomerFilter filters customers. Zero values are ignored.
+type CustomerFilter struct {
+	Email      string
+	ExternalID string
+}
+
+// LicenseIssuerFilter filters license issuers. Zero values are ignored.
+type LicenseIssuerFilter struct {
+	Active  *bool
+	Deleted bool // Lists trash instead.
+}
+
+// LicenseSessionFilter filters license sessions. Zero values are ignored.
+type LicenseSessionFilter struct {
+	AppVersion string
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// Notification is an email queued for sending to a single recipient.
+type Notification struct {
+	ID          int64      `json:"id"`
+	Kind        string     `json:"kind"`
+	Recipient   string     `json:"recipient"`
+	Subject     string     `json:"subject"`
+	Body        string     `json:"body"`
+	DedupKey    *string    `json:"-"` // Notifications with the same key are sent once per recipient.
+	Attempts    int        `json:"attempts"`
+	NextAttempt time.Time  `json:"nextAttempt"`
+	LastError   string     `json:"lastError"`
+	Sent        *time.Time `json:"sent"` // Nil if not sent yet.
+	Created     time.Time  `json:"created"`
+	IssuerID    int        `json:"-"`
+	LicenseID   []byte     `json:"licenseID"`
+}
+
+// NotificationTemplate overrides the default template of a notification kind
+// for the issuer.
+type NotificationTemplate struct {
+	IssuerID int        `json:"-"`
+	Kind     string     `json:"kind"`
+	Subject  string     `json:"subject"`
+	Body     string     `json:"body"`
+	Updated  *time.Time `json:"updated"` // Nil for default templates.
+}
+
+// NotificationOptOut excludes email from issuer's notifications, except
+// license delivery.
+type NotificationOptOut struct {
+	IssuerID int       `json:"-"`
+	Email    string    `json:"email"`
+	Created  time.Time `json:"created"`
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Product struct {
 	ID           int       `json:"id"`
@@ -11,4 +14,11 @@ type Product struct {
 	Created      time.Time `json:"created"`
 	Updated      time.Time `json:"updated"`
 	IssuerID     int       `json:"-"`
+
+	// DataSchema is JSON Schema data of the product and its licenses must
+	// conform to. Nil if data is unconstrained.
+	DataSchema json.RawMessage `json:"dataSchema"`
+
+	// Deleted is the time product was moved to trash, nil if it wasn't.
+	Deleted *time.Time `json:"deleted,omitempty"`
 }
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ProductEdition is an edition of a product, e.g., Standard or Pro, with
+// defaults delivered to licenses assigned to it.
+type ProductEdition struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Features  []string  `json:"features"`
+	Channels  []string  `json:"channels"` // Release channels licenses are entitled to.
+	Data      []byte    `json:"data"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	ProductID int       `json:"productID"`
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ProductRelease is a release of a product, delivered to licenses entitled to
+// it via signed release manifests.
+type ProductRelease struct {
+	ID      int    `json:"id"`
+	Version string `json:"version"`
+	Channel string `json:"channel"`
+	Notes   string `json:"notes"`
+
+	// EditionIDs restricts release to licenses of these editions, empty means
+	// all licenses of the product.
+	EditionIDs []int64 `json:"editionIDs"`
+	// MinAppVersion is the minimum app version required to update to the
+	// release, empty means any.
+	MinAppVersion string `json:"minAppVersion"`
+
+	Artifacts ReleaseArtifacts `json:"artifacts"`
+	Released  time.Time        `json:"released"`
+	Created   time.Time        `json:"created"`
+	Updated   time.Time        `json:"updated"`
+	ProductID int              `json:"productID"`
+}
+
+// ReleaseArtifact is a downloadable file of the release.
+type ReleaseArtifact struct {
+	Name   string `json:"name"`   // File name delivered to clients.
+	Path   string `json:"path"`   // Path relative to releases directory.
+	Size   int64  `json:"size"`   // Calculated by the server.
+	SHA256 []byte `json:"sha256"` // Calculated by the server.
+}
+
+type ReleaseArtifacts []ReleaseArtifact
+
+// Value returns artifacts as json.
+func (a ReleaseArtifacts) Value() (driver.Value, error) {
+	if a == nil {
+		a = ReleaseArtifacts{}
+	}
+	return json.Marshal(a)
+}
+
+// Scan scans artifacts from json.
+func (a *ReleaseArtifacts) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, a)
+	case string:
+		return json.Unmarshal([]byte(src), a)
+	default:
+		return fmt.Errorf("unsupported artifacts type: %T", src)
+	}
+}
+
+// ReleaseManifest lists releases license is entitled to. It is signed by the
+// server and delivered to license session's client.
+type ReleaseManifest struct {
+	LicenseID  []byte            `json:"licenseID"`
+	ProductID  int               `json:"productID"`
+	EditionID  *int              `json:"editionID,omitempty"`
+	AppVersion string            `json:"appVersion"`
+	Issued     time.Time         `json:"issued"`
+	Expire     time.Time         `json:"expire"` // Download tokens expire.
+	Releases   []ManifestRelease `json:"releases"`
+}
+
+type ManifestRelease struct {
+	Version   string             `json:"version"`
+	Channel   string             `json:"channel"`
+	Notes     string             `json:"notes,omitempty"`
+	Released  time.Time          `json:"released"`
+	Artifacts []ManifestArtifact `json:"artifacts"`
+}
+
+type ManifestArtifact struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 []byte `json:"sha256"`
+	Token  string `json:"token"` // Download token.
+}
@@ -0,0 +1,10 @@
+package model
+
+// ServerStats counts licensing server's resources, trash excluded.
+type ServerStats struct {
+	LicenseIssuers        int `json:"licenseIssuers"`
+	Products              int `json:"products"`
+	Licenses              int `json:"licenses"`
+	ActiveLicenses        int `json:"activeLicenses"`
+	ActiveLicenseSessions int `json:"activeLicenseSessions"`
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	maxRequestIDLen = 128
+)
+
+// requestLog is access log state of a request, filled in by handlers.
+type requestLog struct {
+	route    string // Matched route template, empty if none.
+	issuerID int    // Authenticated license issuer, 0 if none.
+	redact   bool   // Request carries key material, path is not logged.
+	debug    bool   // Successful request is logged at debug level.
+}
+
+type requestLogKey struct{}
+
+// accessLogMiddleware assigns request ID, or propagates a valid one given in
+// X-Request-ID header, and logs served requests. It must wrap the whole
+// router, so that requests matching no route are logged too, while the router
+// should use routeLogMiddleware.
+//
+// Logger with request ID is passed down in request context, so that errors are
+// logged with it (see logError).
+func accessLogMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		rl := &requestLog{}
+		l := log.WithField("request_id", id)
+		ctx := context.WithValue(r.Context(), requestLogKey{}, rl)
+		ctx = log.NewContext(ctx, l)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(ctx))
+
+		route := rl.route
+		if route == "" {
+			route = "unknown"
+		}
+		fields := log.Fields{
+			"method":    r.Method,
+			"route":     route,
+			"status":    rec.statusCode,
+			"duration":  time.Since(start).Milliseconds(),
+			"remote_ip": remoteIP(r),
+		}
+		if !rl.redact {
+			fields["path"] = r.URL.Path
+		}
+		if rl.issuerID != 0 {
+			fields["issuer"] = rl.issuerID
+		}
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			fields["forwarded_for"] = fwd
+		}
+		entry := l.WithFields(fields)
+		if rl.debug && rec.statusCode < http.StatusBadRequest {
+			entry.Debug("request")
+		} else {
+			entry.Info("request")
+		}
+	})
+}
+
+// routeLogMiddleware records route template of the matched request in access
+// log.
+func routeLogMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+			if cr := mux.CurrentRoute(r); cr != nil {
+				tmpl, err := cr.GetPathTemplate()
+				if err == nil {
+					rl.route = tmpl
+				}
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// withRedactedLog marks requests, which carry key material in path or query,
+// e.g., licensing API session IDs and download tokens. Only route template of
+// such request is logged.
+func withRedactedLog(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+			rl.redact = true
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// withDebugLog marks requests, which are logged at debug level unless they
+// fail, e.g., health checks.
+func withDebugLog(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+			rl.debug = true
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// withIssuerLog records authenticated license issuer in access log and returns
+// request, which logs errors with it.
+func withIssuerLog(r *http.Request, login *model.LicenseIssuer) *http.Request {
+	if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		rl.issuerID = login.ID
+	}
+	l := log.FromContext(r.Context()).WithField("issuer", login.ID)
+	return r.WithContext(log.NewContext(r.Context(), l))
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := cryptorand.Read(b)
+	if err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
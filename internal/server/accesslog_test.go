@@ -0,0 +1,132 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"req-1_2.3:4", true},
+		{strings.Repeat("a", maxRequestIDLen), true},
+		{strings.Repeat("a", maxRequestIDLen+1), false},
+		{"with space", false},
+		{"line\nbreak", false},
+		{"ąčę", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, validRequestID(tt.id), tt.id)
+	}
+}
+
+// newAccessLogTest returns router wrapped in access log like NewRouter does
+// and log handler capturing entries.
+func newAccessLogTest(t *testing.T) (*mux.Router, http.Handler, *memory.Handler) {
+	logs := memory.New()
+	prev := log.Log
+	log.Log = &log.Logger{Handler: logs, Level: log.DebugLevel}
+	t.Cleanup(func() { log.Log = prev })
+
+	r := mux.NewRouter()
+	r.Use(routeLogMiddleware)
+	return r, accessLogMiddleware(r), logs
+}
+
+func accessLogEntry(t *testing.T, logs *memory.Handler) *log.Entry {
+	for _, e := range logs.Entries {
+		if e.Message == "request" {
+			return e
+		}
+	}
+	require.FailNow(t, "no access log entry")
+	return nil
+}
+
+func TestAccessLogMiddleware_notFound(t *testing.T) {
+	r, h, logs := newAccessLogTest(t)
+	r.Path("/found").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+		httptest.NewRequest(http.MethodPost, "/found", nil),
+	} {
+		logs.Entries = nil
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		assert.True(t, validRequestID(w.Header().Get(requestIDHeader)))
+		e := accessLogEntry(t, logs)
+		assert.Equal(t, "unknown", e.Fields["route"])
+		assert.Equal(t, w.Code, e.Fields["status"])
+		assert.Equal(t, req.URL.Path, e.Fields["path"])
+	}
+}
+
+func TestAccessLogMiddleware_requestID(t *testing.T) {
+	_, h, logs := newAccessLogTest(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "upstream-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, "upstream-1", w.Header().Get(requestIDHeader))
+	assert.Equal(t, "upstream-1", accessLogEntry(t, logs).Fields["request_id"])
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "invalid id")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.NotEqual(t, "invalid id", w.Header().Get(requestIDHeader))
+	assert.True(t, validRequestID(w.Header().Get(requestIDHeader)))
+}
+
+func TestAccessLogMiddleware_redacted(t *testing.T) {
+	r, h, logs := newAccessLogTest(t)
+	const route = "/api/license-sessions/{CLIENT_SESSION_ID}"
+	r.Path(route).Handler(withRedactedLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/api/license-sessions/secret", nil))
+
+	e := accessLogEntry(t, logs)
+	assert.Equal(t, route, e.Fields["route"])
+	assert.NotContains(t, e.Fields, "path")
+	for _, v := range e.Fields {
+		assert.NotContains(t, fmt.Sprint(v), "secret")
+	}
+}
+
+func TestAccessLogMiddleware_issuer(t *testing.T) {
+	r, h, logs := newAccessLogTest(t)
+	r.Path("/licenses").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = withIssuerLog(r, &model.LicenseIssuer{ID: 7})
+		logError(r.Context(), errors.New("failure"), "getting licenses")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/licenses", nil))
+
+	require.Len(t, logs.Entries, 2)
+	errEntry, reqEntry := logs.Entries[0], logs.Entries[1]
+	assert.Equal(t, log.ErrorLevel, errEntry.Level)
+	assert.Equal(t, 7, errEntry.Fields["issuer"])
+	assert.Equal(t, 7, reqEntry.Fields["issuer"])
+	assert.Equal(t, w.Header().Get(requestIDHeader), errEntry.Fields["request_id"])
+	assert.Equal(t, w.Header().Get(requestIDHeader), reqEntry.Fields["request_id"])
+}
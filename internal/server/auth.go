@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/x509"
 	"errors"
 	"net/http"
 	"strconv"
@@ -39,11 +40,11 @@ func withAPIAuth(c *core.Core, h apiAuthHandler) apiHandler {
 				case errors.Is(err, auth.ErrNoLogin):
 					return responseUnauthorized()
 				default:
-					logError(err, "basic-auth")
+					logError(r.Context(), err, "basic-auth")
 					return responseInternalServerError()
 				}
 			}
-			return h(r, li)
+			return h(withIssuerLog(r, li), li)
 		}
 
 		// Bearer token
@@ -59,17 +60,46 @@ func withAPIAuth(c *core.Core, h apiAuthHandler) apiHandler {
 				case errors.Is(err, core.ErrUserInactive):
 					return responseUnauthorized()
 				default:
-					logError(err, "bearer-auth")
+					logError(r.Context(), err, "bearer-auth")
 					return responseUnauthorized()
 					// return responseInternalServerError()
 				}
 			}
-			return h(r, li)
+			return h(withIssuerLog(r, li), li)
+		}
+
+		// TLS client certificate, verified against trusted CAs
+		if cert := verifiedClientCert(r); cert != nil {
+			li, err := c.AuthenticateClientCert(r.Context(), cert)
+			if err != nil {
+				switch {
+				case errors.Is(err, core.ErrNotFound):
+					return responseUnauthorized()
+				case errors.Is(err, core.ErrUserInactive):
+					return responseUnauthorized()
+				case errors.Is(err, core.ErrClientCertAmbiguous):
+					logError(r.Context(), err, "client-cert-auth")
+					return responseUnauthorized()
+				default:
+					logError(r.Context(), err, "client-cert-auth")
+					return responseInternalServerError()
+				}
+			}
+			return h(withIssuerLog(r, li), li)
 		}
 		return responseUnauthorized()
 	}
 }
 
+// verifiedClientCert returns TLS client certificate of the request, verified
+// against trusted CAs, or nil.
+func verifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
 func withAuthorized(c *core.Core, h apiAuthHandler) apiAuthHandler {
 	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
 		licenseIssuerIDStr, ok := mux.Vars(r)["LICENSE_ISSUER_ID"]
@@ -122,13 +152,13 @@ func createToken(c *core.Core) apiHandler {
 			case errors.Is(err, auth.ErrNoLogin):
 				return responseUnauthorized()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 		token, err := c.CreateToken(li)
 		if err != nil {
-			logError(err, scope)
+			logError(r.Context(), err, scope)
 			return responseInternalServerError()
 		}
 		return responseJson(http.StatusOK, createTokenRes{
@@ -166,7 +196,7 @@ func updatePassword(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrPasswdTooWeak):
 				return responseBadRequest(err)
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
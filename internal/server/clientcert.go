@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+func createClientCert(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "create client cert"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		var req model.ClientCert
+		err = jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		li, err := c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		cc, err := c.NewClientCert(r.Context(), login, li, &req, verifiedClientCert(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrInsufficientPerm):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrDuplicate):
+				return responseConflict("client cert is already registered")
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, cc)
+	}
+}
+
+func getAllClientCerts(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all client certs"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		cc, err := c.GetAllClientCerts(r.Context(), licenseIssuerID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if cc == nil {
+			cc = make([]*model.ClientCert, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, cc)
+	}
+}
+
+func getClientCert(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get client cert"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		clientCertID, err := strconv.Atoi(vars["CLIENT_CERT_ID"])
+		if err != nil {
+			return responseBadRequestf("client cert id: %v", err)
+		}
+
+		cc, err := c.GetClientCert(r.Context(), clientCertID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if cc.IssuerID != licenseIssuerID {
+			return responseNotFound()
+		}
+		return responseJson(http.StatusOK, cc)
+	}
+}
+
+func deleteClientCert(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "delete client cert"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		clientCertID, err := strconv.Atoi(vars["CLIENT_CERT_ID"])
+		if err != nil {
+			return responseBadRequestf("client cert id: %v", err)
+		}
+
+		err = c.DeleteClientCert(r.Context(), clientCertID, licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
@@ -3,21 +3,60 @@ package server
 import (
 	"net/http"
 	"strings"
+	"sync"
 )
 
+// CORSConf defines cross-origin requests policy.
+type CORSConf struct {
+	ResourceApi    bool // Allow cross-origin requests to resource API.
+	LicensingApi   bool // Allow cross-origin requests to licensing API.
+	Monitoring     bool // Allow cross-origin requests to monitoring router.
+	AllowedOrigins []string
+}
+
+// CORS holds cross-origin requests policy, which can be changed while router
+// is serving.
+type CORS struct {
+	mu   sync.RWMutex
+	conf CORSConf
+}
+
+func NewCORS(conf CORSConf) *CORS {
+	return &CORS{conf: conf}
+}
+
+// Set replaces cross-origin requests policy.
+func (c *CORS) Set(conf CORSConf) {
+	c.mu.Lock()
+	c.conf = conf
+	c.mu.Unlock()
+}
+
+func (c *CORS) get() CORSConf {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conf
+}
+
 type corsHandler struct {
+	cors    *CORS
+	enabled func(conf CORSConf) bool // Reports whether CORS is enabled for the API.
 	headers []string
 	methods []string
 }
 
 func (h corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled(h.cors.get()) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
 	w.Header().Set("Access-Control-Allow-Headers", strings.Join(h.headers, ","))
 	w.Header().Set("Access-Control-Allow-Methods", strings.Join(h.methods, ","))
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func corsOriginMiddleware(origins []string) func(http.Handler) http.Handler {
-	allowed := func(origin string) (string, bool) {
+func corsOriginMiddleware(cors *CORS, enabled func(conf CORSConf) bool) func(http.Handler) http.Handler {
+	allowed := func(origins []string, origin string) (string, bool) {
 		for _, v := range origins {
 			switch v {
 			case origin, "*":
@@ -29,11 +68,17 @@ func corsOriginMiddleware(origins []string) func(http.Handler) http.Handler {
 
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conf := cors.get()
+			if !enabled(conf) {
+				h.ServeHTTP(w, r)
+				return
+			}
 			origin := r.Header.Get("Origin")
-			origin, ok := allowed(origin)
+			origin, ok := allowed(conf.AllowedOrigins, origin)
 			if ok {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
-				if len(origins) > 1 {
+				w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count,X-Next-Cursor,ETag,Idempotent-Replayed,X-Request-ID")
+				if len(conf.AllowedOrigins) > 1 {
 					w.Header().Set("Vary", "Origin")
 				}
 			}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// issuerCustomer returns customer of the request path, which must belong to
+// the license issuer of the path. Response is returned on failure.
+func issuerCustomer(r *http.Request, c *core.Core, scope string) (*model.Customer, *apiResponse) {
+	vars := mux.Vars(r)
+	licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+	if err != nil {
+		return nil, responseBadRequestf("license issuer id: %v", err)
+	}
+	customerID, err := strconv.Atoi(vars["CUSTOMER_ID"])
+	if err != nil {
+		return nil, responseBadRequestf("customer id: %v", err)
+	}
+
+	cu, err := c.GetCustomer(r.Context(), customerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			return nil, responseNotFound()
+		default:
+			logError(r.Context(), err, scope)
+			return nil, responseInternalServerError()
+		}
+	}
+	if licenseIssuerID != cu.IssuerID {
+		return nil, responseNotFound()
+	}
+	return cu, nil
+}
+
+func createCustomer(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "create customer"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		var req model.Customer
+		err = jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		li, err := c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		cu, err := c.NewCustomer(r.Context(), li, &req)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrDuplicate):
+				return responseConflict("customer with the external id already exists")
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, cu)
+	}
+}
+
+func getAllCustomers(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all customers"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		_, err = c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		opts, err := listOptions(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		filter := &model.CustomerFilter{
+			Email:      r.URL.Query().Get("email"),
+			ExternalID: r.URL.Query().Get("externalID"),
+		}
+
+		cc, page, err := c.GetCustomersByIssuer(r.Context(), licenseIssuerID, filter, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if cc == nil {
+			cc = make([]*model.Customer, 0) // Force empty array json
+		}
+		return responseList(cc, page)
+	}
+}
+
+func getCustomer(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get customer"
+		cu, res := issuerCustomer(r, c, scope)
+		if res != nil {
+			return res
+		}
+		return responseJson(http.StatusOK, cu)
+	}
+}
+
+// getCustomerLicenses lists licenses of the customer, accepts the same query
+// parameters as licenses listing.
+func getCustomerLicenses(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get customer licenses"
+		cu, res := issuerCustomer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		opts, err := listOptions(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		filter, err := licenseFilter(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		filter.CustomerID = &cu.ID
+
+		ll, page, err := c.GetLicensesByIssuer(r.Context(), cu.IssuerID, filter, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if ll == nil {
+			ll = make([]*model.License, 0) // Force empty array json
+		}
+		return responseList(ll, page)
+	}
+}
+
+func updateCustomer(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "update customer"
+		cu, res := issuerCustomer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		data, err := readAllLim(r.Body)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		err = json.Unmarshal(data, cu)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		changes, err := core.UnmarshalChanges(data)
+		if err != nil {
+			return responseBadRequest(err) // should never happen
+		}
+		mask, _ := c.AuthorizeCustomerUpdate(login)
+		field, ok := core.ChangesInMask(changes, mask)
+		if !ok {
+			return responseBadRequestf("unauthorized to change field: %s", field)
+		}
+
+		err = c.UpdateCustomer(r.Context(), cu, changes)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrDuplicate):
+				return responseConflict("customer with the external id already exists")
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		cu, err = c.GetCustomer(r.Context(), cu.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusOK, cu)
+	}
+}
+
+func deleteCustomer(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "delete customer"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		customerID, err := strconv.Atoi(vars["CUSTOMER_ID"])
+		if err != nil {
+			return responseBadRequestf("customer id: %v", err)
+		}
+
+		_, canDelete := c.AuthorizeCustomerUpdate(login)
+		if !canDelete {
+			return responseForbidden()
+		}
+		err = c.DeleteCustomer(r.Context(), customerID, licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
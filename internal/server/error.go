@@ -1,17 +1,21 @@
 package server
 
 import (
+	"context"
 	"errors"
 
 	"github.com/apex/log"
 	"github.com/sewiti/licensing-system/internal/core"
 )
 
-func logError(err error, scope string) {
+// logError logs error with request context fields, e.g., request ID.
+// Underlying error of core.SensitiveError is logged in full.
+func logError(ctx context.Context, err error, scope string) {
+	l := log.FromContext(ctx)
 	sErr := &core.SensitiveError{}
 	if errors.As(err, &sErr) {
-		log.WithError(sErr.Err).Errorf("%s: %s", scope, sErr.Message)
+		l.WithError(sErr.Err).Errorf("%s: %s", scope, sErr.Message)
 	} else {
-		log.WithError(err).Error(scope)
+		l.WithError(err).Error(scope)
 	}
 }
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// etag returns strong entity tag of a resource derived from its updated
+// timestamp. Timestamps are stored with microsecond precision.
+func etag(updated time.Time) string {
+	return `"` + strconv.FormatInt(updated.UnixMicro(), 36) + `"`
+}
+
+// licenseETag returns strong entity tag of a license. Last used timestamp is
+// written by licensing without touching updated, so it's a part of the tag.
+func licenseETag(l *model.License) string {
+	if l.LastUsed == nil {
+		return etag(l.Updated)
+	}
+	return `"` + strconv.FormatInt(l.Updated.UnixMicro(), 36) +
+		"." + strconv.FormatInt(l.LastUsed.UnixMicro(), 36) + `"`
+}
+
+// parseETag parses updated timestamp of a strong entity tag. Last used part of
+// license's tag is ignored, as it can't be modified through the API.
+func parseETag(tag string) (time.Time, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return time.Time{}, false
+	}
+	updated, _, _ := strings.Cut(tag[1:len(tag)-1], ".")
+	us, err := strconv.ParseInt(updated, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMicro(us), true
+}
+
+// headerETags returns entity tags listed in the header.
+func headerETags(h http.Header, key string) []string {
+	var tags []string
+	for _, v := range h.Values(key) {
+		for _, tag := range strings.Split(v, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// ifMatch returns updated timestamps of If-Match entity tags, resource must
+// have one of them to be modified. Nil is returned if header is absent or is
+// "*". Weak tags never match.
+func ifMatch(r *http.Request) []time.Time {
+	tags := headerETags(r.Header, "If-Match")
+	if len(tags) == 0 {
+		return nil
+	}
+	tt := make([]time.Time, 0, len(tags))
+	for _, tag := range tags {
+		if tag == "*" {
+			return nil
+		}
+		if t, ok := parseETag(tag); ok {
+			tt = append(tt, t)
+		}
+	}
+	return tt
+}
+
+// ifNoneMatch reports whether If-None-Match header matches the entity tag
+// using weak comparison.
+func ifNoneMatch(r *http.Request, tag string) bool {
+	for _, t := range headerETags(r.Header, "If-None-Match") {
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// responseEntity responds with the resource and its entity tag. Not Modified
+// is responded to GET if If-None-Match matches.
+func responseEntity(r *http.Request, tag string, data interface{}) *apiResponse {
+	var res *apiResponse
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && ifNoneMatch(r, tag) {
+		res = &apiResponse{statusCode: http.StatusNotModified}
+	} else {
+		res = responseJson(http.StatusOK, data)
+	}
+	res.setHeader("ETag", tag)
+	return res
+}
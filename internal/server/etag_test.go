@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseETag(t *testing.T) {
+	updated := time.Date(2022, 1, 1, 0, 0, 0, 1000, time.UTC)
+	lastUsed := updated.Add(time.Hour)
+
+	got, ok := parseETag(etag(updated))
+	assert.True(t, ok)
+	assert.True(t, updated.Equal(got))
+
+	got, ok = parseETag(licenseETag(&model.License{Updated: updated, LastUsed: &lastUsed}))
+	assert.True(t, ok)
+	assert.True(t, updated.Equal(got))
+
+	_, ok = parseETag(`W/"abc"`)
+	assert.False(t, ok)
+}
+
+func TestResponseEntity_lastUsed(t *testing.T) {
+	updated := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &model.License{Updated: updated}
+	tag := licenseETag(l)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", tag)
+	res := responseEntity(r, licenseETag(l), l)
+	assert.Equal(t, http.StatusNotModified, res.statusCode)
+
+	// Session created, last used changes without touching updated.
+	lastUsed := updated.Add(time.Hour)
+	l.LastUsed = &lastUsed
+	res = responseEntity(r, licenseETag(l), l)
+	assert.Equal(t, http.StatusOK, res.statusCode)
+	assert.NotEqual(t, tag, licenseETag(l))
+
+	// Updates are still allowed with the tag, which last used has changed.
+	r = httptest.NewRequest(http.MethodPatch, "/", nil)
+	r.Header.Set("If-Match", tag)
+	got := ifMatch(r)
+	if assert.Len(t, got, 1) {
+		assert.True(t, updated.Equal(got[0]))
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+)
+
+const readinessTimeout = 5 * time.Second
+
+// BuildInfo describes licensing server build.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+func healthz() apiHandler {
+	type healthzRes struct {
+		Status string `json:"status"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		return responseJson(http.StatusOK, healthzRes{Status: "ok"})
+	}
+}
+
+func readyz(c *core.Core) apiHandler {
+	type readyzRes struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		const scope = "readiness check"
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		checks := c.CheckReadiness(ctx)
+		res := readyzRes{
+			Status: "ok",
+			Checks: make(map[string]string, len(checks)),
+		}
+		for _, hc := range checks {
+			if hc.Err != nil {
+				// Check errors may reveal internal hosts, so only logged.
+				logError(r.Context(), hc.Err, scope+": "+hc.Name)
+				res.Checks[hc.Name] = "unavailable"
+				continue
+			}
+			res.Checks[hc.Name] = "ok"
+		}
+		if !core.Ready(checks) {
+			res.Status = "unavailable"
+			return responseJson(http.StatusServiceUnavailable, res)
+		}
+		return responseJson(http.StatusOK, res)
+	}
+}
+
+func version(c *core.Core, build BuildInfo) apiHandler {
+	type versionRes struct {
+		BuildInfo
+		ServerID []byte `json:"serverID"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		return responseJson(http.StatusOK, versionRes{
+			BuildInfo: build,
+			ServerID:  c.ServerID(),
+		})
+	}
+}
+
+// handleHealth registers health, readiness and version endpoints.
+func handleHealth(r *mux.Router, c *core.Core, build BuildInfo) {
+	r.Path("/healthz").Methods(http.MethodGet).Handler(withDebugLog(withAPI(healthz())))
+	r.Path("/readyz").Methods(http.MethodGet).Handler(withDebugLog(withAPI(readyz(c))))
+	r.Path("/version").Methods(http.MethodGet).Handler(withDebugLog(withAPI(version(c, build))))
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// withIdempotency makes create request idempotent by Idempotency-Key header.
+// Successful response is stored with its headers and replayed to retries
+// with the same key, while failed requests can be retried. Key is held until
+// the request finishes, however long it runs. Requests without the header
+// aren't affected.
+func withIdempotency(c *core.Core, h apiAuthHandler) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "idempotency"
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			return h(r, login)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		if len(body) > maxImportSize {
+			return responseJsonMsgf(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", maxImportSize)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		k, err := c.BeginIdempotentRequest(r.Context(), login.ID, key, requestHash(r, body))
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrIdempotencyKeyReused):
+				return responseJsonMsg(http.StatusUnprocessableEntity, err)
+			case errors.Is(err, core.ErrIdempotencyInProgress):
+				return responseConflict(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if k.StatusCode != 0 {
+			res := &apiResponse{
+				statusCode: k.StatusCode,
+				json:       true,
+				body:       k.Body,
+				header:     http.Header(k.Header).Clone(),
+			}
+			res.setHeader("Idempotent-Replayed", "true")
+			return res
+		}
+
+		// Request may outlive the client, so key is held until it finishes.
+		holdCtx, stopHold := context.WithCancel(context.Background())
+		held := make(chan struct{})
+		go func() {
+			defer close(held)
+			c.HoldIdempotentRequest(holdCtx, k, func(err error) {
+				logError(r.Context(), err, scope)
+			})
+		}()
+		res := h(r, login)
+		stopHold()
+		<-held
+
+		// Client may have given up already, key must be finished regardless.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if res != nil && res.statusCode >= 200 && res.statusCode < 300 {
+			err = c.CompleteIdempotentRequest(ctx, k, res.statusCode, model.ResponseHeader(res.header), res.body)
+		} else {
+			err = c.AbortIdempotentRequest(ctx, k)
+		}
+		if err != nil {
+			logError(r.Context(), err, scope)
+		}
+		return res
+	}
+}
+
+// requestHash identifies request by its method, path and body.
+func requestHash(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithIdempotency_tooLarge(t *testing.T) {
+	called := false
+	h := withIdempotency(&core.Core{}, func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		called = true
+		return responseNoContent()
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/license-issuers/3/licenses/import",
+		bytes.NewReader(make([]byte, maxImportSize+1)))
+	r.Header.Set("Idempotency-Key", "import-1234")
+	res := h(r, &model.LicenseIssuer{ID: 3})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.statusCode)
+	assert.False(t, called, "truncated body must not reach the handler")
+}
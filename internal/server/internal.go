@@ -7,10 +7,22 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/sewiti/licensing-system/internal/core"
 	"github.com/sewiti/licensing-system/internal/core/auth"
+	"github.com/sewiti/licensing-system/internal/model"
 )
 
-func NewRouterInternal(c *core.Core) *mux.Router {
+// NewRouterInternal creates router of the internal socket, used by CLI. Socket
+// is accessible to the server's user only, so requests are privileged.
+//
+// Resource API handlers are reused for administration, except license issuers
+// are also looked up by username.
+func NewRouterInternal(c *core.Core, build BuildInfo) http.Handler {
 	r := mux.NewRouter()
+	r.Use(routeLogMiddleware)
+	handler := func(r *mux.Router, path, method string, h apiAuthHandler) {
+		r.Path(path).Methods(method).Handler(withAPI(withCLILogin(h)))
+	}
+
+	r.Path("/server").Methods(http.MethodGet).Handler(withAPI(internalGetServerInfo(c, build)))
 
 	r.Path("/license-issuers/{LICENSE_ISSUER_USERNAME:[A-Za-z0-9_-]+}/active").
 		Methods(http.MethodPatch).Handler(withAPI(internalUpdateLicenseIssuerActive(c)))
@@ -18,7 +30,77 @@ func NewRouterInternal(c *core.Core) *mux.Router {
 	r.Path("/license-issuers/{LICENSE_ISSUER_USERNAME:[A-Za-z0-9_-]+}/change-password").
 		Methods(http.MethodPatch).Handler(withAPI(internalUpdateLicenseIssuerPassword(c)))
 
-	return r
+	handler(r, "/license-issuers", http.MethodPost, createLicenseIssuer(c))
+	handler(r, "/license-issuers", http.MethodGet, getAllLicenseIssuers(c))
+	handler(r, "/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}", http.MethodPatch, updateLicenseIssuer(c))
+	handler(r, "/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}", http.MethodDelete, deleteLicenseIssuer(c))
+	r.Path("/license-issuers/{LICENSE_ISSUER_USERNAME:[A-Za-z0-9_-]+}").
+		Methods(http.MethodGet).Handler(withAPI(internalGetLicenseIssuer(c)))
+
+	li := r.PathPrefix("/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}").Subrouter()
+	handler(li, "/licenses", http.MethodPost, createLicense(c))
+	handler(li, "/licenses", http.MethodGet, getAllLicenses(c))
+	handler(li, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}", http.MethodGet, getLicense(c))
+	handler(li, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}", http.MethodPatch, updateLicense(c))
+	handler(li, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}/sessions", http.MethodGet, getAllLicenseSessions(c))
+	handler(li, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}/sessions/{CLIENT_SESSION_ID:[A-Za-z0-9_-]{43}=}", http.MethodDelete, deleteLicenseSession(c))
+	handler(li, "/products", http.MethodPost, createProduct(c))
+	handler(li, "/products", http.MethodGet, getAllProducts(c))
+	handler(li, "/products/{PRODUCT_ID:[0-9]+}", http.MethodGet, getProduct(c))
+	handler(li, "/products/{PRODUCT_ID:[0-9]+}", http.MethodPatch, updateProduct(c))
+
+	return accessLogMiddleware(r)
+}
+
+// withCLILogin authorizes request as CLI user.
+func withCLILogin(h apiAuthHandler) apiHandler {
+	return func(r *http.Request) *apiResponse {
+		return h(r, core.CLILogin())
+	}
+}
+
+func internalGetServerInfo(c *core.Core, build BuildInfo) apiHandler {
+	type serverInfoRes struct {
+		BuildInfo
+		ServerID []byte             `json:"serverID"`
+		Stats    *model.ServerStats `json:"stats"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		const scope = "internal get server info"
+		stats, err := c.GetServerStats(r.Context())
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		return responseJson(http.StatusOK, serverInfoRes{
+			BuildInfo: build,
+			ServerID:  c.ServerID(),
+			Stats:     stats,
+		})
+	}
+}
+
+func internalGetLicenseIssuer(c *core.Core) apiHandler {
+	return func(r *http.Request) *apiResponse {
+		const scope = "internal get license issuer"
+		username, ok := mux.Vars(r)["LICENSE_ISSUER_USERNAME"]
+		if !ok {
+			return responseBadRequestf("license issuer username: missing")
+		}
+
+		li, err := c.GetLicenseIssuerByUsername(r.Context(), username)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusOK, li)
+	}
 }
 
 func internalUpdateLicenseIssuerActive(c *core.Core) apiHandler {
@@ -44,7 +126,7 @@ func internalUpdateLicenseIssuerActive(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -60,7 +142,7 @@ func internalUpdateLicenseIssuerActive(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -91,7 +173,7 @@ func internalUpdateLicenseIssuerPassword(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -106,7 +188,7 @@ func internalUpdateLicenseIssuerPassword(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrPasswdTooWeak):
 				return responseBadRequest(err)
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
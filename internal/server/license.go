@@ -1,14 +1,22 @@
 package server
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sewiti/licensing-system/internal/core"
 	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
 )
 
 func createLicense(c *core.Core) apiAuthHandler {
@@ -19,17 +27,18 @@ func createLicense(c *core.Core) apiAuthHandler {
 			return responseBadRequestf("license issuer id: %v", err)
 		}
 
+		data, err := readAllLim(r.Body)
+		if err != nil {
+			return responseBadRequest(err)
+		}
 		req := model.License{ // only a handful of fields will be used
 			Active:      true,
 			MaxSessions: 1,
 		}
-		err = jsonDecodeLim(r.Body, &req)
+		err = json.Unmarshal(data, &req)
 		if err != nil {
 			return responseBadRequest(err)
 		}
-		if req.Tags == nil {
-			req.Tags = make([]string, 0)
-		}
 
 		li, err := c.GetLicenseIssuer(r.Context(), licenseIssuerID)
 		if err != nil {
@@ -37,11 +46,16 @@ func createLicense(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 
+		resp := applyLicenseTemplate(r, c, li.ID, &req, data, scope)
+		if resp != nil {
+			return resp
+		}
+
 		l, err := c.NewLicense(r.Context(), li, &req)
 		if err != nil {
 			switch {
@@ -50,7 +64,7 @@ func createLicense(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrExceedsLimit):
 				return responseBadRequest(err)
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -58,7 +72,40 @@ func createLicense(c *core.Core) apiAuthHandler {
 	}
 }
 
+// applyLicenseTemplate applies license template of the request, fields
+// present in request data override template defaults. Template version is
+// cleared without a template. Returns nil on success.
+func applyLicenseTemplate(r *http.Request, c *core.Core, licenseIssuerID int, req *model.License, data []byte, scope string) *apiResponse {
+	changes, err := core.UnmarshalChanges(data)
+	if err != nil {
+		return responseBadRequest(err) // should never happen
+	}
+	err = c.ApplyLicenseTemplate(r.Context(), licenseIssuerID, req, changes)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidInput):
+			return responseBadRequest(err)
+		default:
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+	}
+	if req.Tags == nil {
+		req.Tags = make([]string, 0)
+	}
+	return nil
+}
+
 func getAllLicenses(c *core.Core) apiAuthHandler {
+	return listLicenses(c, false)
+}
+
+// getLicensesTrash lists deleted licenses, which can be restored.
+func getLicensesTrash(c *core.Core) apiAuthHandler {
+	return listLicenses(c, true)
+}
+
+func listLicenses(c *core.Core, trash bool) apiAuthHandler {
 	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
 		const scope = "get all licenses"
 		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
@@ -72,15 +119,68 @@ func getAllLicenses(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 
-		ll, err := c.GetAllLicensesByIssuer(r.Context(), licenseIssuerID)
+		opts, err := listOptions(r.URL.Query())
 		if err != nil {
-			logError(err, scope)
-			return responseInternalServerError()
+			return responseBadRequest(err)
+		}
+		filter, err := licenseFilter(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		filter.Deleted = trash
+
+		ll, page, err := c.GetLicensesByIssuer(r.Context(), licenseIssuerID, filter, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if ll == nil {
+			ll = make([]*model.License, 0) // Force empty array json
+		}
+		return responseList(ll, page)
+	}
+}
+
+// lookupLicenses finds licenses by full or partial key given in the key
+// query parameter.
+func lookupLicenses(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "lookup licenses"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		_, err = c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		ll, err := c.LookupLicenses(r.Context(), licenseIssuerID, r.URL.Query().Get("key"))
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
 		}
 		if ll == nil {
 			ll = make([]*model.License, 0) // Force empty array json
@@ -108,14 +208,100 @@ func getLicense(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 		if licenseIssuerID != l.IssuerID {
 			return responseNotFound()
 		}
-		return responseJson(http.StatusOK, l)
+		return responseEntity(r, licenseETag(l), l)
+	}
+}
+
+func getLicenseAddons(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get license add-ons"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		licenseID, err := pathVarKey(vars["LICENSE_ID"])
+		if err != nil {
+			return responseBadRequestf("license id: %v", err)
+		}
+
+		l, err := c.GetLicense(r.Context(), licenseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if licenseIssuerID != l.IssuerID {
+			return responseNotFound()
+		}
+
+		ll, err := c.GetLicenseAddons(r.Context(), l.ID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if ll == nil {
+			ll = make([]*model.License, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, ll)
+	}
+}
+
+func getLicenseUsage(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get license usage"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		licenseID, err := pathVarKey(vars["LICENSE_ID"])
+		if err != nil {
+			return responseBadRequestf("license id: %v", err)
+		}
+		from, err := queryTime(r.URL.Query(), "from")
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		to, err := queryTime(r.URL.Query(), "to")
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		l, err := c.GetLicense(r.Context(), licenseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if licenseIssuerID != l.IssuerID {
+			return responseNotFound()
+		}
+
+		uu, err := c.GetLicenseUsage(r.Context(), l.ID, from, to)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if uu == nil {
+			uu = make([]*model.LicenseUsage, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, uu)
 	}
 }
 
@@ -155,14 +341,33 @@ func updateLicense(c *core.Core) apiAuthHandler {
 			return responseBadRequestf("unauthorized to change field: %s", field)
 		}
 
-		err = c.UpdateLicense(r.Context(), l, changes)
+		wasActive := false
+		if _, ok := changes["active"]; ok && !l.Active {
+			prev, err := c.GetLicense(r.Context(), licenseID)
+			if err != nil {
+				switch {
+				case errors.Is(err, core.ErrNotFound):
+					return responseNotFound()
+				default:
+					logError(r.Context(), err, scope)
+					return responseInternalServerError()
+				}
+			}
+			wasActive = prev.Active
+		}
+
+		err = c.UpdateLicense(r.Context(), l, changes, ifMatch(r))
 		if err != nil {
 			// TODO
 			switch {
+			case errors.Is(err, core.ErrPreconditionFailed):
+				return responsePreconditionFailed()
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
 			case errors.Is(err, core.ErrInvalidInput):
 				return responseBadRequest(err)
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -173,11 +378,17 @@ func updateLicense(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound() // should never happen
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
-		return responseJson(http.StatusOK, l)
+		if wasActive && !l.Active {
+			err = c.NotifyLicenseDeactivated(r.Context(), l)
+			if err != nil {
+				logError(r.Context(), err, scope) // license is updated regardless
+			}
+		}
+		return responseEntity(r, licenseETag(l), l)
 	}
 }
 
@@ -198,16 +409,276 @@ func deleteLicense(c *core.Core) apiAuthHandler {
 		if !canDelete {
 			return responseForbidden()
 		}
-		err = c.DeleteLicense(r.Context(), licenseID, licenseIssuerID)
+		err = c.DeleteLicense(r.Context(), licenseID, licenseIssuerID, ifMatch(r))
 		if err != nil {
 			switch {
+			case errors.Is(err, core.ErrPreconditionFailed):
+				return responsePreconditionFailed()
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 		return responseNoContent()
 	}
 }
+
+func restoreLicense(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "restore license"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		licenseID, err := pathVarKey(vars["LICENSE_ID"])
+		if err != nil {
+			return responseBadRequestf("license id: %v", err)
+		}
+
+		_, canDelete := c.AuthorizeLicenseUpdate(login)
+		if !canDelete {
+			return responseForbidden()
+		}
+		l, err := c.RestoreLicense(r.Context(), licenseID, licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrExceedsLimit):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseEntity(r, licenseETag(l), l)
+	}
+}
+
+// maxImportSize is the maximum size of licenses import request.
+const maxImportSize = 16 * 1024 * 1024 // 16 MiB
+
+func createLicenses(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "create licenses"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		var req struct {
+			Count    int             `json:"count"`
+			Template json.RawMessage `json:"template"`
+		}
+		err = jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		tmpl := model.License{ // only a handful of fields will be used
+			Active:      true,
+			MaxSessions: 1,
+		}
+		if len(req.Template) > 0 {
+			err = json.Unmarshal(req.Template, &tmpl)
+			if err != nil {
+				return responseBadRequestf("template: %v", err)
+			}
+		}
+
+		li, err := c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		resp := applyLicenseTemplate(r, c, li.ID, &tmpl, req.Template, scope)
+		if resp != nil {
+			return resp
+		}
+
+		ll, err := c.NewLicenses(r.Context(), li, &tmpl, req.Count)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrExceedsLimit):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, ll)
+	}
+}
+
+func importLicenses(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "import licenses"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		var req []*model.License
+		err = json.NewDecoder(io.LimitReader(r.Body, maxImportSize)).Decode(&req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		li, err := c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		ll, err := c.ImportLicenses(r.Context(), li, req)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrExceedsLimit):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrDuplicate):
+				return responseConflict(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, ll)
+	}
+}
+
+func exportLicenses(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "export licenses"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		format := r.URL.Query().Get("format")
+		switch format {
+		case "":
+			format = "json"
+		case "json", "csv":
+		default:
+			return responseBadRequestf("format: expected json or csv")
+		}
+
+		_, err = c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		opts, err := listOptions(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		opts.Limit, opts.Cursor = 0, "" // export everything
+		filter, err := licenseFilter(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		ll, _, err := c.GetLicensesByIssuer(r.Context(), licenseIssuerID, filter, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		var res *apiResponse
+		switch format {
+		case "csv":
+			bs, err := licensesCSV(ll)
+			if err != nil {
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+			res = &apiResponse{
+				statusCode: http.StatusOK,
+				body:       bs,
+			}
+			res.setHeader("Content-Type", "text/csv; charset=utf-8")
+		default:
+			if ll == nil {
+				ll = make([]*model.License, 0) // Force empty array json
+			}
+			res = responseJson(http.StatusOK, ll)
+		}
+		res.setHeader("Content-Disposition", fmt.Sprintf(`attachment; filename="licenses.%s"`, format))
+		return res
+	}
+}
+
+// licensesCSV encodes licenses as CSV with a header row. Binary fields are
+// encoded in base64, tags are separated by semicolon.
+func licensesCSV(ll []*model.License) ([]byte, error) {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	err := w.Write([]string{"id", "key", "formattedKey", "active", "name", "tags", "endUserEmail", "note", "data", "maxSessions", "validUntil", "created", "updated", "lastUsed", "productID"})
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range ll {
+		var productID string
+		if l.ProductID != nil {
+			productID = strconv.Itoa(*l.ProductID)
+		}
+		err = w.Write([]string{
+			base64.StdEncoding.EncodeToString(l.ID),
+			base64.StdEncoding.EncodeToString(l.Key),
+			util.FormatKey(l.Key),
+			strconv.FormatBool(l.Active),
+			l.Name,
+			strings.Join(l.Tags, ";"),
+			l.EndUserEmail,
+			l.Note,
+			base64.StdEncoding.EncodeToString(l.Data),
+			strconv.Itoa(l.MaxSessions),
+			formatTime(l.ValidUntil),
+			formatTime(&l.Created),
+			formatTime(&l.Updated),
+			formatTime(l.LastUsed),
+			productID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	cryptorand "crypto/rand"
+
+	"github.com/apex/log"
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+// Licensing
+
+func licConsumeCredits(c *core.Core) apiHandler {
+	type consumeCreditsReq struct {
+		Data []byte `json:"data"`
+		N    []byte `json:"n"`
+	}
+	type consumeCreditsReqData struct {
+		Timestamp time.Time `json:"ts"`
+		ReceiptID []byte    `json:"id"`
+		Feature   string    `json:"feature"`
+		Amount    int64     `json:"amount"`
+	}
+	type consumeCreditsRes struct {
+		Data []byte `json:"data"`
+		N    []byte `json:"n"`
+	}
+	type consumeCreditsResData struct {
+		Timestamp time.Time `json:"ts"`
+		Receipt   []byte    `json:"receipt"`
+		Signature []byte    `json:"sig"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		const scope = "consume credits"
+		clientSessionID, err := pathVarKey(mux.Vars(r)["CLIENT_SESSION_ID"])
+		if err != nil {
+			return responseBadRequestf("client session id: %v", err)
+		}
+
+		var req consumeCreditsReq
+		err = jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		ls, err := c.GetLicenseSession(r.Context(), clientSessionID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		var reqData consumeCreditsReqData
+		err = util.OpenJsonBox(&reqData, req.Data, req.N, ls.ClientID, ls.ServerKey)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		l, err := c.GetLicense(r.Context(), ls.LicenseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		receipt, sig, err := c.ConsumeLicenseCredits(r.Context(), ls, l, reqData.Timestamp, reqData.ReceiptID, reqData.Feature, reqData.Amount)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrInsufficientCredits):
+				return responseJsonMsg(http.StatusPaymentRequired, err)
+			case errors.Is(err, core.ErrTimeOutOfSync):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseExpired):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseInactive):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseIsAddon):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrProductInactive):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseIssuerDisabled):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseSessionExpired):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrDuplicate):
+				return responseConflict(err)
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		resData := consumeCreditsResData{
+			Timestamp: time.Now(),
+			Receipt:   receipt,
+			Signature: sig,
+		}
+		nonce, err := util.GenerateNonce(cryptorand.Reader)
+		if err != nil {
+			log.FromContext(r.Context()).WithError(err).Error("generating nonce")
+			return responseInternalServerError()
+		}
+		box, err := util.SealJsonBox(resData, nonce, ls.ClientID, ls.ServerKey)
+		if err != nil {
+			log.FromContext(r.Context()).WithError(err).Error("sealing json box")
+			return responseInternalServerError()
+		}
+		return responseJson(http.StatusOK, consumeCreditsRes{
+			Data: box,
+			N:    nonce,
+		})
+	}
+}
+
+// Resource
+
+// issuerLicense returns license of the request path, which must belong to
+// the license issuer of the path. Response is returned on failure.
+func issuerLicense(r *http.Request, c *core.Core, scope string) (*model.License, *apiResponse) {
+	vars := mux.Vars(r)
+	licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+	if err != nil {
+		return nil, responseBadRequestf("license issuer id: %v", err)
+	}
+	licenseID, err := pathVarKey(vars["LICENSE_ID"])
+	if err != nil {
+		return nil, responseBadRequestf("license id: %v", err)
+	}
+
+	l, err := c.GetLicense(r.Context(), licenseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			return nil, responseNotFound()
+		default:
+			logError(r.Context(), err, scope)
+			return nil, responseInternalServerError()
+		}
+	}
+	if licenseIssuerID != l.IssuerID {
+		return nil, responseNotFound()
+	}
+	return l, nil
+}
+
+func getLicenseCredits(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get license credits"
+		l, res := issuerLicense(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		cc, err := c.GetLicenseCredits(r.Context(), l.ID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if cc == nil {
+			cc = make([]*model.LicenseCredit, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, cc)
+	}
+}
+
+func addLicenseCredits(c *core.Core) apiAuthHandler {
+	type addLicenseCreditsReq struct {
+		Feature string `json:"feature"`
+		Amount  int64  `json:"amount"`
+	}
+
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "add license credits"
+		l, res := issuerLicense(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		var req addLicenseCreditsReq
+		err := jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		lc, err := c.AddLicenseCredits(r.Context(), l.ID, req.Feature, req.Amount)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusOK, lc)
+	}
+}
+
+func getCreditReceipts(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get credit receipts"
+		l, res := issuerLicense(r, c, scope)
+		if res != nil {
+			return res
+		}
+		from, err := queryTime(r.URL.Query(), "from")
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		to, err := queryTime(r.URL.Query(), "to")
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		rr, err := c.GetCreditReceipts(r.Context(), l.ID, from, to)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if rr == nil {
+			rr = make([]*model.CreditReceipt, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, rr)
+	}
+}
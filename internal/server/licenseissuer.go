@@ -35,7 +35,7 @@ func createLicenseIssuer(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrDuplicate):
 				return responseConflict(err)
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -44,17 +44,40 @@ func createLicenseIssuer(c *core.Core) apiAuthHandler {
 }
 
 func getAllLicenseIssuers(c *core.Core) apiAuthHandler {
+	return listLicenseIssuers(c, false)
+}
+
+// getLicenseIssuersTrash lists deleted license issuers, which can be restored.
+func getLicenseIssuersTrash(c *core.Core) apiAuthHandler {
+	return listLicenseIssuers(c, true)
+}
+
+func listLicenseIssuers(c *core.Core, trash bool) apiAuthHandler {
 	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
 		const scope = "get all license issuers"
-		lii, err := c.GetAllLicenseIssuers(r.Context())
+		opts, err := listOptions(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		active, err := queryBool(r.URL.Query(), "active")
 		if err != nil {
-			logError(err, scope)
-			return responseInternalServerError()
+			return responseBadRequest(err)
+		}
+
+		lii, page, err := c.GetLicenseIssuers(r.Context(), &model.LicenseIssuerFilter{Active: active, Deleted: trash}, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
 		}
 		if lii == nil {
 			lii = make([]*model.LicenseIssuer, 0) // Force empty array json
 		}
-		return responseJson(http.StatusOK, lii)
+		return responseList(lii, page)
 	}
 }
 
@@ -72,11 +95,11 @@ func getLicenseIssuer(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
-		return responseJson(http.StatusOK, li)
+		return responseEntity(r, etag(li.Updated), li)
 	}
 }
 
@@ -110,9 +133,11 @@ func updateLicenseIssuer(c *core.Core) apiAuthHandler {
 			return responseBadRequestf("unauthorized to change field: %s", field)
 		}
 
-		err = c.UpdateLicenseIssuer(r.Context(), li, changes)
+		err = c.UpdateLicenseIssuer(r.Context(), li, changes, ifMatch(r))
 		if err != nil {
 			switch {
+			case errors.Is(err, core.ErrPreconditionFailed):
+				return responsePreconditionFailed()
 			case errors.Is(err, core.ErrSuperadminImmutable):
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrDuplicate):
@@ -122,7 +147,7 @@ func updateLicenseIssuer(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -133,11 +158,11 @@ func updateLicenseIssuer(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound() // should never happen
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
-		return responseJson(http.StatusOK, li)
+		return responseEntity(r, etag(li.Updated), li)
 	}
 }
 
@@ -153,18 +178,46 @@ func deleteLicenseIssuer(c *core.Core) apiAuthHandler {
 		if !canDelete {
 			return responseForbidden()
 		}
-		err = c.DeleteLicenseIssuer(r.Context(), licenseIssuerID)
+		err = c.DeleteLicenseIssuer(r.Context(), licenseIssuerID, ifMatch(r))
 		if err != nil {
 			switch {
+			case errors.Is(err, core.ErrPreconditionFailed):
+				return responsePreconditionFailed()
 			case errors.Is(err, core.ErrSuperadminImmutable):
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 		return responseNoContent()
 	}
 }
+
+func restoreLicenseIssuer(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "restore license issuer"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		_, canDelete := c.AuthorizeLicenseIssuerUpdate(login)
+		if !canDelete {
+			return responseForbidden()
+		}
+		li, err := c.RestoreLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseEntity(r, etag(li.Updated), li)
+	}
+}
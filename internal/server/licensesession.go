@@ -18,6 +18,30 @@ import (
 
 // Licensing
 
+// licenseAddonResData is an add-on license delivered along with its base
+// license session.
+type licenseAddonResData struct {
+	Name       string     `json:"name,omitempty"`
+	Data       []byte     `json:"data,omitempty"`
+	Features   []string   `json:"features,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+	ProductID  *int       `json:"productID,omitempty"`
+}
+
+func licenseAddonsResData(addons []*model.License) []licenseAddonResData {
+	res := make([]licenseAddonResData, len(addons))
+	for i, a := range addons {
+		res[i] = licenseAddonResData{
+			Name:       a.Name,
+			Data:       a.Data,
+			Features:   a.Features,
+			ValidUntil: a.ValidUntil,
+			ProductID:  a.ProductID,
+		}
+	}
+	return res
+}
+
 func licCreateLicenseSession(c *core.Core) apiHandler {
 	type createLicenseSessionReq struct {
 		LicenseID []byte `json:"lid"`
@@ -42,9 +66,17 @@ func licCreateLicenseSession(c *core.Core) apiHandler {
 		ExpireAfter     time.Time `json:"expire"`
 		Name            string    `json:"name,omitempty"`
 		Data            []byte    `json:"data,omitempty"`
+		Features        []string  `json:"features,omitempty"`
 		ProductID       *int      `json:"productID,omitempty"`
 		ProductName     string    `json:"productName"`
 		ProductData     []byte    `json:"productData,omitempty"`
+		EditionID       *int      `json:"editionID,omitempty"`
+		EditionName     string    `json:"editionName,omitempty"`
+		EditionData     []byte    `json:"editionData,omitempty"`
+		Channels        []string  `json:"channels,omitempty"`
+
+		Addons        []licenseAddonResData `json:"addons,omitempty"`
+		QuotaExceeded []string              `json:"quotaExceeded,omitempty"`
 	}
 
 	return func(r *http.Request) *apiResponse {
@@ -62,7 +94,7 @@ func licCreateLicenseSession(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -73,7 +105,7 @@ func licCreateLicenseSession(c *core.Core) apiHandler {
 			return responseBadRequest(err)
 		}
 
-		ls, p, refresh, err := c.NewLicenseSession(
+		ls, ent, refresh, err := c.NewLicenseSession(
 			r.Context(),
 			l,
 			data.ClientSessionID,
@@ -90,14 +122,18 @@ func licCreateLicenseSession(c *core.Core) apiHandler {
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrLicenseInactive):
 				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseIsAddon):
+				return responseForbidden(err)
 			case errors.Is(err, core.ErrProductInactive):
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrLicenseIssuerDisabled):
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrRateLimitReached):
 				return responseConflict(err)
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -109,18 +145,25 @@ func licCreateLicenseSession(c *core.Core) apiHandler {
 			Timestamp:       time.Now(),
 			Name:            l.Name,
 			Data:            l.Data,
+			Features:        core.LicenseFeatures(l, ent.Edition, ent.Addons),
 			ProductID:       l.ProductID,
-			ProductName:     p.Name,
-			ProductData:     p.Data,
+			ProductName:     ent.Product.Name,
+			ProductData:     ent.Product.Data,
+			EditionID:       l.EditionID,
+			EditionName:     ent.Edition.Name,
+			EditionData:     ent.Edition.Data,
+			Channels:        core.LicenseChannels(l, ent.Edition),
+			Addons:          licenseAddonsResData(ent.Addons),
+			QuotaExceeded:   ent.QuotaExceeded,
 		}
 		nonce, err := util.GenerateNonce(cryptorand.Reader)
 		if err != nil {
-			log.WithError(err).Error("generating nonce")
+			log.FromContext(r.Context()).WithError(err).Error("generating nonce")
 			return responseInternalServerError()
 		}
 		box, err := util.SealJsonBox(resData, nonce, ls.ClientID, c.ServerKey())
 		if err != nil {
-			log.WithError(err).Error("sealing json box")
+			log.FromContext(r.Context()).WithError(err).Error("sealing json box")
 			return responseInternalServerError()
 		}
 		return responseJson(http.StatusCreated, createLicenseSessionRes{
@@ -136,7 +179,9 @@ func licUpdateLicenseSession(c *core.Core) apiHandler {
 		N    []byte `json:"n"`
 	}
 	type updateLicenseSessionReqData struct {
-		Timestamp time.Time `json:"ts"`
+		Timestamp time.Time        `json:"ts"`
+		ReportID  []byte           `json:"reportID,omitempty"` // Required with usage.
+		Usage     map[string]int64 `json:"usage,omitempty"`
 	}
 	type updateLicenseSessionRes struct {
 		Data []byte `json:"data"`
@@ -148,9 +193,17 @@ func licUpdateLicenseSession(c *core.Core) apiHandler {
 		ExpireAfter  time.Time `json:"expire"`
 		Name         string    `json:"name,omitempty"`
 		Data         []byte    `json:"data,omitempty"`
+		Features     []string  `json:"features,omitempty"`
 		ProductID    *int      `json:"productID,omitempty"`
 		ProductName  string    `json:"productName"`
 		ProductData  []byte    `json:"productData,omitempty"`
+		EditionID    *int      `json:"editionID,omitempty"`
+		EditionName  string    `json:"editionName,omitempty"`
+		EditionData  []byte    `json:"editionData,omitempty"`
+		Channels     []string  `json:"channels,omitempty"`
+
+		Addons        []licenseAddonResData `json:"addons,omitempty"`
+		QuotaExceeded []string              `json:"quotaExceeded,omitempty"`
 	}
 
 	return func(r *http.Request) *apiResponse {
@@ -171,7 +224,7 @@ func licUpdateLicenseSession(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -187,19 +240,23 @@ func licUpdateLicenseSession(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
-		p, refresh, err := c.UpdateLicenseSession(r.Context(), ls, l, reqData.Timestamp)
+		ent, refresh, err := c.UpdateLicenseSession(r.Context(), ls, l, reqData.Timestamp, reqData.ReportID, reqData.Usage)
 		if err != nil {
 			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
 			case errors.Is(err, core.ErrTimeOutOfSync):
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrLicenseExpired):
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrLicenseInactive):
 				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseIsAddon):
+				return responseForbidden(err)
 			case errors.Is(err, core.ErrProductInactive):
 				return responseForbidden(err)
 			case errors.Is(err, core.ErrLicenseIssuerDisabled):
@@ -209,29 +266,36 @@ func licUpdateLicenseSession(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 
 		resData := updateLicenseSessionResData{
-			Timestamp:    time.Now(),
-			RefreshAfter: refresh,
-			ExpireAfter:  ls.Expire,
-			Name:         l.Name,
-			Data:         l.Data,
-			ProductID:    l.ProductID,
-			ProductName:  p.Name,
-			ProductData:  p.Data,
+			Timestamp:     time.Now(),
+			RefreshAfter:  refresh,
+			ExpireAfter:   ls.Expire,
+			Name:          l.Name,
+			Data:          l.Data,
+			Features:      core.LicenseFeatures(l, ent.Edition, ent.Addons),
+			ProductID:     l.ProductID,
+			ProductName:   ent.Product.Name,
+			ProductData:   ent.Product.Data,
+			EditionID:     l.EditionID,
+			EditionName:   ent.Edition.Name,
+			EditionData:   ent.Edition.Data,
+			Channels:      core.LicenseChannels(l, ent.Edition),
+			Addons:        licenseAddonsResData(ent.Addons),
+			QuotaExceeded: ent.QuotaExceeded,
 		}
 		nonce, err := util.GenerateNonce(cryptorand.Reader)
 		if err != nil {
-			log.WithError(err).Error("generating nonce")
+			log.FromContext(r.Context()).WithError(err).Error("generating nonce")
 			return responseInternalServerError()
 		}
 		box, err := util.SealJsonBox(resData, nonce, ls.ClientID, ls.ServerKey)
 		if err != nil {
-			log.WithError(err).Error("sealing json box")
+			log.FromContext(r.Context()).WithError(err).Error("sealing json box")
 			return responseInternalServerError()
 		}
 		return responseJson(http.StatusCreated, updateLicenseSessionRes{
@@ -247,7 +311,9 @@ func licDeleteLicenseSession(c *core.Core) apiHandler {
 		N    []byte `json:"n"`
 	}
 	type deleteLicenseSessionReqData struct {
-		Timestamp time.Time `json:"ts"`
+		Timestamp time.Time        `json:"ts"`
+		ReportID  []byte           `json:"reportID,omitempty"` // Required with usage.
+		Usage     map[string]int64 `json:"usage,omitempty"`
 	}
 
 	return func(r *http.Request) *apiResponse {
@@ -268,7 +334,7 @@ func licDeleteLicenseSession(c *core.Core) apiHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -277,14 +343,15 @@ func licDeleteLicenseSession(c *core.Core) apiHandler {
 		if err != nil {
 			return responseBadRequest(err)
 		}
-
-		err = c.DeleteLicenseSession(r.Context(), ls.ClientID)
+		err = c.CloseLicenseSession(r.Context(), ls, reqData.ReportID, reqData.Usage)
 		if err != nil {
 			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -308,20 +375,33 @@ func getAllLicenseSessions(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 
-		lss, err := c.GetAllLicenseSessionsByLicense(r.Context(), licenseID)
+		opts, err := listOptions(r.URL.Query())
 		if err != nil {
-			logError(err, scope)
-			return responseInternalServerError()
+			return responseBadRequest(err)
+		}
+		filter := &model.LicenseSessionFilter{
+			AppVersion: r.URL.Query().Get("appVersion"),
+		}
+
+		lss, page, err := c.GetLicenseSessionsByLicense(r.Context(), licenseID, filter, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
 		}
 		if lss == nil {
 			lss = make([]*model.LicenseSession, 0) // Force empty array json
 		}
-		return responseJson(http.StatusOK, lss)
+		return responseList(lss, page)
 	}
 }
 
@@ -345,7 +425,7 @@ func getLicenseSession(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -379,7 +459,7 @@ func deleteLicenseSession(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -393,7 +473,7 @@ func deleteLicenseSession(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
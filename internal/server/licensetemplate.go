@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+func createLicenseTemplate(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "create license template"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		req := model.LicenseTemplate{
+			MaxSessions: 1,
+		}
+		err = jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		if req.Tags == nil {
+			req.Tags = make([]string, 0)
+		}
+		if req.Features == nil {
+			req.Features = make([]string, 0)
+		}
+
+		li, err := c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		t, err := c.NewLicenseTemplate(r.Context(), li, &req)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, t)
+	}
+}
+
+func getAllLicenseTemplates(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all license templates"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		_, err = c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		tt, err := c.GetAllLicenseTemplatesByIssuer(r.Context(), licenseIssuerID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if tt == nil {
+			tt = make([]*model.LicenseTemplate, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, tt)
+	}
+}
+
+func getLicenseTemplate(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get license template"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		licenseTemplateID, err := strconv.Atoi(vars["LICENSE_TEMPLATE_ID"])
+		if err != nil {
+			return responseBadRequestf("license template id: %v", err)
+		}
+
+		t, err := c.GetLicenseTemplate(r.Context(), licenseTemplateID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if licenseIssuerID != t.IssuerID {
+			return responseNotFound()
+		}
+		return responseJson(http.StatusOK, t)
+	}
+}
+
+func updateLicenseTemplate(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "update license template"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		licenseTemplateID, err := strconv.Atoi(vars["LICENSE_TEMPLATE_ID"])
+		if err != nil {
+			return responseBadRequestf("license template id: %v", err)
+		}
+
+		data, err := readAllLim(r.Body)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		t := &model.LicenseTemplate{
+			ID:       licenseTemplateID,
+			IssuerID: licenseIssuerID,
+		}
+		err = json.Unmarshal(data, t)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		changes, err := core.UnmarshalChanges(data)
+		if err != nil {
+			return responseBadRequest(err) // should never happen
+		}
+		mask, _ := c.AuthorizeLicenseTemplateUpdate(login)
+		field, ok := core.ChangesInMask(changes, mask)
+		if !ok {
+			return responseBadRequestf("unauthorized to change field: %s", field)
+		}
+
+		err = c.UpdateLicenseTemplate(r.Context(), t, changes)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		t, err = c.GetLicenseTemplate(r.Context(), licenseTemplateID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if licenseIssuerID != t.IssuerID {
+			return responseNotFound()
+		}
+		return responseJson(http.StatusOK, t)
+	}
+}
+
+func deleteLicenseTemplate(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "delete license template"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		licenseTemplateID, err := strconv.Atoi(vars["LICENSE_TEMPLATE_ID"])
+		if err != nil {
+			return responseBadRequestf("license template id: %v", err)
+		}
+
+		_, canDelete := c.AuthorizeLicenseTemplateUpdate(login)
+		if !canDelete {
+			return responseForbidden()
+		}
+		err = c.DeleteLicenseTemplate(r.Context(), licenseTemplateID, licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	cryptorand "crypto/rand"
+
+	"github.com/apex/log"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+// Licensing, self-service of end users authenticated by license key.
+
+func licGetLicenseMachines(c *core.Core) apiHandler {
+	type getLicenseMachinesReq struct {
+		LicenseID []byte `json:"lid"`
+		Data      []byte `json:"data"`
+		N         []byte `json:"n"`
+	}
+	type getLicenseMachinesReqData struct {
+		Timestamp time.Time `json:"ts"`
+	}
+	type getLicenseMachinesRes struct {
+		Data []byte `json:"data"`
+		N    []byte `json:"n"`
+	}
+	type getLicenseMachinesResData struct {
+		Timestamp    time.Time               `json:"ts"`
+		Machines     []*model.LicenseMachine `json:"machines"`
+		MaxSessions  int                     `json:"maxSessions"`
+		Transfers    int                     `json:"transfers"`
+		MaxTransfers int                     `json:"maxTransfers"`
+		NextTransfer *time.Time              `json:"nextTransfer,omitempty"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		const scope = "get license machines"
+
+		var req getLicenseMachinesReq
+		err := jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		l, err := c.GetLicense(r.Context(), req.LicenseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		var reqData getLicenseMachinesReqData
+		err = util.OpenJsonBox(&reqData, req.Data, req.N, l.ID, c.ServerKey())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		machines, err := c.GetLicenseMachines(r.Context(), l, reqData.Timestamp)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrTimeOutOfSync):
+				return responseForbidden(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		status, err := c.GetLicenseTransferStatus(r.Context(), l)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+
+		resData := getLicenseMachinesResData{
+			Timestamp:    time.Now(),
+			Machines:     machines,
+			MaxSessions:  l.MaxSessions,
+			Transfers:    status.Transfers,
+			MaxTransfers: status.MaxTransfers,
+			NextTransfer: status.NextTransfer,
+		}
+		nonce, err := util.GenerateNonce(cryptorand.Reader)
+		if err != nil {
+			log.FromContext(r.Context()).WithError(err).Error("generating nonce")
+			return responseInternalServerError()
+		}
+		box, err := util.SealJsonBox(resData, nonce, l.ID, c.ServerKey())
+		if err != nil {
+			log.FromContext(r.Context()).WithError(err).Error("sealing json box")
+			return responseInternalServerError()
+		}
+		return responseJson(http.StatusOK, getLicenseMachinesRes{
+			Data: box,
+			N:    nonce,
+		})
+	}
+}
+
+func licDeactivateLicenseMachine(c *core.Core) apiHandler {
+	type deactivateLicenseMachineReq struct {
+		LicenseID []byte `json:"lid"`
+		Data      []byte `json:"data"`
+		N         []byte `json:"n"`
+	}
+	type deactivateLicenseMachineReqData struct {
+		Timestamp time.Time `json:"ts"`
+		MachineID []byte    `json:"machineID"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		const scope = "deactivate license machine"
+
+		var req deactivateLicenseMachineReq
+		err := jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		l, err := c.GetLicense(r.Context(), req.LicenseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		var reqData deactivateLicenseMachineReqData
+		err = util.OpenJsonBox(&reqData, req.Data, req.N, l.ID, c.ServerKey())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		err = c.DeactivateMachine(r.Context(), l, reqData.MachineID, reqData.Timestamp)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrTimeOutOfSync):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrTransferDisabled):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrTransferLimitReached):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrTransferCooldown):
+				return responseConflict(err)
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
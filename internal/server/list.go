@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listOptions parses pagination, sorting and search query parameters:
+//   - limit:  maximum number of items in a page, up to maxListLimit,
+//     defaultListLimit if only cursor is given.
+//   - cursor: next page cursor, returned in X-Next-Cursor header.
+//   - sort:   sort key, prefixed with "-" for descending order.
+//   - q:      full-text search query.
+//
+// Listing without limit and cursor isn't paginated and returns all items.
+func listOptions(q url.Values) (*model.ListOptions, error) {
+	opts := &model.ListOptions{
+		Cursor: q.Get("cursor"),
+		Search: q.Get("q"),
+	}
+	if opts.Cursor != "" {
+		opts.Limit = defaultListLimit
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit: must be a positive integer")
+		}
+		if limit > maxListLimit {
+			limit = maxListLimit
+		}
+		opts.Limit = limit
+	}
+	if v := q.Get("sort"); v != "" {
+		opts.Sort = strings.TrimPrefix(v, "-")
+		opts.Desc = strings.HasPrefix(v, "-")
+	}
+	return opts, nil
+}
+
+func queryBool(q url.Values, key string) (*bool, error) {
+	v := q.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	return &b, nil
+}
+
+func queryInt(q url.Values, key string) (*int, error) {
+	v := q.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	return &i, nil
+}
+
+func queryTime(q url.Values, key string) (*time.Time, error) {
+	v := q.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	return &t, nil
+}
+
+func licenseFilter(q url.Values) (*model.LicenseFilter, error) {
+	var err error
+	f := &model.LicenseFilter{
+		Tag:          q.Get("tag"),
+		EndUserEmail: q.Get("endUserEmail"),
+	}
+	if f.Active, err = queryBool(q, "active"); err != nil {
+		return nil, err
+	}
+	if f.ProductID, err = queryInt(q, "productID"); err != nil {
+		return nil, err
+	}
+	if f.CustomerID, err = queryInt(q, "customerID"); err != nil {
+		return nil, err
+	}
+	if f.ExpiringBefore, err = queryTime(q, "expiringBefore"); err != nil {
+		return nil, err
+	}
+	if f.LastUsedBefore, err = queryTime(q, "lastUsedBefore"); err != nil {
+		return nil, err
+	}
+	if f.LastUsedAfter, err = queryTime(q, "lastUsedAfter"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// responseList responds with items array. Total count and next page cursor
+// are set in X-Total-Count and X-Next-Cursor headers.
+func responseList(items interface{}, page *model.Page) *apiResponse {
+	res := responseJson(http.StatusOK, items)
+	if page == nil {
+		return res
+	}
+	res.setHeader("X-Total-Count", strconv.Itoa(page.Total))
+	if page.Next != "" {
+		res.setHeader("X-Next-Cursor", page.Next)
+	}
+	return res
+}
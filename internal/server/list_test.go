@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListOptions_limit(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 0},
+		{"sort=name", 0},
+		{"cursor=abc", defaultListLimit},
+		{"limit=5", 5},
+		{"limit=5000", maxListLimit},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		opts, err := listOptions(q)
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.want, opts.Limit, tt.query)
+	}
+
+	for _, query := range []string{"limit=0", "limit=-1", "limit=x"} {
+		q, _ := url.ParseQuery(query)
+		_, err := listOptions(q)
+		assert.Error(t, err, query)
+	}
+}
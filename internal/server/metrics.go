@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/metrics"
+)
+
+// statusRecorder records response status code.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// metricsMiddleware records HTTP requests count and latency per route.
+func metricsMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if cr := mux.CurrentRoute(r); cr != nil {
+			tmpl, err := cr.GetPathTemplate()
+			if err == nil {
+				route = tmpl
+			}
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		metrics.ObserveHTTPRequest(route, r.Method, rec.statusCode, time.Since(start))
+	})
+}
+
+// NewRouterMonitoring returns router serving health, readiness and version
+// endpoints, and metrics if conf.ServeMetrics, used for a separate monitoring
+// listener. API options of conf are ignored.
+func NewRouterMonitoring(c *core.Core, conf RouterConf) http.Handler {
+	r := mux.NewRouter()
+	r.Use(routeLogMiddleware)
+	if conf.CORS != nil {
+		r.Use(corsOriginMiddleware(conf.CORS, func(conf CORSConf) bool { return conf.Monitoring }))
+	}
+	if conf.ServeMetrics {
+		r.Path("/metrics").Methods(http.MethodGet).Handler(metrics.Handler())
+	}
+	handleHealth(r, c, conf.Build)
+	return accessLogMiddleware(r)
+}
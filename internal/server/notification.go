@@ -0,0 +1,333 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// Licensing
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>{{.Email}} is unsubscribed from the notifications.</p>
+{{else}}<form method="post" action="?token={{.Token}}">
+<p>Unsubscribe {{.Email}} from the notifications?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// responseUnsubscribePage responds with unsubscribe confirmation page, or with
+// its result if done.
+func responseUnsubscribePage(email, token string, done bool) *apiResponse {
+	var buf bytes.Buffer
+	err := unsubscribePage.Execute(&buf, struct {
+		Email string
+		Token string
+		Done  bool
+	}{
+		Email: email,
+		Token: token,
+		Done:  done,
+	})
+	if err != nil {
+		log.WithError(err).Error("rendering unsubscribe page")
+		return responseInternalServerError()
+	}
+	res := &apiResponse{
+		statusCode: http.StatusOK,
+		body:       buf.Bytes(),
+	}
+	res.setHeader("Content-Type", "text/html; charset=utf-8")
+	return res
+}
+
+// licUnsubscribeConfirm shows confirmation page of unsubscribe link in the
+// notification emails. Link scanners and prefetchers following it don't opt
+// recipient out.
+func licUnsubscribeConfirm(c *core.Core) apiHandler {
+	return func(r *http.Request) *apiResponse {
+		token := r.URL.Query().Get("token")
+		email, err := c.UnsubscribeRecipient(token)
+		if err != nil {
+			return responseForbidden(err)
+		}
+		return responseUnsubscribePage(email, token, false)
+	}
+}
+
+// licUnsubscribe opts recipient out of issuer's notifications. It's posted by
+// the confirmation page and by email clients supporting one-click unsubscribe
+// (RFC 8058), which post form encoded body to the List-Unsubscribe URL.
+func licUnsubscribe(c *core.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const scope = "unsubscribe"
+		token := r.URL.Query().Get("token")
+		o, err := c.Unsubscribe(r.Context(), token)
+		var res *apiResponse
+		switch {
+		case err == nil:
+			res = responseUnsubscribePage(o.Email, token, true)
+		case errors.Is(err, core.ErrInvalidInput):
+			res = responseForbidden(err)
+		default:
+			logError(r.Context(), err, scope)
+			res = responseInternalServerError()
+		}
+		res.Write(w)
+	})
+}
+
+// Resource API
+
+// pathLicenseIssuer returns license issuer of the request path. Response is
+// returned on failure.
+func pathLicenseIssuer(r *http.Request, c *core.Core, scope string) (*model.LicenseIssuer, *apiResponse) {
+	licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+	if err != nil {
+		return nil, responseBadRequestf("license issuer id: %v", err)
+	}
+	li, err := c.GetLicenseIssuer(r.Context(), licenseIssuerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			return nil, responseNotFound()
+		default:
+			logError(r.Context(), err, scope)
+			return nil, responseInternalServerError()
+		}
+	}
+	return li, nil
+}
+
+// deliverLicense queues license key delivery to the end user of the license.
+func deliverLicense(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "deliver license"
+		l, res := issuerLicense(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		nn, err := c.NotifyLicenseDelivery(r.Context(), l)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrNotificationsDisabled):
+				return responseConflict(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if nn == nil {
+			nn = make([]*model.Notification, 0) // Force empty array json
+		}
+		return responseJson(http.StatusAccepted, nn)
+	}
+}
+
+func getAllNotifications(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all notifications"
+		li, res := pathLicenseIssuer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		opts, err := listOptions(r.URL.Query())
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		nn, page, err := c.GetNotificationsByIssuer(r.Context(), li.ID, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if nn == nil {
+			nn = make([]*model.Notification, 0) // Force empty array json
+		}
+		return responseList(nn, page)
+	}
+}
+
+func getAllNotificationTemplates(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all notification templates"
+		li, res := pathLicenseIssuer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		tt, err := c.GetNotificationTemplates(r.Context(), li.ID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		return responseJson(http.StatusOK, tt)
+	}
+}
+
+func getNotificationTemplate(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get notification template"
+		li, res := pathLicenseIssuer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		nt, err := c.GetNotificationTemplate(r.Context(), li.ID, mux.Vars(r)["KIND"])
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusOK, nt)
+	}
+}
+
+func setNotificationTemplate(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "set notification template"
+		li, res := pathLicenseIssuer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		var req model.NotificationTemplate
+		err := jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		nt, err := c.SetNotificationTemplate(r.Context(), li, mux.Vars(r)["KIND"], &req)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusOK, nt)
+	}
+}
+
+// resetNotificationTemplate deletes issuer's template, default one is used
+// instead.
+func resetNotificationTemplate(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "reset notification template"
+		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		err = c.ResetNotificationTemplate(r.Context(), licenseIssuerID, mux.Vars(r)["KIND"])
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
+
+func getAllNotificationOptOuts(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all notification opt-outs"
+		li, res := pathLicenseIssuer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		oo, err := c.GetNotificationOptOuts(r.Context(), li.ID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if oo == nil {
+			oo = make([]*model.NotificationOptOut, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, oo)
+	}
+}
+
+func createNotificationOptOut(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "create notification opt-out"
+		li, res := pathLicenseIssuer(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		var req model.NotificationOptOut
+		err := jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		o, err := c.OptOutNotifications(r.Context(), li.ID, req.Email)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, o)
+	}
+}
+
+func deleteNotificationOptOut(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "delete notification opt-out"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+
+		err = c.DeleteNotificationOptOut(r.Context(), licenseIssuerID, vars["EMAIL"])
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseUnsubscribePage(t *testing.T) {
+	res := responseUnsubscribePage("<user@example.com>", "abc.def", false)
+	require.Equal(t, http.StatusOK, res.statusCode)
+	assert.Equal(t, "text/html; charset=utf-8", res.header.Get("Content-Type"))
+	body := string(res.body)
+	assert.Contains(t, body, `<form method="post" action="?token=abc.def">`)
+	assert.Contains(t, body, "&lt;user@example.com&gt;")
+	assert.NotContains(t, body, "is unsubscribed")
+
+	res = responseUnsubscribePage("user@example.com", "abc.def", true)
+	assert.Contains(t, string(res.body), "user@example.com is unsubscribed")
+	assert.NotContains(t, string(res.body), "<form")
+}
+
+func TestLicUnsubscribe_oneClick(t *testing.T) {
+	discardLog(t)
+	h := NewRouter(&core.Core{}, RouterConf{LicensingAPI: true})
+
+	// One-click unsubscribe posts form encoded body, token is invalid, so that
+	// core isn't reached.
+	r := httptest.NewRequest(http.MethodPost, "/api/notifications/unsubscribe?token=invalid",
+		strings.NewReader("List-Unsubscribe=One-Click"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
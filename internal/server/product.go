@@ -34,7 +34,7 @@ func createProduct(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -45,7 +45,7 @@ func createProduct(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrInvalidInput):
 				return responseBadRequest(err)
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -54,6 +54,15 @@ func createProduct(c *core.Core) apiAuthHandler {
 }
 
 func getAllProducts(c *core.Core) apiAuthHandler {
+	return listProducts(c, false)
+}
+
+// getProductsTrash lists deleted products, which can be restored.
+func getProductsTrash(c *core.Core) apiAuthHandler {
+	return listProducts(c, true)
+}
+
+func listProducts(c *core.Core, trash bool) apiAuthHandler {
 	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
 		const scope = "get all products"
 		licenseIssuerID, err := strconv.Atoi(mux.Vars(r)["LICENSE_ISSUER_ID"])
@@ -67,20 +76,34 @@ func getAllProducts(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 
-		pp, err := c.GetAllProductsByIssuer(r.Context(), licenseIssuerID)
+		opts, err := listOptions(r.URL.Query())
 		if err != nil {
-			logError(err, scope)
-			return responseInternalServerError()
+			return responseBadRequest(err)
+		}
+		active, err := queryBool(r.URL.Query(), "active")
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		pp, page, err := c.GetProductsByIssuer(r.Context(), licenseIssuerID, &model.ProductFilter{Active: active, Deleted: trash}, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
 		}
 		if pp == nil {
 			pp = make([]*model.Product, 0)
 		}
-		return responseJson(http.StatusOK, pp)
+		return responseList(pp, page)
 	}
 }
 
@@ -103,14 +126,14 @@ func getProduct(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 		if licenseIssuerID != p.IssuerID {
 			return responseNotFound()
 		}
-		return responseJson(http.StatusOK, p)
+		return responseEntity(r, etag(p.Updated), p)
 	}
 }
 
@@ -150,13 +173,17 @@ func updateProduct(c *core.Core) apiAuthHandler {
 			return responseBadRequestf("unauthorized to change field: %s", field)
 		}
 
-		err = c.UpdateProduct(r.Context(), p, changes)
+		err = c.UpdateProduct(r.Context(), p, changes, ifMatch(r))
 		if err != nil {
 			switch {
+			case errors.Is(err, core.ErrPreconditionFailed):
+				return responsePreconditionFailed()
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
 			case errors.Is(err, core.ErrInvalidInput):
 				return responseBadRequest(err)
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
@@ -167,11 +194,33 @@ func updateProduct(c *core.Core) apiAuthHandler {
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
-		return responseJson(http.StatusOK, p)
+		return responseEntity(r, etag(p.Updated), p)
+	}
+}
+
+// getNonconformingLicenses lists product's licenses, which data doesn't
+// conform to the current data schema of the product.
+func getNonconformingLicenses(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get nonconforming licenses"
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		reports, err := c.CheckProductLicensesData(r.Context(), p)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if reports == nil {
+			reports = make([]*core.LicenseDataReport, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, reports)
 	}
 }
 
@@ -192,16 +241,51 @@ func deleteProduct(c *core.Core) apiAuthHandler {
 		if !canDelete {
 			return responseForbidden()
 		}
-		err = c.DeleteProduct(r.Context(), productID, licenseIssuerID)
+		err = c.DeleteProduct(r.Context(), productID, licenseIssuerID, ifMatch(r))
 		if err != nil {
 			switch {
+			case errors.Is(err, core.ErrPreconditionFailed):
+				return responsePreconditionFailed()
 			case errors.Is(err, core.ErrNotFound):
 				return responseNotFound()
 			default:
-				logError(err, scope)
+				logError(r.Context(), err, scope)
 				return responseInternalServerError()
 			}
 		}
 		return responseNoContent()
 	}
 }
+
+func restoreProduct(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "restore product"
+		vars := mux.Vars(r)
+		licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+		if err != nil {
+			return responseBadRequestf("license issuer id: %v", err)
+		}
+		productID, err := strconv.Atoi(vars["PRODUCT_ID"])
+		if err != nil {
+			return responseBadRequestf("product id: %v", err)
+		}
+
+		_, canDelete := c.AuthorizeProductUpdate(login)
+		if !canDelete {
+			return responseForbidden()
+		}
+		p, err := c.RestoreProduct(r.Context(), productID, licenseIssuerID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrExceedsLimit):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseEntity(r, etag(p.Updated), p)
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+)
+
+// issuerProduct returns product of the request path, which must belong to
+// the license issuer of the path. Response is returned on failure.
+func issuerProduct(r *http.Request, c *core.Core, scope string) (*model.Product, *apiResponse) {
+	vars := mux.Vars(r)
+	licenseIssuerID, err := strconv.Atoi(vars["LICENSE_ISSUER_ID"])
+	if err != nil {
+		return nil, responseBadRequestf("license issuer id: %v", err)
+	}
+	productID, err := strconv.Atoi(vars["PRODUCT_ID"])
+	if err != nil {
+		return nil, responseBadRequestf("product id: %v", err)
+	}
+
+	p, err := c.GetProduct(r.Context(), productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			return nil, responseNotFound()
+		default:
+			logError(r.Context(), err, scope)
+			return nil, responseInternalServerError()
+		}
+	}
+	if licenseIssuerID != p.IssuerID {
+		return nil, responseNotFound()
+	}
+	return p, nil
+}
+
+func createProductEdition(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "create product edition"
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		var req model.ProductEdition
+		err := jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		if req.Features == nil {
+			req.Features = make([]string, 0)
+		}
+		if req.Channels == nil {
+			req.Channels = make([]string, 0)
+		}
+
+		e, err := c.NewProductEdition(r.Context(), p, &req)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, e)
+	}
+}
+
+func getAllProductEditions(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all product editions"
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		ee, err := c.GetAllProductEditions(r.Context(), p.ID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if ee == nil {
+			ee = make([]*model.ProductEdition, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, ee)
+	}
+}
+
+func getProductEdition(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get product edition"
+		productEditionID, err := strconv.Atoi(mux.Vars(r)["PRODUCT_EDITION_ID"])
+		if err != nil {
+			return responseBadRequestf("product edition id: %v", err)
+		}
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		e, err := c.GetProductEdition(r.Context(), productEditionID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if p.ID != e.ProductID {
+			return responseNotFound()
+		}
+		return responseJson(http.StatusOK, e)
+	}
+}
+
+func updateProductEdition(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "update product edition"
+		productEditionID, err := strconv.Atoi(mux.Vars(r)["PRODUCT_EDITION_ID"])
+		if err != nil {
+			return responseBadRequestf("product edition id: %v", err)
+		}
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		data, err := readAllLim(r.Body)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		e := &model.ProductEdition{
+			ID:        productEditionID,
+			ProductID: p.ID,
+		}
+		err = json.Unmarshal(data, e)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		changes, err := core.UnmarshalChanges(data)
+		if err != nil {
+			return responseBadRequest(err) // should never happen
+		}
+		mask, _ := c.AuthorizeProductEditionUpdate(login)
+		field, ok := core.ChangesInMask(changes, mask)
+		if !ok {
+			return responseBadRequestf("unauthorized to change field: %s", field)
+		}
+
+		err = c.UpdateProductEdition(r.Context(), e, changes)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		e, err = c.GetProductEdition(r.Context(), productEditionID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if p.ID != e.ProductID {
+			return responseNotFound()
+		}
+		return responseJson(http.StatusOK, e)
+	}
+}
+
+func deleteProductEdition(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "delete product edition"
+		productEditionID, err := strconv.Atoi(mux.Vars(r)["PRODUCT_EDITION_ID"])
+		if err != nil {
+			return responseBadRequestf("product edition id: %v", err)
+		}
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		_, canDelete := c.AuthorizeProductEditionUpdate(login)
+		if !canDelete {
+			return responseForbidden()
+		}
+		err = c.DeleteProductEdition(r.Context(), productEditionID, p.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	cryptorand "crypto/rand"
+
+	"github.com/apex/log"
+	"github.com/gorilla/mux"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/model"
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+// Licensing
+
+func licGetReleaseManifest(c *core.Core) apiHandler {
+	type getReleaseManifestReq struct {
+		Data []byte `json:"data"`
+		N    []byte `json:"n"`
+	}
+	type getReleaseManifestReqData struct {
+		Timestamp time.Time `json:"ts"`
+	}
+	type getReleaseManifestRes struct {
+		Data []byte `json:"data"`
+		N    []byte `json:"n"`
+	}
+	type getReleaseManifestResData struct {
+		Timestamp time.Time `json:"ts"`
+		Manifest  []byte    `json:"manifest"`
+		Signature []byte    `json:"sig"`
+	}
+
+	return func(r *http.Request) *apiResponse {
+		const scope = "get release manifest"
+		clientSessionID, err := pathVarKey(mux.Vars(r)["CLIENT_SESSION_ID"])
+		if err != nil {
+			return responseBadRequestf("client session id: %v", err)
+		}
+
+		var req getReleaseManifestReq
+		err = jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		ls, err := c.GetLicenseSession(r.Context(), clientSessionID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		var reqData getReleaseManifestReqData
+		err = util.OpenJsonBox(&reqData, req.Data, req.N, ls.ClientID, ls.ServerKey)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		l, err := c.GetLicense(r.Context(), ls.LicenseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		manifest, sig, err := c.ReleaseManifest(r.Context(), ls, l, reqData.Timestamp)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrTimeOutOfSync):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseExpired):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseInactive):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseIsAddon):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrProductInactive):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseIssuerDisabled):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrLicenseSessionExpired):
+				return responseForbidden(err)
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		resData := getReleaseManifestResData{
+			Timestamp: time.Now(),
+			Manifest:  manifest,
+			Signature: sig,
+		}
+		nonce, err := util.GenerateNonce(cryptorand.Reader)
+		if err != nil {
+			log.FromContext(r.Context()).WithError(err).Error("generating nonce")
+			return responseInternalServerError()
+		}
+		box, err := util.SealJsonBox(resData, nonce, ls.ClientID, ls.ServerKey)
+		if err != nil {
+			log.FromContext(r.Context()).WithError(err).Error("sealing json box")
+			return responseInternalServerError()
+		}
+		return responseJson(http.StatusOK, getReleaseManifestRes{
+			Data: box,
+			N:    nonce,
+		})
+	}
+}
+
+// licDownloadReleaseArtifact serves release artifact granted by the download
+// token of a release manifest. Supports range requests.
+func licDownloadReleaseArtifact(c *core.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const scope = "download release artifact"
+		a, f, err := c.OpenReleaseArtifact(r.Context(), mux.Vars(r)["TOKEN"])
+		if err != nil {
+			var res *apiResponse
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				res = responseForbidden(err)
+			case errors.Is(err, core.ErrDownloadExpired):
+				res = responseForbidden(err)
+			case errors.Is(err, core.ErrReleasesDisabled):
+				res = responseNotFound()
+			case errors.Is(err, core.ErrNotFound):
+				res = responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				res = responseInternalServerError()
+			}
+			res.Write(w)
+			return
+		}
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil {
+			logError(r.Context(), err, scope)
+			responseInternalServerError().Write(w)
+			return
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+		http.ServeContent(w, r, a.Name, fi.ModTime(), f)
+	})
+}
+
+// Resource
+
+func createProductRelease(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "create product release"
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		var req model.ProductRelease
+		err := jsonDecodeLim(r.Body, &req)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		if req.EditionIDs == nil {
+			req.EditionIDs = make([]int64, 0)
+		}
+
+		pr, err := c.NewProductRelease(r.Context(), p, &req)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrReleasesDisabled):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrDuplicate):
+				return responseConflict(err)
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseJson(http.StatusCreated, pr)
+	}
+}
+
+func getAllProductReleases(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get all product releases"
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		rr, err := c.GetAllProductReleases(r.Context(), p.ID)
+		if err != nil {
+			logError(r.Context(), err, scope)
+			return responseInternalServerError()
+		}
+		if rr == nil {
+			rr = make([]*model.ProductRelease, 0) // Force empty array json
+		}
+		return responseJson(http.StatusOK, rr)
+	}
+}
+
+func getProductRelease(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "get product release"
+		productReleaseID, err := strconv.Atoi(mux.Vars(r)["PRODUCT_RELEASE_ID"])
+		if err != nil {
+			return responseBadRequestf("product release id: %v", err)
+		}
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		pr, err := c.GetProductRelease(r.Context(), productReleaseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if p.ID != pr.ProductID {
+			return responseNotFound()
+		}
+		return responseJson(http.StatusOK, pr)
+	}
+}
+
+func updateProductRelease(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "update product release"
+		productReleaseID, err := strconv.Atoi(mux.Vars(r)["PRODUCT_RELEASE_ID"])
+		if err != nil {
+			return responseBadRequestf("product release id: %v", err)
+		}
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		data, err := readAllLim(r.Body)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+		pr := &model.ProductRelease{
+			ID:        productReleaseID,
+			ProductID: p.ID,
+		}
+		err = json.Unmarshal(data, pr)
+		if err != nil {
+			return responseBadRequest(err)
+		}
+
+		changes, err := core.UnmarshalChanges(data)
+		if err != nil {
+			return responseBadRequest(err) // should never happen
+		}
+		mask, _ := c.AuthorizeProductReleaseUpdate(login)
+		field, ok := core.ChangesInMask(changes, mask)
+		if !ok {
+			return responseBadRequestf("unauthorized to change field: %s", field)
+		}
+
+		err = c.UpdateProductRelease(r.Context(), pr, changes)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrInvalidInput):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrReleasesDisabled):
+				return responseBadRequest(err)
+			case errors.Is(err, core.ErrDuplicate):
+				return responseConflict(err)
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+
+		pr, err = c.GetProductRelease(r.Context(), productReleaseID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		if p.ID != pr.ProductID {
+			return responseNotFound()
+		}
+		return responseJson(http.StatusOK, pr)
+	}
+}
+
+func deleteProductRelease(c *core.Core) apiAuthHandler {
+	return func(r *http.Request, login *model.LicenseIssuer) *apiResponse {
+		const scope = "delete product release"
+		productReleaseID, err := strconv.Atoi(mux.Vars(r)["PRODUCT_RELEASE_ID"])
+		if err != nil {
+			return responseBadRequestf("product release id: %v", err)
+		}
+		p, res := issuerProduct(r, c, scope)
+		if res != nil {
+			return res
+		}
+
+		_, canDelete := c.AuthorizeProductReleaseUpdate(login)
+		if !canDelete {
+			return responseForbidden()
+		}
+		err = c.DeleteProductRelease(r.Context(), productReleaseID, p.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, core.ErrNotFound):
+				return responseNotFound()
+			default:
+				logError(r.Context(), err, scope)
+				return responseInternalServerError()
+			}
+		}
+		return responseNoContent()
+	}
+}
@@ -12,12 +12,21 @@ import (
 	"strings"
 
 	"github.com/apex/log"
+	"github.com/sewiti/licensing-system/internal/core"
 )
 
 type apiResponse struct {
 	statusCode int
 	json       bool
 	body       []byte
+	header     http.Header
+}
+
+func (res *apiResponse) setHeader(key, value string) {
+	if res.header == nil {
+		res.header = make(http.Header)
+	}
+	res.header.Set(key, value)
 }
 
 func (res *apiResponse) Write(w http.ResponseWriter) error {
@@ -25,9 +34,14 @@ func (res *apiResponse) Write(w http.ResponseWriter) error {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
-	if res.json {
+	for k, v := range res.header {
+		w.Header()[k] = v
+	}
+	switch {
+	case res.header.Get("Content-Type") != "":
+	case res.json:
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	} else {
+	default:
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	}
 	w.WriteHeader(res.statusCode)
@@ -72,10 +86,25 @@ func responseNoContent() *apiResponse {
 	}
 }
 
+type dataErrorResponse struct {
+	Message string            `json:"message"`
+	Errors  []core.FieldError `json:"errors"`
+}
+
+// responseBadRequest responds with the message. Data schema errors
+// additionally list the errors of each field.
 func responseBadRequest(a ...interface{}) *apiResponse {
 	if len(a) == 0 {
 		return responseJsonMsg(http.StatusBadRequest, "400 Bad Request")
 	}
+	var dErr *core.DataError
+	if err, ok := a[0].(error); ok && len(a) == 1 && errors.As(err, &dErr) {
+		return responseJson(http.StatusBadRequest,
+			dataErrorResponse{
+				Message: err.Error(),
+				Errors:  dErr.Fields,
+			})
+	}
 	return responseJsonMsg(http.StatusBadRequest, a...)
 }
 
@@ -113,6 +142,10 @@ func responseConflict(a ...interface{}) *apiResponse {
 // 	return responseJsonMsgf(http.StatusConflict, format, a...)
 // }
 
+func responsePreconditionFailed() *apiResponse {
+	return responseJsonMsg(http.StatusPreconditionFailed, "412 Precondition Failed")
+}
+
 func responseInternalServerError() *apiResponse {
 	return &apiResponse{
 		statusCode: http.StatusInternalServerError,
@@ -145,7 +178,7 @@ func spaHandler(public fs.FS, root string) http.Handler {
 		p := path.Clean(r.URL.Path)
 		f, err := open(p)
 		if err != nil {
-			log.WithError(err).WithField("path", p).Error("serving spa, opening file")
+			log.FromContext(r.Context()).WithError(err).WithField("path", p).Error("serving spa, opening file")
 			responseInternalServerError().Write(w)
 			return
 		}
@@ -160,7 +193,7 @@ func spaHandler(public fs.FS, root string) http.Handler {
 		}
 		_, err = io.Copy(w, f)
 		if err != nil {
-			log.WithError(err).WithField("path", p).Error("serving spa")
+			log.FromContext(r.Context()).WithError(err).WithField("path", p).Error("serving spa")
 		}
 	})
 }
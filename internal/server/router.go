@@ -8,31 +8,60 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/sewiti/licensing-system/internal/metrics"
 )
 
 //go:embed public/*
 var publicDir embed.FS
 
-func NewRouter(c *core.Core, resourceApiCors, licensingCors bool, allowedOrigins []string) *mux.Router {
-	corsOriginMiddleware := corsOriginMiddleware(allowedOrigins)
-	corsHandler := corsOriginMiddleware(corsHandler{
-		headers: []string{"Authorization", "Content-Type"},
-		methods: []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete, http.MethodOptions},
-	})
-
-	resourceHandler := func(r *mux.Router, path, method string, h http.Handler) {
-		if resourceApiCors {
-			h = corsOriginMiddleware(h)
-			r.Path(path).Methods(http.MethodOptions).Handler(corsHandler)
+// RouterConf defines main router options.
+type RouterConf struct {
+	CORS *CORS // Nil disables CORS.
+
+	LicensingAPI bool // Serve licensing API.
+	ResourceAPI  bool // Serve resource API and single page app.
+
+	Metrics      bool // Collect HTTP requests metrics.
+	ServeMetrics bool // Serve metrics at /metrics.
+
+	Build BuildInfo
+}
+
+func NewRouter(c *core.Core, conf RouterConf) http.Handler {
+	cors := conf.CORS
+	if cors == nil {
+		cors = NewCORS(CORSConf{})
+	}
+	resourceApiCors := func(conf CORSConf) bool { return conf.ResourceApi }
+	licensingCors := func(conf CORSConf) bool { return conf.LicensingApi }
+	corsHeaders := []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "Idempotency-Key", requestIDHeader}
+	corsMethods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+	// CORS routes are always registered, as CORS can be enabled while serving.
+	corsRoutes := func(enabled func(conf CORSConf) bool) func(r *mux.Router, path, method string, h http.Handler) {
+		originMiddleware := corsOriginMiddleware(cors, enabled)
+		preflight := originMiddleware(corsHandler{
+			cors:    cors,
+			enabled: enabled,
+			headers: corsHeaders,
+			methods: corsMethods,
+		})
+		return func(r *mux.Router, path, method string, h http.Handler) {
+			r.Path(path).Methods(http.MethodOptions).Handler(preflight)
+			r.Path(path).Methods(method).Handler(originMiddleware(h))
 		}
-		r.Path(path).Methods(method).Handler(h)
 	}
+	resourceHandler := corsRoutes(resourceApiCors)
+	licensingRoutes := corsRoutes(licensingCors)
 	licensingHandler := func(r *mux.Router, path, method string, h http.Handler) {
-		if licensingCors {
-			h = corsOriginMiddleware(h)
-			r.Path(path).Methods(http.MethodOptions).Handler(corsHandler)
-		}
-		r.Path(path).Methods(method).Handler(h)
+		licensingRoutes(r, path, method, withRedactedLog(h))
+	}
+	skip := func(r *mux.Router, path, method string, h http.Handler) {}
+	if !conf.ResourceAPI {
+		resourceHandler = skip
+	}
+	if !conf.LicensingAPI {
+		licensingHandler = skip
 	}
 
 	withAPIAuthorized := func(h apiAuthHandler) http.Handler {
@@ -40,34 +69,106 @@ func NewRouter(c *core.Core, resourceApiCors, licensingCors bool, allowedOrigins
 	}
 
 	r := mux.NewRouter()
+	r.Use(routeLogMiddleware)
+	if conf.Metrics {
+		r.Use(metricsMiddleware)
+		if conf.ServeMetrics {
+			r.Path("/metrics").Methods(http.MethodGet).Handler(metrics.Handler())
+		}
+	}
+	if conf.ResourceAPI {
+		// Not exposed on licensing API only listener.
+		handleHealth(r, c, conf.Build)
+	}
 	api := r.PathPrefix("/api").Subrouter()
 
 	// Licensing API
 	licensingHandler(api, "/license-sessions", http.MethodPost, withAPI(licCreateLicenseSession(c)))
+	licensingHandler(api, "/license-sessions/machines", http.MethodPost, withAPI(licGetLicenseMachines(c)))
+	licensingHandler(api, "/license-sessions/deactivate", http.MethodPost, withAPI(licDeactivateLicenseMachine(c)))
 	licensingHandler(api, "/license-sessions/{CLIENT_SESSION_ID:[A-Za-z0-9_-]{43}=}", http.MethodPatch, withAPI(licUpdateLicenseSession(c)))
 	licensingHandler(api, "/license-sessions/{CLIENT_SESSION_ID:[A-Za-z0-9_-]{43}=}", http.MethodDelete, withAPI(licDeleteLicenseSession(c)))
+	licensingHandler(api, "/license-sessions/{CLIENT_SESSION_ID:[A-Za-z0-9_-]{43}=}/releases", http.MethodPost, withAPI(licGetReleaseManifest(c)))
+	licensingHandler(api, "/license-sessions/{CLIENT_SESSION_ID:[A-Za-z0-9_-]{43}=}/credits", http.MethodPost, withAPI(licConsumeCredits(c)))
+	licensingHandler(api, "/license-sessions/downloads/{TOKEN:[A-Za-z0-9_.-]+}", http.MethodGet, licDownloadReleaseArtifact(c))
+	licensingHandler(api, "/notifications/unsubscribe", http.MethodGet, withAPI(licUnsubscribeConfirm(c)))
+	licensingHandler(api, "/notifications/unsubscribe", http.MethodPost, licUnsubscribe(c))
 
 	// Resource API
-	resourceHandler(api, "/license-issuers", http.MethodPost, withAPIAuthorized(createLicenseIssuer(c)))
+	resourceHandler(api, "/license-issuers", http.MethodPost, withAPIAuthorized(withIdempotency(c, createLicenseIssuer(c))))
 	resourceHandler(api, "/license-issuers", http.MethodGet, withAPIAuthorized(getAllLicenseIssuers(c)))
+	resourceHandler(api, "/license-issuers/trash", http.MethodGet, withAPIAuthorized(getLicenseIssuersTrash(c)))
 	resourceHandler(api, "/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}", http.MethodGet, withAPIAuthorized(getLicenseIssuer(c)))
 	resourceHandler(api, "/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}", http.MethodPatch, withAPIAuthorized(updateLicenseIssuer(c)))
 	resourceHandler(api, "/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}", http.MethodDelete, withAPIAuthorized(deleteLicenseIssuer(c)))
+	resourceHandler(api, "/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}/restore", http.MethodPost, withAPIAuthorized(restoreLicenseIssuer(c)))
 
 	apili := api.PathPrefix("/license-issuers/{LICENSE_ISSUER_ID:[0-9]+}").Subrouter()
-	resourceHandler(apili, "/licenses", http.MethodPost, withAPIAuthorized(createLicense(c)))
+	resourceHandler(apili, "/licenses", http.MethodPost, withAPIAuthorized(withIdempotency(c, createLicense(c))))
 	resourceHandler(apili, "/licenses", http.MethodGet, withAPIAuthorized(getAllLicenses(c)))
+	resourceHandler(apili, "/licenses/bulk", http.MethodPost, withAPIAuthorized(withIdempotency(c, createLicenses(c))))
+	resourceHandler(apili, "/licenses/import", http.MethodPost, withAPIAuthorized(withIdempotency(c, importLicenses(c))))
+	resourceHandler(apili, "/licenses/export", http.MethodGet, withAPIAuthorized(exportLicenses(c)))
+	resourceHandler(apili, "/licenses/lookup", http.MethodGet, withAPIAuthorized(lookupLicenses(c)))
+	resourceHandler(apili, "/licenses/trash", http.MethodGet, withAPIAuthorized(getLicensesTrash(c)))
 	resourceHandler(apili, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}", http.MethodGet, withAPIAuthorized(getLicense(c)))
 	resourceHandler(apili, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}", http.MethodPatch, withAPIAuthorized(updateLicense(c)))
 	resourceHandler(apili, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}", http.MethodDelete, withAPIAuthorized(deleteLicense(c)))
+	resourceHandler(apili, "/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}/restore", http.MethodPost, withAPIAuthorized(restoreLicense(c)))
 
-	resourceHandler(apili, "/products", http.MethodPost, withAPIAuthorized(createProduct(c)))
+	resourceHandler(apili, "/products", http.MethodPost, withAPIAuthorized(withIdempotency(c, createProduct(c))))
 	resourceHandler(apili, "/products", http.MethodGet, withAPIAuthorized(getAllProducts(c)))
+	resourceHandler(apili, "/products/trash", http.MethodGet, withAPIAuthorized(getProductsTrash(c)))
 	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}", http.MethodGet, withAPIAuthorized(getProduct(c)))
 	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}", http.MethodPatch, withAPIAuthorized(updateProduct(c)))
 	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}", http.MethodDelete, withAPIAuthorized(deleteProduct(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/restore", http.MethodPost, withAPIAuthorized(restoreProduct(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/nonconforming-licenses", http.MethodGet, withAPIAuthorized(getNonconformingLicenses(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/editions", http.MethodPost, withAPIAuthorized(createProductEdition(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/editions", http.MethodGet, withAPIAuthorized(getAllProductEditions(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/editions/{PRODUCT_EDITION_ID:[0-9]+}", http.MethodGet, withAPIAuthorized(getProductEdition(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/editions/{PRODUCT_EDITION_ID:[0-9]+}", http.MethodPatch, withAPIAuthorized(updateProductEdition(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/editions/{PRODUCT_EDITION_ID:[0-9]+}", http.MethodDelete, withAPIAuthorized(deleteProductEdition(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/releases", http.MethodPost, withAPIAuthorized(createProductRelease(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/releases", http.MethodGet, withAPIAuthorized(getAllProductReleases(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/releases/{PRODUCT_RELEASE_ID:[0-9]+}", http.MethodGet, withAPIAuthorized(getProductRelease(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/releases/{PRODUCT_RELEASE_ID:[0-9]+}", http.MethodPatch, withAPIAuthorized(updateProductRelease(c)))
+	resourceHandler(apili, "/products/{PRODUCT_ID:[0-9]+}/releases/{PRODUCT_RELEASE_ID:[0-9]+}", http.MethodDelete, withAPIAuthorized(deleteProductRelease(c)))
+
+	resourceHandler(apili, "/customers", http.MethodPost, withAPIAuthorized(createCustomer(c)))
+	resourceHandler(apili, "/customers", http.MethodGet, withAPIAuthorized(getAllCustomers(c)))
+	resourceHandler(apili, "/customers/{CUSTOMER_ID:[0-9]+}", http.MethodGet, withAPIAuthorized(getCustomer(c)))
+	resourceHandler(apili, "/customers/{CUSTOMER_ID:[0-9]+}", http.MethodPatch, withAPIAuthorized(updateCustomer(c)))
+	resourceHandler(apili, "/customers/{CUSTOMER_ID:[0-9]+}", http.MethodDelete, withAPIAuthorized(deleteCustomer(c)))
+	resourceHandler(apili, "/customers/{CUSTOMER_ID:[0-9]+}/licenses", http.MethodGet, withAPIAuthorized(getCustomerLicenses(c)))
+
+	resourceHandler(apili, "/client-certs", http.MethodPost, withAPIAuthorized(createClientCert(c)))
+	resourceHandler(apili, "/client-certs", http.MethodGet, withAPIAuthorized(getAllClientCerts(c)))
+	resourceHandler(apili, "/client-certs/{CLIENT_CERT_ID:[0-9]+}", http.MethodGet, withAPIAuthorized(getClientCert(c)))
+	resourceHandler(apili, "/client-certs/{CLIENT_CERT_ID:[0-9]+}", http.MethodDelete, withAPIAuthorized(deleteClientCert(c)))
+
+	resourceHandler(apili, "/license-templates", http.MethodPost, withAPIAuthorized(createLicenseTemplate(c)))
+	resourceHandler(apili, "/license-templates", http.MethodGet, withAPIAuthorized(getAllLicenseTemplates(c)))
+	resourceHandler(apili, "/license-templates/{LICENSE_TEMPLATE_ID:[0-9]+}", http.MethodGet, withAPIAuthorized(getLicenseTemplate(c)))
+	resourceHandler(apili, "/license-templates/{LICENSE_TEMPLATE_ID:[0-9]+}", http.MethodPatch, withAPIAuthorized(updateLicenseTemplate(c)))
+	resourceHandler(apili, "/license-templates/{LICENSE_TEMPLATE_ID:[0-9]+}", http.MethodDelete, withAPIAuthorized(deleteLicenseTemplate(c)))
+
+	resourceHandler(apili, "/notifications", http.MethodGet, withAPIAuthorized(getAllNotifications(c)))
+	resourceHandler(apili, "/notification-templates", http.MethodGet, withAPIAuthorized(getAllNotificationTemplates(c)))
+	resourceHandler(apili, "/notification-templates/{KIND:[a-z-]+}", http.MethodGet, withAPIAuthorized(getNotificationTemplate(c)))
+	resourceHandler(apili, "/notification-templates/{KIND:[a-z-]+}", http.MethodPut, withAPIAuthorized(setNotificationTemplate(c)))
+	resourceHandler(apili, "/notification-templates/{KIND:[a-z-]+}", http.MethodDelete, withAPIAuthorized(resetNotificationTemplate(c)))
+	resourceHandler(apili, "/notification-opt-outs", http.MethodGet, withAPIAuthorized(getAllNotificationOptOuts(c)))
+	resourceHandler(apili, "/notification-opt-outs", http.MethodPost, withAPIAuthorized(createNotificationOptOut(c)))
+	resourceHandler(apili, "/notification-opt-outs/{EMAIL}", http.MethodDelete, withAPIAuthorized(deleteNotificationOptOut(c)))
 
 	apilil := apili.PathPrefix("/licenses/{LICENSE_ID:[A-Za-z0-9_-]{43}=}").Subrouter()
+	resourceHandler(apilil, "/addons", http.MethodGet, withAPIAuthorized(getLicenseAddons(c)))
+	resourceHandler(apilil, "/deliver", http.MethodPost, withAPIAuthorized(deliverLicense(c)))
+	resourceHandler(apilil, "/usage", http.MethodGet, withAPIAuthorized(getLicenseUsage(c)))
+	resourceHandler(apilil, "/credits", http.MethodGet, withAPIAuthorized(getLicenseCredits(c)))
+	resourceHandler(apilil, "/credits", http.MethodPost, withAPIAuthorized(addLicenseCredits(c)))
+	resourceHandler(apilil, "/credits/receipts", http.MethodGet, withAPIAuthorized(getCreditReceipts(c)))
 	resourceHandler(apilil, "/sessions", http.MethodGet, withAPIAuthorized(getAllLicenseSessions(c)))
 	resourceHandler(apilil, "/sessions/{CLIENT_SESSION_ID:[A-Za-z0-9_-]{43}=}", http.MethodGet, withAPIAuthorized(getLicenseSession(c)))
 	resourceHandler(apilil, "/sessions/{CLIENT_SESSION_ID:[A-Za-z0-9_-]{43}=}", http.MethodDelete, withAPIAuthorized(deleteLicenseSession(c)))
@@ -77,11 +178,11 @@ func NewRouter(c *core.Core, resourceApiCors, licensingCors bool, allowedOrigins
 	resourceHandler(api, "/change-password/{LICENSE_ISSUER_ID:[0-9]+}", http.MethodPatch, withAPIAuthorized(updatePassword(c)))
 
 	// Single page app
-	if c.UseGUI() {
+	if conf.ResourceAPI && c.UseGUI() {
 		r.PathPrefix("/").Handler(spaHandler(publicDir, "public"))
 	}
 
-	return r
+	return accessLogMiddleware(r)
 }
 
 func pathVarKey(str string) ([]byte, error) {
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/discard"
+	"github.com/sewiti/licensing-system/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveStatus(h http.Handler, method, path string) int {
+	r := httptest.NewRequest(method, path, strings.NewReader("not json"))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func discardLog(t *testing.T) {
+	prev := log.Log
+	log.Log = &log.Logger{Handler: discard.New(), Level: log.InfoLevel}
+	t.Cleanup(func() { log.Log = prev })
+}
+
+const testLicenseID = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+var (
+	resourceRoutes = []struct{ method, path string }{
+		{http.MethodPost, "/api/login"},
+		{http.MethodGet, "/api/license-issuers"},
+		{http.MethodPost, "/api/license-issuers"},
+		{http.MethodGet, "/api/license-issuers/1/licenses"},
+		{http.MethodGet, "/api/license-issuers/1/licenses/" + testLicenseID},
+		{http.MethodGet, "/api/license-issuers/1/products"},
+		{http.MethodGet, "/"},
+		{http.MethodGet, "/index.html"},
+		{http.MethodGet, "/healthz"},
+		{http.MethodGet, "/readyz"},
+		{http.MethodGet, "/version"},
+		{http.MethodGet, "/metrics"},
+	}
+	licensingRoutes = []struct{ method, path string }{
+		{http.MethodPost, "/api/license-sessions"},
+		{http.MethodPost, "/api/license-sessions/machines"},
+		{http.MethodPost, "/api/license-sessions/deactivate"},
+		{http.MethodPatch, "/api/license-sessions/" + testLicenseID},
+		{http.MethodDelete, "/api/license-sessions/" + testLicenseID},
+		{http.MethodPost, "/api/license-sessions/" + testLicenseID + "/releases"},
+		{http.MethodPost, "/api/license-sessions/" + testLicenseID + "/credits"},
+		{http.MethodGet, "/api/license-sessions/downloads/token"},
+		{http.MethodGet, "/api/notifications/unsubscribe"},
+		{http.MethodPost, "/api/notifications/unsubscribe"},
+	}
+)
+
+func TestNewRouter_licensingAPI(t *testing.T) {
+	discardLog(t)
+	h := NewRouter(&core.Core{}, RouterConf{LicensingAPI: true})
+
+	for _, rt := range resourceRoutes {
+		assert.Equal(t, http.StatusNotFound, serveStatus(h, rt.method, rt.path), rt.method+" "+rt.path)
+	}
+	// Request body is invalid, so that core isn't reached.
+	assert.Equal(t, http.StatusBadRequest, serveStatus(h, http.MethodPost, "/api/license-sessions"))
+}
+
+func TestNewRouter_resourceAPI(t *testing.T) {
+	discardLog(t)
+	h := NewRouter(&core.Core{}, RouterConf{ResourceAPI: true})
+
+	for _, rt := range licensingRoutes {
+		assert.Equal(t, http.StatusNotFound, serveStatus(h, rt.method, rt.path), rt.method+" "+rt.path)
+	}
+	assert.Equal(t, http.StatusUnauthorized, serveStatus(h, http.MethodGet, "/api/license-issuers"))
+	assert.Equal(t, http.StatusOK, serveStatus(h, http.MethodGet, "/healthz"))
+}
+
+func TestNewRouterMonitoring(t *testing.T) {
+	discardLog(t)
+	h := NewRouterMonitoring(&core.Core{}, RouterConf{LicensingAPI: true, ResourceAPI: true})
+
+	for _, rt := range licensingRoutes {
+		assert.Equal(t, http.StatusNotFound, serveStatus(h, rt.method, rt.path), rt.method+" "+rt.path)
+	}
+	for _, rt := range resourceRoutes[:6] {
+		assert.Equal(t, http.StatusNotFound, serveStatus(h, rt.method, rt.path), rt.method+" "+rt.path)
+	}
+	assert.Equal(t, http.StatusOK, serveStatus(h, http.MethodGet, "/healthz"))
+}
@@ -29,6 +29,10 @@ type Client struct {
 
 	mx      sync.RWMutex
 	session *session
+
+	usageMx     sync.Mutex
+	usage       map[string]int64 // Buffered usage, reported on refresh.
+	usageReport *usageReport     // Unacknowledged report, resent as is.
 }
 
 var ErrNotConnected = errors.New("license: client: session not established")
@@ -41,6 +45,14 @@ func NewClient(url string, serverID, machineID, licenseKey []byte) (*Client, err
 	serverIDCopy := make([]byte, 32)
 	copy(serverIDCopy, serverID)
 
+	if len(licenseKey) != 32 {
+		// Accept formatted or encoded key as well.
+		key, err := ParseKey(string(licenseKey))
+		if err != nil {
+			return nil, fmt.Errorf("license: client: parsing license key: %w", err)
+		}
+		licenseKey = key
+	}
 	if len(licenseKey) != 32 {
 		return nil, errors.New("license: client: license key must be of length 32")
 	}
@@ -95,12 +107,21 @@ func (c *Client) newSession(ctx context.Context) (*session, error) {
 		clientKey: clientKey,
 		url:       c.url,
 
-		name: data.Name,
-		data: data.Data,
+		name:     data.Name,
+		data:     data.Data,
+		features: data.Features,
 
 		productID:   data.ProductID,
 		productName: data.ProductName,
 		productData: data.ProductData,
+
+		editionID:   data.EditionID,
+		editionName: data.EditionName,
+		editionData: data.EditionData,
+		channels:    data.Channels,
+
+		addons:        data.Addons,
+		quotaExceeded: data.QuotaExceeded,
 	}
 	s.updateTimes(time.Now(), data.Timestamp, data.RefreshAfter, data.ExpireAfter)
 	return s, nil
@@ -171,8 +192,10 @@ func (c *Client) Run(ctx context.Context, maxRefresh time.Duration, cb SessionCa
 			expireT.Stop()
 
 			c.mx.Lock()
-			err := c.session.refresh(ctx, cryptorand.Reader)
+			report := c.takeUsage()
+			err := c.session.refresh(ctx, report, cryptorand.Reader)
 			if err == nil {
+				c.ackUsage(report)
 				c.state = StateValid
 				c.mx.Unlock()
 				cb.call("license session refreshed successfully", nil)
@@ -182,14 +205,16 @@ func (c *Client) Run(ctx context.Context, maxRefresh time.Duration, cb SessionCa
 
 			if !errors.Is(err, errTemporary) {
 				// Error
-				_ = c.session.close(ctx, cryptorand.Reader)
+				if c.session.close(ctx, report, cryptorand.Reader) == nil {
+					c.ackUsage(report)
+				}
 				c.session = nil
 				c.state = StateClosed
 				c.mx.Unlock()
 				cb.call("refreshing license session", err)
 				return
 			}
-			// Temporary error - schedule a retry
+			// Temporary error - schedule a retry, report is resent
 			c.session.refreshAfter = time.Now().Add(retryDelay)
 			// No changes to license session state
 			if retryDelay > maxRefresh {
@@ -207,7 +232,10 @@ func (c *Client) Run(ctx context.Context, maxRefresh time.Duration, cb SessionCa
 			refreshT.Stop()
 
 			c.mx.Lock()
-			_ = c.session.close(ctx, cryptorand.Reader)
+			report := c.takeUsage()
+			if c.session.close(ctx, report, cryptorand.Reader) == nil {
+				c.ackUsage(report)
+			}
 			c.state = StateExpired
 			c.mx.Unlock()
 			cb.call("license session has expired", nil)
@@ -220,7 +248,11 @@ func (c *Client) Run(ctx context.Context, maxRefresh time.Duration, cb SessionCa
 			c.mx.Lock()
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
-			err := c.session.close(ctx, cryptorand.Reader)
+			report := c.takeUsage()
+			err := c.session.close(ctx, report, cryptorand.Reader)
+			if err == nil {
+				c.ackUsage(report)
+			}
 			c.session = nil // Get rid of session
 			c.state = StateClosed
 			c.mx.Unlock()
@@ -270,6 +302,33 @@ func (c *Client) UnmarshalData(v interface{}) error {
 	return json.Unmarshal(c.session.data, v)
 }
 
+// Features returns features enabled by the license.
+func (c *Client) Features() ([]string, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return nil, ErrNotConnected
+	}
+	features := make([]string, len(c.session.features))
+	copy(features, c.session.features)
+	return features, nil
+}
+
+// HasFeature reports whether feature is enabled by the license.
+func (c *Client) HasFeature(feature string) (bool, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return false, ErrNotConnected
+	}
+	for _, f := range c.session.features {
+		if f == feature {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (c *Client) ProductID() (id int, exists bool, err error) {
 	c.mx.RLock()
 	defer c.mx.RUnlock()
@@ -312,6 +371,88 @@ func (c *Client) UnmarshalProductData(v interface{}) error {
 	return json.Unmarshal(c.session.productData, v)
 }
 
+// EditionID returns ID of the product edition license is assigned to.
+func (c *Client) EditionID() (id int, exists bool, err error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return 0, false, ErrNotConnected
+	}
+	if c.session.editionID == nil {
+		return 0, false, nil
+	}
+	return *c.session.editionID, true, nil
+}
+
+func (c *Client) EditionName() (string, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return "", ErrNotConnected
+	}
+	return c.session.editionName, nil
+}
+
+func (c *Client) EditionData() ([]byte, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return nil, ErrNotConnected
+	}
+	data := make([]byte, len(c.session.editionData))
+	copy(data, c.session.editionData)
+	return data, nil
+}
+
+// Channels returns release channels the license is entitled to.
+func (c *Client) Channels() ([]string, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return nil, ErrNotConnected
+	}
+	channels := make([]string, len(c.session.channels))
+	copy(channels, c.session.channels)
+	return channels, nil
+}
+
+// HasChannel reports whether license is entitled to the release channel.
+func (c *Client) HasChannel(channel string) (bool, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return false, ErrNotConnected
+	}
+	for _, ch := range c.session.channels {
+		if ch == channel {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Addon is an active add-on license attached to the license.
+type Addon struct {
+	Name       string     `json:"name,omitempty"`
+	Data       []byte     `json:"data,omitempty"`
+	Features   []string   `json:"features,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+	ProductID  *int       `json:"productID,omitempty"`
+}
+
+// Addons returns active add-ons of the license. Their features are included
+// in Features as well.
+func (c *Client) Addons() ([]Addon, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return nil, ErrNotConnected
+	}
+	addons := make([]Addon, len(c.session.addons))
+	copy(addons, c.session.addons)
+	return addons, nil
+}
+
 type SessionCallback func(msg string, err error)
 
 func (cb SessionCallback) call(msg string, err error) {
@@ -0,0 +1,103 @@
+package license
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cryptorand "crypto/rand"
+
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+var ErrInsufficientCredits = errors.New("license: insufficient credits")
+
+// Receipt records consumption of license's credits. It is signed by the
+// licensing server.
+type Receipt struct {
+	ID              []byte    `json:"id"`
+	LicenseID       []byte    `json:"licenseID"`
+	ClientSessionID []byte    `json:"clientSessionID"`
+	Feature         string    `json:"feature"`
+	Amount          int64     `json:"amount"`
+	Balance         int64     `json:"balance"` // Remaining balance.
+	Created         time.Time `json:"created"`
+}
+
+// VerifyReceipt verifies receipt signature against server ID and parses it.
+func VerifyReceipt(serverID, receipt, sig []byte) (*Receipt, error) {
+	if !util.Verify(serverID, receipt, sig) {
+		return nil, ErrInvalidSignature
+	}
+	var r Receipt
+	err := json.Unmarshal(receipt, &r)
+	if err != nil {
+		return nil, fmt.Errorf("license: receipt: %w", err)
+	}
+	return &r, nil
+}
+
+// Consume atomically consumes n of license's credits of the feature. Returns
+// raw receipt and its signature as well, see VerifyReceipt.
+//
+// Temporary failures are retried a few times. Credits are consumed at most
+// once, even if a response is lost.
+//
+// Returns ErrInsufficientCredits if balance is insufficient. Session must be
+// established.
+func (c *Client) Consume(ctx context.Context, feature string, n int64) (r *Receipt, receipt, sig []byte, err error) {
+	const (
+		attempts = 3
+		retryIn  = time.Second
+	)
+	if n < 1 {
+		return nil, nil, nil, errors.New("license: consume: amount must be positive")
+	}
+	id := make([]byte, 16)
+	_, err = io.ReadFull(cryptorand.Reader, id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("license: consume: %w", err)
+	}
+
+	var data *consumeCreditsResData
+	retryDelay := retryIn
+	for i := 0; ; i++ {
+		c.mx.RLock()
+		if c.session == nil {
+			c.mx.RUnlock()
+			return nil, nil, nil, ErrNotConnected
+		}
+		// Same receipt ID on retries, so that credits aren't consumed twice.
+		data, err = c.session.sendConsume(ctx, id, feature, n, cryptorand.Reader)
+		c.mx.RUnlock()
+		if err == nil {
+			break
+		}
+		if errors.Is(err, ErrInsufficientCredits) {
+			return nil, nil, nil, err
+		}
+		if !errors.Is(err, errTemporary) || i+1 >= attempts {
+			return nil, nil, nil, fmt.Errorf("license: consume: %w", err)
+		}
+		select {
+		case <-time.After(retryDelay):
+			retryDelay *= 2
+		case <-ctx.Done():
+			return nil, nil, nil, fmt.Errorf("license: consume: %w", ctx.Err())
+		}
+	}
+
+	r, err = VerifyReceipt(c.serverID, data.Receipt, data.Signature)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !bytes.Equal(r.ID, id) || !bytes.Equal(r.LicenseID, c.licenseID) ||
+		r.Feature != feature || r.Amount != n {
+		return nil, nil, nil, errors.New("license: consume: receipt mismatch")
+	}
+	return r, data.Receipt, data.Signature, nil
+}
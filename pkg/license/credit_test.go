@@ -0,0 +1,29 @@
+package license
+
+import (
+	"testing"
+
+	cryptorand "crypto/rand"
+
+	"github.com/sewiti/licensing-system/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyReceipt(t *testing.T) {
+	serverID, serverKey, err := util.GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+	receipt := []byte(`{"id":"AQEBAQEBAQEBAQEBAQEBAQ==","feature":"export","amount":3,"balance":7,"created":"2022-01-01T00:00:00Z"}`)
+	sig, err := util.Sign(cryptorand.Reader, serverKey, receipt)
+	require.NoError(t, err)
+
+	r, err := VerifyReceipt(serverID, receipt, sig)
+	require.NoError(t, err)
+	assert.Equal(t, "export", r.Feature)
+	assert.Equal(t, int64(3), r.Amount)
+	assert.Equal(t, int64(7), r.Balance)
+
+	tampered := []byte(`{"id":"AQEBAQEBAQEBAQEBAQEBAQ==","feature":"export","amount":3,"balance":70,"created":"2022-01-01T00:00:00Z"}`)
+	_, err = VerifyReceipt(serverID, tampered, sig)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
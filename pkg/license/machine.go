@@ -0,0 +1,59 @@
+package license
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cryptorand "crypto/rand"
+)
+
+// Machine is a machine with active sessions of the license.
+type Machine struct {
+	MachineID  []byte    `json:"machineID"`
+	Identifier string    `json:"identifier"`
+	AppVersion string    `json:"appVersion"`
+	Sessions   int       `json:"sessions"`
+	LastSeen   time.Time `json:"lastSeen"`
+}
+
+// Machines lists license's machines along with its transfer limits.
+type Machines struct {
+	Machines     []Machine
+	MaxSessions  int
+	Transfers    int        // Number of transfers made.
+	MaxTransfers int        // Zero means transfers are disabled, negative - unlimited.
+	NextTransfer *time.Time // Earliest time of the next transfer, nil means now.
+}
+
+// ListMachines lists machines with active sessions of the license. Session
+// doesn't have to be established.
+func (c *Client) ListMachines(ctx context.Context) (*Machines, error) {
+	data, err := c.sendGetMachines(ctx, cryptorand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("license: list-machines: %w", err)
+	}
+	return &Machines{
+		Machines:     data.Machines,
+		MaxSessions:  data.MaxSessions,
+		Transfers:    data.Transfers,
+		MaxTransfers: data.MaxTransfers,
+		NextTransfer: data.NextTransfer,
+	}, nil
+}
+
+// Deactivate closes all license sessions of the machine, so that license
+// can be used on another one. Nil machine ID deactivates this machine.
+//
+// Deactivation counts as a license transfer and is subject to transfer
+// limits configured by the license issuer.
+func (c *Client) Deactivate(ctx context.Context, machineID []byte) error {
+	if machineID == nil {
+		machineID = c.machineID
+	}
+	err := c.sendDeactivate(ctx, machineID, cryptorand.Reader)
+	if err != nil {
+		return fmt.Errorf("license: deactivate: %w", err)
+	}
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"os"
+	"strings"
+
+	"github.com/sewiti/licensing-system/pkg/util"
 )
 
 func ReadID(path string) ([]byte, error) {
@@ -20,15 +23,23 @@ func ReadID(path string) ([]byte, error) {
 	return id, err
 }
 
+// ReadKey reads license key file. Key can be either base64 encoded or in
+// the formatted form, e.g., "XXXXX-XXXXX-...".
 func ReadKey(path string) ([]byte, error) {
 	bs, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	bs = bytes.TrimSpace(bs)
-	bs = bytes.TrimSuffix(bs, []byte{'='})
+	return ParseKey(string(bs))
+}
 
-	key := make([]byte, base64.RawStdEncoding.DecodedLen(len(bs)))
-	_, err = base64.RawStdEncoding.Decode(key, bs)
-	return key, err
+// ParseKey parses license key, either base64 encoded or in the formatted
+// form, e.g., "XXXXX-XXXXX-...".
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsRune(s, '-') || len(s) == util.FormattedKeyLen {
+		return util.ParseFormattedKey(s)
+	}
+	s = strings.TrimSuffix(s, "=")
+	return base64.RawStdEncoding.DecodeString(s)
 }
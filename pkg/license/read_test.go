@@ -46,6 +46,16 @@ func TestReadKey(t *testing.T) {
 			},
 			assertion: assert.NoError,
 		},
+		{
+			path: "testdata/license_formatted.key",
+			want: []byte{
+				0xe7, 0xd4, 0xb5, 0x7c, 0x7b, 0x6e, 0xc0, 0xa9,
+				0x5c, 0x88, 0xbb, 0x60, 0x41, 0x5b, 0xf8, 0x96,
+				0xe6, 0x7e, 0x88, 0x0f, 0xc9, 0x56, 0x94, 0x82,
+				0x71, 0x10, 0xeb, 0xd9, 0xdc, 0x03, 0x34, 0x31,
+			},
+			assertion: assert.NoError,
+		},
 		{
 			path: "testdata/license_padded.key",
 			want: []byte{
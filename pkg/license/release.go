@@ -0,0 +1,119 @@
+package license
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	cryptorand "crypto/rand"
+
+	"github.com/sewiti/licensing-system/pkg/util"
+)
+
+var ErrInvalidSignature = errors.New("license: invalid signature")
+
+// Manifest lists releases the license is entitled to. It is signed by the
+// licensing server.
+type Manifest struct {
+	LicenseID  []byte    `json:"licenseID"`
+	ProductID  int       `json:"productID"`
+	EditionID  *int      `json:"editionID,omitempty"`
+	AppVersion string    `json:"appVersion"`
+	Issued     time.Time `json:"issued"`
+	Expire     time.Time `json:"expire"` // Download tokens expire.
+	Releases   []Release `json:"releases"`
+}
+
+type Release struct {
+	Version   string     `json:"version"`
+	Channel   string     `json:"channel"`
+	Notes     string     `json:"notes,omitempty"`
+	Released  time.Time  `json:"released"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+type Artifact struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 []byte `json:"sha256"`
+	Token  string `json:"token"` // Download token.
+}
+
+// VerifyManifest verifies manifest signature against server ID and parses
+// it. Useful for verifying manifests stored or passed along, e.g., to an
+// updater.
+func VerifyManifest(serverID, manifest, sig []byte) (*Manifest, error) {
+	if !util.Verify(serverID, manifest, sig) {
+		return nil, ErrInvalidSignature
+	}
+	var m Manifest
+	err := json.Unmarshal(manifest, &m)
+	if err != nil {
+		return nil, fmt.Errorf("license: manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Releases requests manifest of releases the license is entitled to. Returns
+// raw manifest and its signature as well, see VerifyManifest.
+//
+// Session must be established.
+func (c *Client) Releases(ctx context.Context) (m *Manifest, manifest, sig []byte, err error) {
+	c.mx.RLock()
+	if c.session == nil {
+		c.mx.RUnlock()
+		return nil, nil, nil, ErrNotConnected
+	}
+	data, err := c.session.sendGetReleases(ctx, cryptorand.Reader)
+	c.mx.RUnlock()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("license: releases: %w", err)
+	}
+
+	m, err = VerifyManifest(c.serverID, data.Manifest, data.Signature)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !bytes.Equal(m.LicenseID, c.licenseID) {
+		return nil, nil, nil, errors.New("license: releases: manifest of another license")
+	}
+	return m, data.Manifest, data.Signature, nil
+}
+
+// Download downloads release artifact to w, verifying its size and hash.
+// On error, data written to w must be discarded.
+//
+// Download tokens expire, see Manifest.Expire.
+func (c *Client) Download(ctx context.Context, a Artifact, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/downloads/"+a.Token, nil)
+	if err != nil {
+		return fmt.Errorf("license: download: %w", err)
+	}
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("license: download: %w", err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("license: download: unexpected status: %s", r.Status)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), io.LimitReader(r.Body, a.Size+1))
+	if err != nil {
+		return fmt.Errorf("license: download: %w", err)
+	}
+	if n != a.Size {
+		return errors.New("license: download: size mismatch")
+	}
+	if !bytes.Equal(h.Sum(nil), a.SHA256) {
+		return errors.New("license: download: checksum mismatch")
+	}
+	return nil
+}
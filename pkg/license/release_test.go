@@ -0,0 +1,34 @@
+package license
+
+import (
+	"testing"
+
+	cryptorand "crypto/rand"
+
+	"github.com/sewiti/licensing-system/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyManifest(t *testing.T) {
+	serverID, serverKey, err := util.GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+	manifest := []byte(`{"productID":5,"appVersion":"1.0.0","releases":[{"version":"1.1.0","channel":"stable","artifacts":[]}]}`)
+	sig, err := util.Sign(cryptorand.Reader, serverKey, manifest)
+	require.NoError(t, err)
+
+	m, err := VerifyManifest(serverID, manifest, sig)
+	require.NoError(t, err)
+	assert.Equal(t, 5, m.ProductID)
+	require.Len(t, m.Releases, 1)
+	assert.Equal(t, "1.1.0", m.Releases[0].Version)
+
+	tampered := []byte(`{"productID":6,"appVersion":"1.0.0","releases":[{"version":"1.1.0","channel":"stable","artifacts":[]}]}`)
+	_, err = VerifyManifest(serverID, tampered, sig)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	otherID, _, err := util.GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+	_, err = VerifyManifest(otherID, manifest, sig)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -40,9 +40,17 @@ type createLicenseSessionResData struct {
 	ExpireAfter     time.Time `json:"expire"`
 	Name            string    `json:"name,omitempty"`
 	Data            []byte    `json:"data,omitempty"`
+	Features        []string  `json:"features,omitempty"`
 	ProductID       *int      `json:"productID,omitempty"`
 	ProductName     string    `json:"productName"`
 	ProductData     []byte    `json:"productData,omitempty"`
+	EditionID       *int      `json:"editionID,omitempty"`
+	EditionName     string    `json:"editionName,omitempty"`
+	EditionData     []byte    `json:"editionData,omitempty"`
+	Channels        []string  `json:"channels,omitempty"`
+
+	Addons        []Addon  `json:"addons,omitempty"`
+	QuotaExceeded []string `json:"quotaExceeded,omitempty"`
 }
 
 type updateLicenseSessionReq struct {
@@ -51,7 +59,9 @@ type updateLicenseSessionReq struct {
 }
 
 type updateLicenseSessionReqData struct {
-	Timestamp time.Time `json:"ts"`
+	Timestamp time.Time        `json:"ts"`
+	ReportID  []byte           `json:"reportID,omitempty"`
+	Usage     map[string]int64 `json:"usage,omitempty"`
 }
 
 type updateLicenseSessionRes struct {
@@ -65,9 +75,17 @@ type updateLicenseSessionResData struct {
 	ExpireAfter  time.Time `json:"expire"`
 	Name         string    `json:"name,omitempty"`
 	Data         []byte    `json:"data,omitempty"`
+	Features     []string  `json:"features,omitempty"`
 	ProductID    *int      `json:"productID,omitempty"`
 	ProductName  string    `json:"productName"`
 	ProductData  []byte    `json:"productData,omitempty"`
+	EditionID    *int      `json:"editionID,omitempty"`
+	EditionName  string    `json:"editionName,omitempty"`
+	EditionData  []byte    `json:"editionData,omitempty"`
+	Channels     []string  `json:"channels,omitempty"`
+
+	Addons        []Addon  `json:"addons,omitempty"`
+	QuotaExceeded []string `json:"quotaExceeded,omitempty"`
 }
 
 type deleteLicenseSessionReq struct {
@@ -76,7 +94,9 @@ type deleteLicenseSessionReq struct {
 }
 
 type deleteLicenseSessionReqData struct {
-	Timestamp time.Time `json:"ts"`
+	Timestamp time.Time        `json:"ts"`
+	ReportID  []byte           `json:"reportID,omitempty"`
+	Usage     map[string]int64 `json:"usage,omitempty"`
 }
 
 var errTemporary = errors.New("temporary")
@@ -124,6 +144,9 @@ func sendJsonRequest(ctx context.Context, method, url string, reqData, resData i
 	case http.StatusNotFound:
 		return fmt.Errorf("unexpected status: %s", r.Status)
 
+	case http.StatusPaymentRequired:
+		return ErrInsufficientCredits
+
 	case http.StatusInternalServerError:
 		return fmt.Errorf("%w: unexpected status: %s", errTemporary, r.Status)
 
@@ -175,10 +198,14 @@ func (c *Client) sendCreateSession(ctx context.Context, clientID, clientKey []by
 	return &resData, nil
 }
 
-func (s *session) sendRefresh(ctx context.Context, rand io.Reader) (*updateLicenseSessionResData, error) {
+func (s *session) sendRefresh(ctx context.Context, report *usageReport, rand io.Reader) (*updateLicenseSessionResData, error) {
 	reqData := updateLicenseSessionReqData{
 		Timestamp: time.Now(),
 	}
+	if report != nil {
+		reqData.ReportID = report.ID
+		reqData.Usage = report.Usage
+	}
 	nonce, err := util.GenerateNonce(rand)
 	if err != nil {
 		return nil, err
@@ -207,10 +234,14 @@ func (s *session) sendRefresh(ctx context.Context, rand io.Reader) (*updateLicen
 	return &resData, nil
 }
 
-func (s *session) sendClose(ctx context.Context, rand io.Reader) error {
+func (s *session) sendClose(ctx context.Context, report *usageReport, rand io.Reader) error {
 	reqData := deleteLicenseSessionReqData{
 		Timestamp: time.Now(),
 	}
+	if report != nil {
+		reqData.ReportID = report.ID
+		reqData.Usage = report.Usage
+	}
 	nonce, err := util.GenerateNonce(rand)
 	if err != nil {
 		return err
@@ -227,3 +258,202 @@ func (s *session) sendClose(ctx context.Context, rand io.Reader) error {
 	url := fmt.Sprintf("%s/%s", s.url, base64.URLEncoding.EncodeToString(s.clientID))
 	return sendJsonRequest(ctx, http.MethodDelete, url, req, nil)
 }
+
+type getLicenseMachinesReq struct {
+	LicenseID []byte `json:"lid"`
+	Data      []byte `json:"data"`
+	N         []byte `json:"n"`
+}
+
+type getLicenseMachinesReqData struct {
+	Timestamp time.Time `json:"ts"`
+}
+
+type getLicenseMachinesRes struct {
+	Data []byte `json:"data"`
+	N    []byte `json:"n"`
+}
+
+type getLicenseMachinesResData struct {
+	Timestamp    time.Time  `json:"ts"`
+	Machines     []Machine  `json:"machines"`
+	MaxSessions  int        `json:"maxSessions"`
+	Transfers    int        `json:"transfers"`
+	MaxTransfers int        `json:"maxTransfers"`
+	NextTransfer *time.Time `json:"nextTransfer,omitempty"`
+}
+
+type deactivateLicenseMachineReq struct {
+	LicenseID []byte `json:"lid"`
+	Data      []byte `json:"data"`
+	N         []byte `json:"n"`
+}
+
+type deactivateLicenseMachineReqData struct {
+	Timestamp time.Time `json:"ts"`
+	MachineID []byte    `json:"machineID"`
+}
+
+func (c *Client) sendGetMachines(ctx context.Context, rand io.Reader) (*getLicenseMachinesResData, error) {
+	reqData := getLicenseMachinesReqData{
+		Timestamp: time.Now(),
+	}
+	nonce, err := util.GenerateNonce(rand)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := util.SealJsonBox(reqData, nonce, c.serverID, c.licenseKey)
+	if err != nil {
+		return nil, err
+	}
+
+	req := getLicenseMachinesReq{
+		LicenseID: c.licenseID,
+		Data:      bs,
+		N:         nonce,
+	}
+	var res getLicenseMachinesRes
+	err = sendJsonRequest(ctx, http.MethodPost, c.url+"/machines", req, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var resData getLicenseMachinesResData
+	err = util.OpenJsonBox(&resData, res.Data, res.N, c.serverID, c.licenseKey)
+	if err != nil {
+		return nil, err
+	}
+	return &resData, nil
+}
+
+func (c *Client) sendDeactivate(ctx context.Context, machineID []byte, rand io.Reader) error {
+	reqData := deactivateLicenseMachineReqData{
+		Timestamp: time.Now(),
+		MachineID: machineID,
+	}
+	nonce, err := util.GenerateNonce(rand)
+	if err != nil {
+		return err
+	}
+	bs, err := util.SealJsonBox(reqData, nonce, c.serverID, c.licenseKey)
+	if err != nil {
+		return err
+	}
+
+	req := deactivateLicenseMachineReq{
+		LicenseID: c.licenseID,
+		Data:      bs,
+		N:         nonce,
+	}
+	return sendJsonRequest(ctx, http.MethodPost, c.url+"/deactivate", req, nil)
+}
+
+type getReleaseManifestReq struct {
+	Data []byte `json:"data"`
+	N    []byte `json:"n"`
+}
+
+type getReleaseManifestReqData struct {
+	Timestamp time.Time `json:"ts"`
+}
+
+type getReleaseManifestRes struct {
+	Data []byte `json:"data"`
+	N    []byte `json:"n"`
+}
+
+type getReleaseManifestResData struct {
+	Timestamp time.Time `json:"ts"`
+	Manifest  []byte    `json:"manifest"`
+	Signature []byte    `json:"sig"`
+}
+
+func (s *session) sendGetReleases(ctx context.Context, rand io.Reader) (*getReleaseManifestResData, error) {
+	reqData := getReleaseManifestReqData{
+		Timestamp: time.Now(),
+	}
+	nonce, err := util.GenerateNonce(rand)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := util.SealJsonBox(reqData, nonce, s.serverID, s.clientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	req := getReleaseManifestReq{
+		Data: bs,
+		N:    nonce,
+	}
+	var res getReleaseManifestRes
+	url := fmt.Sprintf("%s/%s/releases", s.url, base64.URLEncoding.EncodeToString(s.clientID))
+	err = sendJsonRequest(ctx, http.MethodPost, url, req, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var resData getReleaseManifestResData
+	err = util.OpenJsonBox(&resData, res.Data, res.N, s.serverID, s.clientKey)
+	if err != nil {
+		return nil, err
+	}
+	return &resData, nil
+}
+
+type consumeCreditsReq struct {
+	Data []byte `json:"data"`
+	N    []byte `json:"n"`
+}
+
+type consumeCreditsReqData struct {
+	Timestamp time.Time `json:"ts"`
+	ReceiptID []byte    `json:"id"`
+	Feature   string    `json:"feature"`
+	Amount    int64     `json:"amount"`
+}
+
+type consumeCreditsRes struct {
+	Data []byte `json:"data"`
+	N    []byte `json:"n"`
+}
+
+type consumeCreditsResData struct {
+	Timestamp time.Time `json:"ts"`
+	Receipt   []byte    `json:"receipt"`
+	Signature []byte    `json:"sig"`
+}
+
+func (s *session) sendConsume(ctx context.Context, receiptID []byte, feature string, amount int64, rand io.Reader) (*consumeCreditsResData, error) {
+	reqData := consumeCreditsReqData{
+		Timestamp: time.Now(),
+		ReceiptID: receiptID,
+		Feature:   feature,
+		Amount:    amount,
+	}
+	nonce, err := util.GenerateNonce(rand)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := util.SealJsonBox(reqData, nonce, s.serverID, s.clientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	req := consumeCreditsReq{
+		Data: bs,
+		N:    nonce,
+	}
+	var res consumeCreditsRes
+	url := fmt.Sprintf("%s/%s/credits", s.url, base64.URLEncoding.EncodeToString(s.clientID))
+	err = sendJsonRequest(ctx, http.MethodPost, url, req, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var resData consumeCreditsResData
+	err = util.OpenJsonBox(&resData, res.Data, res.N, s.serverID, s.clientKey)
+	if err != nil {
+		return nil, err
+	}
+	return &resData, nil
+}
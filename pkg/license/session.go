@@ -16,12 +16,21 @@ type session struct {
 	refreshAfter time.Time
 	expireAfter  time.Time
 
-	name string
-	data []byte
+	name     string
+	data     []byte
+	features []string
 
 	productID   *int
 	productName string
 	productData []byte
+
+	editionID   *int
+	editionName string
+	editionData []byte
+	channels    []string
+
+	addons        []Addon
+	quotaExceeded []string
 }
 
 func (s *session) updateTimes(now, remote, refreshAfter, expireAfter time.Time) {
@@ -29,22 +38,31 @@ func (s *session) updateTimes(now, remote, refreshAfter, expireAfter time.Time)
 	s.expireAfter = now.Add(expireAfter.Sub(remote))
 }
 
-func (s *session) refresh(ctx context.Context, rand io.Reader) error {
-	data, err := s.sendRefresh(ctx, rand)
+// refresh refreshes session sending usage report, if any.
+func (s *session) refresh(ctx context.Context, report *usageReport, rand io.Reader) error {
+	data, err := s.sendRefresh(ctx, report, rand)
 	if err != nil {
 		return fmt.Errorf("license: session-refresh: %w", err)
 	}
 	s.updateTimes(time.Now(), data.Timestamp, data.RefreshAfter, data.ExpireAfter)
 	s.name = data.Name
 	s.data = data.Data
+	s.features = data.Features
 	s.productID = data.ProductID
 	s.productName = data.ProductName
 	s.productData = data.ProductData
+	s.editionID = data.EditionID
+	s.editionName = data.EditionName
+	s.editionData = data.EditionData
+	s.channels = data.Channels
+	s.addons = data.Addons
+	s.quotaExceeded = data.QuotaExceeded
 	return nil
 }
 
-func (s *session) close(ctx context.Context, rand io.Reader) error {
-	err := s.sendClose(ctx, rand)
+// close closes session sending usage report, if any.
+func (s *session) close(ctx context.Context, report *usageReport, rand io.Reader) error {
+	err := s.sendClose(ctx, report, rand)
 	if err != nil {
 		return fmt.Errorf("license: session-close: %w", err)
 	}
@@ -0,0 +1,100 @@
+package license
+
+import (
+	cryptorand "crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+var usageMetricRegexp = regexp.MustCompile(`^[a-z0-9_.:-]{1,64}$`)
+
+// ReportUsage buffers usage of the metric. Buffered usage is reported to the
+// server on the next session refresh or close. Failed report is resent as is,
+// usage buffered meanwhile is reported after it.
+func (c *Client) ReportUsage(metric string, amount int64) error {
+	if !usageMetricRegexp.MatchString(metric) {
+		return fmt.Errorf("license: usage: invalid metric: %q", metric)
+	}
+	if amount < 0 {
+		return errors.New("license: usage: amount must not be negative")
+	}
+	if amount == 0 {
+		return nil
+	}
+	c.usageMx.Lock()
+	defer c.usageMx.Unlock()
+	if c.usage == nil {
+		c.usage = make(map[string]int64)
+	}
+	c.usage[metric] += amount
+	return nil
+}
+
+// usageReport is usage reported to the server. Server counts report with the
+// same ID once, so that retried report isn't counted twice.
+type usageReport struct {
+	ID    []byte
+	Usage map[string]int64
+}
+
+// takeUsage returns usage report to send. Unacknowledged report is returned
+// as is, otherwise buffered usage is moved to a new report. Returns nil if
+// there is nothing to report.
+func (c *Client) takeUsage() *usageReport {
+	c.usageMx.Lock()
+	defer c.usageMx.Unlock()
+	if c.usageReport != nil {
+		return c.usageReport
+	}
+	if len(c.usage) == 0 {
+		return nil
+	}
+	id := make([]byte, 16)
+	_, err := io.ReadFull(cryptorand.Reader, id)
+	if err != nil {
+		return nil // Usage stays buffered.
+	}
+	c.usageReport = &usageReport{ID: id, Usage: c.usage}
+	c.usage = nil
+	return c.usageReport
+}
+
+// ackUsage discards report, which has been received by the server.
+func (c *Client) ackUsage(r *usageReport) {
+	c.usageMx.Lock()
+	defer c.usageMx.Unlock()
+	if r != nil && c.usageReport == r {
+		c.usageReport = nil
+	}
+}
+
+// ExceededQuotas returns usage metrics whose quota for the current period has
+// been exceeded, as of the last session refresh.
+func (c *Client) ExceededQuotas() ([]string, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return nil, ErrNotConnected
+	}
+	exceeded := make([]string, len(c.session.quotaExceeded))
+	copy(exceeded, c.session.quotaExceeded)
+	return exceeded, nil
+}
+
+// QuotaExceeded reports whether quota of the usage metric for the current
+// period has been exceeded, as of the last session refresh.
+func (c *Client) QuotaExceeded(metric string) (bool, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.session == nil {
+		return false, ErrNotConnected
+	}
+	for _, m := range c.session.quotaExceeded {
+		if m == metric {
+			return true, nil
+		}
+	}
+	return false, nil
+}
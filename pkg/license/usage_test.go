@@ -0,0 +1,35 @@
+package license
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientUsage(t *testing.T) {
+	c := &Client{}
+	assert.NoError(t, c.ReportUsage("exports", 3))
+	assert.NoError(t, c.ReportUsage("exports", 2))
+	assert.NoError(t, c.ReportUsage("api.calls", 0))
+	assert.Error(t, c.ReportUsage("Exports", 1))
+	assert.Error(t, c.ReportUsage("exports", -1))
+
+	report := c.takeUsage()
+	require.NotNil(t, report)
+	assert.Len(t, report.ID, 16)
+	assert.Equal(t, map[string]int64{"exports": 5}, report.Usage)
+
+	// Unacknowledged report is resent as is, usage buffered meanwhile waits.
+	assert.NoError(t, c.ReportUsage("exports", 1))
+	assert.Same(t, report, c.takeUsage())
+
+	c.ackUsage(report)
+	next := c.takeUsage()
+	require.NotNil(t, next)
+	assert.NotEqual(t, report.ID, next.ID)
+	assert.Equal(t, map[string]int64{"exports": 1}, next.Usage)
+
+	c.ackUsage(next)
+	assert.Nil(t, c.takeUsage())
+}
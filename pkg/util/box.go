@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 
+	"golang.org/x/crypto/curve25519"
 	naclbox "golang.org/x/crypto/nacl/box"
 )
 
@@ -47,6 +48,11 @@ func GenerateKey(rand io.Reader) (publicKey, privateKey []byte, err error) {
 	return pub[:], priv[:], nil
 }
 
+// PublicKey returns public key of the private key given.
+func PublicKey(privateKey []byte) ([]byte, error) {
+	return curve25519.X25519(privateKey, curve25519.Basepoint)
+}
+
 func Nonce(bs []byte) (*[24]byte, error) {
 	if len(bs) != 24 {
 		return nil, errors.New("invalid nonce length")
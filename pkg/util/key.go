@@ -0,0 +1,106 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"strings"
+)
+
+const (
+	keyLen         = 32
+	keyChecksumLen = 2
+	keyGroupLen    = 5
+)
+
+// crockford is Crockford's base32 encoding, which avoids characters easily
+// confused with one another.
+var crockford = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+// FormattedKeyLen is the length of formatted key without group separators.
+var FormattedKeyLen = crockford.EncodedLen(keyLen + keyChecksumLen)
+
+var ErrKeyChecksum = errors.New("key checksum mismatch")
+
+// FormatKey formats 32 byte key in a human-friendly form of Crockford's
+// base32 groups, e.g., "XXXXX-XXXXX-...". The last characters hold a
+// checksum, so typing errors can be detected.
+func FormatKey(key []byte) string {
+	if len(key) != keyLen {
+		return ""
+	}
+	sum := sha256.Sum256(key)
+	bs := append(append(make([]byte, 0, keyLen+keyChecksumLen), key...), sum[:keyChecksumLen]...)
+	enc := crockford.EncodeToString(bs)
+
+	var sb strings.Builder
+	for i := 0; i < len(enc); i += keyGroupLen {
+		if i > 0 {
+			sb.WriteByte('-')
+		}
+		end := i + keyGroupLen
+		if end > len(enc) {
+			end = len(enc)
+		}
+		sb.WriteString(enc[i:end])
+	}
+	return sb.String()
+}
+
+// ParseFormattedKey parses key formatted by FormatKey. Parsing is case
+// insensitive, separators and whitespace are ignored, letters I, L and O are
+// read as digits 1, 1 and 0.
+func ParseFormattedKey(s string) ([]byte, error) {
+	s = normalizeFormattedKey(s)
+	if len(s) != FormattedKeyLen {
+		return nil, errors.New("invalid formatted key length")
+	}
+	bs, err := crockford.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	key, checksum := bs[:keyLen], bs[keyLen:]
+	sum := sha256.Sum256(key)
+	for i, v := range checksum {
+		if sum[i] != v {
+			return nil, ErrKeyChecksum
+		}
+	}
+	return key, nil
+}
+
+// ParseFormattedKeyPrefix parses the beginning of formatted key. Returns
+// bytes of the key that are fully determined by the prefix.
+func ParseFormattedKeyPrefix(s string) ([]byte, error) {
+	s = normalizeFormattedKey(s)
+	if len(s) > FormattedKeyLen {
+		return nil, errors.New("invalid formatted key length")
+	}
+	n := len(s) * 5 / 8
+	if n > keyLen {
+		n = keyLen
+	}
+	padded := s + strings.Repeat("0", FormattedKeyLen-len(s))
+	bs, err := crockford.DecodeString(padded)
+	if err != nil {
+		return nil, err
+	}
+	return bs[:n], nil
+}
+
+func normalizeFormattedKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ', '\t', '\n', '\r':
+			return -1
+		case 'I', 'i', 'L', 'l':
+			return '1'
+		case 'O', 'o':
+			return '0'
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+	cryptorand "crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatKey(t *testing.T) {
+	_, key, err := GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+
+	formatted := FormatKey(key)
+	groups := strings.Split(formatted, "-")
+	assert.Len(t, groups, 11)
+	for _, g := range groups {
+		assert.Len(t, g, 5)
+	}
+
+	got, err := ParseFormattedKey(formatted)
+	assert.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	got, err = ParseFormattedKey(strings.ToLower(strings.ReplaceAll(formatted, "-", " ")))
+	assert.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	prefix, err := ParseFormattedKeyPrefix(formatted[:11])
+	assert.NoError(t, err)
+	assert.Equal(t, key[:6], prefix)
+}
+
+func TestParseFormattedKey(t *testing.T) {
+	key := mustParseBase64("KaxvhSWBy24qXM0NwPxfICuI8q4lidLsB+xnRZ/H9m8=")
+	formatted := FormatKey(key)
+
+	tests := []struct {
+		name    string
+		arg     string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "ok", arg: formatted, want: key},
+		{name: "confusable", arg: strings.NewReplacer("0", "O", "1", "l").Replace(formatted), want: key},
+		{name: "typo", arg: swapFirst(formatted), wantErr: true},
+		{name: "short", arg: formatted[:20], wantErr: true},
+		{name: "invalid char", arg: "U" + formatted[1:], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormattedKey(tt.arg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// swapFirst swaps the first two distinct characters of the key.
+func swapFirst(s string) string {
+	bs := []byte(s)
+	for i := 1; i < len(bs); i++ {
+		if bs[i] != bs[0] && bs[i] != '-' {
+			bs[0], bs[i] = bs[i], bs[0]
+			break
+		}
+	}
+	return string(bs)
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"io"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// SignatureSize is the size of signatures produced by Sign.
+const SignatureSize = ed25519.SignatureSize
+
+// Sign signs message with the curve25519 private key (as used by boxes),
+// so that the signature can be verified against its public key, e.g., server
+// ID.
+//
+// Signatures follow XEdDSA scheme: they are regular ed25519 signatures made
+// with the edwards equivalent of the key.
+func Sign(rand io.Reader, privateKey, message []byte) ([]byte, error) {
+	if len(privateKey) != 32 {
+		return nil, errors.New("invalid key length")
+	}
+	k, err := edwards25519.NewScalar().SetBytesWithClamping(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	pub := new(edwards25519.Point).ScalarBaseMult(k).Bytes()
+	if pub[31]&0x80 != 0 {
+		// Public key converted from montgomery form always has sign bit
+		// cleared, negate private key to match it.
+		k.Negate(k)
+		pub[31] &= 0x7f
+	}
+
+	z := make([]byte, 64)
+	_, err = io.ReadFull(rand, z)
+	if err != nil {
+		return nil, err
+	}
+	// r = hash1(k || M || Z)
+	hash := sha512.New()
+	hash.Write([]byte{
+		0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	})
+	hash.Write(k.Bytes())
+	hash.Write(message)
+	hash.Write(z)
+	r, err := edwards25519.NewScalar().SetUniformBytes(hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+
+	// h = hash(R || A || M)
+	hash.Reset()
+	hash.Write(R)
+	hash.Write(pub)
+	hash.Write(message)
+	h, err := edwards25519.NewScalar().SetUniformBytes(hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	s := edwards25519.NewScalar().MultiplyAdd(h, k, r)
+
+	sig := make([]byte, 0, SignatureSize)
+	sig = append(sig, R...)
+	return append(sig, s.Bytes()...), nil
+}
+
+// Verify reports whether sig is a valid signature of message made by the
+// owner of the curve25519 public key.
+func Verify(publicKey, message, sig []byte) bool {
+	if len(publicKey) != 32 || len(sig) != SignatureSize {
+		return false
+	}
+	pub, err := edwardsPublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, message, sig)
+}
+
+// edwardsPublicKey converts curve25519 public key to ed25519 public key with
+// sign bit cleared: y = (u - 1) / (u + 1).
+func edwardsPublicKey(publicKey []byte) (ed25519.PublicKey, error) {
+	u, err := new(field.Element).SetBytes(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	one := new(field.Element).One()
+	num := new(field.Element).Subtract(u, one)
+	den := new(field.Element).Add(u, one)
+	y := new(field.Element).Multiply(num, den.Invert(den))
+	return ed25519.PublicKey(y.Bytes()), nil
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	cryptorand "crypto/rand"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	msg := []byte("release manifest")
+	// Multiple keys to cover both signs of edwards public key.
+	for i := 0; i < 16; i++ {
+		pub, priv, err := GenerateKey(cryptorand.Reader)
+		require.NoError(t, err)
+		sig, err := Sign(cryptorand.Reader, priv, msg)
+		require.NoError(t, err)
+		require.Len(t, sig, SignatureSize)
+
+		assert.True(t, Verify(pub, msg, sig))
+		assert.False(t, Verify(pub, []byte("release manifesT"), sig))
+
+		otherPub, _, err := GenerateKey(cryptorand.Reader)
+		require.NoError(t, err)
+		assert.False(t, Verify(otherPub, msg, sig))
+
+		tampered := append([]byte{}, sig...)
+		tampered[40] ^= 0x01
+		assert.False(t, Verify(pub, msg, tampered))
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	pub, priv, err := GenerateKey(cryptorand.Reader)
+	require.NoError(t, err)
+	sig, err := Sign(cryptorand.Reader, priv, nil)
+	require.NoError(t, err)
+
+	assert.True(t, Verify(pub, nil, sig))
+	assert.False(t, Verify(pub[:31], nil, sig))
+	assert.False(t, Verify(pub, nil, sig[:63]))
+
+	_, err = Sign(cryptorand.Reader, priv[:31], nil)
+	assert.Error(t, err)
+}